@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+)
+
+// Whether a file should be streamed as Arrow IPC record batches while the
+// model runs rather than written when it ends.
+func isArrow(path string) bool {
+	return filepath.Ext(path) == ".arrows"
+}
+
+var tradeSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "period", Type: arrow.PrimitiveTypes.Int32},
+	{Name: "tick", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "thread", Type: arrow.PrimitiveTypes.Int32},
+	{Name: "buyer", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "seller", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "bid", Type: arrow.PrimitiveTypes.Int32},
+	{Name: "ask", Type: arrow.PrimitiveTypes.Int32},
+	{Name: "price", Type: arrow.PrimitiveTypes.Int32},
+	{Name: "market", Type: arrow.PrimitiveTypes.Int32},
+}, nil)
+
+var quoteSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "period", Type: arrow.PrimitiveTypes.Int32},
+	{Name: "tick", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "thread", Type: arrow.PrimitiveTypes.Int32},
+	{Name: "bid", Type: arrow.PrimitiveTypes.Int32},
+	{Name: "ask", Type: arrow.PrimitiveTypes.Int32},
+	{Name: "bid_depth", Type: arrow.PrimitiveTypes.Int32},
+	{Name: "ask_depth", Type: arrow.PrimitiveTypes.Int32},
+}, nil)
+
+// An Arrow IPC stream, written a record batch at a time.
+type arrowStream struct {
+	f   *os.File
+	w   *ipc.Writer
+	b   *array.RecordBuilder
+	err error
+}
+
+func createArrow(path string, schema *arrow.Schema) (*arrowStream, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &arrowStream{
+		f: f,
+		w: ipc.NewWriter(f, ipc.WithSchema(schema)),
+		b: array.NewRecordBuilder(memory.DefaultAllocator, schema),
+	}, nil
+}
+
+func (s *arrowStream) int32s(i int) *array.Int32Builder { return s.b.Field(i).(*array.Int32Builder) }
+func (s *arrowStream) int64s(i int) *array.Int64Builder { return s.b.Field(i).(*array.Int64Builder) }
+
+// Write the rows built so far as a record batch, unless there are none.
+func (s *arrowStream) flush() error {
+	rec := s.b.NewRecordBatch()
+	defer rec.Release()
+	if s.err == nil && rec.NumRows() > 0 {
+		s.err = s.w.Write(rec)
+	}
+	return s.err
+}
+
+func (s *arrowStream) close() error {
+	s.flush()
+	s.b.Release()
+	if err := s.w.Close(); s.err == nil {
+		s.err = err
+	}
+	if err := s.f.Close(); s.err == nil {
+		s.err = err
+	}
+	return s.err
+}
+
+// Stream the model's trades to an Arrow IPC file, a record batch per tick.
+// Whether a trade is extramarginal is known only once the run has ended, so
+// the stream leaves it out.
+func streamTrades(path string, m *zitraders.Model) (*arrowStream, error) {
+	s, err := createArrow(path, tradeSchema)
+	if err != nil {
+		return nil, err
+	}
+	m.RecordTrades = true
+	m.Observe(func(m *zitraders.Model, _ zitraders.Tick) bool {
+		for _, t := range m.TickTrades() {
+			s.int32s(0).Append(int32(t.Period))
+			s.int64s(1).Append(int64(t.Tick))
+			s.int32s(2).Append(int32(t.Thread))
+			s.int64s(3).Append(int64(t.Buyer))
+			s.int64s(4).Append(int64(t.Seller))
+			s.int32s(5).Append(int32(t.Bid))
+			s.int32s(6).Append(int32(t.Ask))
+			s.int32s(7).Append(int32(t.Price))
+			s.int32s(8).Append(int32(t.Market))
+		}
+		return s.flush() == nil
+	})
+	return s, nil
+}
+
+// Stream the model's samples of its order books to an Arrow IPC file, a
+// record batch per tick.
+func streamQuotes(path string, m *zitraders.Model) (*arrowStream, error) {
+	s, err := createArrow(path, quoteSchema)
+	if err != nil {
+		return nil, err
+	}
+	m.Observe(func(m *zitraders.Model, _ zitraders.Tick) bool {
+		for _, q := range m.TickQuotes() {
+			s.int32s(0).Append(int32(q.Period))
+			s.int64s(1).Append(int64(q.Tick))
+			s.int32s(2).Append(int32(q.Thread))
+			s.int32s(3).Append(int32(q.Bid))
+			s.int32s(4).Append(int32(q.Ask))
+			s.int32s(5).Append(int32(q.BidDepth))
+			s.int32s(6).Append(int32(q.AskDepth))
+		}
+		return s.flush() == nil
+	})
+	return s, nil
+}
+
+// Read a trade log from an Arrow IPC stream.
+func readTradesArrow(path string) ([]zitraders.Trade, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := ipc.NewReader(f, ipc.WithSchema(tradeSchema))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	defer r.Release()
+	var trades []zitraders.Trade
+	for r.Next() {
+		// The reader checked the stream's schema against tradeSchema.
+		rec := r.RecordBatch()
+		int32s := func(i int) *array.Int32 { return rec.Column(i).(*array.Int32) }
+		int64s := func(i int) *array.Int64 { return rec.Column(i).(*array.Int64) }
+		for k := 0; k < int(rec.NumRows()); k++ {
+			trades = append(trades, zitraders.Trade{
+				Period: int(int32s(0).Value(k)),
+				Tick:   int(int64s(1).Value(k)),
+				Thread: int(int32s(2).Value(k)),
+				Buyer:  int(int64s(3).Value(k)),
+				Seller: int(int64s(4).Value(k)),
+				Bid:    int(int32s(5).Value(k)),
+				Ask:    int(int32s(6).Value(k)),
+				Price:  int(int32s(7).Value(k)),
+				Market: int(int32s(8).Value(k)),
+			})
+		}
+	}
+	if err := r.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return trades, nil
+}
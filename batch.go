@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+)
+
+// Run independent replications of the model concurrently, -workers at a
+// time, and summarize them. Each replication's statistics are written to
+// -batch-out.
+func batch(ctx context.Context, opts options) {
+	workers := opts.Workers
+	if workers == 0 {
+		workers = runtime.NumCPU() / opts.NumThreads
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	slog.Info("batch started", "reps", opts.Reps, "workers", workers, "threads", opts.NumThreads)
+	var results []zitraders.Results
+	var err error
+	switch {
+	case opts.Precision > 0 && opts.Antithetic:
+		err = fmt.Errorf("-precision cannot be combined with -antithetic")
+	case opts.Antithetic:
+		results, err = zitraders.ReplicateAntithetic(ctx, opts.Config, opts.Reps, workers, track)
+	case opts.Precision > 0:
+		results, err = zitraders.ReplicateUntil(ctx, opts.Config, opts.precision(), workers, track)
+	default:
+		results, err = zitraders.ReplicateParallel(ctx, opts.Config, opts.Reps, workers, track)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if ctx.Err() != nil || len(results) < opts.Reps && opts.Precision == 0 {
+		slog.Warn("timed out; the last replications were cut short", "started", len(results), "reps", opts.Reps)
+	}
+
+	if opts.BatchOut != "" {
+		if err := writeBatch(opts.BatchOut, results); err != nil {
+			fatal(err)
+		}
+		if err := opts.stamp(opts.BatchOut, 0); err != nil {
+			fatal(err)
+		}
+	}
+	if opts.DB != "" {
+		recordReplications(opts, "batch", results)
+	}
+	summarize(opts, results)
+}
+
+// Write the statistics of each replication as a JSON array, if path ends in
+// .json, or as CSV with one row per replication.
+func writeBatch(path string, results []zitraders.Results) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if filepath.Ext(path) == ".json" {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+
+	cw := csv.NewWriter(f)
+	cw.Write([]string{"rep", "seed", "attempts", "stopped", "number_bought", "number_sold", "mean_price", "sd_price",
+		"median_price", "p5_price", "p95_price", "realized_surplus", "max_surplus", "efficiency", "equilibrium_price", "equilibrium_quantity", "alpha", "volatility",
+		"buyer_surplus", "seller_surplus", "unrealized_surplus", "extramarginal_loss"})
+	for i, r := range results {
+		cw.Write([]string{
+			strconv.Itoa(i + 1),
+			strconv.FormatInt(r.Seed, 10),
+			strconv.FormatInt(r.Attempts, 10),
+			r.Stopped,
+			strconv.Itoa(r.NumberBought),
+			strconv.Itoa(r.NumberSold),
+			formatFloat(r.MeanPrice),
+			formatFloat(r.SDPrice),
+			formatFloat(r.MedianPrice),
+			formatFloat(r.P5Price),
+			formatFloat(r.P95Price),
+			strconv.Itoa(r.RealizedSurplus),
+			strconv.Itoa(r.MaxSurplus),
+			formatFloat(r.Efficiency),
+			formatFloat(r.EquilibriumPrice),
+			strconv.Itoa(r.Equilibrium.Quantity),
+			formatFloat(r.Alpha),
+			formatFloat(r.Volatility),
+			strconv.Itoa(r.Welfare.BuyerSurplus),
+			strconv.Itoa(r.Welfare.SellerSurplus),
+			formatFloat(r.Welfare.Unrealized),
+			formatFloat(r.Welfare.Extramarginal),
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+)
+
+// Write checkpoints of a run to path every interval, whenever the process
+// receives one of checkpointSignals and, before stopping the run, on SIGTERM.
+// Checkpoints are taken at the end of a tick. The returned function stops
+// watching for signals.
+func watchCheckpoints(m *zitraders.Model, path string, every time.Duration) func() {
+	var requested, stop int32
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, append(checkpointSignals, syscall.SIGTERM)...)
+	go func() {
+		for s := range signals {
+			if s == syscall.SIGTERM {
+				atomic.StoreInt32(&stop, 1)
+			}
+			atomic.StoreInt32(&requested, 1)
+		}
+	}()
+
+	last := time.Now()
+	m.Observe(func(m *zitraders.Model, t zitraders.Tick) bool {
+		if atomic.SwapInt32(&requested, 0) == 1 || (every > 0 && time.Since(last) >= every) {
+			if err := writeCheckpoint(m, path); err != nil {
+				slog.Error("checkpoint failed", "path", path, "err", err)
+			} else {
+				slog.Info("checkpoint written", "path", path, "attempts", t.Attempts)
+			}
+			last = time.Now()
+		}
+		return atomic.LoadInt32(&stop) == 0
+	})
+	return func() {
+		signal.Stop(signals)
+		close(signals)
+	}
+}
+
+// Write a checkpoint next to path and then move it into place, so that an
+// interrupted write never replaces a good checkpoint.
+func writeCheckpoint(m *zitraders.Model, path string) error {
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if err := m.Checkpoint(f); err != nil {
+		f.Close()
+		return fmt.Errorf("checkpoint: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Resume a run from a checkpoint file.
+func resume(path string) (*zitraders.Model, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return zitraders.Resume(f)
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// Signals that ask for a checkpoint without stopping the run.
+var checkpointSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,7 @@
+package main
+
+import "os"
+
+// Windows has no user signals, so checkpoints are only taken periodically and
+// on termination.
+var checkpointSignals []os.Signal
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// A cluster runs replications on remote workers, each a `zi-traders grpc`
+// server, through their Replicate method.
+type cluster struct {
+	addrs []string
+	conns []*grpc.ClientConn
+	slots int // replications sent to each worker at once
+}
+
+// A replication to run on the cluster: replication rep of configuration cell.
+type job struct {
+	cell, rep int
+	config    zitraders.Config
+}
+
+// Connect to the workers at addrs. Connections are made lazily, so an
+// unreachable worker only shows when replications are sent to it.
+func dialCluster(addrs []string, slots int) (*cluster, error) {
+	if slots < 1 {
+		slots = 1
+	}
+	c := &cluster{addrs: addrs, slots: slots}
+	for _, addr := range addrs {
+		conn, err := grpc.NewClient(addr,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithDefaultCallOptions(grpc.ForceCodec(jsonCodec{})))
+		if err != nil {
+			c.close()
+			return nil, err
+		}
+		c.conns = append(c.conns, conn)
+	}
+	return c, nil
+}
+
+func (c *cluster) close() {
+	for _, conn := range c.conns {
+		conn.Close()
+	}
+}
+
+// Run reps replications of each configuration on the workers, with the seeds
+// Replicate would draw for it, and return their results by configuration. A
+// replication that fails on one worker is sent to another, and a worker that
+// fails is dropped. Once ctx is done no further replication is sent, and the
+// results of each configuration are cut short at its first replication that
+// did not finish.
+func (c *cluster) replicate(ctx context.Context, configs []zitraders.Config, reps int) ([][]zitraders.Results, error) {
+	if len(configs) == 0 || reps < 1 {
+		return make([][]zitraders.Results, len(configs)), nil
+	}
+	jobs := make(chan job, len(configs)*reps)
+	for k, config := range configs {
+		for i, seed := range zitraders.ReplicationSeeds(config.Seed, reps) {
+			j := job{cell: k, rep: i, config: config}
+			j.config.Seed = seed
+			jobs <- j
+		}
+	}
+
+	results := make([][]zitraders.Results, len(configs))
+	done := make([][]bool, len(configs))
+	for k := range results {
+		results[k], done[k] = make([]zitraders.Results, reps), make([]bool, reps)
+	}
+
+	var (
+		mu        sync.Mutex
+		remaining = len(configs) * reps
+		live      = len(c.conns) // workers none of whose replications has failed
+		dropped   = make([]bool, len(c.conns))
+		failed    error
+	)
+	finished := make(chan struct{})
+	// Stop every slot once all replications are in, a replication is
+	// rejected, or no worker is left.
+	finish := func(err error) {
+		if failed == nil && err != nil {
+			failed = err
+		}
+		select {
+		case <-finished:
+		default:
+			close(finished)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for w, conn := range c.conns {
+		for s := 0; s < c.slots; s++ {
+			wg.Add(1)
+			go func(w int, addr string, conn *grpc.ClientConn) {
+				defer wg.Done()
+				for {
+					// A worker's other slots stop once one of them fails.
+					mu.Lock()
+					gone := dropped[w]
+					mu.Unlock()
+					if gone {
+						return
+					}
+					var j job
+					select {
+					case <-finished:
+						return
+					case <-ctx.Done():
+						return
+					case j = <-jobs:
+					}
+					var r zitraders.Results
+					err := conn.Invoke(ctx, "/zitraders.Market/Replicate", &runRequest{Config: j.config}, &r)
+
+					mu.Lock()
+					switch {
+					case err == nil:
+						results[j.cell][j.rep], done[j.cell][j.rep] = r, true
+						if remaining--; remaining == 0 {
+							finish(nil)
+						}
+					case ctx.Err() != nil:
+					case status.Code(err) == codes.InvalidArgument:
+						finish(fmt.Errorf("%s: %v", addr, status.Convert(err).Message()))
+					default:
+						slog.Warn("worker failed; its replication will be sent to another", "worker", addr, "err", err)
+						jobs <- j
+						if !dropped[w] {
+							dropped[w] = true
+							if live--; live == 0 {
+								finish(fmt.Errorf("every worker failed; the last error, from %s: %v", addr, err))
+							}
+						}
+						mu.Unlock()
+						return
+					}
+					mu.Unlock()
+				}
+			}(w, c.addrs[w], conn)
+		}
+	}
+	wg.Wait()
+	if failed != nil {
+		return nil, failed
+	}
+
+	for k := range results {
+		for i, ok := range done[k] {
+			if !ok {
+				results[k] = results[k][:i]
+				break
+			}
+		}
+	}
+	return results, nil
+}
+
+// Run -reps replications of each configuration on the -remote workers.
+func replicateRemotely(ctx context.Context, opts options, configs []zitraders.Config) ([][]zitraders.Results, error) {
+	c, err := dialCluster(opts.Remote, opts.RemoteSlots)
+	if err != nil {
+		return nil, err
+	}
+	defer c.close()
+	slog.Info("sending replications to workers", "workers", len(opts.Remote), "slots", c.slots, "replications", len(configs)*opts.Reps)
+	return c.replicate(ctx, configs, opts.Reps)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// The subcommands. Those that run the model share one Go flag set, parsed by
+// runCommand rather than by cobra, so their flags keep the single-dash form
+// they have always had.
+func commands() *cobra.Command {
+	root := &cobra.Command{
+		Use:          "zi-traders",
+		Short:        "Zero-intelligence traders in large markets, after Axtell (2009)",
+		Long:         "Zero-intelligence traders in large markets, after Axtell (2009).\n\nWith flags but no command, zi-traders runs the model, as zi-traders run does.",
+		SilenceUsage: true,
+	}
+	model := func(use, short string) *cobra.Command {
+		name := strings.Fields(use)[0]
+		return &cobra.Command{
+			Use:                use,
+			Short:              short,
+			DisableFlagParsing: true,
+			Run:                func(_ *cobra.Command, args []string) { runCommand(name, args) },
+		}
+	}
+	server := func(use, short string, serve func([]string)) *cobra.Command {
+		return &cobra.Command{
+			Use:                use,
+			Short:              short,
+			DisableFlagParsing: true,
+			Run:                func(_ *cobra.Command, args []string) { serve(args) },
+		}
+	}
+	root.AddCommand(
+		model("run [flags]", "Run the model once, or -reps times, and report its statistics (see run -h)"),
+		model("batch [flags]", "Run -reps replications side by side, -workers at a time"),
+		model("sweep -config design.yaml [flags]", "Run the factorial design in a config file's sweep section"),
+		model("replay [flags] trades.csv", "Re-execute a recorded trade log against a fresh population"),
+		model("compare [flags] base.yaml treatment.yaml", "Compare two configurations over replications on common seeds"),
+		model("experiment [flags] experiments.xml [name]", "Run a NetLogo BehaviorSpace experiment and tabulate its runs"),
+		server("serve [-addr :8080]", "Drive the model over HTTP", serve),
+		server("grpc [-addr :9000]", "Serve runs over streaming gRPC", serveGRPC),
+		&cobra.Command{
+			Use:   "version",
+			Short: "Print the version and build information",
+			Args:  cobra.NoArgs,
+			Run:   func(*cobra.Command, []string) { printVersion() },
+		},
+	)
+
+	// Flags before any command, or none at all, mean run.
+	args := os.Args[1:]
+	if len(args) == 0 || (strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "--help") {
+		args = append([]string{"run"}, args...)
+	}
+	root.SetArgs(args)
+	return root
+}
+
+// Print the version of the binary and what its build recorded.
+func printVersion() {
+	v, commit := buildVersion()
+	fmt.Printf("zi-traders %s\n", v)
+	if commit != "" {
+		fmt.Printf("commit:     %s\n", commit)
+	}
+	if t := buildSetting("vcs.time"); t != "" {
+		fmt.Printf("committed:  %s\n", t)
+	}
+	fmt.Printf("go:         %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+)
+
+// Replications compared when -reps does not ask for several, and the
+// bootstrap resamples of their differences unless -bootstrap is given.
+const (
+	defaultCompareReps = 30
+	defaultResamples   = 1000
+)
+
+// A configuration compared, named after its file.
+type scenario struct {
+	Name   string           `json:"name"`
+	Config zitraders.Config `json:"config"`
+}
+
+// Run the base and treatment configurations of two files on common seeds,
+// -reps times each, and report their outcomes side by side with the
+// treatment's effect. Each file is applied over the options given by flags
+// and -config, which the two share.
+func compare(ctx context.Context, opts options, paths []string) {
+	if len(paths) != 2 {
+		fatal(fmt.Errorf("compare needs two config files, the base and the treatment"))
+	}
+	scenarios := make([]scenario, 2)
+	configs := make([]zitraders.Config, 2)
+	for i, path := range paths {
+		o := opts
+		if err := decodeFile(path, &o); err != nil {
+			fatal(fmt.Errorf("config %s: %v", path, err))
+		}
+		if len(o.Sweep) > 0 {
+			fatal(fmt.Errorf("config %s: a compared configuration cannot declare a sweep", path))
+		}
+		// The scenarios share their seed, from which the common seeds of
+		// their replications are drawn.
+		o.Seed = opts.Seed
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		scenarios[i], configs[i] = scenario{Name: name, Config: o.Config}, o.Config
+	}
+	if opts.Seed == 0 {
+		configs[0].Seed = zitraders.ReplicationSeeds(0, 1)[0]
+		configs[1].Seed = configs[0].Seed
+	}
+
+	reps := opts.Reps
+	if reps < 2 {
+		reps = defaultCompareReps
+	}
+	workers := opts.Workers
+	if workers == 0 {
+		workers = runtime.NumCPU() / opts.NumThreads
+	}
+	slog.Info("comparison started", "base", scenarios[0].Name, "treatment", scenarios[1].Name, "reps", reps, "workers", workers)
+	results, err := zitraders.ReplicateCommon(ctx, configs, reps, workers, track)
+	if err != nil {
+		fatal(err)
+	}
+	resamples := opts.Bootstrap
+	if resamples == 0 {
+		resamples = defaultResamples
+	}
+	c := zitraders.Compare(results[0], results[1], resamples, configs[0].Seed)
+	if c.Reps < reps {
+		slog.Warn("timed out; the comparison covers the replications made by then", "reps", c.Reps)
+	}
+
+	if !opts.text() {
+		writeJSON(opts, struct {
+			Provenance provenance           `json:"provenance"`
+			Seed       int64                `json:"seed"`
+			Base       scenario             `json:"base"`
+			Treatment  scenario             `json:"treatment"`
+			Comparison zitraders.Comparison `json:"comparison"`
+		}{opts.run, configs[0].Seed, scenarios[0], scenarios[1], c})
+		return
+	}
+	fmt.Printf("\n%s against %s, %d replications each on common seeds drawn from %d\n", scenarios[1].Name, scenarios[0].Name, c.Reps, configs[0].Seed)
+	fmt.Printf("%-14s %12s %12s %12s %25s %25s\n", "", scenarios[0].Name, scenarios[1].Name, "difference", "95% CI (t)", "95% CI (bootstrap)")
+	for _, o := range c.Outcomes {
+		fmt.Printf("%-14s %12.3f %12.3f %12.3f %25s %25s\n", o.Outcome, o.Base.Mean, o.Treatment.Mean, o.Difference.Mean,
+			fmt.Sprintf("[%.3f, %.3f]", o.Difference.Low, o.Difference.High),
+			fmt.Sprintf("[%.3f, %.3f]", o.Difference.Bootstrap.Low, o.Difference.Bootstrap.High))
+	}
+}
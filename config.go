@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+	"gopkg.in/yaml.v3"
+)
+
+// options are the settings of a command-line run: the model configuration
+// plus everything that only matters to the CLI.
+type options struct {
+	zitraders.Config `yaml:",inline"`
+	logging          `yaml:",inline"`
+
+	run provenance
+
+	Reps     int    `json:"reps" yaml:"reps" toml:"reps"`
+	Workers  int    `json:"workers" yaml:"workers" toml:"workers"`
+	BatchOut string `json:"batch_out" yaml:"batch_out" toml:"batch_out"`
+
+	// Precision is the half-width of the confidence interval of PrecisionOf
+	// at which replications stop, zero to run Reps of them.
+	Precision   float64 `json:"precision" yaml:"precision" toml:"precision"`
+	PrecisionOf string  `json:"precision_of" yaml:"precision_of" toml:"precision_of"`
+	MinReps     int     `json:"min_reps" yaml:"min_reps" toml:"min_reps"`
+
+	// CRN runs every sweep cell on the same seeds, common random numbers.
+	CRN bool `json:"crn" yaml:"crn" toml:"crn"`
+	// Bootstrap is the number of bootstrap resamples, and BootstrapBlock
+	// the trades in each block a single run's bootstrap resamples.
+	Bootstrap      int `json:"bootstrap" yaml:"bootstrap" toml:"bootstrap"`
+	BootstrapBlock int `json:"bootstrap_block" yaml:"bootstrap_block" toml:"bootstrap_block"`
+
+	// Plugins lists the Go plugins to load strategies and institutions from.
+	Plugins plugins `json:"plugins" yaml:"plugins" toml:"plugins"`
+	// Scripts lists the Starlark scripts to load strategies and shock
+	// schedules from.
+	Scripts scripts `json:"scripts" yaml:"scripts" toml:"scripts"`
+
+	// Remote lists the addresses of gRPC workers to send replications to.
+	Remote            []string `json:"remote" yaml:"remote" toml:"remote"`
+	RemoteSlots       int      `json:"remote_slots" yaml:"remote_slots" toml:"remote_slots"`
+	TradesOut         string   `json:"trades_out" yaml:"trades_out" toml:"trades_out"`
+	RosterOut         string   `json:"roster_out" yaml:"roster_out" toml:"roster_out"`
+	AgentsOut         string   `json:"agents_out" yaml:"agents_out" toml:"agents_out"`
+	QuotesOut         string   `json:"quotes_out" yaml:"quotes_out" toml:"quotes_out"`
+	CurvesOut         string   `json:"curves_out" yaml:"curves_out" toml:"curves_out"`
+	PriceHistogramOut string   `json:"price_histogram_out" yaml:"price_histogram_out" toml:"price_histogram_out"`
+	LorenzOut         string   `json:"lorenz_out" yaml:"lorenz_out" toml:"lorenz_out"`
+	RoundsOut         string   `json:"rounds_out" yaml:"rounds_out" toml:"rounds_out"`
+	PriceMap          string   `json:"price_map" yaml:"price_map" toml:"price_map"`
+	DB                string   `json:"db" yaml:"db" toml:"db"`
+	DBTrades          bool     `json:"db_trades" yaml:"db_trades" toml:"db_trades"`
+
+	Autotune         bool `json:"autotune" yaml:"autotune" toml:"autotune"`
+	AutotuneAttempts int  `json:"autotune_attempts" yaml:"autotune_attempts" toml:"autotune_attempts"`
+
+	Timeout       duration    `json:"timeout" yaml:"timeout" toml:"timeout"`
+	ProgressEvery duration    `json:"progress_every" yaml:"progress_every" toml:"progress_every"`
+	JSON          bool        `json:"json" yaml:"json" toml:"json"`
+	JSONOut       string      `json:"json_out" yaml:"json_out" toml:"json_out"`
+	DryRun        bool        `json:"dry_run" yaml:"dry_run" toml:"dry_run"`
+	Profile       profileMode `json:"profile" yaml:"profile" toml:"profile"`
+	MetricsAddr   string      `json:"metrics_addr" yaml:"metrics_addr" toml:"metrics_addr"`
+	TUI           bool        `json:"tui" yaml:"tui" toml:"tui"`
+	Web           string      `json:"web" yaml:"web" toml:"web"`
+
+	Checkpoint      string   `json:"checkpoint" yaml:"checkpoint" toml:"checkpoint"`
+	CheckpointEvery duration `json:"checkpoint_every" yaml:"checkpoint_every" toml:"checkpoint_every"`
+	Resume          string   `json:"resume" yaml:"resume" toml:"resume"`
+
+	Snapshots      string `json:"snapshots" yaml:"snapshots" toml:"snapshots"`
+	SnapshotEvery  int64  `json:"snapshot_every" yaml:"snapshot_every" toml:"snapshot_every"`
+	SnapshotFormat string `json:"snapshot_format" yaml:"snapshot_format" toml:"snapshot_format"`
+	Plots          string `json:"plots" yaml:"plots" toml:"plots"`
+	PlotFormat     string `json:"plot_format" yaml:"plot_format" toml:"plot_format"`
+
+	// Sweep maps parameter names to the levels of a factorial design.
+	Sweep    map[string]zitraders.Range `json:"sweep" yaml:"sweep" toml:"sweep"`
+	SweepOut string                     `json:"sweep_out" yaml:"sweep_out" toml:"sweep_out"`
+}
+
+// load reads options from a JSON, YAML or TOML file, chosen by extension.
+// Flags given on the command line take precedence over values in the file.
+func (o *options) load(path string) error {
+	set := make(map[string]string)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = f.Value.String() })
+
+	if err := decodeFile(path, o); err != nil {
+		return fmt.Errorf("config %s: %v", path, err)
+	}
+
+	// A shocks flag appends, so shocks given on the command line would add
+	// to the file's, and to themselves, rather than replace them as other
+	// flags do.
+	if _, ok := set["shock"]; ok {
+		o.Shocks = nil
+	}
+	for name, value := range set {
+		if err := flag.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeFile strictly decodes a structured file into v, rejecting unknown keys.
+func decodeFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch filepath.Ext(path) {
+	case ".json":
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.DisallowUnknownFields()
+		return d.Decode(v)
+	case ".yaml", ".yml":
+		d := yaml.NewDecoder(bytes.NewReader(data))
+		d.KnownFields(true)
+		return d.Decode(v)
+	case ".toml":
+		md, err := toml.Decode(string(data), v)
+		if err != nil {
+			return err
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown keys %v", undecoded)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported config format %q", filepath.Ext(path))
+	}
+}
+
+// text reports whether results are printed as free-form text rather than JSON.
+func (o *options) text() bool {
+	return !o.JSON && o.JSONOut == ""
+}
+
+// A population flag sets the shares of strategies by name, written as
+// "zip=0.2,sniper=0.1".
+type population map[string]float64
+
+func (p *population) String() string {
+	if p == nil {
+		return ""
+	}
+	names := make([]string, 0, len(*p))
+	for name := range *p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] = name + "=" + strconv.FormatFloat((*p)[name], 'g', -1, 64)
+	}
+	return strings.Join(names, ",")
+}
+
+func (p *population) Set(s string) error {
+	shares := make(population)
+	for _, field := range strings.Split(s, ",") {
+		if field == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return fmt.Errorf("bad share %q (want strategy=share)", field)
+		}
+		share, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("bad share %q: %v", field, err)
+		}
+		shares[strings.TrimSpace(name)] = share
+	}
+	*p = shares
+	return nil
+}
+
+// An addresses flag sets a list of network addresses, written as
+// "host1:9000,host2:9000".
+type addresses []string
+
+func (a *addresses) String() string {
+	if a == nil {
+		return ""
+	}
+	return strings.Join(*a, ",")
+}
+
+func (a *addresses) Set(s string) error {
+	*a = nil
+	for _, addr := range strings.Split(s, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			*a = append(*a, addr)
+		}
+	}
+	return nil
+}
+
+// A duration is a time.Duration that config files may also give as a string
+// such as "10s" or "30m", as the flags take it. A bare number is still read
+// as nanoseconds.
+type duration time.Duration
+
+func (d *duration) String() string {
+	if d == nil {
+		return ""
+	}
+	return time.Duration(*d).String()
+}
+
+func (d *duration) Set(s string) error { return d.UnmarshalText([]byte(s)) }
+
+func (d *duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return d.UnmarshalText([]byte(s))
+	}
+	var ns int64
+	if err := json.Unmarshal(data, &ns); err != nil {
+		return fmt.Errorf("bad duration %s (want a string such as \"10s\")", data)
+	}
+	*d = duration(ns)
+	return nil
+}
+
+func (d duration) MarshalText() ([]byte, error) { return []byte(time.Duration(d).String()), nil }
+
+// A shocks flag adds a shock each time it is given, written as
+// "at=50000000,side=buyers,shift=5" or "period=2,side=sellers,shift=-3".
+// Several shocks may also be separated by semicolons.
+type shocks []zitraders.Shock
+
+func (s *shocks) String() string {
+	if s == nil {
+		return ""
+	}
+	fields := make([]string, len(*s))
+	for i, shock := range *s {
+		if shock.At > 0 {
+			fields[i] = "at=" + strconv.FormatInt(shock.At, 10)
+		} else {
+			fields[i] = "period=" + strconv.Itoa(shock.Period)
+		}
+		fields[i] += ",side=" + shock.Side + ",shift=" + strconv.Itoa(shock.Shift)
+	}
+	return strings.Join(fields, ";")
+}
+
+func (s *shocks) Set(value string) error {
+	for _, spec := range strings.Split(value, ";") {
+		if spec == "" {
+			continue
+		}
+		var shock zitraders.Shock
+		for _, field := range strings.Split(spec, ",") {
+			key, v, ok := strings.Cut(field, "=")
+			if !ok {
+				return fmt.Errorf("bad shock field %q (want key=value)", field)
+			}
+			var err error
+			switch strings.TrimSpace(key) {
+			case "at":
+				shock.At, err = strconv.ParseInt(v, 10, 64)
+			case "period":
+				shock.Period, err = strconv.Atoi(v)
+			case "side":
+				shock.Side = v
+			case "shift":
+				shock.Shift, err = strconv.Atoi(v)
+			default:
+				return fmt.Errorf("unknown shock field %q", key)
+			}
+			if err != nil {
+				return fmt.Errorf("bad shock field %q: %v", field, err)
+			}
+		}
+		*s = append(*s, shock)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+)
+
+// Load a config file after parsing args as the -shock flag, as runCommand
+// does.
+func loadWithShocks(t *testing.T, path string, args ...string) []zitraders.Shock {
+	t.Helper()
+	saved := flag.CommandLine
+	defer func() { flag.CommandLine = saved }()
+	flag.CommandLine = flag.NewFlagSet("zi-traders", flag.ContinueOnError)
+
+	opts := options{Config: zitraders.DefaultConfig()}
+	flag.Var((*shocks)(&opts.Shocks), "shock", "")
+	if err := flag.CommandLine.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if err := opts.load(path); err != nil {
+		t.Fatal(err)
+	}
+	return opts.Shocks
+}
+
+// Shocks given on the command line replace those of a config file, as other
+// flags override the file, and are applied once.
+func TestLoadShocks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shocks.yaml")
+	file := "shocks:\n  - {at: 1000, side: buyers, shift: 5}\n  - {period: 2, side: sellers, shift: -3}\n"
+	if err := os.WriteFile(path, []byte(file), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []zitraders.Shock{{At: 1000, Side: "buyers", Shift: 5}, {Period: 2, Side: "sellers", Shift: -3}}
+	if got := loadWithShocks(t, path); !reflect.DeepEqual(got, want) {
+		t.Errorf("without -shock: got %+v, want the file's %+v", got, want)
+	}
+
+	want = []zitraders.Shock{{At: 50, Side: "both", Shift: 1}, {Period: 3, Side: "buyers", Shift: 2}}
+	got := loadWithShocks(t, path, "-shock", "at=50,side=both,shift=1", "-shock", "period=3,side=buyers,shift=2")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("with -shock: got %+v, want %+v", got, want)
+	}
+}
+
+// A config file may name the profile or, in every format, give true for a
+// CPU profile.
+func TestDecodeProfile(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		ext, file string
+		want      profileMode
+	}{
+		{".json", `{"profile": true}`, "cpu"},
+		{".json", `{"profile": false}`, ""},
+		{".json", `{"profile": "mem"}`, "mem"},
+		{".yaml", "profile: true\n", "cpu"},
+		{".toml", "profile = true\n", "cpu"},
+	} {
+		path := filepath.Join(dir, "profile"+tc.ext)
+		if err := os.WriteFile(path, []byte(tc.file), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		var opts options
+		if err := decodeFile(path, &opts); err != nil {
+			t.Errorf("%q: %v", tc.file, err)
+		} else if opts.Profile != tc.want {
+			t.Errorf("%q: got profile %q, want %q", tc.file, opts.Profile, tc.want)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+)
+
+// The results database's schema. Its version is kept in the user_version
+// pragma; add columns or tables in a new version rather than changing these.
+const dbVersion = 3
+
+var dbSchema = []string{
+	`CREATE TABLE IF NOT EXISTS runs (
+		id      INTEGER PRIMARY KEY,
+		created TEXT NOT NULL,    -- RFC 3339
+		mode    TEXT NOT NULL,    -- single, replication, batch or sweep
+		cell    INTEGER,          -- the sweep cell, counting from one
+		rep     INTEGER,          -- the replication, counting from one
+		seed    INTEGER NOT NULL,
+		config  TEXT NOT NULL     -- the configuration as JSON
+	)`,
+	`CREATE TABLE IF NOT EXISTS factors (
+		run_id INTEGER NOT NULL REFERENCES runs(id),
+		name   TEXT NOT NULL,
+		level  REAL NOT NULL,
+		PRIMARY KEY (run_id, name)
+	)`,
+	`CREATE TABLE IF NOT EXISTS statistics (
+		run_id            INTEGER PRIMARY KEY REFERENCES runs(id),
+		attempts          INTEGER NOT NULL,
+		stopped           TEXT NOT NULL,
+		number_bought     INTEGER NOT NULL,
+		number_sold       INTEGER NOT NULL,
+		mean_price        REAL NOT NULL,
+		sd_price          REAL NOT NULL,
+		min_price         REAL NOT NULL,
+		max_price         REAL NOT NULL,
+		median_price      REAL NOT NULL,
+		realized_surplus  INTEGER NOT NULL,
+		max_surplus       INTEGER NOT NULL,
+		efficiency        REAL NOT NULL,
+		equilibrium_price REAL NOT NULL,
+		alpha             REAL NOT NULL,
+		volatility        REAL NOT NULL,
+		results           TEXT NOT NULL -- every statistic as JSON
+	)`,
+	`CREATE TABLE IF NOT EXISTS trades (
+		run_id INTEGER NOT NULL REFERENCES runs(id),
+		period INTEGER NOT NULL,
+		tick   INTEGER NOT NULL,
+		thread INTEGER NOT NULL,
+		buyer  INTEGER NOT NULL,
+		seller INTEGER NOT NULL,
+		bid    INTEGER NOT NULL,
+		ask    INTEGER NOT NULL,
+		price  INTEGER NOT NULL,
+		market INTEGER NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS trades_run ON trades (run_id)`,
+}
+
+// The statements that bring a database of the previous version up to each
+// later one.
+var dbMigrations = map[int][]string{
+	// The provenance of each run: the invocation that made it and the build
+	// and host it ran on.
+	2: {
+		`ALTER TABLE runs ADD COLUMN invocation TEXT`,
+		`ALTER TABLE runs ADD COLUMN version TEXT`,
+		`ALTER TABLE runs ADD COLUMN git_commit TEXT`,
+		`ALTER TABLE runs ADD COLUMN host TEXT`,
+	},
+	// The invocation column holds the run ID of the provenance, shared by
+	// every run of an invocation, so it is named after it.
+	3: {
+		`ALTER TABLE runs RENAME COLUMN invocation TO provenance_run_id`,
+	},
+}
+
+// A run to record in the results database.
+type dbRun struct {
+	mode      string
+	cell, rep int // zero when they do not apply
+	factors   map[string]float64
+	config    zitraders.Config
+	results   zitraders.Results
+	trades    []zitraders.Trade
+}
+
+// Open the SQLite results database at path, creating it and its tables if
+// need be.
+func openDB(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if version > dbVersion {
+		db.Close()
+		return nil, fmt.Errorf("%s: schema version %d is newer than this program's %d", path, version, dbVersion)
+	}
+	for _, stmt := range dbSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	if version < 1 {
+		version = 1 // the schema above
+	}
+	for v := version + 1; v <= dbVersion; v++ {
+		for _, stmt := range dbMigrations[v] {
+			if _, err := db.Exec(stmt); err != nil {
+				db.Close()
+				return nil, err
+			}
+		}
+	}
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", dbVersion)); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Add runs to the results database at path, with the provenance of the
+// invocation that made them.
+func record(path string, p provenance, runs ...dbRun) error {
+	db, err := openDB(path)
+	if err != nil {
+		return err
+	}
+	for _, run := range runs {
+		if err := writeRun(db, p, run); err != nil {
+			db.Close()
+			return err
+		}
+	}
+	return db.Close()
+}
+
+// Record a run, its statistics and any trades in a single transaction.
+func writeRun(db *sql.DB, p provenance, run dbRun) error {
+	config, err := json.Marshal(run.config)
+	if err != nil {
+		return err
+	}
+	results, err := json.Marshal(run.results)
+	if err != nil {
+		return err
+	}
+	nullable := func(n int) interface{} {
+		if n == 0 {
+			return nil
+		}
+		return n
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO runs (created, mode, cell, rep, seed, config, provenance_run_id, version, git_commit, host)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		time.Now().UTC().Format(time.RFC3339), run.mode, nullable(run.cell), nullable(run.rep), run.results.Seed, string(config),
+		p.RunID, p.Version, p.Commit, p.Host)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	for name, level := range run.factors {
+		if _, err := tx.Exec(`INSERT INTO factors (run_id, name, level) VALUES (?, ?, ?)`, id, name, level); err != nil {
+			return err
+		}
+	}
+	r := run.results
+	if _, err := tx.Exec(`INSERT INTO statistics VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, r.Attempts, r.Stopped, r.NumberBought, r.NumberSold, r.MeanPrice, r.SDPrice, r.MinPrice, r.MaxPrice,
+		r.MedianPrice, r.RealizedSurplus, r.MaxSurplus, r.Efficiency, r.EquilibriumPrice, r.Alpha, r.Volatility,
+		string(results)); err != nil {
+		return err
+	}
+
+	if len(run.trades) > 0 {
+		stmt, err := tx.Prepare(`INSERT INTO trades VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, t := range run.trades {
+			if _, err := stmt.Exec(id, t.Period, t.Tick, t.Thread, t.Buyer, t.Seller, t.Bid, t.Ask, t.Price, t.Market); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
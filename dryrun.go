@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+)
+
+// The effective configuration of a dry run, with each thread's share of the
+// work, and the same for every cell of a sweep.
+type dryRunReport struct {
+	Command          string    `json:"command"`
+	Options          options   `json:"options"`
+	BuyersPerThread  int       `json:"buyers_per_thread"`
+	SellersPerThread int       `json:"sellers_per_thread"`
+	TradesPerThread  int       `json:"trades_per_thread"`
+	Cells            []dryCell `json:"cells,omitempty"`
+}
+
+type dryCell struct {
+	Levels map[string]float64 `json:"levels"`
+	Plan   zitraders.Plan     `json:"plan"`
+}
+
+// Check the options as a run would, print them as JSON with every default
+// filled in, and report the first invalid configuration, naming its sweep
+// cell. No model is run, autotuned or even drawn.
+func dryRun(command string, opts options) {
+	plan, err := zitraders.Check(opts.Config)
+	if err != nil {
+		fatal(err)
+	}
+	report := dryRunReport{
+		Command:          command,
+		Options:          opts,
+		BuyersPerThread:  plan.BuyersPerThread,
+		SellersPerThread: plan.SellersPerThread,
+		TradesPerThread:  plan.TradesPerThread,
+	}
+	report.Options.Config = plan.Config
+
+	if len(opts.Sweep) > 0 {
+		names, cells, err := opts.cells()
+		if err != nil {
+			fatal(err)
+		}
+		for _, c := range cells {
+			levels := make(map[string]float64, len(names))
+			for i, name := range names {
+				levels[name] = c.Levels[i]
+			}
+			p, err := zitraders.Check(c.Config)
+			if err != nil {
+				fatal(fmt.Errorf("sweep cell %v: %v", levels, err))
+			}
+			report.Cells = append(report.Cells, dryCell{Levels: levels, Plan: p})
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		fatal(err)
+	}
+}
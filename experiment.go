@@ -0,0 +1,439 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+)
+
+// An experiment is a design in the manner of NetLogo's BehaviorSpace: the
+// variables it varies, each combination run Repetitions times for at most
+// Steps trade attempts, and the metrics reported for every run.
+type experiment struct {
+	Name        string
+	Repetitions int
+	Steps       int
+	Metrics     []string
+	Factors     []zitraders.Factor
+}
+
+// Metrics reported by an experiment that names none.
+var defaultMetrics = []string{"number_bought", "mean_price", "efficiency"}
+
+// Read the experiments of a BehaviorSpace XML file or of a CSV file of the
+// same elements.
+func readExperiments(path string) ([]experiment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return readExperimentsCSV(f, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	}
+	return readExperimentsXML(f)
+}
+
+// An element of a BehaviorSpace experiment, with the attributes of any of
+// them.
+type xmlElement struct {
+	XMLName  xml.Name
+	Variable string `xml:"variable,attr"`
+	First    string `xml:"first,attr"`
+	Step     string `xml:"step,attr"`
+	Last     string `xml:"last,attr"`
+	Steps    string `xml:"steps,attr"`
+	Values   []struct {
+		Value string `xml:"value,attr"`
+	} `xml:"value"`
+	Text     string       `xml:",chardata"`
+	Children []xmlElement `xml:",any"`
+}
+
+// Elements of NetLogo's that have no counterpart here: the procedures it
+// runs and conditions on its own reporters.
+var netlogoOnly = map[string]bool{
+	"setup": true, "go": true, "final": true, "exitCondition": true, "runMetricsCondition": true,
+	"preExperiment": true, "postExperiment": true, "postRun": true,
+}
+
+func readExperimentsXML(r io.Reader) ([]experiment, error) {
+	var file struct {
+		Experiments []struct {
+			Name        string       `xml:"name,attr"`
+			Repetitions string       `xml:"repetitions,attr"`
+			Elements    []xmlElement `xml:",any"`
+		} `xml:"experiment"`
+	}
+	d := xml.NewDecoder(r)
+	// NetLogo declares its files US-ASCII, a subset of UTF-8.
+	d.CharsetReader = func(charset string, r io.Reader) (io.Reader, error) {
+		if !strings.EqualFold(charset, "us-ascii") {
+			return nil, fmt.Errorf("unsupported encoding %q", charset)
+		}
+		return r, nil
+	}
+	if err := d.Decode(&file); err != nil {
+		return nil, err
+	}
+	var experiments []experiment
+	for _, x := range file.Experiments {
+		e := experiment{Name: x.Name}
+		if err := e.setInt(&e.Repetitions, "repetitions", x.Repetitions); err != nil {
+			return nil, err
+		}
+		if err := e.addXML(x.Elements); err != nil {
+			return nil, err
+		}
+		experiments = append(experiments, e)
+	}
+	return experiments, nil
+}
+
+// Add the elements of the experiment in order. The wrappers of newer
+// versions of BehaviorSpace, such as constants and metrics, are flattened.
+func (e *experiment) addXML(elements []xmlElement) error {
+	for _, el := range elements {
+		switch name := el.XMLName.Local; name {
+		case "timeLimit":
+			if err := e.setInt(&e.Steps, "timeLimit", el.Steps); err != nil {
+				return err
+			}
+		case "metric":
+			e.Metrics = append(e.Metrics, el.Text)
+		case "enumeratedValueSet":
+			values := make([]string, len(el.Values))
+			for i, v := range el.Values {
+				values[i] = v.Value
+			}
+			if err := e.enumerated(el.Variable, values); err != nil {
+				return err
+			}
+		case "steppedValueSet":
+			if err := e.stepped(el.Variable, el.First, el.Step, el.Last); err != nil {
+				return err
+			}
+		case "subExperiment":
+			return fmt.Errorf("experiment %q: sub-experiments are not supported", e.Name)
+		default:
+			if netlogoOnly[name] {
+				continue
+			}
+			if len(el.Children) == 0 {
+				return fmt.Errorf("experiment %q: unknown element %s", e.Name, name)
+			}
+			if err := e.addXML(el.Children); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// A CSV experiment file has a row for each element, its name followed by its
+// attributes or values:
+//
+//	repetitions,10
+//	timeLimit,1000000
+//	metric,efficiency
+//	enumeratedValueSet,num_buyers,100,1000
+//	steppedValueSet,max_buyer_value,10,10,50
+//
+// An experiment,name row starts another experiment; rows before the first
+// belong to one named after the file.
+func readExperimentsCSV(r io.Reader, name string) ([]experiment, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.Comment = '#'
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var experiments []experiment
+	e := &experiment{Name: name, Repetitions: 1}
+	started := false
+	for i, row := range rows {
+		for k := range row {
+			row[k] = strings.TrimSpace(row[k])
+		}
+		arity := func(n int) error {
+			if len(row) != n {
+				return fmt.Errorf("row %d: %s takes %d fields, not %d", i+1, row[0], n-1, len(row)-1)
+			}
+			return nil
+		}
+		switch row[0] {
+		case "experiment":
+			if err = arity(2); err == nil {
+				if started {
+					experiments = append(experiments, *e)
+				}
+				e, started = &experiment{Name: row[1], Repetitions: 1}, true
+			}
+		case "repetitions":
+			if err = arity(2); err == nil {
+				err = e.setInt(&e.Repetitions, "repetitions", row[1])
+			}
+		case "timeLimit":
+			if err = arity(2); err == nil {
+				err = e.setInt(&e.Steps, "timeLimit", row[1])
+			}
+		case "metric":
+			e.Metrics = append(e.Metrics, row[1:]...)
+		case "enumeratedValueSet":
+			if len(row) < 3 {
+				err = fmt.Errorf("row %d: %s needs a variable and its values", i+1, row[0])
+			} else {
+				err = e.enumerated(row[1], row[2:])
+			}
+		case "steppedValueSet":
+			if err = arity(5); err == nil {
+				err = e.stepped(row[1], row[2], row[3], row[4])
+			}
+		default:
+			err = fmt.Errorf("row %d: unknown element %q", i+1, row[0])
+		}
+		if err != nil {
+			return nil, err
+		}
+		started = true
+	}
+	if started {
+		experiments = append(experiments, *e)
+	}
+	return experiments, nil
+}
+
+func (e *experiment) setInt(v *int, attr, s string) error {
+	if s == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return fmt.Errorf("experiment %q: %s %q is not a whole number", e.Name, attr, s)
+	}
+	*v = n
+	return nil
+}
+
+// Variables are named by their config keys, or as NetLogo would, with
+// hyphens for underscores.
+func parameter(variable string) string {
+	return strings.ReplaceAll(strings.TrimSpace(variable), "-", "_")
+}
+
+// A level of a variable: a number, or NetLogo's true or false.
+func (e *experiment) level(variable, s string) (float64, error) {
+	switch s = strings.TrimSpace(s); s {
+	case "true":
+		return 1, nil
+	case "false":
+		return 0, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("experiment %q: %s: %s is not a number", e.Name, variable, s)
+	}
+	return v, nil
+}
+
+func (e *experiment) enumerated(variable string, values []string) error {
+	f := zitraders.Factor{Param: parameter(variable)}
+	for _, s := range values {
+		v, err := e.level(variable, s)
+		if err != nil {
+			return err
+		}
+		f.Levels = append(f.Levels, v)
+	}
+	e.Factors = append(e.Factors, f)
+	return nil
+}
+
+func (e *experiment) stepped(variable, first, step, last string) error {
+	var r zitraders.Range
+	for _, a := range []struct {
+		v *float64
+		s string
+	}{{&r.From, first}, {&r.Step, step}, {&r.To, last}} {
+		v, err := e.level(variable, a.s)
+		if err != nil {
+			return err
+		}
+		*a.v = v
+	}
+	if r.Step <= 0 {
+		return fmt.Errorf("experiment %q: %s: the step must be positive", e.Name, variable)
+	}
+	e.Factors = append(e.Factors, zitraders.Factor{Param: parameter(variable), Levels: r.Levels()})
+	return nil
+}
+
+// Check that every metric names a numeric field of the results, as in their
+// JSON, with hyphens for underscores if need be.
+func checkMetrics(metrics []string) error {
+	t := reflect.TypeOf(zitraders.Results{})
+	fields := make(map[string]reflect.Kind, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		fields[strings.Split(t.Field(i).Tag.Get("json"), ",")[0]] = t.Field(i).Type.Kind()
+	}
+	for _, name := range metrics {
+		switch fields[parameter(name)] {
+		case reflect.Int, reflect.Int64, reflect.Float64, reflect.Bool:
+		case reflect.Invalid:
+			return fmt.Errorf("unknown metric %q", name)
+		default:
+			return fmt.Errorf("metric %q is not a number", name)
+		}
+	}
+	return nil
+}
+
+// The value of a metric in the results as JSON, where a field left out is
+// zero.
+func metric(results map[string]interface{}, name string) float64 {
+	switch v := results[parameter(name)].(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+	}
+	return 0
+}
+
+// Run an experiment of a BehaviorSpace file, the one named or else the
+// first, and write a table of its runs as BehaviorSpace does: one row per
+// run, numbered from 1 with a combination's repetitions in a row, of the
+// levels of its variables, the trade attempts it made and its metrics.
+func runExperiment(ctx context.Context, opts options, args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		fatal(fmt.Errorf("experiment needs an experiment file and optionally the name of an experiment in it"))
+	}
+	experiments, err := readExperiments(args[0])
+	if err != nil {
+		fatal(fmt.Errorf("experiments %s: %v", args[0], err))
+	}
+	var e *experiment
+	for i := range experiments {
+		if len(args) == 1 || experiments[i].Name == args[1] {
+			e = &experiments[i]
+			break
+		}
+	}
+	if e == nil {
+		if len(args) == 1 {
+			fatal(fmt.Errorf("experiments %s: no experiments", args[0]))
+		}
+		fatal(fmt.Errorf("experiments %s: no experiment %q", args[0], args[1]))
+	}
+	if e.Steps > 0 {
+		opts.MaxNumberOfTrades = e.Steps
+	}
+	reps := e.Repetitions
+	if reps < 1 {
+		reps = 1
+	}
+	metrics := e.Metrics
+	if len(metrics) == 0 {
+		metrics = defaultMetrics
+	}
+	if err := checkMetrics(metrics); err != nil {
+		fatal(fmt.Errorf("experiment %q: %v", e.Name, err))
+	}
+	if opts.CRN && opts.Seed == 0 {
+		opts.Seed = time.Now().UnixNano()
+	}
+	cells, err := zitraders.Factorial(opts.Config, e.Factors)
+	if err != nil {
+		fatal(fmt.Errorf("experiment %q: %v", e.Name, err))
+	}
+	slog.Info("experiment", "name", e.Name, "combinations", len(cells), "repetitions", reps)
+
+	var w io.Writer = os.Stdout
+	if opts.SweepOut != "" {
+		f, err := os.Create(opts.SweepOut)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	cw := csv.NewWriter(w)
+	header := []string{"[run number]"}
+	for _, f := range e.Factors {
+		header = append(header, f.Param)
+	}
+	header = append(header, "[step]")
+	for _, name := range metrics {
+		header = append(header, parameter(name))
+	}
+	cw.Write(header)
+
+	run := 0
+	for k, c := range cells {
+		results, err := zitraders.ReplicateContext(ctx, c.Config, reps, track)
+		if err != nil {
+			fatal(err)
+		}
+		if opts.DB != "" {
+			factors := make(map[string]float64, len(e.Factors))
+			for i, f := range e.Factors {
+				factors[f.Param] = c.Levels[i]
+			}
+			runs := make([]dbRun, len(results))
+			for i, r := range results {
+				runs[i] = dbRun{mode: "sweep", cell: k + 1, rep: i + 1, factors: factors, config: c.Config, results: r}
+			}
+			if err := record(opts.DB, opts.run, runs...); err != nil {
+				fatal(err)
+			}
+		}
+		for _, r := range results {
+			run++
+			data, err := json.Marshal(r)
+			if err != nil {
+				fatal(err)
+			}
+			var fields map[string]interface{}
+			if err := json.Unmarshal(data, &fields); err != nil {
+				fatal(err)
+			}
+			row := []string{strconv.Itoa(run)}
+			for _, v := range c.Levels {
+				row = append(row, strconv.FormatFloat(v, 'g', -1, 64))
+			}
+			row = append(row, strconv.FormatInt(r.Attempts, 10))
+			for _, name := range metrics {
+				row = append(row, strconv.FormatFloat(metric(fields, name), 'g', -1, 64))
+			}
+			cw.Write(row)
+		}
+		cw.Flush()
+		if ctx.Err() != nil {
+			slog.Warn("timed out; the last combination was cut short", "completed", k+1, "combinations", len(cells))
+			break
+		}
+	}
+	if err := cw.Error(); err != nil {
+		fatal(err)
+	}
+	if opts.SweepOut != "" {
+		if err := opts.stamp(opts.SweepOut, 0); err != nil {
+			fatal(err)
+		}
+	}
+}
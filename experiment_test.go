@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+)
+
+// Experiments as NetLogo 6 exports them: the first in the flat layout of
+// older versions, the second wrapped as newer ones write it.
+const behaviorSpaceXML = `<?xml version="1.0" encoding="us-ascii"?>
+<!DOCTYPE experiments SYSTEM "behaviorspace.dtd">
+<experiments>
+  <experiment name="efficiency" repetitions="3" runMetricsEveryStep="false">
+    <setup>setup</setup>
+    <go>go</go>
+    <final>export-all-plots "plots.csv"</final>
+    <timeLimit steps="2000"/>
+    <exitCondition>not any? turtles</exitCondition>
+    <metric>mean-price</metric>
+    <metric>efficiency</metric>
+    <enumeratedValueSet variable="num-buyers">
+      <value value="100"/>
+      <value value="200"/>
+    </enumeratedValueSet>
+    <steppedValueSet variable="max-buyer-value" first="10" step="10" last="30"/>
+    <enumeratedValueSet variable="antithetic">
+      <value value="false"/>
+      <value value="true"/>
+    </enumeratedValueSet>
+  </experiment>
+  <experiment name="wrapped" repetitions="1" sequentialRunOrder="true" runMetricsEveryStep="true">
+    <preExperiment>clear-all</preExperiment>
+    <setup>setup</setup>
+    <go>go</go>
+    <postRun>export-world "world.csv"</postRun>
+    <postExperiment>print "done"</postExperiment>
+    <runMetricsCondition>ticks mod 10 = 0</runMetricsCondition>
+    <metrics>
+      <metric>number-bought</metric>
+    </metrics>
+    <constants>
+      <enumeratedValueSet variable="num-sellers">
+        <value value="50"/>
+      </enumeratedValueSet>
+      <steppedValueSet variable="max-seller-value" first="20" step="5" last="30"/>
+    </constants>
+  </experiment>
+</experiments>
+`
+
+var wantExperiments = []experiment{
+	{
+		Name:        "efficiency",
+		Repetitions: 3,
+		Steps:       2000,
+		Metrics:     []string{"mean-price", "efficiency"},
+		Factors: []zitraders.Factor{
+			{Param: "num_buyers", Levels: []float64{100, 200}},
+			{Param: "max_buyer_value", Levels: []float64{10, 20, 30}},
+			{Param: "antithetic", Levels: []float64{0, 1}},
+		},
+	},
+	{
+		Name:        "wrapped",
+		Repetitions: 1,
+		Metrics:     []string{"number-bought"},
+		Factors: []zitraders.Factor{
+			{Param: "num_sellers", Levels: []float64{50}},
+			{Param: "max_seller_value", Levels: []float64{20, 25, 30}},
+		},
+	},
+}
+
+func TestReadExperimentsXML(t *testing.T) {
+	got, err := readExperimentsXML(strings.NewReader(behaviorSpaceXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, wantExperiments) {
+		t.Errorf("got %+v\nwant %+v", got, wantExperiments)
+	}
+}
+
+// The CSV form reads as the XML does.
+func TestReadExperimentsCSV(t *testing.T) {
+	const file = `# the same experiments as behaviorSpaceXML
+experiment,efficiency
+repetitions,3
+timeLimit,2000
+metric,mean-price,efficiency
+enumeratedValueSet,num-buyers,100,200
+steppedValueSet,max-buyer-value,10,10,30
+enumeratedValueSet,antithetic,false,true
+experiment,wrapped
+metric,number-bought
+enumeratedValueSet,num-sellers,50
+steppedValueSet, max-seller-value, 20, 5, 30
+`
+	got, err := readExperimentsCSV(strings.NewReader(file), "design")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, wantExperiments) {
+		t.Errorf("got %+v\nwant %+v", got, wantExperiments)
+	}
+
+	// Rows before any experiment row make one named after the file.
+	got, err = readExperimentsCSV(strings.NewReader("timeLimit,500\nenumeratedValueSet,num_buyers,10\n"), "design")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []experiment{{Name: "design", Repetitions: 1, Steps: 500,
+		Factors: []zitraders.Factor{{Param: "num_buyers", Levels: []float64{10}}}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestReadExperimentsErrors(t *testing.T) {
+	xmlFile := func(body string) string {
+		return `<experiments><experiment name="e">` + body + `</experiment></experiments>`
+	}
+	for _, tc := range []struct {
+		name, file, want string
+		csv              bool
+	}{
+		{"sub-experiment", xmlFile(`<subExperiment/>`), "sub-experiments are not supported", false},
+		{"unknown element", xmlFile(`<runs>5</runs>`), "unknown element runs", false},
+		{"bad repetitions", `<experiments><experiment name="e" repetitions="many"/></experiments>`, "not a whole number", false},
+		{"bad level", xmlFile(`<enumeratedValueSet variable="num-buyers"><value value="lots"/></enumeratedValueSet>`), "lots is not a number", false},
+		{"zero step", xmlFile(`<steppedValueSet variable="num-buyers" first="1" step="0" last="5"/>`), "the step must be positive", false},
+		{"encoding", `<?xml version="1.0" encoding="latin1"?><experiments/>`, "unsupported encoding", false},
+		{"csv arity", "steppedValueSet,num_buyers,1,2\n", "takes 4 fields, not 3", true},
+		{"csv unknown row", "runs,5\n", `unknown element "runs"`, true},
+		{"csv no values", "enumeratedValueSet,num_buyers\n", "needs a variable and its values", true},
+	} {
+		var err error
+		if tc.csv {
+			_, err = readExperimentsCSV(strings.NewReader(tc.file), "e")
+		} else {
+			_, err = readExperimentsXML(strings.NewReader(tc.file))
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got error %v, want one containing %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestCheckMetrics(t *testing.T) {
+	if err := checkMetrics([]string{"mean-price", "efficiency", "number_bought"}); err != nil {
+		t.Error(err)
+	}
+	if err := checkMetrics([]string{"happiness"}); err == nil || !strings.Contains(err.Error(), "unknown metric") {
+		t.Errorf("unknown metric: got %v", err)
+	}
+	if err := checkMetrics([]string{"equilibrium"}); err == nil || !strings.Contains(err.Error(), "not a number") {
+		t.Errorf("non-numeric metric: got %v", err)
+	}
+}
+
+// An experiment writes a row per run, with the levels of its variables, the
+// trade attempts made and its metrics.
+func TestRunExperiment(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "experiments.xml")
+	if err := os.WriteFile(path, []byte(behaviorSpaceXML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var opts options
+	opts.Config = zitraders.DefaultConfig()
+	opts.NumBuyers, opts.NumSellers, opts.NumThreads, opts.Seed = 100, 100, 2, 1
+	opts.SweepOut = filepath.Join(dir, "runs.csv")
+	runExperiment(context.Background(), opts, []string{path, "efficiency"})
+
+	f, err := os.Open(opts.SweepOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := []string{"[run number]", "num_buyers", "max_buyer_value", "antithetic", "[step]", "mean_price", "efficiency"}
+	if !reflect.DeepEqual(rows[0], header) {
+		t.Fatalf("header %q, want %q", rows[0], header)
+	}
+	// 2 × 3 × 2 combinations of 3 repetitions each.
+	if len(rows) != 1+36 {
+		t.Fatalf("%d rows, want 36 runs", len(rows)-1)
+	}
+	if rows[1][0] != "1" || rows[36][0] != "36" {
+		t.Errorf("runs numbered %s to %s", rows[1][0], rows[36][0])
+	}
+	// A combination's repetitions are in a row, the last variable varying
+	// fastest.
+	if got := rows[4][1:4]; !reflect.DeepEqual(got, []string{"100", "10", "1"}) {
+		t.Errorf("run 4 has levels %q", got)
+	}
+	// The time limit bounds every run, as -trades does, each goroutine
+	// making its share of the attempts.
+	for _, row := range rows[1:] {
+		if attempts, _ := strconv.Atoi(row[4]); attempts > 2000 || attempts < 2000-2*opts.NumThreads {
+			t.Fatalf("run %s made %s attempts, want the time limit of 2000", row[0], row[4])
+		}
+	}
+	if _, err := os.Stat(opts.SweepOut + ".meta.json"); err != nil {
+		t.Error(err)
+	}
+}
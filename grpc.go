@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"net"
+	"sync"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The gRPC service is described by hand rather than generated from a .proto
+// file, and its messages are JSON, so clients need no generated code: any
+// gRPC client that sends and receives raw bytes (for example Python's
+// channel.unary_stream with json serializers) can call
+//
+//	/zitraders.Market/Run         RunRequest -> stream Event
+//	/zitraders.Market/Subscribe   SubscribeRequest -> stream Event
+//	/zitraders.Market/Replicate   RunRequest -> Results
+//
+// Run creates a model from the request's config, which starts from the
+// default parameters, streams an event at the end of every tick while it runs
+// and sends the results when it ends. If the client goes away, the run stops
+// at the end of the next tick. Every event carries the run's id, by which
+// Subscribe follows a run in progress from another connection: it streams the
+// run's events from then on, ending with its results. A subscriber that falls
+// behind is dropped rather than holding up the run. Replicate runs the model
+// quietly and returns just its results; coordinators use it to farm out
+// replications.
+
+// A runRequest is the message sent to Run.
+type runRequest struct {
+	Config zitraders.Config `json:"config"`
+}
+
+// A subscribeRequest is the message sent to Subscribe.
+type subscribeRequest struct {
+	Run int `json:"run"`
+}
+
+// An event is a message streamed by Run and Subscribe.
+type event struct {
+	Run       int                `json:"run"`
+	Tick      *zitraders.Tick    `json:"tick,omitempty"`
+	MeanPrice float64            `json:"mean_price,omitempty"`
+	Trades    []zitraders.Trade  `json:"trades,omitempty"` // trades in the tick, if the config records them
+	Results   *zitraders.Results `json:"results,omitempty"`
+}
+
+// jsonCodec encodes gRPC messages as JSON.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                               { return "json" }
+
+type marketServer interface{}
+
+// Events a subscriber may fall behind by before it is dropped.
+const subscriberBacklog = 256
+
+// A grpcServer holds the runs in progress, so that clients can subscribe to
+// them.
+type grpcServer struct {
+	mu   sync.Mutex
+	next int
+	runs map[int]*broadcast
+}
+
+// Register a new run.
+func (s *grpcServer) start() (int, *broadcast) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	b := &broadcast{subs: make(map[*subscriber]bool)}
+	s.runs[s.next] = b
+	return s.next, b
+}
+
+func (s *grpcServer) end(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.runs, id)
+}
+
+func (s *grpcServer) run(id int) *broadcast {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.runs[id]
+}
+
+// A broadcast relays the events of a run to its subscribers.
+type broadcast struct {
+	mu    sync.Mutex
+	subs  map[*subscriber]bool
+	ended bool
+}
+
+type subscriber struct {
+	events  chan *event
+	dropped bool // set before events is closed if the subscriber fell behind
+}
+
+// Subscribe to the run's events, or return nil if it has ended.
+func (b *broadcast) subscribe() *subscriber {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ended {
+		return nil
+	}
+	sub := &subscriber{events: make(chan *event, subscriberBacklog)}
+	b.subs[sub] = true
+	return sub
+}
+
+func (b *broadcast) unsubscribe(sub *subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subs, sub)
+}
+
+// Send an event to every subscriber without waiting, dropping those whose
+// backlog is full. The last event ends every subscription.
+func (b *broadcast) publish(e *event, last bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		select {
+		case sub.events <- e:
+		default:
+			sub.dropped = true
+			close(sub.events)
+			delete(b.subs, sub)
+		}
+	}
+	if last {
+		for sub := range b.subs {
+			close(sub.events)
+			delete(b.subs, sub)
+		}
+		b.ended = true
+	}
+}
+
+var marketService = grpc.ServiceDesc{
+	ServiceName: "zitraders.Market",
+	HandlerType: (*marketServer)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Replicate",
+		Handler:    replicateRun,
+	}},
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Run",
+		Handler:       streamRun,
+		ServerStreams: true,
+	}, {
+		StreamName:    "Subscribe",
+		Handler:       subscribeRun,
+		ServerStreams: true,
+	}},
+}
+
+// Serve the streaming gRPC service.
+func serveGRPC(args []string) {
+	fs := flag.NewFlagSet("grpc", flag.ExitOnError)
+	addr := fs.String("addr", ":9000", "address to listen on")
+	var p plugins
+	fs.Var(&p, "plugin", "load strategies and institutions from these Go plugins")
+	var sc scripts
+	fs.Var(&sc, "script", "load strategies from these Starlark scripts")
+	var l logging
+	l.flags(fs)
+	fs.Parse(args)
+	if err := l.start(); err != nil {
+		fatal(err)
+	}
+	if err := p.load(); err != nil {
+		fatal(err)
+	}
+	if err := sc.load(nil); err != nil {
+		fatal(err)
+	}
+
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fatal(err)
+	}
+	s := grpc.NewServer(grpc.ForceServerCodec(jsonCodec{}))
+	s.RegisterService(&marketService, &grpcServer{runs: make(map[int]*broadcast)})
+	slog.Info("serving gRPC", "addr", *addr)
+	fatal(s.Serve(lis))
+}
+
+func streamRun(srv interface{}, stream grpc.ServerStream) error {
+	req := runRequest{Config: zitraders.DefaultConfig()}
+	if err := stream.RecvMsg(&req); err != nil {
+		return err
+	}
+	// Events are sent between ticks, so runs need some.
+	if req.Config.TickSize == 0 {
+		req.Config.TickSize = 10000
+	}
+	m, err := zitraders.New(req.Config)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	s := srv.(*grpcServer)
+	id, b := s.start()
+	defer s.end(id)
+	slog.Info("run started", "run", id, "seed", m.Seed)
+
+	var sendErr error
+	m.Observe(func(m *zitraders.Model, t zitraders.Tick) bool {
+		if sendErr != nil {
+			return false
+		}
+		e := &event{Run: id, Tick: &t, MeanPrice: m.Progress().MeanPrice()}
+		if m.RecordTrades {
+			e.Trades = m.TickTrades()
+		}
+		b.publish(e, false)
+		sendErr = stream.SendMsg(e)
+		return sendErr == nil
+	})
+	r := m.Run()
+	// Subscribers get the results even of a run its own client abandoned.
+	last := &event{Run: id, Results: &r}
+	b.publish(last, true)
+	if sendErr != nil {
+		return sendErr
+	}
+	return stream.SendMsg(last)
+}
+
+// Stream the events of a run in progress, from now until its results.
+func subscribeRun(srv interface{}, stream grpc.ServerStream) error {
+	var req subscribeRequest
+	if err := stream.RecvMsg(&req); err != nil {
+		return err
+	}
+	var sub *subscriber
+	b := srv.(*grpcServer).run(req.Run)
+	if b != nil {
+		sub = b.subscribe()
+	}
+	if sub == nil {
+		return status.Errorf(codes.NotFound, "no run %d in progress", req.Run)
+	}
+	defer b.unsubscribe(sub)
+	for {
+		select {
+		case e, ok := <-sub.events:
+			if !ok {
+				if sub.dropped {
+					return status.Errorf(codes.ResourceExhausted, "fell more than %d events behind run %d", subscriberBacklog, req.Run)
+				}
+				return nil
+			}
+			if err := stream.SendMsg(e); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+	}
+}
+
+func replicateRun(_ interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := runRequest{Config: zitraders.DefaultConfig()}
+	if err := dec(&req); err != nil {
+		return nil, err
+	}
+	m, err := zitraders.New(req.Config)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	slog.Debug("replication started", "seed", m.Seed)
+	r := m.RunContext(ctx)
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	return &r, nil
+}
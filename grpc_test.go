@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// Serve the gRPC service in memory and connect a client to it.
+func testGRPC(t *testing.T) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(grpc.ForceServerCodec(jsonCodec{}))
+	s.RegisterService(&marketService, &grpcServer{runs: make(map[int]*broadcast)})
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(jsonCodec{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// Open a server stream, sending it the request.
+func openStream(t *testing.T, conn *grpc.ClientConn, method string, req interface{}) grpc.ClientStream {
+	t.Helper()
+	desc := &grpc.StreamDesc{ServerStreams: true}
+	stream, err := conn.NewStream(context.Background(), desc, "/zitraders.Market/"+method)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.SendMsg(req); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	return stream
+}
+
+// Receive events until the last, with the results.
+func drain(stream grpc.ClientStream) ([]event, error) {
+	var events []event
+	for {
+		var e event
+		if err := stream.RecvMsg(&e); err != nil {
+			return events, err
+		}
+		events = append(events, e)
+		if e.Results != nil {
+			return events, nil
+		}
+	}
+}
+
+// A subscriber to a run receives the events its own client does from then
+// on, and the same results.
+func TestSubscribe(t *testing.T) {
+	conn := testGRPC(t)
+	config := zitraders.DefaultConfig()
+	config.NumBuyers, config.NumSellers, config.NumThreads, config.Seed = 1000, 1000, 2, 1
+	// More events than fit in the connection's flow-control window, so the
+	// run waits on its client and is still in progress to subscribe to.
+	config.Periods, config.MaxNumberOfTrades, config.TickSize = 10, 100000, 100
+	run := openStream(t, conn, "Run", &runRequest{Config: config})
+	var first event
+	if err := run.RecvMsg(&first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Run == 0 || first.Tick == nil {
+		t.Fatalf("first event %+v", first)
+	}
+
+	sub := openStream(t, conn, "Subscribe", &subscribeRequest{Run: first.Run})
+	subscribed := make(chan []event)
+	go func() {
+		events, err := drain(sub)
+		if err != nil {
+			t.Error(err)
+		}
+		subscribed <- events
+	}()
+	events, err := drain(run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := <-subscribed
+	if len(got) == 0 {
+		t.Fatal("the subscriber received nothing")
+	}
+	if last := got[len(got)-1]; last.Results == nil || !reflect.DeepEqual(*last.Results, *events[len(events)-1].Results) {
+		t.Errorf("the subscriber's last event %+v lacks the run's results", last)
+	}
+	if want := events[len(events)-len(got):]; !reflect.DeepEqual(got, want) {
+		t.Errorf("the subscriber received %d events that are not the last of the run's %d", len(got), len(events)+1)
+	}
+
+	// The run is over.
+	_, err = drain(openStream(t, conn, "Subscribe", &subscribeRequest{Run: first.Run}))
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("subscribing to a finished run: got %v", err)
+	}
+}
+
+// A subscriber that falls behind is dropped, and one that subscribes after
+// the last event gets nothing.
+func TestBroadcast(t *testing.T) {
+	b := &broadcast{subs: make(map[*subscriber]bool)}
+	slow, fast := b.subscribe(), b.subscribe()
+	for k := 0; k <= subscriberBacklog; k++ {
+		b.publish(&event{Tick: &zitraders.Tick{Attempts: int64(k)}}, false)
+		if k < subscriberBacklog {
+			<-fast.events
+		}
+	}
+	n := 0
+	for range slow.events {
+		n++
+	}
+	if !slow.dropped || n != subscriberBacklog {
+		t.Errorf("the slow subscriber got %d events before it was closed, dropped %v", n, slow.dropped)
+	}
+
+	b.publish(&event{Results: &zitraders.Results{}}, true)
+	var last *event
+	for e := range fast.events {
+		last = e
+	}
+	if fast.dropped || last == nil || last.Results == nil {
+		t.Errorf("the fast subscriber ended with %+v, dropped %v", last, fast.dropped)
+	}
+	if b.subscribe() != nil {
+		t.Error("subscribed after the last event")
+	}
+}
@@ -0,0 +1,84 @@
+// Command libzitraders builds the model as a C shared library, for bindings
+// in other languages such as the Python module in ../python:
+//
+//	go build -buildmode=c-shared -o libzitraders.so ./libzitraders
+//
+// Configurations go in and results come out as JSON, in the fields and
+// defaults of a config file; results are returned in C strings the caller
+// frees with zt_free.
+package main
+
+/*
+#include <stdlib.h>
+
+// A trade callback receives the fields of a trade and returns nonzero to
+// stop the run.
+typedef int (*zt_trade_fn)(int period, int tick, int thread, int buyer, int seller, int bid, int ask, int price, int market);
+
+static int zt_call(zt_trade_fn f, int period, int tick, int thread, int buyer, int seller, int bid, int ask, int price, int market) {
+	return f(period, tick, thread, buyer, seller, bid, ask, price, market);
+}
+*/
+import "C"
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"unsafe"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+)
+
+func main() {}
+
+// zt_run runs the model configured by the JSON object config and returns its
+// results as JSON, or an object with an "error" field. Unless onTrade is
+// NULL it is called with every trade at the end of the tick in which it was
+// made, while the trading threads are paused.
+//
+//export zt_run
+func zt_run(config *C.char, onTrade C.zt_trade_fn) *C.char {
+	r, err := run(C.GoString(config), onTrade)
+	if err != nil {
+		r, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	return C.CString(string(r))
+}
+
+// zt_free frees a string returned by zt_run.
+//
+//export zt_free
+func zt_free(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+func run(config string, onTrade C.zt_trade_fn) ([]byte, error) {
+	c := zitraders.DefaultConfig()
+	d := json.NewDecoder(bytes.NewReader([]byte(config)))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&c); err != nil {
+		return nil, fmt.Errorf("config: %v", err)
+	}
+	// Trades are streamed between ticks, so runs need some.
+	if onTrade != nil && c.TickSize == 0 {
+		c.TickSize = 10000
+	}
+	m, err := zitraders.New(c)
+	if err != nil {
+		return nil, err
+	}
+	if onTrade != nil {
+		m.RecordTrades = true
+		m.Observe(func(m *zitraders.Model, _ zitraders.Tick) bool {
+			for _, t := range m.TickTrades() {
+				if C.zt_call(onTrade, C.int(t.Period), C.int(t.Tick), C.int(t.Thread), C.int(t.Buyer), C.int(t.Seller),
+					C.int(t.Bid), C.int(t.Ask), C.int(t.Price), C.int(t.Market)) != 0 {
+					return false
+				}
+			}
+			return true
+		})
+	}
+	return json.Marshal(m.Run())
+}
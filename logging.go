@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Logging settings, shared by runs and the serve and grpc subcommands.
+type logging struct {
+	Level  string `json:"log_level" yaml:"log_level" toml:"log_level"`
+	Format string `json:"log_format" yaml:"log_format" toml:"log_format"`
+	File   string `json:"log_file" yaml:"log_file" toml:"log_file"`
+}
+
+func (l *logging) flags(fs *flag.FlagSet) {
+	fs.StringVar(&l.Level, "log-level", "info", "log messages at this level or above: debug, info, warn or error")
+	fs.StringVar(&l.Format, "log-format", "text", "log format: text or json")
+	fs.StringVar(&l.File, "log-file", "", "append log messages to this file instead of stderr")
+}
+
+// Install a logger with these settings as the default, to which the log
+// package's output is also sent.
+func (l logging) start() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(l.Level)); err != nil {
+		return fmt.Errorf("unknown log level %q", l.Level)
+	}
+
+	var w io.Writer = os.Stderr
+	if l.File != "" {
+		f, err := os.OpenFile(l.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		w = f
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch l.Format {
+	case "", "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown log format %q", l.Format)
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// Log an error and exit.
+func fatal(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
+}
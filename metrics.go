@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+)
+
+// The model currently running, published to the metrics endpoint.
+var current atomic.Value
+
+// Publish a model to the metrics endpoint as the one currently running.
+func track(m *zitraders.Model) {
+	current.Store(m)
+}
+
+// Serve Prometheus metrics for the current model at addr in the background.
+func serveMetrics(addr string) {
+	progress := func(f func(zitraders.Progress, *zitraders.Model) float64) func() float64 {
+		return func() float64 {
+			m, ok := current.Load().(*zitraders.Model)
+			if !ok {
+				return 0
+			}
+			return f(m.Progress(), m)
+		}
+	}
+
+	prometheus.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "zitraders_trade_attempts_total",
+			Help: "Trade attempts made in the current run.",
+		}, progress(func(p zitraders.Progress, m *zitraders.Model) float64 { return float64(p.Attempts) })),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "zitraders_trades_total",
+			Help: "Trades executed in the current run.",
+		}, progress(func(p zitraders.Progress, m *zitraders.Model) float64 { return float64(p.Trades) })),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "zitraders_goroutine_utilization",
+			Help: "Share of the run's goroutines still trading.",
+		}, progress(func(p zitraders.Progress, m *zitraders.Model) float64 {
+			return float64(p.Running) / float64(m.NumThreads)
+		})),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "zitraders_mean_price",
+			Help: "Average transaction price so far in the current run.",
+		}, progress(func(p zitraders.Progress, m *zitraders.Model) float64 { return p.MeanPrice() })),
+	)
+
+	http.Handle("/metrics", promhttp.Handler())
+	go func() {
+		fatal(http.ListenAndServe(addr, nil))
+	}()
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress/zstd"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+)
+
+// Rows are written to Parquet files in batches of this many.
+const parquetBatch = 64 * 1024
+
+type tradeRow struct {
+	Period int32 `parquet:"period,delta"`
+	Tick   int64 `parquet:"tick,delta"`
+	Thread int32 `parquet:"thread"`
+	Buyer  int64 `parquet:"buyer"`
+	Seller int64 `parquet:"seller"`
+	Bid    int32 `parquet:"bid"`
+	Ask    int32 `parquet:"ask"`
+	Price  int32 `parquet:"price"`
+	Market int32 `parquet:"market"`
+
+	Extramarginal bool `parquet:"extramarginal,optional"`
+}
+
+type quoteRow struct {
+	Period   int32 `parquet:"period,delta"`
+	Tick     int64 `parquet:"tick,delta"`
+	Thread   int32 `parquet:"thread"`
+	Bid      int32 `parquet:"bid"`
+	Ask      int32 `parquet:"ask"`
+	BidDepth int32 `parquet:"bid_depth"`
+	AskDepth int32 `parquet:"ask_depth"`
+}
+
+type agentRow struct {
+	Side  string `parquet:"side,dict"`
+	Agent int64  `parquet:"agent,delta"`
+	Value int32  `parquet:"value"`
+	Held  int32  `parquet:"held"`
+	Price int32  `parquet:"price"`
+}
+
+type agentResultRow struct {
+	ID       int64  `parquet:"id,delta"`
+	Side     string `parquet:"side,dict"`
+	Agent    int64  `parquet:"agent,delta"`
+	Strategy string `parquet:"strategy,dict"`
+	Value    int32  `parquet:"value"`
+	Held     int32  `parquet:"held"`
+	Price    int32  `parquet:"price"`
+	Traded   int32  `parquet:"traded"`
+	Surplus  int32  `parquet:"surplus"`
+}
+
+type memberRow struct {
+	ID       int64   `parquet:"id,delta"`
+	Side     string  `parquet:"side,dict"`
+	Agent    int64   `parquet:"agent,delta"`
+	Strategy string  `parquet:"strategy,dict"`
+	Market   int32   `parquet:"market"`
+	Values   []int32 `parquet:"values,list"`
+}
+
+// Whether a file should be written as Parquet rather than CSV.
+func isParquet(path string) bool {
+	return filepath.Ext(path) == ".parquet"
+}
+
+// A Parquet file of rows of type T, written in batches.
+type parquetFile[T any] struct {
+	f     *os.File
+	w     *parquet.GenericWriter[T]
+	batch []T
+	err   error
+}
+
+func createParquet[T any](path string) (*parquetFile[T], error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := parquet.NewGenericWriter[T](f, parquet.Compression(&zstd.Codec{}))
+	return &parquetFile[T]{f: f, w: w, batch: make([]T, 0, parquetBatch)}, nil
+}
+
+func (p *parquetFile[T]) add(row T) {
+	p.batch = append(p.batch, row)
+	if len(p.batch) == cap(p.batch) {
+		p.flush()
+	}
+}
+
+func (p *parquetFile[T]) flush() {
+	if p.err == nil && len(p.batch) > 0 {
+		_, p.err = p.w.Write(p.batch)
+	}
+	p.batch = p.batch[:0]
+}
+
+func (p *parquetFile[T]) close() error {
+	p.flush()
+	if err := p.w.Close(); p.err == nil {
+		p.err = err
+	}
+	if err := p.f.Close(); p.err == nil {
+		p.err = err
+	}
+	return p.err
+}
+
+// Write a trade log as a Parquet file.
+func writeTradesParquet(path string, trades []zitraders.Trade) error {
+	p, err := createParquet[tradeRow](path)
+	if err != nil {
+		return err
+	}
+	for _, t := range trades {
+		p.add(tradeRow{
+			Period: int32(t.Period),
+			Tick:   int64(t.Tick),
+			Thread: int32(t.Thread),
+			Buyer:  int64(t.Buyer),
+			Seller: int64(t.Seller),
+			Bid:    int32(t.Bid),
+			Ask:    int32(t.Ask),
+			Price:  int32(t.Price),
+			Market: int32(t.Market),
+
+			Extramarginal: t.Extramarginal,
+		})
+	}
+	return p.close()
+}
+
+// Write samples of the order books as a Parquet file.
+func writeQuotesParquet(path string, quotes []zitraders.Quote) error {
+	p, err := createParquet[quoteRow](path)
+	if err != nil {
+		return err
+	}
+	for _, q := range quotes {
+		p.add(quoteRow{
+			Period:   int32(q.Period),
+			Tick:     int64(q.Tick),
+			Thread:   int32(q.Thread),
+			Bid:      int32(q.Bid),
+			Ask:      int32(q.Ask),
+			BidDepth: int32(q.BidDepth),
+			AskDepth: int32(q.AskDepth),
+		})
+	}
+	return p.close()
+}
+
+// Write a snapshot of every agent as a Parquet file.
+func writeSnapshotParquet(m *zitraders.Model, path string) error {
+	p, err := createParquet[agentRow](path)
+	if err != nil {
+		return err
+	}
+	m.EachAgent(func(a zitraders.AgentState) {
+		p.add(agentRow{Side: a.Side, Agent: int64(a.Agent), Value: int32(a.Value), Held: int32(a.Held), Price: int32(a.Price)})
+	})
+	return p.close()
+}
+
+// Write the roster of agents as a Parquet file.
+func writeRosterParquet(path string, m *zitraders.Model) error {
+	p, err := createParquet[memberRow](path)
+	if err != nil {
+		return err
+	}
+	m.EachMember(func(x zitraders.Member) {
+		values := make([]int32, len(x.Values))
+		for k, v := range x.Values {
+			values[k] = int32(v)
+		}
+		p.add(memberRow{ID: int64(x.ID), Side: x.Side, Agent: int64(x.Agent), Strategy: x.Strategy, Market: int32(x.Market), Values: values})
+	})
+	return p.close()
+}
+
+// Write the final state and surplus of every agent as a Parquet file.
+func writeAgentResultsParquet(path string, m *zitraders.Model) error {
+	p, err := createParquet[agentResultRow](path)
+	if err != nil {
+		return err
+	}
+	m.EachAgentResult(func(a zitraders.AgentResult) {
+		p.add(agentResultRow{
+			ID:       int64(a.ID),
+			Side:     a.Side,
+			Agent:    int64(a.Agent),
+			Strategy: a.Strategy,
+			Value:    int32(a.Value),
+			Held:     int32(a.Held),
+			Price:    int32(a.Price),
+			Traded:   int32(a.Traded),
+			Surplus:  int32(a.Surplus),
+		})
+	})
+	return p.close()
+}
+
+// Read a trade log written by writeTradesParquet.
+func readTradesParquet(path string) ([]zitraders.Trade, error) {
+	rows, err := parquet.ReadFile[tradeRow](path)
+	if err != nil {
+		return nil, err
+	}
+	trades := make([]zitraders.Trade, len(rows))
+	for i, t := range rows {
+		trades[i] = zitraders.Trade{
+			Period: int(t.Period),
+			Tick:   int(t.Tick),
+			Thread: int(t.Thread),
+			Buyer:  int(t.Buyer),
+			Seller: int(t.Seller),
+			Bid:    int(t.Bid),
+			Ask:    int(t.Ask),
+			Price:  int(t.Price),
+			Market: int(t.Market),
+
+			Extramarginal: t.Extramarginal,
+		}
+	}
+	return trades, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+)
+
+// Draw the price series, the price histogram and the supply and demand
+// curves of a run into dir, as PNG or SVG files depending on format.
+func writePlots(dir, format string, trades []zitraders.Trade, c zitraders.Curves) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for name, p := range map[string]func([]zitraders.Trade, zitraders.Curves) (*plot.Plot, error){
+		"prices":    plotPrices,
+		"histogram": plotHistogram,
+		"curves":    plotCurves,
+	} {
+		pl, err := p(trades, c)
+		if err != nil {
+			return err
+		}
+		if err := pl.Save(8*vg.Inch, 5*vg.Inch, filepath.Join(dir, name+"."+format)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Transaction prices in the order the trades were executed.
+func plotPrices(trades []zitraders.Trade, c zitraders.Curves) (*plot.Plot, error) {
+	p := plot.New()
+	p.Title.Text = "Transaction prices"
+	p.X.Label.Text = "trade"
+	p.Y.Label.Text = "price"
+
+	pts := make(plotter.XYs, len(trades))
+	for i, t := range trades {
+		pts[i] = plotter.XY{X: float64(i + 1), Y: float64(t.Price)}
+	}
+	s, err := plotter.NewScatter(pts)
+	if err != nil {
+		return nil, err
+	}
+	s.GlyphStyle.Radius = vg.Points(1)
+	eq := plotter.NewFunction(func(float64) float64 { return c.EquilibriumPrice })
+	eq.Dashes = []vg.Length{vg.Points(4), vg.Points(4)}
+	p.Add(s, eq)
+	p.Legend.Add("equilibrium", eq)
+	return p, nil
+}
+
+// A histogram of transaction prices.
+func plotHistogram(trades []zitraders.Trade, c zitraders.Curves) (*plot.Plot, error) {
+	p := plot.New()
+	p.Title.Text = "Transaction price distribution"
+	p.X.Label.Text = "price"
+	p.Y.Label.Text = "trades"
+
+	prices := make(plotter.Values, len(trades))
+	low, high := 0, 0
+	for i, t := range trades {
+		prices[i] = float64(t.Price)
+		if i == 0 || t.Price < low {
+			low = t.Price
+		}
+		if t.Price > high {
+			high = t.Price
+		}
+	}
+	if len(prices) == 0 {
+		return p, nil
+	}
+	h, err := plotter.NewHist(prices, high-low+1)
+	if err != nil {
+		return nil, err
+	}
+	p.Add(h)
+	return p, nil
+}
+
+// The induced supply and demand curves with the realized trades overlaid in
+// the order they were executed.
+func plotCurves(trades []zitraders.Trade, c zitraders.Curves) (*plot.Plot, error) {
+	p := plot.New()
+	p.Title.Text = "Supply and demand"
+	p.X.Label.Text = "quantity"
+	p.Y.Label.Text = "price"
+
+	demand, err := plotter.NewLine(steps(c.Demand))
+	if err != nil {
+		return nil, err
+	}
+	demand.StepStyle = plotter.PostStep
+	demand.Color = plotutil.Color(0)
+	supply, err := plotter.NewLine(steps(c.Supply))
+	if err != nil {
+		return nil, err
+	}
+	supply.StepStyle = plotter.PostStep
+	supply.Color = plotutil.Color(1)
+
+	pts := make(plotter.XYs, len(trades))
+	for i, t := range trades {
+		pts[i] = plotter.XY{X: float64(i + 1), Y: float64(t.Price)}
+	}
+	realized, err := plotter.NewScatter(pts)
+	if err != nil {
+		return nil, err
+	}
+	realized.GlyphStyle.Radius = vg.Points(1)
+
+	p.Add(demand, supply, realized)
+	p.Legend.Add("demand", demand)
+	p.Legend.Add("supply", supply)
+	p.Legend.Add("trades", realized)
+	p.Legend.Top = true
+	return p, nil
+}
+
+// The corners of a step curve through a schedule, keeping only the units at
+// which the price changes so that large populations stay cheap to draw.
+func steps(schedule []int) plotter.XYs {
+	var pts plotter.XYs
+	for i, v := range schedule {
+		if i == 0 || v != schedule[i-1] {
+			pts = append(pts, plotter.XY{X: float64(i), Y: float64(v)})
+		}
+	}
+	if n := len(schedule); n > 0 {
+		pts = append(pts, plotter.XY{X: float64(n), Y: float64(schedule[n-1])})
+	}
+	return pts
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"plugin"
+	"strings"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+)
+
+// A plugins flag sets the Go plugins to load strategies and institutions
+// from, written as "a.so,b.so".
+type plugins []string
+
+func (p *plugins) String() string {
+	if p == nil {
+		return ""
+	}
+	return strings.Join(*p, ",")
+}
+
+func (p *plugins) Set(s string) error {
+	*p = nil
+	for _, path := range strings.Split(s, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			*p = append(*p, path)
+		}
+	}
+	return nil
+}
+
+// Load every plugin, so that configurations can name what they register.
+func (p plugins) load() error {
+	for _, path := range p {
+		if err := loadPlugin(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Open a Go plugin, a main package built with
+//
+//	go build -buildmode=plugin
+//
+// against the same version of the zitraders package, and register the
+// strategies and institutions it exports. A plugin may call RegisterStrategy
+// and RegisterMarket from an init function, or export either of
+//
+//	var Strategies = map[string]zitraders.StrategyFactory{...}
+//	var Markets = map[string]zitraders.MarketFactory{...}
+//
+// whose entries are registered under their names. Go supports plugins only
+// on Linux, FreeBSD and macOS, and only in binaries built with cgo.
+func loadPlugin(path string) error {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return fmt.Errorf("plugin %s: %v", path, err)
+	}
+	if sym, err := p.Lookup("Strategies"); err == nil {
+		s, ok := sym.(*map[string]zitraders.StrategyFactory)
+		if !ok {
+			return fmt.Errorf("plugin %s: Strategies is a %T, not a map[string]zitraders.StrategyFactory", path, sym)
+		}
+		for name, f := range *s {
+			zitraders.RegisterStrategy(name, f)
+		}
+	}
+	if sym, err := p.Lookup("Markets"); err == nil {
+		s, ok := sym.(*map[string]zitraders.MarketFactory)
+		if !ok {
+			return fmt.Errorf("plugin %s: Markets is a %T, not a map[string]zitraders.MarketFactory", path, sym)
+		}
+		for name, f := range *s {
+			zitraders.RegisterMarket(name, f)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+)
+
+// Build the plugin in testdata/plugins/name, skipping the test where Go
+// cannot build or load plugins.
+func buildPlugin(t *testing.T, name string) string {
+	t.Helper()
+	switch runtime.GOOS {
+	case "linux", "freebsd", "darwin":
+	default:
+		t.Skipf("no plugins on %s", runtime.GOOS)
+	}
+	if testing.Short() {
+		t.Skip("building a plugin is slow")
+	}
+	if out, err := exec.Command("go", "env", "CGO_ENABLED").Output(); err != nil || strings.TrimSpace(string(out)) != "1" {
+		t.Skip("plugins need cgo")
+	}
+	path := filepath.Join(t.TempDir(), name+".so")
+	cmd := exec.Command("go", "build", "-buildmode=plugin", "-o", path, "./testdata/plugins/"+name)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("cannot build a plugin: %v\n%s", err, out)
+	}
+	return path
+}
+
+// Load a plugin built by buildPlugin, skipping the test if the test binary
+// was built with flags the plugin was not, such as -race or -cover.
+func loadTestPlugin(t *testing.T, path string) error {
+	t.Helper()
+	err := loadPlugin(path)
+	if err != nil && strings.Contains(err.Error(), "different version") {
+		t.Skip(err)
+	}
+	return err
+}
+
+func TestLoadPlugin(t *testing.T) {
+	path := buildPlugin(t, "truthful")
+	if err := loadTestPlugin(t, path); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, name := range zitraders.Strategies() {
+		found = found || name == "plugin-truthful"
+	}
+	if !found {
+		t.Fatalf("strategies %q lack the plugin's", zitraders.Strategies())
+	}
+
+	config := zitraders.DefaultConfig()
+	config.NumBuyers, config.NumSellers, config.MaxNumberOfTrades, config.NumThreads = 100, 100, 2000, 2
+	config.Strategy = "plugin-truthful"
+	m, err := zitraders.New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := m.Run(); r.NumberBought == 0 {
+		t.Error("the plugin's traders made no trades")
+	}
+}
+
+func TestLoadPluginErrors(t *testing.T) {
+	if err := loadPlugin("testdata/missing.so"); err == nil {
+		t.Error("loading a missing plugin succeeded")
+	}
+
+	path := buildPlugin(t, "wrongtype")
+	err := loadTestPlugin(t, path)
+	if err == nil || !strings.Contains(err.Error(), "not a map[string]zitraders.StrategyFactory") {
+		t.Errorf("got error %v, want one naming the type Strategies should have", err)
+	}
+	for _, name := range zitraders.Strategies() {
+		if name == "plugin-wrong" {
+			t.Error("a strategy of the wrong type was registered")
+		}
+	}
+}
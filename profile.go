@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/profile"
+)
+
+// profileMode is the -profile flag, which names the profile to take. It
+// takes a value, -profile cpu, so that a following argument is never mistaken
+// for one; config files may still give true for a CPU profile, as when the
+// flag was a boolean.
+type profileMode string
+
+var profileModes = map[profileMode]func(*profile.Profile){
+	"cpu":   profile.CPUProfile,
+	"mem":   profile.MemProfile,
+	"block": profile.BlockProfile,
+	"mutex": profile.MutexProfile,
+	"trace": profile.TraceProfile,
+}
+
+func (p *profileMode) String() string { return string(*p) }
+
+func (p *profileMode) Set(s string) error {
+	switch s {
+	case "true":
+		s = "cpu"
+	case "false":
+		s = ""
+	}
+	if _, ok := profileModes[profileMode(s)]; !ok && s != "" {
+		return fmt.Errorf("unknown profile %q (want cpu, mem, block, mutex or trace)", s)
+	}
+	*p = profileMode(s)
+	return nil
+}
+
+// UnmarshalText lets config files name the profile in the same way.
+func (p *profileMode) UnmarshalText(text []byte) error {
+	return p.Set(string(text))
+}
+
+// UnmarshalJSON takes a JSON boolean as well as a string, as YAML and TOML
+// files do.
+func (p *profileMode) UnmarshalJSON(data []byte) error {
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		return p.Set(strconv.FormatBool(b))
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("profile %s is neither a string nor a boolean", data)
+	}
+	return p.Set(s)
+}
+
+// start begins profiling into the current directory. The returned value's
+// Stop method writes the profile.
+func (p profileMode) start() interface{ Stop() } {
+	return profile.Start(profileModes[p], profile.ProfilePath("."))
+}
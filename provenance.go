@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"runtime/debug"
+	"time"
+)
+
+// provenance identifies the invocation that produced a set of outputs: a run
+// ID, when it started, the build that ran it and the host it ran on. It is
+// printed with the text results, included in JSON output and database rows,
+// and written with the full options beside every other output file.
+type provenance struct {
+	RunID   string    `json:"run_id"`
+	Started time.Time `json:"started"`
+	Version string    `json:"version"`
+	Commit  string    `json:"commit,omitempty"` // the VCS revision built, with "+dirty" if it had local changes
+	Host    string    `json:"host"`
+}
+
+func newProvenance() provenance {
+	p := provenance{Started: time.Now().UTC()}
+	var id [4]byte
+	rand.Read(id[:])
+	p.RunID = p.Started.Format("20060102T150405Z") + "-" + hex.EncodeToString(id[:])
+	p.Version, p.Commit = buildVersion()
+	p.Host, _ = os.Hostname()
+	return p
+}
+
+// The version of a release build, set with
+// -ldflags "-X main.version=v1.2.3"; otherwise the module version is used.
+var version string
+
+// The version and VCS revision the binary was built from, as far as the
+// build recorded them.
+func buildVersion() (string, string) {
+	v := version
+	if v == "" {
+		v = "unknown"
+		if info, ok := debug.ReadBuildInfo(); ok {
+			v = info.Main.Version
+		}
+	}
+	commit := buildSetting("vcs.revision")
+	if commit != "" && buildSetting("vcs.modified") == "true" {
+		commit += "+dirty"
+	}
+	return v, commit
+}
+
+// A setting recorded by the build, such as vcs.revision, or "" if there is
+// none.
+func buildSetting(key string) string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == key {
+				return s.Value
+			}
+		}
+	}
+	return ""
+}
+
+// Write the provenance of an output file, the seed of its run if there is
+// just one, and the options that produced it to path.meta.json.
+func (o options) stamp(path string, seed int64) error {
+	f, err := os.Create(path + ".meta.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(struct {
+		Provenance provenance `json:"provenance"`
+		Seed       int64      `json:"seed,omitempty"`
+		Options    options    `json:"options"`
+	}{o.run, seed, o})
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
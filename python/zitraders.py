@@ -0,0 +1,83 @@
+"""Python bindings to the zero-intelligence traders model.
+
+The model runs in Go, in the C shared library built from ../libzitraders:
+
+    go build -buildmode=c-shared -o python/libzitraders.so ./libzitraders
+
+The library is looked for beside this module unless ZITRADERS_LIB names it.
+Configurations are dicts with the fields of a JSON config file, and results
+are dicts with the fields of the CLI's JSON results:
+
+    import zitraders
+
+    r = zitraders.run({"num_buyers": 1000, "num_sellers": 1000, "seed": 1})
+    print(r["mean_price"], r["efficiency"])
+
+    prices = []
+    zitraders.run({"seed": 1}, on_trade=lambda t: prices.append(t["price"]))
+"""
+
+import ctypes
+import json
+import os
+import sys
+
+__all__ = ["run", "trades"]
+
+TRADE_FIELDS = ("period", "tick", "thread", "buyer", "seller", "bid", "ask", "price", "market")
+
+_TradeFn = ctypes.CFUNCTYPE(ctypes.c_int, *[ctypes.c_int] * len(TRADE_FIELDS))
+_lib = None
+
+
+def _library():
+    global _lib
+    if _lib is None:
+        path = os.environ.get("ZITRADERS_LIB")
+        if not path:
+            ext = {"darwin": ".dylib", "win32": ".dll"}.get(sys.platform, ".so")
+            path = os.path.join(os.path.dirname(os.path.abspath(__file__)), "libzitraders" + ext)
+        lib = ctypes.CDLL(path)
+        lib.zt_run.argtypes = [ctypes.c_char_p, _TradeFn]
+        lib.zt_run.restype = ctypes.c_void_p
+        lib.zt_free.argtypes = [ctypes.c_void_p]
+        lib.zt_free.restype = None
+        _lib = lib
+    return _lib
+
+
+def run(config=None, on_trade=None):
+    """Run the model and return its results.
+
+    on_trade, if given, is called with a dict of every trade as the run goes,
+    at the end of the tick in which it was made; returning False stops the
+    run. An exception it raises stops the run and is raised again here.
+    """
+    lib = _library()
+    failed = []
+
+    def trade(*fields):
+        try:
+            return 1 if on_trade(dict(zip(TRADE_FIELDS, fields))) is False else 0
+        except BaseException as e:
+            failed.append(e)
+            return 1
+
+    callback = _TradeFn(trade) if on_trade else _TradeFn()
+    p = lib.zt_run(json.dumps(config or {}).encode(), callback)
+    try:
+        results = json.loads(ctypes.string_at(p).decode())
+    finally:
+        lib.zt_free(p)
+    if failed:
+        raise failed[0]
+    if "error" in results:
+        raise ValueError(results["error"])
+    return results
+
+
+def trades(config=None):
+    """Run the model and return its results and a list of its trades."""
+    log = []
+    results = run(config, on_trade=log.append)
+    return results, log
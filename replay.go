@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+)
+
+// Replay the trade log named by args against a fresh population, drawn with
+// the run's configuration and seed, and report the statistics of the replayed
+// trades. With -resume, the population is the checkpoint's and the trades it
+// had executed are replayed and checked against the agents it saved.
+func replay(opts options, args []string) {
+	if len(args) != 1 {
+		fatal(fmt.Errorf("usage: zi-traders replay [flags] trades.csv"))
+	}
+	trades, err := readTrades(args[0])
+	if err != nil {
+		fatal(err)
+	}
+
+	config := opts.Config
+	var saved *zitraders.Model
+	if opts.Resume != "" {
+		if saved, err = resume(opts.Resume); err != nil {
+			fatal(err)
+		}
+		config = saved.Config
+		n := saved.Progress().Trades
+		if n > int64(len(trades)) {
+			fatal(fmt.Errorf("the checkpoint had executed %d trades, but the log has only %d", n, len(trades)))
+		}
+		trades = trades[:n]
+	} else if config.Seed == 0 {
+		fatal(fmt.Errorf("replay needs the -seed of the run that wrote the trade log"))
+	}
+
+	m, err := zitraders.New(config)
+	if err != nil {
+		fatal(err)
+	}
+	r, err := m.Replay(trades)
+	if err != nil {
+		fatal(fmt.Errorf("%s: %v", args[0], err))
+	}
+	if saved != nil {
+		if err := sameAgents(m, saved); err != nil {
+			fatal(fmt.Errorf("the trade log does not match the checkpoint: %v", err))
+		}
+		slog.Info("the trade log matches the checkpoint", "trades", len(trades))
+	}
+
+	if opts.text() {
+		printResults(r)
+	} else {
+		writeJSON(opts, struct {
+			Provenance provenance        `json:"provenance"`
+			Config     zitraders.Config  `json:"config"`
+			Results    zitraders.Results `json:"results"`
+		}{opts.run, m.Config, r})
+	}
+	if opts.Plots != "" {
+		if err := writePlots(opts.Plots, opts.PlotFormat, trades, m.Curves()); err != nil {
+			fatal(err)
+		}
+		if err := opts.stamp(opts.Plots, m.Seed); err != nil {
+			fatal(err)
+		}
+	}
+}
+
+// Read a trade log from a CSV or, if path ends in .parquet or .arrows, a
+// Parquet file or Arrow IPC stream.
+func readTrades(path string) ([]zitraders.Trade, error) {
+	if isParquet(path) {
+		return readTradesParquet(path)
+	}
+	if isArrow(path) {
+		return readTradesArrow(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	trades, err := zitraders.ReadTradesCSV(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return trades, nil
+}
+
+// Check that two models of the same configuration have agents holding the
+// same units at the same values and last prices.
+func sameAgents(a, b *zitraders.Model) error {
+	var want []zitraders.AgentState
+	b.EachAgent(func(x zitraders.AgentState) { want = append(want, x) })
+	i := 0
+	var err error
+	a.EachAgent(func(x zitraders.AgentState) {
+		if err == nil && x != want[i] {
+			err = fmt.Errorf("%s %d is %+v after the replay, %+v in the checkpoint", x.Side, x.Agent, x, want[i])
+		}
+		i++
+	})
+	return err
+}
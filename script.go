@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"strings"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+	starjson "go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// A scripts flag sets the Starlark scripts to load strategies and shock
+// schedules from, written as "a.star,b.star".
+type scripts []string
+
+func (s *scripts) String() string     { return (*plugins)(s).String() }
+func (s *scripts) Set(v string) error { return (*plugins)(s).Set(v) }
+
+// Load every script, registering its strategy and, given a configuration,
+// adding its shocks to it.
+func (s scripts) load(c *zitraders.Config) error {
+	for _, path := range s {
+		sc, err := loadScript(path)
+		if err != nil {
+			return err
+		}
+		sc.register()
+		if c == nil {
+			continue
+		}
+		shocks, err := sc.shocks(*c)
+		if err != nil {
+			return err
+		}
+		c.Shocks = append(c.Shocks, shocks...)
+	}
+	return nil
+}
+
+// A script is a Starlark file defining a strategy, a shock schedule or both.
+// The strategy is named after the file, without its extension.
+type script struct {
+	name    string
+	globals starlark.StringDict
+}
+
+// Builtins of every script: the JSON module and random draws from the
+// generator of the trader quoting.
+var scriptBuiltins = starlark.StringDict{
+	"json":    starjson.Module,
+	"randint": starlark.NewBuiltin("randint", randint),
+	"random":  starlark.NewBuiltin("random", random),
+}
+
+func loadScript(path string) (*script, error) {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	thread := &starlark.Thread{Name: name}
+	opts := &syntax.FileOptions{Set: true, While: true, TopLevelControl: true, GlobalReassign: true, Recursion: true}
+	globals, err := starlark.ExecFileOptions(opts, thread, path, nil, scriptBuiltins)
+	if err != nil {
+		return nil, fmt.Errorf("script %s: %v", path, err)
+	}
+	return &script{name: name, globals: globals}, nil
+}
+
+// The script's function called name, or nil if it has none.
+func (s *script) function(name string) starlark.Callable {
+	f, _ := s.globals[name].(starlark.Callable)
+	return f
+}
+
+// Register the script's strategy, if it defines bid and ask functions. It is
+// a Learner if it also defines traded or missed.
+func (s *script) register() {
+	bid, ask := s.function("bid"), s.function("ask")
+	if bid == nil || ask == nil {
+		return
+	}
+	traded, missed := s.function("traded"), s.function("missed")
+	zitraders.RegisterStrategy(s.name, func(buyer bool, _ *rand.Rand) zitraders.Strategy {
+		st := &scriptStrategy{name: s.name, bid: bid, ask: ask, buyer: buyer,
+			thread: &starlark.Thread{Name: s.name}, state: new(starlark.Dict)}
+		if traded != nil || missed != nil {
+			return &scriptLearner{st, traded, missed}
+		}
+		return st
+	})
+}
+
+// The shocks the script's shocks function schedules, given the configuration
+// as a dict of its JSON fields and with randint and random drawing from a
+// generator seeded with the configuration's seed. It returns a list of dicts
+// with the fields of a shock in a config file.
+func (s *script) shocks(c zitraders.Config) ([]zitraders.Shock, error) {
+	f := s.function("shocks")
+	if f == nil {
+		return nil, nil
+	}
+	thread := &starlark.Thread{Name: s.name}
+	thread.SetLocal("rand", rand.New(rand.NewSource(c.Seed)))
+	config, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	arg, err := starlark.Call(thread, starjson.Module.Members["decode"], starlark.Tuple{starlark.String(config)}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("script %s: %v", s.name, err)
+	}
+	v, err := starlark.Call(thread, f, starlark.Tuple{arg}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("script %s: %v", s.name, err)
+	}
+	if v == starlark.None {
+		return nil, nil
+	}
+	encoded, err := starlark.Call(thread, starjson.Module.Members["encode"], starlark.Tuple{v}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("script %s: shocks: %v", s.name, err)
+	}
+	var shocks []zitraders.Shock
+	d := json.NewDecoder(bytes.NewReader([]byte(encoded.(starlark.String))))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&shocks); err != nil {
+		return nil, fmt.Errorf("script %s: shocks: %v", s.name, err)
+	}
+	return shocks, nil
+}
+
+// A scriptStrategy quotes by calling a script's bid and ask functions with a
+// view of the market: a struct of the fields of a MarketView, in snake case,
+// plus whether the trader is a buyer and a dict it may keep state in.
+type scriptStrategy struct {
+	name     string
+	bid, ask starlark.Callable
+	buyer    bool
+	thread   *starlark.Thread
+	state    *starlark.Dict
+}
+
+func (s *scriptStrategy) Bid(ctx zitraders.MarketView) int { return s.quote(s.bid, ctx) }
+func (s *scriptStrategy) Ask(ctx zitraders.MarketView) int { return s.quote(s.ask, ctx) }
+
+func (s *scriptStrategy) view(ctx zitraders.MarketView) *starlarkstruct.Struct {
+	return starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"value":            starlark.MakeInt(ctx.Value),
+		"max_buyer_value":  starlark.MakeInt(ctx.MaxBuyerValue),
+		"max_seller_value": starlark.MakeInt(ctx.MaxSellerValue),
+		"max_price":        starlark.MakeInt(ctx.MaxPrice()),
+		"bid":              starlark.MakeInt(ctx.Bid),
+		"ask":              starlark.MakeInt(ctx.Ask),
+		"time":             starlark.MakeInt(ctx.Time),
+		"end":              starlark.MakeInt(ctx.End),
+		"buyer":            starlark.Bool(s.buyer),
+		"state":            s.state,
+	})
+}
+
+// Call f with the view and any further arguments. A script that fails
+// mid-run ends it.
+func (s *scriptStrategy) call(f starlark.Callable, ctx zitraders.MarketView, args ...starlark.Value) starlark.Value {
+	s.thread.SetLocal("rand", ctx.Rand)
+	v, err := starlark.Call(s.thread, f, append(starlark.Tuple{s.view(ctx)}, args...), nil)
+	if err != nil {
+		fatal(fmt.Errorf("script %s: %v", s.name, err))
+	}
+	return v
+}
+
+// A quote of None or 0 keeps the trader out of the market.
+func (s *scriptStrategy) quote(f starlark.Callable, ctx zitraders.MarketView) int {
+	v := s.call(f, ctx)
+	if v == starlark.None {
+		return zitraders.NoQuote
+	}
+	q, err := starlark.AsInt32(v)
+	if err != nil {
+		fatal(fmt.Errorf("script %s: %s returned %s, not a price", s.name, f.Name(), v.Type()))
+	}
+	return q
+}
+
+type scriptLearner struct {
+	*scriptStrategy
+	traded, missed starlark.Callable
+}
+
+func (s *scriptLearner) Traded(ctx zitraders.MarketView, price int) {
+	if s.traded != nil {
+		s.call(s.traded, ctx, starlark.MakeInt(price))
+	}
+}
+
+func (s *scriptLearner) Missed(ctx zitraders.MarketView, quote int) {
+	if s.missed != nil {
+		s.call(s.missed, ctx, starlark.MakeInt(quote))
+	}
+}
+
+// randint(a, b) draws an integer from a to b inclusive, as Python's
+// random.randint does.
+func randint(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var lo, hi int
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &lo, &hi); err != nil {
+		return nil, err
+	}
+	if hi < lo {
+		return nil, fmt.Errorf("%s: empty range %d to %d", b.Name(), lo, hi)
+	}
+	return starlark.MakeInt(lo + scriptRand(thread).Intn(hi-lo+1)), nil
+}
+
+// random() draws a float from [0, 1).
+func random(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 0); err != nil {
+		return nil, err
+	}
+	return starlark.Float(scriptRand(thread).Float64()), nil
+}
+
+// The generator of the trader quoting or, for a shock schedule, one seeded
+// with the run's seed; a script's top level draws from a fixed one.
+func scriptRand(thread *starlark.Thread) *rand.Rand {
+	if r, ok := thread.Local("rand").(*rand.Rand); ok {
+		return r
+	}
+	r := rand.New(rand.NewSource(1))
+	thread.SetLocal("rand", r)
+	return r
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+)
+
+// Run states reported by the server.
+const (
+	created  = "created"
+	running  = "running"
+	finished = "finished"
+	deleted  = "deleted"
+)
+
+// A run is a model held by the server.
+type run struct {
+	id    int
+	model *zitraders.Model
+	stop  int32 // set to stop the run at the end of its current tick
+
+	mu      sync.Mutex
+	state   string
+	results *zitraders.Results
+}
+
+// The status of a run as reported by the server.
+type runStatus struct {
+	ID       int                `json:"id"`
+	State    string             `json:"state"`
+	Config   zitraders.Config   `json:"config"`
+	Progress zitraders.Progress `json:"progress"`
+}
+
+func (r *run) status() runStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return runStatus{ID: r.id, State: r.state, Config: r.model.Config, Progress: r.model.Progress()}
+}
+
+// A server holds models created over HTTP and runs them in the background.
+type server struct {
+	mu   sync.Mutex
+	next int
+	runs map[int]*run
+}
+
+// Serve the REST API:
+//
+//	GET    /runs              list runs
+//	POST   /runs              create a model from a JSON config
+//	GET    /runs/{id}         poll a run's status and progress
+//	DELETE /runs/{id}         discard a run that is not running
+//	POST   /runs/{id}/start   start a run in the background
+//	POST   /runs/{id}/stop    stop a run at the end of its current tick
+//	GET    /runs/{id}/results fetch the results of a finished run
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	var p plugins
+	fs.Var(&p, "plugin", "load strategies and institutions from these Go plugins")
+	var sc scripts
+	fs.Var(&sc, "script", "load strategies from these Starlark scripts")
+	var l logging
+	l.flags(fs)
+	fs.Parse(args)
+	if err := l.start(); err != nil {
+		fatal(err)
+	}
+	if err := p.load(); err != nil {
+		fatal(err)
+	}
+	if err := sc.load(nil); err != nil {
+		fatal(err)
+	}
+
+	s := &server{runs: make(map[int]*run)}
+	http.HandleFunc("/runs", s.handleRuns)
+	http.HandleFunc("/runs/", s.handleRun)
+	slog.Info("serving", "addr", *addr)
+	fatal(http.ListenAndServe(*addr, nil))
+}
+
+func (s *server) handleRuns(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		// A run's lock is never taken while holding the server's.
+		s.mu.Lock()
+		runs := make([]*run, 0, len(s.runs))
+		for id := 1; id <= s.next; id++ {
+			if r, ok := s.runs[id]; ok {
+				runs = append(runs, r)
+			}
+		}
+		s.mu.Unlock()
+		list := make([]runStatus, len(runs))
+		for i, r := range runs {
+			list[i] = r.status()
+		}
+		reply(w, http.StatusOK, list)
+
+	case http.MethodPost:
+		config := zitraders.DefaultConfig()
+		d := json.NewDecoder(req.Body)
+		d.DisallowUnknownFields()
+		if err := d.Decode(&config); err != nil {
+			fail(w, http.StatusBadRequest, err)
+			return
+		}
+		// Runs are stopped between ticks, so they need some.
+		if config.TickSize == 0 {
+			config.TickSize = 10000
+		}
+		m, err := zitraders.New(config)
+		if err != nil {
+			fail(w, http.StatusBadRequest, err)
+			return
+		}
+
+		// The observer is registered before the run can be started.
+		r := &run{model: m, state: created}
+		m.Observe(func(*zitraders.Model, zitraders.Tick) bool {
+			return atomic.LoadInt32(&r.stop) == 0
+		})
+		s.mu.Lock()
+		s.next++
+		r.id = s.next
+		s.runs[r.id] = r
+		s.mu.Unlock()
+		reply(w, http.StatusCreated, r.status())
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *server) handleRun(w http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/runs/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || len(parts) > 2 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	s.mu.Lock()
+	r, ok := s.runs[id]
+	s.mu.Unlock()
+	if !ok {
+		fail(w, http.StatusNotFound, fmt.Errorf("no run %d", id))
+		return
+	}
+
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+	switch {
+	case action == "" && req.Method == http.MethodGet:
+		reply(w, http.StatusOK, r.status())
+
+	case action == "" && req.Method == http.MethodDelete:
+		// Marked deleted, the run can no longer be started, and it leaves
+		// the map once its own lock is released.
+		r.mu.Lock()
+		if r.state == running {
+			r.mu.Unlock()
+			fail(w, http.StatusConflict, fmt.Errorf("run %d is running", id))
+			return
+		}
+		r.state = deleted
+		r.mu.Unlock()
+		s.mu.Lock()
+		delete(s.runs, id)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+
+	case action == "start" && req.Method == http.MethodPost:
+		r.mu.Lock()
+		if r.state == deleted {
+			r.mu.Unlock()
+			fail(w, http.StatusNotFound, fmt.Errorf("no run %d", id))
+			return
+		}
+		if r.state != created {
+			r.mu.Unlock()
+			fail(w, http.StatusConflict, fmt.Errorf("run %d has already been started", id))
+			return
+		}
+		r.state = running
+		r.mu.Unlock()
+		go func() {
+			results := r.model.Run()
+			r.mu.Lock()
+			r.state, r.results = finished, &results
+			r.mu.Unlock()
+		}()
+		reply(w, http.StatusAccepted, r.status())
+
+	case action == "stop" && req.Method == http.MethodPost:
+		atomic.StoreInt32(&r.stop, 1)
+		reply(w, http.StatusAccepted, r.status())
+
+	case action == "results" && req.Method == http.MethodGet:
+		r.mu.Lock()
+		results := r.results
+		r.mu.Unlock()
+		if results == nil {
+			fail(w, http.StatusConflict, fmt.Errorf("run %d has not finished", id))
+			return
+		}
+		reply(w, http.StatusOK, results)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// Write v as the JSON body of a response.
+func reply(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("writing response", "err", err)
+	}
+}
+
+// Report an error as a JSON response.
+func fail(w http.ResponseWriter, code int, err error) {
+	reply(w, code, struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+)
+
+// Stop a run at the end of its current tick when the process receives SIGINT
+// or SIGTERM, so that its statistics cover the trades made so far and its
+// output is still written. A second SIGINT kills the process. The returned
+// function stops watching for signals.
+func stopOnSignal(m *zitraders.Model) func() {
+	var stop int32
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for s := range signals {
+			slog.Warn("stopping at the end of the tick", "signal", s.String())
+			atomic.StoreInt32(&stop, 1)
+			signal.Stop(signals)
+		}
+	}()
+	m.Observe(func(m *zitraders.Model, t zitraders.Tick) bool {
+		return atomic.LoadInt32(&stop) == 0
+	})
+	return func() { signal.Stop(signals) }
+}
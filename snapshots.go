@@ -0,0 +1,60 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+)
+
+// Write a snapshot of the population into dir, as gzipped CSV or Parquet, at
+// the end of the first tick after every further `every` trades, and at the
+// end of the run. Each snapshot is named after the number of attempts made
+// when it was taken.
+func watchSnapshots(m *zitraders.Model, dir, format string, every int64) error {
+	write, ext := writeSnapshot, ".csv.gz"
+	switch format {
+	case "", "csv":
+	case "parquet":
+		write, ext = writeSnapshotParquet, ".parquet"
+	default:
+		return fmt.Errorf("unknown snapshot format %q", format)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	next := every
+	m.Observe(func(m *zitraders.Model, t zitraders.Tick) bool {
+		if (every > 0 && t.Trades >= next) || t.Final {
+			path := filepath.Join(dir, fmt.Sprintf("snapshot-%012d%s", t.Attempts, ext))
+			if err := write(m, path); err != nil {
+				slog.Error("snapshot failed", "path", path, "err", err)
+			}
+			for every > 0 && next <= t.Trades {
+				next += every
+			}
+		}
+		return true
+	})
+	return nil
+}
+
+func writeSnapshot(m *zitraders.Model, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	z := gzip.NewWriter(f)
+	if err := m.WriteSnapshot(z); err != nil {
+		f.Close()
+		return err
+	}
+	if err := z.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
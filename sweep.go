@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+)
+
+// The names of the swept parameters, in order, and the cells of the design.
+func (o options) cells() ([]string, []zitraders.Cell, error) {
+	names := make([]string, 0, len(o.Sweep))
+	for name := range o.Sweep {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	factors := make([]zitraders.Factor, len(names))
+	for i, name := range names {
+		factors[i] = zitraders.Factor{Param: name, Levels: o.Sweep[name].Levels()}
+	}
+	cells, err := zitraders.Factorial(o.Config, factors)
+	return names, cells, err
+}
+
+// Run the full factorial design declared in the sweep section of the config
+// and write one row of summary statistics per cell.
+func sweep(ctx context.Context, opts options) {
+	if opts.CRN && opts.Seed == 0 {
+		opts.Seed = time.Now().UnixNano()
+	}
+	names, cells, err := opts.cells()
+	if err != nil {
+		fatal(err)
+	}
+
+	var w io.Writer = os.Stdout
+	if opts.SweepOut != "" {
+		f, err := os.Create(opts.SweepOut)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	cw := csv.NewWriter(w)
+	header := append(names, "reps", "quantity", "quantity_sd", "mean_price", "mean_price_sd", "efficiency", "efficiency_sd")
+	cw.Write(header)
+
+	reps := opts.Reps
+	if reps < 1 {
+		reps = 1
+	}
+	// Remote workers are sent every cell's replications at once.
+	var remote [][]zitraders.Results
+	if len(opts.Remote) > 0 && opts.Antithetic {
+		fatal(fmt.Errorf("-antithetic cannot be combined with -remote"))
+	}
+	if len(opts.Remote) > 0 {
+		configs := make([]zitraders.Config, len(cells))
+		for k, c := range cells {
+			configs[k] = c.Config
+		}
+		opts.Reps = reps
+		if remote, err = replicateRemotely(ctx, opts, configs); err != nil {
+			fatal(err)
+		}
+	}
+	for k, c := range cells {
+		var results []zitraders.Results
+		switch {
+		case remote != nil:
+			results = remote[k]
+		case opts.Antithetic:
+			results, err = zitraders.ReplicateAntithetic(ctx, c.Config, reps, 1, track)
+		default:
+			results, err = zitraders.ReplicateContext(ctx, c.Config, reps, track)
+		}
+		if err != nil {
+			fatal(err)
+		}
+		if len(results) == 0 {
+			break
+		}
+		if opts.DB != "" {
+			factors := make(map[string]float64, len(names))
+			for i, name := range names {
+				factors[name] = c.Levels[i]
+			}
+			runs := make([]dbRun, len(results))
+			for i, r := range results {
+				runs[i] = dbRun{mode: "sweep", cell: k + 1, rep: i + 1, factors: factors, config: c.Config, results: r}
+			}
+			if err := record(opts.DB, opts.run, runs...); err != nil {
+				fatal(err)
+			}
+		}
+		s := zitraders.Summarize(results)
+		if opts.Antithetic {
+			s = zitraders.SummarizePairs(results)
+		}
+		row := make([]string, 0, len(header))
+		for _, v := range c.Levels {
+			row = append(row, strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		row = append(row,
+			strconv.Itoa(s.Reps),
+			formatFloat(s.Quantity.Mean), formatFloat(s.Quantity.SD),
+			formatFloat(s.MeanPrice.Mean), formatFloat(s.MeanPrice.SD),
+			formatFloat(s.Efficiency.Mean), formatFloat(s.Efficiency.SD))
+		cw.Write(row)
+		cw.Flush()
+		if ctx.Err() != nil {
+			slog.Warn("timed out; the last cell was cut short", "completed", k+1, "cells", len(cells))
+			break
+		}
+	}
+	if err := cw.Error(); err != nil {
+		fatal(err)
+	}
+	if opts.SweepOut != "" {
+		if err := opts.stamp(opts.SweepOut, 0); err != nil {
+			fatal(err)
+		}
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 4, 64)
+}
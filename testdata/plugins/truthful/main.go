@@ -0,0 +1,19 @@
+// A plugin exporting a strategy that quotes its trader's value.
+package main
+
+import (
+	"math/rand"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+)
+
+type truthful struct{}
+
+func (truthful) Bid(ctx zitraders.MarketView) int { return ctx.Value }
+func (truthful) Ask(ctx zitraders.MarketView) int { return ctx.Value }
+
+var Strategies = map[string]zitraders.StrategyFactory{
+	"plugin-truthful": func(bool, *rand.Rand) zitraders.Strategy { return truthful{} },
+}
+
+func main() {}
@@ -0,0 +1,6 @@
+// A plugin exporting Strategies of the wrong type.
+package main
+
+var Strategies = map[string]string{"plugin-wrong": "zi-c"}
+
+func main() {}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+)
+
+const (
+	tuiRefresh = 250 * time.Millisecond
+	tuiWidth   = 60 // prices kept for the sparkline
+)
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Redraw a live dashboard of the model's progress on the terminal until done
+// is closed, then draw it once more and close drawn.
+func dashboard(m *zitraders.Model, done <-chan struct{}, drawn chan<- struct{}) {
+	defer close(drawn)
+	start := time.Now()
+	ticker := time.NewTicker(tuiRefresh)
+	defer ticker.Stop()
+
+	var last zitraders.Progress
+	lastTime := start
+	var rate float64
+	var prices []float64
+	update := func() {
+		p := m.Progress()
+		now := time.Now()
+		rate = float64(p.Trades-last.Trades) / now.Sub(lastTime).Seconds()
+		if p.Trades > last.Trades {
+			prices = append(prices, float64(p.Volume-last.Volume)/float64(p.Trades-last.Trades))
+			if len(prices) > tuiWidth {
+				prices = prices[1:]
+			}
+		}
+		last, lastTime = p, now
+		draw(m, p, rate, prices, now.Sub(start))
+	}
+
+	fmt.Print("\x1b[2J")
+	for {
+		select {
+		case <-done:
+			update()
+			return
+		case <-ticker.C:
+			update()
+		}
+	}
+}
+
+// Draw one frame of the dashboard from the top of the screen.
+func draw(m *zitraders.Model, p zitraders.Progress, rate float64, prices []float64, elapsed time.Duration) {
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	line := func(format string, a ...interface{}) {
+		fmt.Fprintf(&b, format+"\x1b[K\n", a...)
+	}
+	line("ZERO INTELLIGENCE TRADERS  seed %d  %s elapsed", m.Seed, elapsed.Round(time.Second))
+	line("")
+	line("attempts   %12d of %d (%.1f%%)", p.Attempts, m.MaxNumberOfTrades, 100*float64(p.Attempts)/float64(m.MaxNumberOfTrades))
+	line("trades     %12d (%.3f%% of attempts)", p.Trades, 100*float64(p.Trades)/math.Max(1, float64(p.Attempts)))
+	line("trades/sec %12.0f", rate)
+	line("goroutines %12d of %d trading", p.Running, m.NumThreads)
+	line("price      %12.3f mean, %.3f s.d.", p.MeanPrice(), p.SDPrice())
+	line("")
+	line("recent prices %s", sparkline(prices))
+	os.Stdout.WriteString(b.String())
+}
+
+// A sparkline of the values scaled between their minimum and maximum.
+func sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	low, high := values[0], values[0]
+	for _, v := range values {
+		low, high = math.Min(low, v), math.Max(high, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		k := len(sparks) - 1
+		if high > low {
+			k = int((v - low) / (high - low) * float64(len(sparks)-1))
+		}
+		b.WriteRune(sparks[k])
+	}
+	fmt.Fprintf(&b, " (%.2f to %.2f)", low, high)
+	return b.String()
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sdmccabe/zi-traders-go/zitraders"
+)
+
+//go:embed web/index.html
+var indexPage []byte
+
+const webRefresh = 250 * time.Millisecond
+
+// A sample is one point of the live charts.
+type sample struct {
+	Elapsed   float64 `json:"elapsed"` // seconds since the run started
+	Attempts  int64   `json:"attempts"`
+	Trades    int64   `json:"trades"`
+	MeanPrice float64 `json:"mean_price"` // over the whole run so far
+	Price     float64 `json:"price"`      // over the trades since the last sample
+	Volume    int64   `json:"volume"`     // trades since the last sample
+	Done      bool    `json:"done"`
+}
+
+// A feed samples a running model and keeps every sample so that browsers
+// connecting late still see the whole run.
+type feed struct {
+	EquilibriumPrice  float64 `json:"equilibrium_price"`
+	MaxNumberOfTrades int     `json:"max_number_of_trades"`
+
+	mu      sync.Mutex
+	samples []sample
+}
+
+// Sample the model until done is closed.
+func (f *feed) run(m *zitraders.Model, done <-chan struct{}) {
+	start := time.Now()
+	ticker := time.NewTicker(webRefresh)
+	defer ticker.Stop()
+	var last zitraders.Progress
+	for {
+		finished := false
+		select {
+		case <-done:
+			finished = true
+		case <-ticker.C:
+		}
+		p := m.Progress()
+		s := sample{
+			Elapsed:   time.Since(start).Seconds(),
+			Attempts:  p.Attempts,
+			Trades:    p.Trades,
+			MeanPrice: p.MeanPrice(),
+			Volume:    p.Trades - last.Trades,
+			Done:      finished,
+		}
+		if s.Volume > 0 {
+			s.Price = float64(p.Volume-last.Volume) / float64(s.Volume)
+		}
+		last = p
+		f.mu.Lock()
+		f.samples = append(f.samples, s)
+		f.mu.Unlock()
+		if finished {
+			return
+		}
+	}
+}
+
+// The samples from the k-th on.
+func (f *feed) since(k int) []sample {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]sample(nil), f.samples[k:]...)
+}
+
+var upgrader = websocket.Upgrader{}
+
+// Serve the dashboard page and the sample stream at addr in the background.
+func serveWeb(addr string, f *feed) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(indexPage)
+	})
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, req *http.Request) {
+		conn, err := upgrader.Upgrade(w, req, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if err := conn.WriteJSON(f); err != nil {
+			return
+		}
+		for k := 0; ; {
+			samples := f.since(k)
+			for _, s := range samples {
+				if err := conn.WriteJSON(s); err != nil {
+					return
+				}
+				if s.Done {
+					return
+				}
+			}
+			k += len(samples)
+			time.Sleep(webRefresh)
+		}
+	})
+	go func() {
+		fatal(http.ListenAndServe(addr, mux))
+	}()
+}
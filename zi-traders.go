@@ -9,174 +9,906 @@ package main
 // Gode and Sunder, QJE, 1993
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
-	"github.com/grd/stat"
-	"github.com/pkg/profile"
-	"math/rand"
-	"runtime"
-	"sync"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
+
+	"github.com/sdmccabe/zi-traders-go/zitraders"
 )
 
-//globals
-var numBuyers = 1200000
-var numSellers = 1200000
-var maxBuyerValue = 30
-var maxSellerValue = 30
-var maxNumberOfTrades = 100000000
-var numThreads int
-var buyersPerThread int
-var sellersPerThread int
-var tradesPerThread int
-var buyers []agent
-var sellers []agent
-var verbose bool
-var profiling bool
+func main() {
+	if err := commands().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// Parse the flags of a command that runs the model, which are the same for
+// run, batch, sweep and replay, and carry it out.
+func runCommand(command string, args []string) {
+	flag.CommandLine.Init("zi-traders "+command, flag.ExitOnError)
+	opts := options{Config: zitraders.DefaultConfig()}
+	var configPath string
+	flag.StringVar(&configPath, "config", "", "load parameters from a JSON, YAML or TOML file")
+	flag.IntVar(&opts.NumThreads, "p", opts.NumThreads, "number of goroutine to use")
+	flag.IntVar(&opts.NumBuyers, "buyers", opts.NumBuyers, "number of buyers")
+	flag.IntVar(&opts.NumSellers, "sellers", opts.NumSellers, "number of sellers")
+	flag.IntVar(&opts.MaxBuyerValue, "max-buyer-value", opts.MaxBuyerValue, "maximum buyer valuation")
+	flag.IntVar(&opts.MaxSellerValue, "max-seller-value", opts.MaxSellerValue, "maximum seller cost")
+	flag.Float64Var(&opts.PriceTick, "price-tick", 0, "the currency value of one tick, in which values, costs and prices are counted (0 for 1)")
+	flag.IntVar(&opts.Units, "units", opts.Units, "units demanded by each buyer and supplied by each seller")
+	flag.IntVar(&opts.SellerUnits, "seller-units", 0, "units supplied by each seller (0 for -units)")
+	flag.IntVar(&opts.MaxNumberOfTrades, "trades", opts.MaxNumberOfTrades, "number of trade attempts")
+	flag.IntVar(&opts.Periods, "periods", 1, "trading periods of -trades attempts each, with endowments restored between them")
+	flag.Int64Var(&opts.Seed, "seed", 0, "random seed (0 seeds from the clock)")
+	flag.BoolVar(&opts.Antithetic, "antithetic", false, "draw from the complements of the seed's streams; with -reps, run each replication as an antithetic pair")
+	flag.BoolVar(&opts.CRN, "crn", false, "run every sweep cell on the same seeds, drawing one if -seed is 0")
+	flag.StringVar(&opts.Matching, "matching", opts.Matching, "matching mode: partitioned, global or pool")
+	flag.BoolVar(&opts.Batched, "batched", false, "draw each goroutine's pairs and quotes in batches, a faster loop for plain ZI-C markets with its own random stream")
+	flag.BoolVar(&opts.FirstTouch, "first-touch", false, "zero each goroutine's partition of the agents from a goroutine of its own, to spread them over NUMA nodes")
+	flag.StringVar(&opts.Transfer, "transfer", opts.Transfer, "how units change hands under global or pool matching: claim, cas or mutex")
+	flag.StringVar(&opts.Sampler, "sampler", zitraders.Random, "how each attempt's buyer and seller are chosen: "+strings.Join(zitraders.Samplers(), ", "))
+	flag.StringVar(&opts.Layout, "layout", opts.Layout, "agent storage layout: aos, soa or compact (single-unit bilateral markets only)")
+	flag.BoolVar(&opts.SharedBudget, "shared-budget", false, "draw trade attempts from a budget shared by the goroutines (bilateral markets with global matching; not reproducible)")
+	flag.StringVar(&opts.Institution, "market", opts.Institution, "market institution: "+strings.Join(zitraders.Institutions(), ", "))
+	flag.IntVar(&opts.CallRound, "call-round", opts.CallRound, "quotes collected per call market round")
+	flag.Float64Var(&opts.BuyerArrival, "buyer-arrival", 0, "the chance each cda or call market quote is a buyer's (0 for one half)")
+	flag.Float64Var(&opts.Latency, "latency", 0, "mean delay, in attempts, before a slow trader's quote reaches the cda order book")
+	flag.Float64Var(&opts.FastShare, "fast-share", 0, "share of traders whose quotes reach the order book at once under -latency")
+	flag.IntVar(&opts.OrderLifetime, "order-lifetime", 0, "attempts a cda order stands in the book before it expires (0 for good till the close)")
+	flag.BoolVar(&opts.Requote, "requote", false, "have traders quote again as soon as their cda order expires")
+	flag.IntVar(&opts.QuoteEvery, "quote-every", 0, "attempts per goroutine between samples of the cda order book (0 for none, or 1000 with -quotes-out)")
+	flag.Float64Var(&opts.Unconstrained, "unconstrained", 0, "share of unconstrained (ZI-U) traders, 1 for an all ZI-U market")
+	flag.Float64Var(&opts.ZIP, "zip", 0, "share of ZIP (zero-intelligence-plus) traders, 1 for an all ZIP market")
+	flag.Float64Var(&opts.GD, "gd", 0, "share of GD (Gjerstad-Dickhaut) traders, 1 for an all GD market")
+	flag.StringVar(&opts.Strategy, "strategy", "zi-c", "strategy of the traders not drawn as another type: "+strings.Join(zitraders.Strategies(), ", "))
+	flag.Var((*population)(&opts.Population), "population", "shares of further strategies by name, e.g. zip=0.2,sniper=0.1")
+	flag.Float64Var(&opts.Sniper, "sniper", 0, "share of Kaplan's sniper traders, 1 for an all sniper market")
+	flag.BoolVar(&opts.Dealer, "dealer", false, "add a market maker to each goroutine's bilateral market")
+	flag.IntVar(&opts.DealerSpread, "dealer-spread", 0, "the market maker's ask less its bid (0 for the default of 2)")
+	flag.IntVar(&opts.DealerInventory, "dealer-inventory", 0, "the most units a market maker may hold (0 for the default of 10)")
+	flag.IntVar(&opts.Markets, "markets", 0, "markets the traders are split into (0 for one)")
+	flag.IntVar(&opts.MarketShift, "market-shift", 0, "added to values and costs in each successive market")
+	flag.BoolVar(&opts.Arbitrage, "arbitrage", false, "add an arbitrageur across markets to each goroutine")
+	flag.IntVar(&opts.ArbitrageMargin, "arbitrage-margin", 0, "the least profit an arbitrageur seeks on a unit (0 for the default of 1)")
+	flag.IntVar(&opts.ArbitrageCapacity, "arbitrage-capacity", 0, "the most units an arbitrageur may hold (0 for the default of 10)")
+	flag.StringVar(&opts.Network.Topology, "network", zitraders.Complete, "the graph traders meet on: complete, ring, small-world, scale-free or edges (global or pool matching only)")
+	flag.IntVar(&opts.Network.Degree, "degree", 0, "sellers each buyer is linked to on a ring, or links each trader adds when scale-free (0 for the default of 4)")
+	flag.Float64Var(&opts.Network.Rewire, "rewire", 0, "the share of a small world's links rewired (0 for the default of 0.1)")
+	flag.StringVar(&opts.Network.File, "edges", "", "read the edges network from this CSV file of buyer and seller indices")
+	flag.IntVar(&opts.Spatial.Width, "grid-width", 0, "place traders on a lattice this many cells wide (global or pool matching only)")
+	flag.IntVar(&opts.Spatial.Height, "grid-height", 0, "the lattice's height in cells")
+	flag.IntVar(&opts.Spatial.Radius, "radius", 0, "cells away in either direction a buyer may meet a seller (0 for the default of 1)")
+	flag.Float64Var(&opts.Spatial.Move, "move", 0, "the probability each trader moves to an adjacent cell between ticks")
+	flag.IntVar(&opts.PriceFloor, "floor", 0, "the lowest legal price (0 for none)")
+	flag.IntVar(&opts.PriceCeiling, "ceiling", 0, "the highest legal price (0 for none)")
+	flag.Float64Var(&opts.Tax, "tax", 0, "a fixed tax on every trade")
+	flag.Float64Var(&opts.TaxRate, "tax-rate", 0, "a tax on every trade as a share of its price")
+	flag.StringVar(&opts.TaxPayer, "tax-payer", zitraders.TaxSplit, "who pays the tax: buyer, seller or split")
+	flag.Var((*shocks)(&opts.Shocks), "shock", "shift buyer values or seller costs during the run, e.g. at=50000000,side=buyers,shift=5 (repeatable)")
+	flag.IntVar(&opts.Memory, "memory", 0, "quotes remembered by GD traders and snipers (0 for the default of 100)")
+	flag.BoolVar(&opts.Verbose, "v", false, "verbose (log the run's progress at info level)")
+	opts.logging.flags(flag.CommandLine)
+	flag.IntVar(&opts.TickSize, "tick", 0, "trade attempts per goroutine between checks of the stopping rules")
+	flag.BoolVar(&opts.StopWhenCleared, "stop-cleared", false, "stop once no mutually beneficial trade remains")
+	flag.Float64Var(&opts.MinTradeRate, "min-trade-rate", 0, "stop once fewer than this share of a tick's attempts trade")
+	flag.IntVar(&opts.VolatilityWindow, "volatility-window", 0, "report price volatility over each window of this many trades")
+	flag.IntVar(&opts.ConvergenceBlock, "block", 0, "report price convergence per block of this many trades")
+	flag.IntVar(&opts.WarmUp, "warm-up", 0, "leave this many trades out of the measurement window")
+	flag.IntVar(&opts.Measure, "measure", 0, "measure this many trades after the warm-up (0 for the rest of the run)")
+	flag.IntVar(&opts.RoundSize, "round", 0, "report the trades of each round of this many attempts per goroutine")
+	flag.StringVar(&opts.RoundsOut, "rounds-out", "", "write the rounds of -round to this CSV file")
+	flag.IntVar(&opts.PriceBins, "price-bins", 10, "report prices in a histogram of this many bins (0 for none)")
+	flag.StringVar(&opts.PriceHistogramOut, "price-histogram-out", "", "write the histogram of prices to this CSV file")
+	flag.StringVar(&opts.LorenzOut, "lorenz-out", "", "write the Lorenz curves of profits, of all traders and of each side, to this CSV file")
+	flag.StringVar(&opts.TradesOut, "trades-out", "", "write the trade log to this CSV file, or Parquet if it ends in .parquet, or stream it as Arrow IPC record batches if it ends in .arrows")
+	flag.StringVar(&opts.QuotesOut, "quotes-out", "", "write samples of the cda order book's best bid, ask and depth to this CSV or Parquet file, or stream them to an .arrows file")
+	flag.StringVar(&opts.RosterOut, "roster-out", "", "write every agent's ID, strategy, market and initial values to this CSV or Parquet file")
+	flag.StringVar(&opts.AgentsOut, "agents-out", "", "write every agent's final state and realized surplus to this CSV or Parquet file")
+	flag.StringVar(&opts.PriceMap, "price-map", "", "write the lattice's mean price by cell to this CSV or JSON file")
+	flag.StringVar(&opts.DB, "db", "", "add the runs and their statistics to this SQLite database")
+	flag.BoolVar(&opts.DBTrades, "db-trades", false, "also add a single run's trades to the -db database")
+	flag.StringVar(&opts.CurvesOut, "curves-out", "", "write the supply and demand curves to this CSV or JSON file")
+	flag.Var(&opts.Timeout, "timeout", "stop after this `duration` of wall-clock time (e.g. 30m), reporting on the trades made until then")
+	flag.Var(&opts.ProgressEvery, "progress-every", "log progress every `duration` (e.g. 10s)")
+	flag.StringVar(&opts.Plots, "plots", "", "draw price, histogram and supply and demand plots into this directory")
+	flag.StringVar(&opts.PlotFormat, "plot-format", "png", "plot file format: png or svg")
+	flag.StringVar(&opts.Web, "web", "", "serve a live web dashboard of a single run at this address (e.g. :8080)")
+	flag.BoolVar(&opts.TUI, "tui", false, "show a live dashboard of a single run in the terminal")
+	flag.StringVar(&opts.MetricsAddr, "metrics-addr", "", "serve Prometheus metrics at this address (e.g. :9090)")
+	flag.StringVar(&opts.Checkpoint, "checkpoint", "", "write checkpoints of a single run to this file on SIGUSR1 and SIGTERM")
+	flag.Var(&opts.CheckpointEvery, "checkpoint-every", "also write a checkpoint every `duration` (e.g. 10m)")
+	flag.StringVar(&opts.Resume, "resume", "", "resume the run saved in this checkpoint file")
+	flag.StringVar(&opts.Snapshots, "snapshots", "", "write snapshots of every agent of a single run into this directory")
+	flag.Int64Var(&opts.SnapshotEvery, "snapshot-every", 0, "take a snapshot after every this many trades, as well as at the end")
+	flag.StringVar(&opts.SnapshotFormat, "snapshot-format", "csv", "snapshot file format: csv (gzipped) or parquet")
+	flag.BoolVar(&opts.Autotune, "autotune", false, "time short warm-up runs with a few numbers of goroutines and use the fastest")
+	flag.IntVar(&opts.AutotuneAttempts, "autotune-attempts", 0, "trade attempts in each warm-up run (0 for a tenth of -trades)")
+	flag.IntVar(&opts.Reps, "reps", 1, "number of replications with different seeds")
+	flag.Float64Var(&opts.Precision, "precision", 0, "run replications until the 95% CI of -precision-of has at most this half-width, with -reps as the most (default 1000)")
+	flag.StringVar(&opts.PrecisionOf, "precision-of", zitraders.OutcomeMeanPrice, "the outcome -precision applies to: mean_price or efficiency")
+	flag.IntVar(&opts.MinReps, "min-reps", 0, "replications to run at least under -precision (0 for 5)")
+	flag.IntVar(&opts.Bootstrap, "bootstrap", 0, "bootstrap resamples for confidence intervals of a run's statistics or of a summary of replications (compare draws 1000 if 0)")
+	flag.IntVar(&opts.BootstrapBlock, "bootstrap-block", 0, "trades in each block the bootstrap of a single run resamples (0 for the cube root of the trades)")
+	flag.IntVar(&opts.Workers, "workers", 0, "replications run at once by the batch subcommand (0 for the number of CPUs over -p)")
+	flag.Var(&opts.Plugins, "plugin", "load strategies and institutions from these Go plugins, e.g. ./mine.so")
+	flag.Var(&opts.Scripts, "script", "load a strategy and shock schedule from these Starlark scripts, e.g. ./mine.star")
+	flag.Var((*addresses)(&opts.Remote), "remote", "send replications and sweep cells to these gRPC workers, e.g. host1:9000,host2:9000")
+	flag.IntVar(&opts.RemoteSlots, "remote-slots", 1, "replications sent to each -remote worker at once")
+	flag.StringVar(&opts.BatchOut, "batch-out", "", "write each batch replication's statistics to this CSV or JSON file")
+	flag.StringVar(&opts.SweepOut, "sweep-out", "", "write sweep results to this CSV file instead of stdout")
+	flag.BoolVar(&opts.JSON, "json", false, "print the configuration and results as JSON")
+	flag.StringVar(&opts.JSONOut, "json-out", "", "write the configuration and results as JSON to this file")
+	flag.BoolVar(&opts.DryRun, "dry-run", false, "check the flags and config file, print the effective configuration and exit")
+	flag.Var(&opts.Profile, "profile", "write a profile to the current directory: cpu, mem, block, mutex or trace")
+	flag.CommandLine.Parse(args)
+	switch command {
+	case "replay", "compare", "experiment":
+	default:
+		if flag.NArg() > 0 {
+			fatal(fmt.Errorf("unexpected argument %q; flags must come before any arguments", flag.Arg(0)))
+		}
+	}
+
+	if configPath != "" {
+		if err := opts.load(configPath); err != nil {
+			fatal(err)
+		}
+	}
+	if err := opts.Plugins.load(); err != nil {
+		fatal(err)
+	}
+	if err := opts.Scripts.load(&opts.Config); err != nil {
+		fatal(err)
+	}
+	if command == "sweep" && len(opts.Sweep) == 0 {
+		fatal(fmt.Errorf("a sweep needs a -config file with a sweep section"))
+	}
+	if opts.DryRun {
+		dryRun(command, opts)
+		return
+	}
+	if err := opts.logging.start(); err != nil {
+		fatal(err)
+	}
+
+	if opts.MetricsAddr != "" {
+		serveMetrics(opts.MetricsAddr)
+	}
+
+	if opts.Profile != "" {
+		defer opts.Profile.start().Stop()
+	}
+
+	var trials []zitraders.Trial
+	if opts.Autotune {
+		trials = autotune(&opts)
+	}
+	opts.run = newProvenance()
+	if opts.text() {
+		// Sweeps and experiments write CSV to stdout unless -sweep-out is
+		// given, so the banner goes to stderr to keep it out of the table.
+		var w io.Writer = os.Stdout
+		tabulating := command == "experiment" || (len(opts.Sweep) > 0 && command != "batch" && command != "replay" && command != "compare")
+		if tabulating && opts.SweepOut == "" {
+			w = os.Stderr
+		}
+		fmt.Fprintf(w, "\nZERO INTELLIGENCE TRADERS\n")
+		printProvenance(w, opts)
+		fmt.Fprintf(w, "numThreads: %d\n", opts.NumThreads)
+		for _, t := range trials {
+			fmt.Fprintf(w, "  autotune trial with %d goroutine(s): %.0f attempts per second\n", t.Threads, t.Rate)
+		}
+	}
 
-type agent struct {
-	buyerOrSeller bool // true is buyer, false is seller
-	quantityHeld  int
-	value         int
-	price         int
+	ctx := context.Background()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(opts.Timeout))
+		defer cancel()
+	}
+
+	if command == "batch" {
+		batch(ctx, opts)
+	} else if command == "replay" {
+		replay(opts, flag.Args())
+	} else if command == "compare" {
+		compare(ctx, opts, flag.Args())
+	} else if command == "experiment" {
+		runExperiment(ctx, opts, flag.Args())
+	} else if len(opts.Sweep) > 0 {
+		sweep(ctx, opts)
+	} else if opts.Reps > 1 || opts.Precision > 0 {
+		replicate(ctx, opts)
+	} else {
+		runOnce(ctx, opts)
+	}
 }
 
-func (a agent) String() string {
-	return fmt.Sprintf("buyer: %t, held: %d, value: %d, price: %d\n", a.buyerOrSeller, a.quantityHeld, a.value, a.price)
+// Set the number of goroutines to the fastest in a few warm-up runs,
+// returning the trials.
+func autotune(opts *options) []zitraders.Trial {
+	threads, trials, err := zitraders.Autotune(context.Background(), opts.Config, opts.AutotuneAttempts)
+	if err != nil {
+		fatal(err)
+	}
+	slog.Info("autotuned", "threads", threads, "was", opts.NumThreads)
+	opts.NumThreads = threads
+	return trials
 }
 
-// Create two slices of agents, one representing buyers and the other sellers.
-func initializeAgents() ([]agent, []agent) {
+// Run the model once and report its statistics.
+func runOnce(ctx context.Context, opts options) {
+	if opts.TradesOut != "" || opts.Plots != "" || opts.DBTrades || opts.Bootstrap > 0 {
+		opts.RecordTrades = true
+	}
+	if opts.QuotesOut != "" && opts.QuoteEvery == 0 {
+		opts.QuoteEvery = 1000
+	}
 
-	b := make([]agent, numBuyers)
-	s := make([]agent, numSellers)
+	// Runs are stopped on a signal, and checkpoints and snapshots taken,
+	// between ticks.
+	if opts.TickSize == 0 {
+		opts.TickSize = 10000
+	}
 
-	for i := 0; i < numBuyers; i++ {
-		b[i] = agent{
-			buyerOrSeller: true,
-			quantityHeld:  0,
-			value:         rand.Intn(maxBuyerValue) + 1}
+	var m *zitraders.Model
+	var err error
+	if opts.Resume != "" {
+		m, err = resume(opts.Resume)
+	} else {
+		m, err = zitraders.New(opts.Config)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if opts.text() {
+		fmt.Printf("seed: %d\n", m.Seed)
+	}
+	track(m)
+	unwatch := func() {}
+	if opts.Checkpoint != "" {
+		unwatch = watchCheckpoints(m, opts.Checkpoint, time.Duration(opts.CheckpointEvery))
+	}
+	if opts.Snapshots != "" {
+		if err := watchSnapshots(m, opts.Snapshots, opts.SnapshotFormat, opts.SnapshotEvery); err != nil {
+			fatal(err)
+		}
 	}
 
-	for i := 0; i < numSellers; i++ {
-		s[i] = agent{
-			buyerOrSeller: false,
-			quantityHeld:  1,
-			value:         rand.Intn(maxSellerValue) + 1}
+	if opts.RosterOut != "" {
+		if opts.Resume != "" {
+			slog.Warn("the roster of a resumed run gives the values in force at the checkpoint")
+		}
+		if err := writeRoster(opts.RosterOut, m); err != nil {
+			fatal(err)
+		}
 	}
 
-	return b, s
-}
+	if opts.CurvesOut != "" {
+		if err := writeCurves(opts.CurvesOut, m.Curves()); err != nil {
+			fatal(err)
+		}
+	}
+
+	var streams []*arrowStream
+	if isArrow(opts.TradesOut) {
+		s, err := streamTrades(opts.TradesOut, m)
+		if err != nil {
+			fatal(err)
+		}
+		streams = append(streams, s)
+	}
+	if isArrow(opts.QuotesOut) {
+		s, err := streamQuotes(opts.QuotesOut, m)
+		if err != nil {
+			fatal(err)
+		}
+		streams = append(streams, s)
+	}
+
+	if opts.ProgressEvery > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go reportProgress(m, time.Duration(opts.ProgressEvery), done)
+	}
 
-// Divide the agent population into chunks, have these chunks perform trades,
-// then compute market statistics.
-func openMarket() {
-	var wg sync.WaitGroup
+	var done, drawn chan struct{}
+	if opts.TUI {
+		done, drawn = make(chan struct{}), make(chan struct{})
+		go dashboard(m, done, drawn)
+	}
+	var sampled chan struct{}
+	if opts.Web != "" {
+		sampled = make(chan struct{})
+		f := &feed{EquilibriumPrice: m.Curves().EquilibriumPrice, MaxNumberOfTrades: m.MaxNumberOfTrades}
+		go f.run(m, sampled)
+		serveWeb(opts.Web, f)
+	}
 
-	if verbose {
-		fmt.Println(buyers)
+	release := stopOnSignal(m)
+	r := m.RunContext(ctx)
+	release()
+	unwatch()
+	for _, s := range streams {
+		if err := s.close(); err != nil {
+			fatal(err)
+		}
+	}
+	if opts.TUI {
+		close(done)
+		<-drawn
+	}
+	if sampled != nil {
+		close(sampled)
+	}
+	var boot *zitraders.BootstrapResults
+	if opts.Bootstrap > 0 {
+		b, err := m.Bootstrap(r, opts.Bootstrap, opts.BootstrapBlock)
+		if err != nil {
+			slog.Warn("no bootstrap", "err", err)
+		} else {
+			boot = &b
+		}
+	}
+	if opts.text() {
+		printResults(r)
+		if boot != nil {
+			printBootstrap(*boot)
+		}
+		printPerformance(m.Performance())
+	} else {
+		writeJSON(opts, struct {
+			Provenance  provenance                  `json:"provenance"`
+			Config      zitraders.Config            `json:"config"`
+			Results     zitraders.Results           `json:"results"`
+			Bootstrap   *zitraders.BootstrapResults `json:"bootstrap,omitempty"`
+			Performance zitraders.Performance       `json:"performance"`
+		}{opts.run, m.Config, r, boot, m.Performance()})
 	}
 
-	for i := 0; i < numThreads; i++ {
-		wg.Add(1)
-		go func(threadNum int) {
-			defer wg.Done()
-			if verbose {
-				defer fmt.Printf("Finished thread number %d\n", threadNum)
+	if opts.DB != "" {
+		run := dbRun{mode: "single", config: m.Config, results: r}
+		if opts.DBTrades {
+			run.trades = m.Trades()
+		}
+		if err := record(opts.DB, opts.run, run); err != nil {
+			fatal(err)
+		}
+	}
+	if opts.TradesOut != "" && !isArrow(opts.TradesOut) {
+		if err := writeTrades(opts.TradesOut, m.Trades()); err != nil {
+			fatal(err)
+		}
+	}
+	if opts.AgentsOut != "" {
+		if err := writeAgentResults(opts.AgentsOut, m); err != nil {
+			fatal(err)
+		}
+	}
+	if opts.QuotesOut != "" && !isArrow(opts.QuotesOut) {
+		if err := writeQuotes(opts.QuotesOut, m.Quotes()); err != nil {
+			fatal(err)
+		}
+	}
+	if opts.PriceHistogramOut != "" {
+		if err := writePriceHistogram(opts.PriceHistogramOut, r.PriceHistogram); err != nil {
+			fatal(err)
+		}
+	}
+	if opts.RoundsOut != "" {
+		if err := writeRounds(opts.RoundsOut, r.Rounds); err != nil {
+			fatal(err)
+		}
+	}
+	if opts.LorenzOut != "" {
+		if err := writeLorenz(opts.LorenzOut, r); err != nil {
+			fatal(err)
+		}
+	}
+	if opts.PriceMap != "" {
+		if err := writePriceMap(opts.PriceMap, m.PriceMap()); err != nil {
+			fatal(err)
+		}
+	}
+	if opts.Plots != "" {
+		if err := writePlots(opts.Plots, opts.PlotFormat, m.Trades(), m.Curves()); err != nil {
+			fatal(err)
+		}
+	}
+	for _, path := range []string{opts.TradesOut, opts.QuotesOut, opts.RosterOut, opts.AgentsOut, opts.CurvesOut, opts.PriceHistogramOut,
+		opts.LorenzOut, opts.RoundsOut, opts.PriceMap, opts.Plots, opts.Snapshots} {
+		if path != "" {
+			if err := opts.stamp(path, m.Seed); err != nil {
+				fatal(err)
 			}
-			doTrades(threadNum)
-		}(i)
+		}
 	}
-	wg.Wait() //block until all threads are done for safety
+	if opts.Web != "" {
+		slog.Info("run finished; the dashboard is still served until interrupted", "addr", opts.Web)
+		select {}
+	}
+}
 
-	if verbose {
-		fmt.Println(buyers)
+// Log the model's progress at every interval until done is closed.
+func reportProgress(m *zitraders.Model, every time.Duration, done <-chan struct{}) {
+	start := time.Now()
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			p := m.Progress()
+			elapsed := time.Since(start)
+			slog.Info("progress", "attempts", p.Attempts, "max_attempts", m.MaxNumberOfTrades, "trades", p.Trades,
+				"seconds", elapsed.Seconds(), "rate", math.Round(float64(p.Attempts)/elapsed.Seconds()))
+		}
 	}
+}
 
-	computeStatistics()
+// Run independent replications of the model and summarize them.
+func replicate(ctx context.Context, opts options) {
+	var results []zitraders.Results
+	var err error
+	switch {
+	case (opts.Precision > 0 || opts.Antithetic) && len(opts.Remote) > 0:
+		err = fmt.Errorf("-precision and -antithetic cannot be combined with -remote")
+	case opts.Precision > 0 && opts.Antithetic:
+		err = fmt.Errorf("-precision cannot be combined with -antithetic")
+	case opts.Antithetic:
+		results, err = zitraders.ReplicateAntithetic(ctx, opts.Config, opts.Reps, 1, track)
+	case opts.Precision > 0:
+		results, err = zitraders.ReplicateUntil(ctx, opts.Config, opts.precision(), 1, track)
+	case len(opts.Remote) > 0:
+		var cells [][]zitraders.Results
+		cells, err = replicateRemotely(ctx, opts, []zitraders.Config{opts.Config})
+		if err == nil {
+			results = cells[0]
+		}
+	default:
+		results, err = zitraders.ReplicateContext(ctx, opts.Config, opts.Reps, track)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if ctx.Err() != nil || len(results) < opts.Reps && opts.Precision == 0 {
+		slog.Warn("timed out; the last replication was cut short", "completed", len(results), "reps", opts.Reps)
+	}
+	if opts.DB != "" {
+		recordReplications(opts, "replication", results)
+	}
+	summarize(opts, results)
 }
 
-//Pair up buyers and sellers and execute trades if the bid and ask prices are compatible.
-func doTrades(threadNum int) {
-	// Each thread needs its own random source to prevent excessive blocking on rand.
-	// Adding these lines sped the model up approx. 9 times.
-	source := rand.NewSource(time.Now().UnixNano())
-	generator := rand.New(source)
+// The sequential stopping rule of -precision, with -reps as the most
+// replications.
+func (o options) precision() zitraders.Precision {
+	p := zitraders.Precision{Outcome: o.PrecisionOf, Tolerance: o.Precision, MinReps: o.MinReps}
+	if o.Reps > 1 {
+		p.MaxReps = o.Reps
+	}
+	return p
+}
 
-	for i := 1; i < tradesPerThread; i++ { //why i=1?
+// Add replications to the -db database.
+func recordReplications(opts options, mode string, results []zitraders.Results) {
+	runs := make([]dbRun, len(results))
+	for i, r := range results {
+		runs[i] = dbRun{mode: mode, rep: i + 1, config: opts.Config, results: r}
+	}
+	if err := record(opts.DB, opts.run, runs...); err != nil {
+		fatal(err)
+	}
+}
 
-		//bound the slice based on thread number
-		lowerBuyerBound := threadNum * buyersPerThread
-		upperBuyerBound := (threadNum+1)*buyersPerThread - 1
-		lowerSellerBound := threadNum * sellersPerThread
-		upperSellerBound := (threadNum+1)*sellersPerThread - 1
+// Report replications and their summary as text or JSON.
+func summarize(opts options, results []zitraders.Results) {
+	s := zitraders.Summarize(results)
+	if opts.Antithetic {
+		s = zitraders.SummarizePairs(results)
+	} else if opts.Bootstrap > 0 && len(results) > 0 {
+		s = zitraders.SummarizeBootstrap(results, opts.Bootstrap, results[0].Seed)
+	}
+	if !opts.text() {
+		writeJSON(opts, struct {
+			Provenance   provenance          `json:"provenance"`
+			Config       zitraders.Config    `json:"config"`
+			Replications []zitraders.Results `json:"replications"`
+			Summary      zitraders.Summary   `json:"summary"`
+		}{opts.run, opts.Config, results, s})
+		return
+	}
 
-		//select buyer and seller
-		buyerIndex := lowerBuyerBound + generator.Intn(upperBuyerBound-lowerBuyerBound)
-		sellerIndex := lowerSellerBound + generator.Intn(upperSellerBound-lowerSellerBound)
+	for i, r := range results {
+		fmt.Printf("rep %d (seed %d): %d traded at %f, efficiency %.2f%%\n", i+1, r.Seed, r.NumberBought, r.MeanPrice, r.Efficiency)
+	}
 
-		//set bid and ask prices
-		bidPrice := generator.Intn(buyers[buyerIndex].value) + 1
-		askPrice := sellers[sellerIndex].value + generator.Intn(maxSellerValue-sellers[sellerIndex].value+1)
+	if opts.Antithetic {
+		fmt.Printf("\nSummary of %d antithetic pairs of replications (mean, s.d., 95%% CI of the pairs' means)\n", s.Reps)
+	} else {
+		fmt.Printf("\nSummary of %d replications (mean, s.d., 95%% CI)\n", s.Reps)
+	}
+	printEstimate("quantity", s.Quantity)
+	printEstimate("average price", s.MeanPrice)
+	printEstimate("efficiency", s.Efficiency)
+	printEstimate("alpha", s.Alpha)
+	if opts.Precision > 0 {
+		p := opts.precision()
+		verdict := "within"
+		if !p.Met(results) {
+			verdict = "short of"
+		}
+		fmt.Printf("After %d replications the half-width of the %s interval is %f, %s the tolerance of %f\n",
+			len(results), p.Outcome, p.Estimate(results).HalfWidth(), verdict, p.Tolerance)
+	}
+}
+
+// Print the run's provenance and its parameters, as JSON on one line.
+func printProvenance(w io.Writer, opts options) {
+	p := opts.run
+	commit := ""
+	if p.Commit != "" {
+		commit = ", commit " + p.Commit
+	}
+	fmt.Fprintf(w, "run %s started %s on %s (version %s%s)\n", p.RunID, p.Started.Format(time.RFC3339), p.Host, p.Version, commit)
+	params, err := json.Marshal(opts.Config)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Fprintf(w, "parameters: %s\n", params)
+}
+
+func printEstimate(name string, e zitraders.Estimate) {
+	fmt.Printf("%-14s %10.3f %10.3f [%.3f, %.3f]", name, e.Mean, e.SD, e.Low, e.High)
+	if b := e.Bootstrap; b != nil {
+		fmt.Printf(" bootstrap [%.3f, %.3f]", b.Low, b.High)
+	}
+	fmt.Println()
+}
 
-		var transactionPrice int
+// Print the bootstrap intervals of a single run's statistics.
+func printBootstrap(b zitraders.BootstrapResults) {
+	fmt.Printf("95%% bootstrap intervals from %d resamples of blocks of %d trades: mean price [%.3f, %.3f], efficiency [%.2f%%, %.2f%%], alpha [%.2f%%, %.2f%%]\n",
+		b.Resamples, b.Block, b.MeanPrice.Low, b.MeanPrice.High, b.Efficiency.Low, b.Efficiency.High, b.Alpha.Low, b.Alpha.High)
+}
 
-		//is a deal possible?
-		if buyers[buyerIndex].quantityHeld == 0 && sellers[sellerIndex].quantityHeld == 1 && bidPrice >= askPrice {
-			// set transaction price
-			transactionPrice = askPrice + generator.Intn(bidPrice-askPrice+1)
-			buyers[buyerIndex].price = transactionPrice
-			sellers[sellerIndex].price = transactionPrice
+func printResults(r zitraders.Results) {
+	fmt.Printf("%d items bought and %d items sold\n", r.NumberBought, r.NumberSold)
+	fmt.Printf("The average price = %f and the s.d. is %f\n", r.MeanPrice, r.SDPrice)
+	fmt.Printf("The median price = %.1f (range %.0f to %.0f, interquartile range %.1f)\n", r.MedianPrice, r.MinPrice, r.MaxPrice, r.IQRPrice)
+	fmt.Printf("The 5th and 95th percentiles of price = %.1f and %.1f\n", r.P5Price, r.P95Price)
+	fmt.Printf("The coefficient of variation of prices = %.4f\n", r.CVPrice)
+	if r.PriceTick != 1 {
+		fmt.Printf("Prices, values and profits are counted in ticks of %g: the average price is %.4f and the median %.4f in currency\n",
+			r.PriceTick, r.Currency(r.MeanPrice), r.Currency(r.MedianPrice))
+	}
+	e := r.Equilibrium
+	fmt.Printf("Competitive equilibrium: %d units at a price from %d to %d, for a surplus of %d per period\n", e.Quantity, e.PriceLow, e.PriceHigh, e.Surplus)
+	fmt.Printf("Realized surplus = %d of a maximum %d (efficiency %.2f%%)\n", r.RealizedSurplus, r.MaxSurplus, r.Efficiency)
+	w := r.Welfare
+	fmt.Printf("Buyers realized %d, sellers %d and intermediaries %d; %.1f was lost to intramarginal units left untraded and %.1f to extramarginal units traded\n",
+		w.BuyerSurplus, w.SellerSurplus, w.IntermediarySurplus, w.Unrealized, w.Extramarginal)
+	fmt.Printf("Smith's alpha = %.2f%% around an equilibrium price of %.2f\n", r.Alpha, r.EquilibriumPrice)
+	fmt.Printf("Profit per trader = %f (s.d. %f, Gini coefficient %.3f; buyers %.3f, sellers %.3f)\n",
+		r.Profits.Mean, r.Profits.SD, r.Profits.Gini, r.BuyerProfits.Gini, r.SellerProfits.Gini)
+	printHistogram(r.Profits)
+	printPriceHistogram(r.PriceHistogram)
+	if r.Volatility > 0 {
+		fmt.Printf("Price volatility = %f (root mean squared change between successive prices)\n", r.Volatility)
+		fmt.Printf("Autocorrelation of successive prices = %.4f\n", r.Autocorrelation)
+	}
+	if x := r.Window; x != nil {
+		fmt.Printf("After a warm-up of %d trades, %d measured: mean price %f (s.d. %f, median %.1f, from %.0f to %.0f), alpha %.2f%%, surplus %d\n",
+			x.WarmUp, x.Trades, x.MeanPrice, x.SDPrice, x.MedianPrice, x.MinPrice, x.MaxPrice, x.Alpha, x.Surplus)
+	}
+	if x := r.Marginal; x != nil {
+		fmt.Printf("Intramarginal trades = %d for a surplus of %d; extramarginal trades = %d for %d, displacing %.1f\n",
+			x.Intramarginal, x.IntramarginalSurplus, x.Extramarginal, x.ExtramarginalSurplus, x.Displaced)
+	}
+	if p := r.Policy; p != nil {
+		fmt.Printf("Tax revenue = %.2f; the policy allows a surplus of at most %d, a deadweight loss of %d\n", p.TaxRevenue, p.MaxSurplus, p.DeadweightLoss)
+	}
+	if d := r.Dealer; d != nil {
+		fmt.Printf("Market makers bought %d and sold %d units, holding %d, for a profit of %d\n", d.Bought, d.Sold, d.Inventory, d.Profit)
+	}
+	if b := r.Book; b != nil {
+		fmt.Printf("Bid-ask spread = %.3f (s.d. %.3f, volatility %.3f) with both sides quoted in %.2f%% of attempts; depth %.2f bids and %.2f asks on average, at most %d and %d\n",
+			b.MeanSpread, b.SDSpread, b.SpreadVolatility, 100*b.Quoted, b.MeanBidDepth, b.MeanAskDepth, b.MaxBidDepth, b.MaxAskDepth)
+	}
+	if r.Expired > 0 {
+		fmt.Printf("%d orders expired in the book\n", r.Expired)
+	}
+	if l := r.Latency; l != nil {
+		fmt.Printf("Fast traders (%d) traded %d units for a profit of %.3f each; slow traders (%d) traded %d for %.3f each; %d quotes never arrived\n",
+			l.Fast, l.FastTrades, l.FastProfit, l.Slow, l.SlowTrades, l.SlowProfit, l.Dropped)
+	}
+	if a := r.Arbitrage; a != nil {
+		fmt.Printf("Arbitrageurs bought %d and sold %d units, holding %d, for a profit of %d\n", a.Bought, a.Sold, a.Inventory, a.Profit)
+	}
+	for _, s := range r.Shocks {
+		fmt.Printf("Shock after %d attempts and %d trades moved the equilibrium price from %.2f to %.2f\n", s.Attempts, s.Trades, s.Before, s.After)
+	}
+	switch r.Stopped {
+	case zitraders.StopCleared:
+		fmt.Printf("Stopped after %d attempts: no mutually beneficial trade remains\n", r.Attempts)
+	case zitraders.StopRate:
+		fmt.Printf("Stopped after %d attempts: the trade rate fell below the minimum\n", r.Attempts)
+	case zitraders.StopCanceled:
+		fmt.Printf("Stopped after %d attempts: the run timed out\n", r.Attempts)
+	case zitraders.StopObserver:
+		fmt.Printf("Stopped after %d attempts\n", r.Attempts)
+	}
 
-			// execute trade
-			buyers[buyerIndex].quantityHeld = 1
-			sellers[sellerIndex].quantityHeld = 0
+	if len(r.Types) > 0 {
+		fmt.Printf("%-10s %8s %8s %10s %10s %12s\n", "strategy", "buyers", "sellers", "trades", "profit", "per trader")
+		for _, t := range r.Types {
+			fmt.Printf("%-10s %8d %8d %10d %10d %12.3f\n", t.Strategy, t.Buyers, t.Sellers, t.Trades, t.Profit, t.MeanProfit)
+		}
+	}
+
+	if len(r.Markets) > 0 {
+		fmt.Printf("%6s %10s %10s %10s %10s %10s %10s\n", "market", "bought", "sold", "mean", "s.d.", "eq. price", "efficiency")
+		for k, x := range r.Markets {
+			fmt.Printf("%6d %10d %10d %10.3f %10.3f %10.2f %10.2f\n", k, x.NumberBought, x.NumberSold, x.MeanPrice, x.SDPrice, x.EquilibriumPrice, x.Efficiency)
+		}
+		fmt.Printf("Price dispersion across markets = %f\n", r.Dispersion)
+	}
+
+	if n := r.Network; n != nil {
+		fmt.Printf("%s network of %d links: mean degree %.2f, maximum %d, %d traders isolated\n", n.Topology, n.Links, n.MeanDegree, n.MaxDegree, n.Isolated)
+		fmt.Printf("Price dispersion across neighborhoods = %f\n", n.Dispersion)
+		fmt.Printf("%8s %10s %10s %10s %10s\n", "degree", "traders", "trades", "profit", "mean")
+		for _, d := range n.Degrees {
+			fmt.Printf("%8d %10d %10d %10.3f %10.3f\n", d.Degree, d.Traders, d.Trades, d.MeanProfit, d.MeanPrice)
+		}
+	}
+
+	if s := r.Spatial; s != nil {
+		fmt.Printf("Prices in %d cells of the lattice: s.d. %f across cells, Moran's I %.3f; traders moved %d times\n", s.Cells, s.Dispersion, s.Moran, s.Moves)
+	}
+
+	if len(r.Periods) > 0 {
+		fmt.Printf("%6s %10s %10s %10s %10s %10s %10s\n", "period", "trades", "mean", "s.d.", "surplus", "efficiency", "alpha")
+		for k, p := range r.Periods {
+			fmt.Printf("%6d %10d %10.3f %10.3f %10d %10.2f %10.3f\n", k+1, p.Trades, p.MeanPrice, p.SDPrice, p.RealizedSurplus, p.Efficiency, p.Alpha)
+		}
+	}
+
+	if len(r.RollingVolatility) > 0 {
+		fmt.Printf("%6s %10s\n", "window", "volatility")
+		for k, v := range r.RollingVolatility {
+			fmt.Printf("%6d %10.3f\n", k+1, v)
+		}
+	}
+
+	if len(r.Rounds) > 0 {
+		fmt.Printf("%6s %6s %10s %10s %10s %10s\n", "period", "round", "trades", "mean", "alpha", "efficiency")
+		for _, x := range r.Rounds {
+			fmt.Printf("%6d %6d %10d %10.3f %10.3f %10.2f\n", x.Period, x.Round, x.Trades, x.MeanPrice, x.Alpha, x.Efficiency)
+		}
+	}
+
+	if len(r.Convergence) > 0 {
+		fmt.Printf("Equilibrium price = %.2f\n", r.EquilibriumPrice)
+		fmt.Printf("%10s %10s %10s %10s %10s %10s\n", "trades", "mean", "variance", "deviation", "running", "alpha")
+		for _, b := range r.Convergence {
+			fmt.Printf("%10d %10.3f %10.3f %10.3f %10.3f %10.3f\n", b.Trades, b.Mean, b.Variance, b.Deviation, b.Running, b.Alpha)
 		}
 	}
 }
 
-// Compute some statistics for the run and output to STDOUT.
-func computeStatistics() {
-	numberBought := 0
-	numberSold := 0
-	sum := make(stat.IntSlice, 0)
+// Print the number of traders at each profit, several to a line.
+func printHistogram(p zitraders.Profits) {
+	const perLine = 6
+	fmt.Println("Traders by profit:")
+	for k, c := range p.Histogram {
+		fmt.Printf("%6d: %-9d", p.Min+k, c)
+		if (k+1)%perLine == 0 || k == len(p.Histogram)-1 {
+			fmt.Println()
+		}
+	}
+}
 
-	for _, x := range buyers {
-		if x.quantityHeld == 1 {
-			numberBought++
-			sum = append(sum, int64(x.price))
+// Print the prices traded by bin, each with a bar scaled to the fullest.
+func printPriceHistogram(bins []zitraders.Bin) {
+	const width = 50
+	if len(bins) == 0 {
+		return
+	}
+	most := 0
+	for _, b := range bins {
+		if b.Count > most {
+			most = b.Count
 		}
 	}
-	for _, x := range sellers {
-		if x.quantityHeld == 0 {
-			numberSold++
-			sum = append(sum, int64(x.price))
+	fmt.Println("Prices traded, counting each side of a trade:")
+	for _, b := range bins {
+		bar := 0
+		if most > 0 {
+			bar = b.Count * width / most
 		}
+		fmt.Printf("%6d to %-6d %10d %s\n", b.Low, b.High, b.Count, strings.Repeat("#", bar))
 	}
-	fmt.Printf("%d items bought and %d items sold\n", numberBought, numberSold)
-	fmt.Printf("The average price = %f and the s.d. is %f\n", stat.Mean(sum), stat.Sd(sum))
 }
 
-func main() {
+// Print the work done by each goroutine, and by all of them.
+func printPerformance(p zitraders.Performance) {
+	fmt.Printf("%9s %14s %12s %9s %14s\n", "goroutine", "attempts", "trades", "seconds", "attempts/sec")
+	for _, t := range p.Threads {
+		fmt.Printf("%9d %14d %12d %9.3f %14.0f\n", t.Thread, t.Attempts, t.Trades, t.Elapsed.Seconds(), t.Rate)
+	}
+	fmt.Printf("%9s %14d %12d %9.3f %14.0f\n", "all", p.Attempts, p.Trades, p.Elapsed.Seconds(), p.Rate)
+	fmt.Printf("The busiest goroutine traded for %.2f times the mean\n", p.Imbalance)
+}
+
+// Write the histogram of prices to a CSV file.
+func writePriceHistogram(path string, bins []zitraders.Bin) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := zitraders.WritePriceHistogramCSV(f, bins); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Write the rounds of a run to a CSV file.
+func writeRounds(path string, rounds []zitraders.Round) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := zitraders.WriteRoundsCSV(f, rounds); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Write the Lorenz curves of the run's profits to a CSV file.
+func writeLorenz(path string, r zitraders.Results) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := zitraders.WriteLorenzCSV(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Write the trade log of a run to a CSV file.
+func writeTrades(path string, trades []zitraders.Trade) error {
+	if isParquet(path) {
+		return writeTradesParquet(path, trades)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := zitraders.WriteTradesCSV(f, trades); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Write the roster of agents as CSV or, if path ends in .parquet, Parquet.
+func writeRoster(path string, m *zitraders.Model) error {
+	if isParquet(path) {
+		return writeRosterParquet(path, m)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := m.WriteRoster(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
 
-	fmt.Printf("\nZERO INTELLIGENCE TRADERS\n")
-	flag.IntVar(&numThreads, "p", runtime.NumCPU()*2, "number of goroutine to use")
-	flag.BoolVar(&verbose, "v", false, "verbose (track goroutines)")
-	flag.BoolVar(&profiling, "profile", false, "enable CPU profiling")
-	flag.Parse()
+// Write the final state and surplus of every agent as CSV or, if path ends
+// in .parquet, Parquet.
+func writeAgentResults(path string, m *zitraders.Model) error {
+	if isParquet(path) {
+		return writeAgentResultsParquet(path, m)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := m.WriteAgentResults(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
 
-	if profiling {
-		defer profile.Start(profile.CPUProfile, profile.ProfilePath(".")).Stop()
+// Write samples of the order books as CSV or, if path ends in .parquet,
+// Parquet.
+func writeQuotes(path string, quotes []zitraders.Quote) error {
+	if isParquet(path) {
+		return writeQuotesParquet(path, quotes)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := zitraders.WriteQuotesCSV(f, quotes); err != nil {
+		f.Close()
+		return err
 	}
+	return f.Close()
+}
 
-	buyersPerThread = numBuyers / numThreads
-	sellersPerThread = numSellers / numThreads
-	tradesPerThread = maxNumberOfTrades / numThreads
+// Write the supply and demand curves as JSON or, for any other extension, CSV.
+func writeCurves(path string, c zitraders.Curves) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if filepath.Ext(path) == ".json" {
+		err = json.NewEncoder(f).Encode(c)
+	} else {
+		err = c.WriteCSV(f)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
 
-	// seed RNG
-	rand.Seed(time.Now().UTC().UnixNano())
-	fmt.Printf("numThreads: %d\n", numThreads)
+// Write a lattice's price map as JSON or, for any other extension, CSV.
+func writePriceMap(path string, p *zitraders.PriceMap) error {
+	if p == nil {
+		return fmt.Errorf("there is no price map without a lattice")
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if filepath.Ext(path) == ".json" {
+		err = json.NewEncoder(f).Encode(p)
+	} else {
+		err = p.WriteCSV(f)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
 
-	buyers, sellers = initializeAgents()
-	openMarket()
+// Write v as indented JSON to stdout or the file given by -json-out.
+func writeJSON(opts options, v interface{}) {
+	w := os.Stdout
+	if opts.JSONOut != "" {
+		f, err := os.Create(opts.JSONOut)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fatal(err)
+	}
 }
@@ -0,0 +1,294 @@
+package zitraders
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+type agent struct {
+	busy          int32 // claimed by a thread under global matching; accessed atomically
+	buyerOrSeller bool  // true is buyer, false is seller
+	kind          uint8 // index of the agent's strategy in Model.kinds
+	strategy      Strategy
+	quantityHeld  int32 // accessed atomically under the cas transfer
+	value         int   // value or cost of the marginal unit
+	price         int   // most recent transaction price
+	earned        int   // surplus realized in earlier trading periods
+	schedule      []int // marginal values (buyers, falling) or costs (sellers, rising)
+	prices        []int // transaction price of each unit traded, over all periods
+}
+
+func (a agent) String() string {
+	return fmt.Sprintf("buyer: %t, strategy: %T, held: %d, value: %d, price: %d\n", a.buyerOrSeller, a.strategy, a.quantityHeld, a.value, a.price)
+}
+
+// Claim a buyer and a seller for the exclusive use of the calling thread.
+// Claims never wait: if either agent is busy in another thread, neither is
+// claimed and the trade attempt is abandoned.
+func claim(buyer, seller *agent) bool {
+	if !atomic.CompareAndSwapInt32(&buyer.busy, 0, 1) {
+		return false
+	}
+	if !atomic.CompareAndSwapInt32(&seller.busy, 0, 1) {
+		atomic.StoreInt32(&buyer.busy, 0)
+		return false
+	}
+	return true
+}
+
+// Release agents claimed with claim.
+func release(buyer, seller *agent) {
+	atomic.StoreInt32(&seller.busy, 0)
+	atomic.StoreInt32(&buyer.busy, 0)
+}
+
+// A buyer can trade until its demand schedule is exhausted.
+func (a *agent) canBuy() bool {
+	return int(a.quantityHeld) < len(a.schedule)
+}
+
+// A seller can trade while it holds units.
+func (a *agent) canSell() bool {
+	return a.quantityHeld > 0
+}
+
+// Buy one unit at the given price and move on to the next marginal value.
+func (a *agent) buy(price int) {
+	a.price = price
+	a.prices = append(a.prices, price)
+	a.quantityHeld++
+	if int(a.quantityHeld) < len(a.schedule) {
+		a.value = a.schedule[a.quantityHeld]
+	}
+}
+
+// Sell one unit at the given price and move on to the next marginal cost.
+func (a *agent) sell(price int) {
+	a.price = price
+	a.prices = append(a.prices, price)
+	a.quantityHeld--
+	if sold := a.traded(); sold < len(a.schedule) {
+		a.value = a.schedule[sold]
+	}
+}
+
+// The number of units traded in the current period.
+func (a *agent) traded() int {
+	if a.buyerOrSeller {
+		return int(a.quantityHeld)
+	}
+	return len(a.schedule) - int(a.quantityHeld)
+}
+
+// The surplus realized on the units traded so far, in all periods.
+func (a *agent) surplus() int {
+	s := a.earned
+	for k, p := range a.prices[len(a.prices)-a.traded():] {
+		if a.buyerOrSeller {
+			s += a.schedule[k] - p
+		} else {
+			s += p - a.schedule[k]
+		}
+	}
+	return s
+}
+
+// Restore the agent's endowment for a new trading period: a buyer again
+// demands, and a seller again supplies, its whole schedule.
+func (a *agent) reendow() {
+	a.earned = a.surplus()
+	if a.buyerOrSeller {
+		a.quantityHeld = 0
+	} else {
+		a.quantityHeld = int32(len(a.schedule))
+	}
+	a.value = a.schedule[0]
+}
+
+// Create two slices of agents, one representing buyers and the other sellers.
+// Each buyer trades up to Units units a period and each seller SellerUnits;
+// their schedules and price histories are carved out of two shared backing
+// arrays to avoid an allocation per agent. The agents are drawn in blocks, in
+// parallel, each block from a generator of its own.
+func (m *Model) initializeAgents(buyerValue, sellerCost func(*rand.Rand) int) ([]agent, []agent, error) {
+
+	b := make([]agent, m.NumBuyers)
+	s := make([]agent, m.NumSellers)
+	schedules := make([]int, m.NumBuyers*m.Units+m.NumSellers*m.SellerUnits)
+	prices := make([]int, len(schedules)*m.Periods)
+	first := m.NumBuyers * m.Units // the first seller's schedule
+	if m.FirstTouch {
+		m.firstTouch(func(t int) {
+			lowerBuyer, upperBuyer, lowerSeller, upperSeller := m.bounds(t)
+			zero(b[lowerBuyer:upperBuyer])
+			zero(s[lowerSeller:upperSeller])
+			for _, r := range [][2]int{
+				{lowerBuyer * m.Units, upperBuyer * m.Units},
+				{first + lowerSeller*m.SellerUnits, first + upperSeller*m.SellerUnits},
+			} {
+				zero(schedules[r[0]:r[1]])
+				zero(prices[r[0]*m.Periods : r[1]*m.Periods])
+			}
+		})
+	}
+
+	// Each agent draws its strategy from the population's mix; those who
+	// draw none follow Strategy.
+	shares := m.mix()
+	draw := false
+	for _, t := range shares {
+		draw = draw || t.share > 0
+	}
+	factories := make([]StrategyFactory, len(m.kinds))
+	for k, name := range m.kinds {
+		f, err := strategy(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		factories[k] = f
+	}
+
+	var learning int32
+	for _, side := range []struct {
+		agents      []agent
+		buyer       bool
+		units, from int // the units of each agent, and the first one's schedule
+		value       func(*rand.Rand) int
+		max         int
+	}{
+		{b, true, m.Units, 0, buyerValue, m.MaxBuyerValue},
+		{s, false, m.SellerUnits, first, sellerCost, m.MaxSellerValue},
+	} {
+		m.inBlocks(len(side.agents), func(_, block, lo, hi int) {
+			r := m.blockSource(side.buyer, block)
+			for i := lo; i < hi; i++ {
+				at := side.from + i*side.units
+				schedule := schedules[at : at+side.units : at+side.units]
+				for k := range schedule {
+					schedule[k] = side.value(r)
+					if m.MarketShift != 0 {
+						schedule[k] = m.shiftInto(m.market(i), schedule[k], side.max)
+					}
+				}
+				held := 0
+				if side.buyer {
+					sort.Sort(sort.Reverse(sort.IntSlice(schedule)))
+				} else {
+					sort.Ints(schedule)
+					held = side.units
+				}
+
+				name := m.Strategy
+				if draw {
+					x, cumulative := r.Float64(), 0.0
+					for _, t := range shares {
+						if cumulative += t.share; x < cumulative {
+							name = t.strategy
+							break
+						}
+					}
+				}
+				kind := m.kind(name)
+				side.agents[i] = agent{
+					buyerOrSeller: side.buyer,
+					kind:          kind,
+					strategy:      factories[kind](side.buyer, r),
+					quantityHeld:  int32(held),
+					value:         schedule[0],
+					schedule:      schedule,
+					prices:        prices[at*m.Periods : at*m.Periods : (at+side.units)*m.Periods]}
+				if _, ok := side.agents[i].strategy.(Learner); ok {
+					atomic.StoreInt32(&learning, 1)
+				}
+			}
+		})
+	}
+	m.learning = learning == 1
+
+	return b, s, nil
+}
+
+// Agents drawn at a time from a block's generator.
+const initBlock = 1 << 14
+
+// The generator of the given block of buyers or sellers. It depends only on
+// the seed and the block, so the agents drawn do not depend on how many
+// goroutines draw them.
+func (m *Model) blockSource(buyers bool, block int) *rand.Rand {
+	x := uint64(2 * block)
+	if buyers {
+		x++
+	}
+	return rand.New(newXoshiro(int64(splitmix(uint64(m.Seed)) ^ x)).mirror(m.Antithetic))
+}
+
+// The goroutines that work through n agents in blocks: as many as there are
+// processors, but no more than there are blocks.
+func blockWorkers(n int) int {
+	blocks := (n + initBlock - 1) / initBlock
+	workers := runtime.GOMAXPROCS(0)
+	if workers > blocks {
+		workers = blocks
+	}
+	return workers
+}
+
+// Call do with each block of n agents and its bounds, from blockWorkers(n)
+// goroutines, each of which passes its own number as worker.
+func (m *Model) inBlocks(n int, do func(worker, block, lo, hi int)) {
+	blocks := (n + initBlock - 1) / initBlock
+	var next int64 = -1
+	var wg sync.WaitGroup
+	for w := 0; w < blockWorkers(n); w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for block := int(atomic.AddInt64(&next, 1)); block < blocks; block = int(atomic.AddInt64(&next, 1)) {
+				lo, hi := block*initBlock, (block+1)*initBlock
+				if hi > n {
+					hi = n
+				}
+				do(w, block, lo, hi)
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+// The highest price any trader can quote.
+func (m *Model) maxPrice() int {
+	if m.MaxBuyerValue > m.MaxSellerValue {
+		return m.MaxBuyerValue
+	}
+	return m.MaxSellerValue
+}
+
+// Draw a buyer's bid from its strategy, or NoQuote if the buyer stays out,
+// given the thread's market h. ZI-C traders, the common case, skip building
+// a view of the market; this keeps the default model as fast as before
+// strategies were pluggable.
+func (m *Model) bid(a *agent, h *history, r *rand.Rand) int {
+	if m.policy {
+		return m.policed(a, h, r)
+	}
+	if _, ok := a.strategy.(zic); ok {
+		return zicBid(a.value, r)
+	}
+	return a.strategy.Bid(m.view(a, h, r))
+}
+
+// Draw a seller's ask from its strategy, or NoQuote if the seller stays out,
+// given the thread's market h.
+func (m *Model) ask(a *agent, h *history, r *rand.Rand) int {
+	if m.policy {
+		return m.policed(a, h, r)
+	}
+	if _, ok := a.strategy.(zic); ok {
+		return zicAsk(a.value, m.MaxSellerValue, r)
+	}
+	return a.strategy.Ask(m.view(a, h, r))
+}
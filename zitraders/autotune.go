@@ -0,0 +1,78 @@
+package zitraders
+
+import (
+	"context"
+	"runtime"
+	"time"
+)
+
+// A Trial is a warm-up run of the autotuner.
+type Trial struct {
+	Threads  int           `json:"threads"`
+	Attempts int64         `json:"attempts"`
+	Elapsed  time.Duration `json:"elapsed"` // spent trading, not drawing the agents or computing statistics
+	Rate     float64       `json:"rate"`    // attempts per second
+}
+
+// Autotune times short warm-up runs of config with a few numbers of threads,
+// from one to twice GOMAXPROCS, and returns the fastest with the trials in
+// order of threads. Each trial makes the given number of trade attempts, or a
+// tenth of MaxNumberOfTrades if it is zero, in a single trading period with
+// no stopping rules. Each also draws its own agents, which takes longer than
+// the trial itself in a large market, so autotuning pays only for long runs.
+// If ctx is done the fastest of the trials made is returned.
+func Autotune(ctx context.Context, config Config, attempts int) (int, []Trial, error) {
+	if attempts <= 0 {
+		attempts = config.MaxNumberOfTrades / 10
+	}
+	var trials []Trial
+	best := -1
+	for _, n := range threadCounts(runtime.GOMAXPROCS(0)) {
+		c := config
+		c.NumThreads, c.MaxNumberOfTrades, c.Periods = n, attempts, 1
+		c.TickSize, c.StopWhenCleared, c.MinTradeRate, c.Shocks = 0, false, 0, nil
+		if c.MaxNumberOfTrades < 2*n {
+			c.MaxNumberOfTrades = 2 * n
+		}
+		m, err := New(c)
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(trials) > 0 && m.NumThreads == trials[len(trials)-1].Threads {
+			continue // too few traders for more threads
+		}
+		var t Trial
+		m.Observe(func(m *Model, tick Tick) bool {
+			if tick.Final {
+				t = Trial{Threads: m.NumThreads, Attempts: tick.Attempts, Elapsed: tick.Elapsed}
+			}
+			return true
+		})
+		m.RunContext(ctx)
+		if ctx.Err() != nil {
+			break
+		}
+		if t.Elapsed > 0 {
+			t.Rate = float64(t.Attempts) / t.Elapsed.Seconds()
+		}
+		if best < 0 || t.Rate > trials[best].Rate {
+			best = len(trials)
+		}
+		trials = append(trials, t)
+	}
+	if best < 0 {
+		return config.NumThreads, trials, nil
+	}
+	return trials[best].Threads, trials, nil
+}
+
+// The numbers of threads the autotuner tries on p processors.
+func threadCounts(p int) []int {
+	counts := []int{1}
+	for _, n := range []int{p / 2, p, 2 * p} {
+		if n > counts[len(counts)-1] {
+			counts = append(counts, n)
+		}
+	}
+	return counts
+}
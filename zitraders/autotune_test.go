@@ -0,0 +1,40 @@
+package zitraders
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestThreadCounts(t *testing.T) {
+	for p, want := range map[int][]int{1: {1, 2}, 2: {1, 2, 4}, 8: {1, 4, 8, 16}} {
+		if got := threadCounts(p); !reflect.DeepEqual(got, want) {
+			t.Errorf("%d processors: tried %v, want %v", p, got, want)
+		}
+	}
+}
+
+// The autotuner picks the fastest of its trials.
+func TestAutotune(t *testing.T) {
+	threads, trials, err := Autotune(context.Background(), testConfig(), 20000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trials) < 2 {
+		t.Fatalf("%d trials", len(trials))
+	}
+	var chosen Trial
+	for _, tr := range trials {
+		if tr.Attempts == 0 || tr.Rate <= 0 {
+			t.Errorf("trial %+v made no progress", tr)
+		}
+		if tr.Threads == threads {
+			chosen = tr
+		}
+	}
+	for _, tr := range trials {
+		if tr.Rate > chosen.Rate {
+			t.Errorf("chose %d threads over the faster %+v", threads, tr)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package zitraders
+
+import (
+	"context"
+	"fmt"
+)
+
+// The batched loop is a faster form of doTrades for the plainest market: ZI-C
+// traders meeting at random within their partitions, with no price controls,
+// market makers or submarkets. Rather than drawing each attempt's pair and
+// quotes as it goes, a thread fills fixed arrays of tradeBatch attempts with
+// their random buyers, sellers and quote fractions in one tight loop, then
+// runs the attempts over them, and only the prices of the trades are drawn as
+// they happen. Fixed arrays let the compiler drop the bounds checks, and the
+// generator's work pipelines without the branches of matching in between.
+// Draws are scaled to a range by multiplication rather than by rejection, so
+// the random stream, and with it every run, differs from the scalar loop's,
+// though the model is the same.
+
+// Attempts drawn at a time by the batched loop.
+const tradeBatch = 1024
+
+// A batch of attempts' random draws: the indices of their buyers and sellers
+// within the partition, and where in their ranges the bid and ask fall, as
+// fractions of 2^32.
+type draws struct {
+	buyers  [tradeBatch]uint32
+	sellers [tradeBatch]uint32
+	bids    [tradeBatch]uint32
+	asks    [tradeBatch]uint32
+}
+
+func (m *Model) checkBatched() error {
+	if m.Batched && (!m.bilateral() || m.Matching != Partitioned || m.Layout != AoS || m.mixed() ||
+		m.policy || m.Dealer || m.Markets > 1 || m.samplers != nil) {
+		return fmt.Errorf("the batched loop supports only ZI-C traders in a single partitioned bilateral market with random matching, the aos layout and no price controls or market makers")
+	}
+	return nil
+}
+
+// Fill d with draws for partitions of nb buyers and ns sellers.
+func (d *draws) fill(x *xoshiro, nb, ns uint64) {
+	for k := range d.buyers {
+		u, v := x.Uint64(), x.Uint64()
+		d.buyers[k] = uint32((u & 0xffffffff) * nb >> 32)
+		d.sellers[k] = uint32((u >> 32) * ns >> 32)
+		d.bids[k] = uint32(v)
+		d.asks[k] = uint32(v >> 32)
+	}
+}
+
+// The integer in [lo, hi] at fraction f of 2^32 along it.
+func scale(f uint32, lo, hi int) int {
+	return lo + int(uint64(f)*uint64(hi-lo+1)>>32)
+}
+
+// Run a partition's attempts as doTrades does, drawing them in batches from
+// the thread's source x.
+func (m *Model) doBatchedTrades(ctx context.Context, p partition, x *xoshiro) []Trade {
+	buyers, sellers := p.buyers, p.sellers
+	var trades []Trade
+	progress := m.tally(p.thread, &trades)
+	defer progress.close()
+
+	d := new(draws)
+	i := 1 + m.skip
+	for {
+		d.fill(x, uint64(len(buyers)), uint64(len(sellers)))
+		for k := 0; k < tradeBatch; k, i = k+1, i+1 {
+			if !m.more(ctx, i, progress) {
+				return trades
+			}
+			progress.attempt()
+			buyer, seller := &buyers[d.buyers[k]], &sellers[d.sellers[k]]
+			if !buyer.canBuy() || !seller.canSell() {
+				continue
+			}
+			bid := scale(d.bids[k], 1, buyer.value)
+			ask := scale(d.asks[k], seller.value, m.MaxSellerValue)
+			if bid < ask {
+				continue
+			}
+			price := scale(uint32(x.Uint64()>>32), ask, bid)
+			buyer.buy(price)
+			seller.sell(price)
+			progress.trade(price)
+			if m.RecordTrades {
+				t := Trade{Bid: bid, Ask: ask, Price: price}.between(int(d.buyers[k]), int(d.sellers[k]))
+				trades = append(trades, p.record(t, i))
+			}
+		}
+	}
+}
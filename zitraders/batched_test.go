@@ -0,0 +1,52 @@
+package zitraders
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestScale(t *testing.T) {
+	for _, c := range []struct{ lo, hi int }{{1, 1}, {1, 30}, {17, 200}} {
+		if got := scale(0, c.lo, c.hi); got != c.lo {
+			t.Errorf("scale(0, %d, %d) = %d", c.lo, c.hi, got)
+		}
+		if got := scale(math.MaxUint32, c.lo, c.hi); got != c.hi {
+			t.Errorf("scale(max, %d, %d) = %d", c.lo, c.hi, got)
+		}
+	}
+}
+
+// The batched loop runs the same model as the scalar one.
+func TestBatched(t *testing.T) {
+	config := testConfig()
+	config.Units, config.Periods, config.TickSize = 2, 2, 1000
+	scalar := newTestModel(t, config).Run()
+	config.Batched = true
+	m := newTestModel(t, config)
+	m.Observe(func(m *Model, tick Tick) bool { return true })
+	r := m.Run()
+
+	if r.Attempts != scalar.Attempts {
+		t.Errorf("%d attempts, want %d", r.Attempts, scalar.Attempts)
+	}
+	if math.Abs(r.Efficiency-scalar.Efficiency) > 2 {
+		t.Errorf("efficiency %.2f, scalar %.2f", r.Efficiency, scalar.Efficiency)
+	}
+}
+
+func benchmarkBatchedTrades(b *testing.B, n int) {
+	config := testConfig()
+	config.NumBuyers, config.NumSellers = n, n
+	config.MaxNumberOfTrades = b.N + 1
+	config.NumThreads = 1
+	config.Batched = true
+	m := newTestModel(b, config)
+	p := m.partitions()[0]
+	b.ResetTimer()
+	m.doBatchedTrades(context.Background(), p, newStream(1, 1))
+}
+
+func BenchmarkBatchedTrades1K(b *testing.B)   { benchmarkBatchedTrades(b, 1000) }
+func BenchmarkBatchedTrades100K(b *testing.B) { benchmarkBatchedTrades(b, 100000) }
+func BenchmarkBatchedTrades1M(b *testing.B)   { benchmarkBatchedTrades(b, 1000000) }
@@ -0,0 +1,323 @@
+package zitraders
+
+import (
+	"container/heap"
+	"context"
+	"math/rand"
+	"sync/atomic"
+)
+
+// An order is a standing quote in the book. Orders at the same price are
+// ranked by arrival.
+type order struct {
+	agent int
+	price int
+	seq   int
+}
+
+type bidQueue []order
+
+func (q bidQueue) Len() int { return len(q) }
+func (q bidQueue) Less(i, j int) bool {
+	return q[i].price > q[j].price || (q[i].price == q[j].price && q[i].seq < q[j].seq)
+}
+func (q bidQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *bidQueue) Push(x interface{}) { *q = append(*q, x.(order)) }
+func (q *bidQueue) Pop() interface{} {
+	old := *q
+	o := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return o
+}
+
+type askQueue []order
+
+func (q askQueue) Len() int { return len(q) }
+func (q askQueue) Less(i, j int) bool {
+	return q[i].price < q[j].price || (q[i].price == q[j].price && q[i].seq < q[j].seq)
+}
+func (q askQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *askQueue) Push(x interface{}) { *q = append(*q, x.(order)) }
+func (q *askQueue) Pop() interface{} {
+	old := *q
+	o := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return o
+}
+
+// A book is a limit order book in which each agent has at most one standing
+// order. A new quote replaces the agent's previous one; replaced orders stay
+// in the queues and are discarded when they reach the top. With a lifetime,
+// orders expire that many attempts after they join the book.
+type book struct {
+	bids     bidQueue
+	asks     askQueue
+	seq      int
+	buyers   map[int]int // live order sequence number by buyer
+	sellers  map[int]int // live order sequence number by seller
+	now      int         // the attempt under way
+	lifetime int
+	expiring []expiry // orders in the order they expire, which is the order they joined
+}
+
+// When an order leaves the book unless it has traded or been replaced.
+type expiry struct {
+	at, seq, agent int
+	buyer          bool
+}
+
+func newBook(lifetime int) *book {
+	return &book{buyers: make(map[int]int), sellers: make(map[int]int), lifetime: lifetime}
+}
+
+// Remove the orders that expire by attempt i, calling f with the trader of
+// each that was still live.
+func (b *book) expire(i int, f func(buyer bool, agent int)) {
+	n := 0
+	for ; n < len(b.expiring) && b.expiring[n].at <= i; n++ {
+		e := b.expiring[n]
+		live := b.sellers
+		if e.buyer {
+			live = b.buyers
+		}
+		if live[e.agent] == e.seq {
+			delete(live, e.agent)
+			f(e.buyer, e.agent)
+		}
+	}
+	b.expiring = b.expiring[n:]
+	b.compact()
+}
+
+// Drop the dead orders from the queues once they outnumber the live ones, as
+// they do when expired orders are replaced faster than they reach the top.
+func (b *book) compact() {
+	if len(b.bids)+len(b.asks) < 2*(len(b.buyers)+len(b.sellers))+64 {
+		return
+	}
+	bids := b.bids[:0]
+	for _, o := range b.bids {
+		if b.buyers[o.agent] == o.seq {
+			bids = append(bids, o)
+		}
+	}
+	asks := b.asks[:0]
+	for _, o := range b.asks {
+		if b.sellers[o.agent] == o.seq {
+			asks = append(asks, o)
+		}
+	}
+	b.bids, b.asks = bids, asks
+	heap.Init(&b.bids)
+	heap.Init(&b.asks)
+}
+
+// Note when a new order expires.
+func (b *book) expires(buyer bool, agent int) {
+	if b.lifetime > 0 {
+		b.expiring = append(b.expiring, expiry{at: b.now + b.lifetime, seq: b.seq, agent: agent, buyer: buyer})
+	}
+}
+
+// The best live bid, if any.
+func (b *book) bestBid() (order, bool) {
+	for len(b.bids) > 0 {
+		if o := b.bids[0]; b.buyers[o.agent] == o.seq {
+			return o, true
+		}
+		heap.Pop(&b.bids)
+	}
+	return order{}, false
+}
+
+// The best live ask, if any.
+func (b *book) bestAsk() (order, bool) {
+	for len(b.asks) > 0 {
+		if o := b.asks[0]; b.sellers[o.agent] == o.seq {
+			return o, true
+		}
+		heap.Pop(&b.asks)
+	}
+	return order{}, false
+}
+
+// Submit a bid. If it crosses the best ask, the ask is removed from the book
+// and returned; otherwise the bid joins the book.
+func (b *book) bid(buyer, price int) (order, bool) {
+	delete(b.buyers, buyer)
+	if ask, ok := b.bestAsk(); ok && ask.price <= price {
+		heap.Pop(&b.asks)
+		delete(b.sellers, ask.agent)
+		return ask, true
+	}
+	b.seq++
+	b.buyers[buyer] = b.seq
+	heap.Push(&b.bids, order{agent: buyer, price: price, seq: b.seq})
+	b.expires(true, buyer)
+	return order{}, false
+}
+
+// Submit an ask. If it crosses the best bid, the bid is removed from the book
+// and returned; otherwise the ask joins the book.
+func (b *book) ask(seller, price int) (order, bool) {
+	delete(b.sellers, seller)
+	if bid, ok := b.bestBid(); ok && bid.price >= price {
+		heap.Pop(&b.bids)
+		delete(b.buyers, bid.agent)
+		return bid, true
+	}
+	b.seq++
+	b.sellers[seller] = b.seq
+	heap.Push(&b.asks, order{agent: seller, price: price, seq: b.seq})
+	b.expires(false, seller)
+	return order{}, false
+}
+
+// Whether the next trader to quote in an order book or call market is a
+// buyer. Each side arrives equally often unless BuyerArrival says otherwise,
+// whatever its size, so a thin side's traders quote more often.
+func (m *Model) buyerArrives(r *rand.Rand) bool {
+	if m.BuyerArrival == 0 {
+		return r.Intn(2) == 0
+	}
+	return r.Float64() < m.BuyerArrival
+}
+
+// Run a continuous double auction within a thread's partition. At each step a
+// random trader who can still trade submits a quote to the partition's
+// order book, and a trade executes at the standing quote's price whenever the
+// new quote crosses it. Under a latency model slow traders' quotes reach the
+// book some steps later, ahead of the step's own quote. Orders with a
+// lifetime expire before either, and their traders may quote again at once.
+func (m *Model) doAuction(ctx context.Context, p partition, generator *rand.Rand) []Trade {
+
+	buyers, sellers := p.buyers, p.sellers
+	b := newBook(m.OrderLifetime)
+	var trades []Trade
+	progress := m.tally(p.thread, &trades)
+	defer progress.close()
+
+	// Submit a quote to the book, and trade if it crosses the standing quote.
+	submit := func(i int, buyer bool, agent, price int) {
+		var t Trade
+		if buyer {
+			ask, ok := b.bid(agent, price)
+			p.history.add(price, true, ok)
+			if !ok {
+				if best, ok := b.bestAsk(); ok {
+					m.missed(&buyers[agent], p.history, best.price, generator)
+				}
+				return
+			}
+			p.history.add(ask.price, false, true)
+			t = Trade{Buyer: agent, Seller: ask.agent, Bid: price, Ask: ask.price, Price: ask.price}
+		} else {
+			bid, ok := b.ask(agent, price)
+			p.history.add(price, false, ok)
+			if !ok {
+				if best, ok := b.bestBid(); ok {
+					m.missed(&sellers[agent], p.history, best.price, generator)
+				}
+				return
+			}
+			p.history.add(bid.price, true, true)
+			t = Trade{Buyer: bid.agent, Seller: agent, Bid: bid.price, Ask: price, Price: bid.price}
+		}
+
+		// execute trade
+		m.traded(&buyers[t.Buyer], p.history, t.Price, generator)
+		m.traded(&sellers[t.Seller], p.history, t.Price, generator)
+		buyers[t.Buyer].buy(t.Price)
+		sellers[t.Seller].sell(t.Price)
+		progress.trade(t.Price)
+
+		if m.RecordTrades {
+			trades = append(trades, p.record(t, i))
+		}
+	}
+
+	var flight *arrivals
+	if m.Latency > 0 {
+		flight = newArrivals()
+		defer func() {
+			atomic.AddInt64(&m.dropped, flight.dropped+int64(len(flight.buyers)+len(flight.sellers)))
+		}()
+	}
+
+	// Have a trader who can trade quote, sending the quote to the book or, if
+	// it is slow, on its way there.
+	quote := func(i int, buyer bool, agent int) {
+		var price int
+		if buyer {
+			price = m.bid(&buyers[agent], p.history, generator)
+		} else {
+			price = m.ask(&sellers[agent], p.history, generator)
+		}
+		if price == NoQuote {
+			return
+		}
+
+		if flight != nil {
+			offset, trader := p.sellerOffset, &sellers[agent]
+			if buyer {
+				offset, trader = p.buyerOffset, &buyers[agent]
+			}
+			if !m.fast(buyer, offset+agent) {
+				if delay := int(generator.ExpFloat64() * m.Latency); delay > 0 {
+					flight.send(i+delay, buyer, agent, price, int(trader.quantityHeld))
+					return
+				}
+			}
+			flight.cancel(buyer, agent)
+		}
+		submit(i, buyer, agent, price)
+	}
+
+	var expired int64
+	defer func() { atomic.AddInt64(&m.expired, expired) }()
+
+	for i := 1; i < m.tradesPerThread && m.advance(ctx, i, progress); i++ {
+		progress.attempt()
+		p.history.at(i)
+		b.now = i
+		b.expire(i, func(buyer bool, agent int) {
+			expired++
+			if m.Requote && ((buyer && buyers[agent].canBuy()) || (!buyer && sellers[agent].canSell())) {
+				p.history.see(b)
+				quote(i, buyer, agent)
+			}
+		})
+		if flight != nil {
+			for x, ok := flight.next(i); ok; x, ok = flight.next(i) {
+				trader := &sellers[x.agent]
+				if x.buyer {
+					trader = &buyers[x.agent]
+				}
+				if int(trader.quantityHeld) != x.held {
+					flight.dropped++
+					continue
+				}
+				p.history.see(b)
+				submit(i, x.buyer, x.agent, x.price)
+			}
+		}
+		p.history.see(b)
+		m.observeBook(m.books[p.thread], b, p, i)
+
+		buyer := m.buyerArrives(generator)
+		var agent int
+		if buyer {
+			agent = generator.Intn(len(buyers))
+			if !buyers[agent].canBuy() {
+				continue
+			}
+		} else {
+			agent = generator.Intn(len(sellers))
+			if !sellers[agent].canSell() {
+				continue
+			}
+		}
+		quote(i, buyer, agent)
+	}
+	return trades
+}
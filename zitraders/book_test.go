@@ -0,0 +1,36 @@
+package zitraders
+
+import "testing"
+
+// Orders expire their lifetime after joining the book, unless they have
+// traded or been replaced.
+func TestBookExpiry(t *testing.T) {
+	b := newBook(10)
+	b.now = 1
+	b.bid(1, 15)
+	b.ask(2, 20)
+	b.now = 3
+	b.bid(3, 12)
+	b.bid(1, 16) // replaces buyer 1's first bid
+	b.now = 5
+	b.ask(4, 16) // trades with buyer 1's second bid
+
+	var expired []int
+	for i := 1; i <= 15; i++ {
+		b.expire(i, func(buyer bool, agent int) {
+			if buyer != (agent%2 == 1) {
+				t.Errorf("agent %d expired on the wrong side", agent)
+			}
+			expired = append(expired, agent)
+		})
+	}
+	if len(expired) != 2 || expired[0] != 2 || expired[1] != 3 {
+		t.Errorf("expired %v, want [2 3]", expired)
+	}
+	if _, ok := b.bestBid(); ok {
+		t.Errorf("a bid is still live")
+	}
+	if _, ok := b.bestAsk(); ok {
+		t.Errorf("an ask is still live")
+	}
+}
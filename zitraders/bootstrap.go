@@ -0,0 +1,126 @@
+package zitraders
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// BootstrapResults give moving block bootstrap intervals for statistics of a
+// single run. Successive trades are correlated, so rather than single trades
+// the bootstrap resamples blocks of consecutive trades of the log, which keep
+// the correlation within them.
+type BootstrapResults struct {
+	Resamples  int      `json:"resamples"`
+	Block      int      `json:"block"`      // trades in a block
+	MeanPrice  Interval `json:"mean_price"` // of the trades in the log, each counted once
+	Efficiency Interval `json:"efficiency"` // with any intermediaries' profits taken as given
+	Alpha      Interval `json:"alpha"`
+}
+
+// Bootstrap draws resamples resamples of the trade log of a model that has
+// run and recorded its trades, in blocks of block trades (zero for the cube
+// root of the number of trades), and returns percentile intervals for the
+// statistics of r, the model's results.
+func (m *Model) Bootstrap(r Results, resamples, block int) (BootstrapResults, error) {
+	n := len(m.trades)
+	if !m.RecordTrades || n == 0 || r.Marginal == nil {
+		return BootstrapResults{}, fmt.Errorf("a bootstrap needs a run with trades recorded")
+	}
+	if resamples < 1 || block < 0 {
+		return BootstrapResults{}, fmt.Errorf("a bootstrap needs at least one resample and a block that is not negative")
+	}
+	if block == 0 {
+		block = int(math.Ceil(math.Cbrt(float64(n))))
+	}
+	if block > n {
+		block = n
+	}
+
+	// Sums of the prices, their squares and the traders' surplus over the
+	// first i trades, from which the sums over any block follow at once.
+	prices, squares, surplus := make([]int64, n+1), make([]int64, n+1), make([]int64, n+1)
+	m.walkTrades(func(k int, t *Trade, value, cost int) {
+		s := 0
+		if t.Buyer >= 0 {
+			s += value - t.Price
+		}
+		if t.Seller >= 0 {
+			s += t.Price - cost
+		}
+		p := int64(t.Price)
+		prices[k+1] = prices[k] + p
+		squares[k+1] = squares[k] + p*p
+		surplus[k+1] = surplus[k] + int64(s)
+	})
+	// The sum over the block of length block starting at trade i, wrapping
+	// around the end of the log.
+	sum := func(x []int64, i int) int64 {
+		if i+block <= n {
+			return x[i+block] - x[i]
+		}
+		return x[n] - x[i] + x[i+block-n]
+	}
+
+	intermediaries := float64(r.RealizedSurplus - r.Marginal.IntramarginalSurplus - r.Marginal.ExtramarginalSurplus)
+	eq := r.EquilibriumPrice
+	blocks := (n + block - 1) / block
+	length := float64(blocks * block)
+	g := rand.New(m.stream(m.NumThreads + 3 + len(m.Shocks)))
+	var means, efficiencies, alphas []float64
+	for b := 0; b < resamples; b++ {
+		var p, q, s int64
+		for k := 0; k < blocks; k++ {
+			i := g.Intn(n)
+			p += sum(prices, i)
+			q += sum(squares, i)
+			s += sum(surplus, i)
+		}
+		mean := float64(p) / length
+		means = append(means, mean)
+		if r.MaxSurplus > 0 {
+			realized := float64(s)*float64(n)/length + intermediaries
+			efficiencies = append(efficiencies, 100*realized/float64(r.MaxSurplus))
+		}
+		// The mean squared deviation from eq is that from the mean plus the
+		// squared distance between the two.
+		d := mean - eq
+		alphas = append(alphas, alpha(float64(q)/length-mean*mean+d*d, eq))
+	}
+	return BootstrapResults{
+		Resamples:  resamples,
+		Block:      block,
+		MeanPrice:  percentiles(means),
+		Efficiency: percentiles(efficiencies),
+		Alpha:      percentiles(alphas),
+	}, nil
+}
+
+// The percentile bootstrap 95% interval of the mean of xs, from resamples
+// resamples of xs drawn with replacement, or nil if there are none.
+func bootstrapMean(xs []float64, resamples int, r *rand.Rand) *Interval {
+	if len(xs) == 0 || resamples < 1 {
+		return nil
+	}
+	means := make([]float64, resamples)
+	for b := range means {
+		sum := 0.0
+		for range xs {
+			sum += xs[r.Intn(len(xs))]
+		}
+		means[b] = sum / float64(len(xs))
+	}
+	i := percentiles(means)
+	return &i
+}
+
+// The 2.5th and 97.5th percentiles of the statistic over the resamples.
+func percentiles(xs []float64) Interval {
+	if len(xs) == 0 {
+		return Interval{}
+	}
+	sort.Float64s(xs)
+	last := float64(len(xs) - 1)
+	return Interval{Low: xs[int(0.025*last)], High: xs[int(0.975*last)]}
+}
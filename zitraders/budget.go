@@ -0,0 +1,80 @@
+package zitraders
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// Under a shared budget the threads of a bilateral market with global matching
+// draw their trade attempts from a common counter instead of making an equal
+// share each, so a period makes exactly MaxNumberOfTrades attempts whatever
+// the number of threads, and threads whose attempts are quick take on more of
+// them. Under partitioned matching the fastest thread would take the attempts
+// of the others, whose traders would then never be offered a trade, so it
+// needs global matching, where every thread draws from every trader. The
+// counter is drawn down in chunks to keep contention on it low, and refilled
+// a tick's worth at a time while the threads are paused. Which thread makes
+// which attempt depends on how the threads are scheduled, so a run with a
+// shared budget cannot be reproduced from its seed.
+
+// Attempts a thread draws from the shared budget at a time.
+const budgetChunk = 256
+
+type budget struct {
+	left     int64 // attempts left to draw in the current tick; accessed atomically
+	unissued int   // attempts of the period not yet issued to a tick
+}
+
+func (m *Model) checkBudget() error {
+	if m.SharedBudget && (!m.bilateral() || m.Matching != Global) {
+		return fmt.Errorf("a shared budget requires a bilateral market with global matching")
+	}
+	return nil
+}
+
+// Issue the attempts of the next tick, or of the whole period without ticks.
+// It is called only while no thread is trading.
+func (m *Model) issue() {
+	n := m.budget.unissued
+	if tick := m.size * m.NumThreads; tick > 0 && tick < n {
+		n = tick
+	}
+	m.budget.unissued -= n
+	atomic.StoreInt64(&m.budget.left, int64(n))
+}
+
+// Draw up to a chunk of attempts from the budget, returning how many.
+func (m *Model) claim() int {
+	left := atomic.AddInt64(&m.budget.left, -budgetChunk)
+	switch {
+	case left >= 0:
+		return budgetChunk
+	case left > -budgetChunk:
+		return int(left + budgetChunk)
+	}
+	return 0
+}
+
+// Whether a thread goes on to attempt i: under a shared budget, whether it
+// draws another attempt, and otherwise whether i is within its share. Either
+// way the thread pauses at the end of every tick.
+func (m *Model) more(ctx context.Context, i int, progress *tally) bool {
+	if !m.SharedBudget {
+		return i < m.tradesPerThread && m.advance(ctx, i, progress)
+	}
+	for progress.quota == 0 {
+		// Threads that never pause check ctx whenever they draw a chunk.
+		if m.size == 0 && ctx.Err() != nil {
+			return false
+		}
+		if progress.quota = m.claim(); progress.quota > 0 {
+			break
+		}
+		if m.size == 0 || m.budget.unissued == 0 || !m.pause(progress) {
+			return false
+		}
+	}
+	progress.quota--
+	return true
+}
@@ -0,0 +1,56 @@
+package zitraders
+
+import (
+	"math"
+	"runtime"
+	"testing"
+)
+
+// A shared budget makes exactly the attempts asked for, however they divide
+// among the threads, and every thread takes part.
+func TestSharedBudget(t *testing.T) {
+	for _, tick := range []int{0, 777} {
+		config := testConfig()
+		config.Matching, config.TickSize = Global, tick
+		config.NumThreads, config.MaxNumberOfTrades = 3, 100001
+		config.SharedBudget = true
+		m := newTestModel(t, config)
+		ticks := 0
+		m.Observe(func(m *Model, tick Tick) bool {
+			ticks++
+			return true
+		})
+		r := m.Run()
+
+		if r.Attempts != 100001 {
+			t.Errorf("tick %d: %d attempts, want 100001", tick, r.Attempts)
+		}
+		if tick > 0 && ticks != 100001/(3*tick)+1 {
+			t.Errorf("tick %d: %d ticks", tick, ticks)
+		}
+		// With fewer processors than threads, one may draw the whole budget
+		// before the others are scheduled.
+		for _, p := range m.Performance().Threads {
+			if p.Attempts == 0 && runtime.NumCPU() >= config.NumThreads {
+				t.Errorf("tick %d: thread %d made no attempts", tick, p.Thread)
+			}
+		}
+
+		// The market trades as it does with equal shares.
+		config.SharedBudget, config.MaxNumberOfTrades = false, 100002
+		equal := newTestModel(t, config).Run()
+		if math.Abs(r.Efficiency-equal.Efficiency) > 5 {
+			t.Errorf("tick %d: efficiency %.1f%%, but %.1f%% with equal shares", tick, r.Efficiency, equal.Efficiency)
+		}
+	}
+}
+
+// Under partitioned matching the budget would let one thread starve the
+// partitions of the others.
+func TestSharedBudgetPartitioned(t *testing.T) {
+	config := testConfig()
+	config.SharedBudget = true
+	if _, err := New(config); err == nil {
+		t.Error("a shared budget was allowed under partitioned matching")
+	}
+}
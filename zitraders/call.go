@@ -0,0 +1,176 @@
+package zitraders
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+)
+
+// Run a periodic call market within a thread's partition. Each round collects
+// CallRound quotes, one unit each, from random traders who can still trade (a
+// trader's latest quote in the round replaces earlier ones), then clears the
+// market at a single price and executes every compatible bid-ask pair at that
+// price.
+func (m *Model) doCallMarket(ctx context.Context, p partition, generator *rand.Rand) []Trade {
+
+	buyers, sellers := p.buyers, p.sellers
+	bids := make(map[int]int)
+	asks := make(map[int]int)
+	var trades []Trade
+	progress := m.tally(p.thread, &trades)
+	defer progress.close()
+
+	for i := 1; i < m.tradesPerThread && m.advance(ctx, i, progress); i++ {
+		progress.attempt()
+		p.history.at(i)
+		if m.buyerArrives(generator) {
+			buyerIndex := generator.Intn(len(buyers))
+			if buyers[buyerIndex].canBuy() {
+				place(bids, buyerIndex, m.bid(&buyers[buyerIndex], p.history, generator))
+			}
+		} else {
+			sellerIndex := generator.Intn(len(sellers))
+			if sellers[sellerIndex].canSell() {
+				place(asks, sellerIndex, m.ask(&sellers[sellerIndex], p.history, generator))
+			}
+		}
+
+		if i%m.CallRound != 0 && i != m.tradesPerThread-1 {
+			continue
+		}
+
+		cleared := clearCall(bids, asks)
+		if p.history != nil {
+			rememberCall(p.history, bids, asks, cleared)
+		}
+		if len(cleared) > 0 {
+			m.observeCall(buyers, sellers, bids, asks, cleared, p.history, generator)
+		}
+		for _, t := range cleared {
+			buyers[t.Buyer].buy(t.Price)
+			sellers[t.Seller].sell(t.Price)
+			progress.trade(t.Price)
+
+			if m.RecordTrades {
+				trades = append(trades, p.record(t, i))
+			}
+		}
+		bids = make(map[int]int)
+		asks = make(map[int]int)
+	}
+	return trades
+}
+
+// Record a trader's latest quote in a round; a trader who stays out withdraws
+// any earlier quote.
+func place(quotes map[int]int, agent, price int) {
+	if price == NoQuote {
+		delete(quotes, agent)
+		return
+	}
+	quotes[agent] = price
+}
+
+// Let the traders who quoted in a round observe its outcome: those who
+// traded raise their margins toward the clearing price, and the others lower
+// theirs toward it.
+func (m *Model) observeCall(buyers, sellers []agent, bids, asks map[int]int, trades []Trade, h *history, generator *rand.Rand) {
+	if !m.learning {
+		return
+	}
+	price := trades[0].Price
+	for _, t := range trades {
+		m.traded(&buyers[t.Buyer], h, price, generator)
+		m.traded(&sellers[t.Seller], h, price, generator)
+		delete(bids, t.Buyer)
+		delete(asks, t.Seller)
+	}
+	for _, agent := range sortedKeys(bids) {
+		m.missed(&buyers[agent], h, price, generator)
+	}
+	for _, agent := range sortedKeys(asks) {
+		m.missed(&sellers[agent], h, price, generator)
+	}
+}
+
+// Remember every quote of a round, accepted if its trader traded. The
+// round's best quotes are the current quotes until the next round clears.
+func rememberCall(h *history, bids, asks map[int]int, trades []Trade) {
+	buyers := make(map[int]bool, len(trades))
+	sellers := make(map[int]bool, len(trades))
+	for _, t := range trades {
+		buyers[t.Buyer], sellers[t.Seller] = true, true
+	}
+	for _, agent := range sortedKeys(bids) {
+		h.add(bids[agent], true, buyers[agent])
+	}
+	for _, agent := range sortedKeys(asks) {
+		h.add(asks[agent], false, sellers[agent])
+	}
+	h.bid, h.ask = NoQuote, NoQuote
+	for _, price := range bids {
+		if price > h.bid {
+			h.bid = price
+		}
+	}
+	for _, price := range asks {
+		if h.ask == NoQuote || price < h.ask {
+			h.ask = price
+		}
+	}
+}
+
+// The keys of a map in increasing order, so that draws made while visiting
+// them do not depend on map order.
+func sortedKeys(m map[int]int) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// Clear a round of the call market: rank bids from highest and asks from
+// lowest, pair them off while they are compatible, and price every pair at
+// the midpoint of the range of market-clearing prices.
+func clearCall(bids, asks map[int]int) []Trade {
+	b := make([]order, 0, len(bids))
+	for agent, price := range bids {
+		b = append(b, order{agent: agent, price: price})
+	}
+	a := make([]order, 0, len(asks))
+	for agent, price := range asks {
+		a = append(a, order{agent: agent, price: price})
+	}
+	// Ties are broken by agent index so that clearing does not depend on map order.
+	sort.Slice(b, func(i, j int) bool {
+		return b[i].price > b[j].price || (b[i].price == b[j].price && b[i].agent < b[j].agent)
+	})
+	sort.Slice(a, func(i, j int) bool {
+		return a[i].price < a[j].price || (a[i].price == a[j].price && a[i].agent < a[j].agent)
+	})
+
+	k := 0
+	for k < len(b) && k < len(a) && b[k].price >= a[k].price {
+		k++
+	}
+	if k == 0 {
+		return nil
+	}
+
+	low, high := a[k-1].price, b[k-1].price
+	if k < len(b) && b[k].price > low {
+		low = b[k].price
+	}
+	if k < len(a) && a[k].price < high {
+		high = a[k].price
+	}
+	price := (low + high) / 2
+
+	trades := make([]Trade, k)
+	for i := range trades {
+		trades[i] = Trade{Buyer: b[i].agent, Seller: a[i].agent, Bid: b[i].price, Ask: a[i].price, Price: price}
+	}
+	return trades
+}
@@ -0,0 +1,266 @@
+package zitraders
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// A checkpoint is the state of a run at the end of a tick, from which it can
+// be resumed exactly.
+type checkpoint struct {
+	Config       Config
+	Ticks        int
+	Period       int      // the trading period under way
+	First        int      // ticks completed in earlier periods
+	Mark         Progress // the counts at the start of the period
+	Periods      []Period // the completed periods
+	Struck       []bool   // which shocks have struck
+	Shocks       []ShockResults
+	Attempts     int64
+	Executed     int64
+	Volume       int64
+	Squares      int64
+	Sources      [][4]uint64
+	Buyers       []agentState
+	Sellers      []agentState
+	Stores       [2]storeState // buyers and sellers under the struct-of-arrays layouts
+	Histories    []historyState
+	Dealers      []dealerState
+	Arbitrageurs []arbitrageurState
+	Lattice      *latticeState
+	Trades       []Trade
+}
+
+type agentState struct {
+	Held     int
+	Value    int
+	Price    int
+	Earned   int
+	Schedule []int
+	Prices   []int
+	ZIP      []float64 // margin, learning rate, momentum coefficient and smoothed change of a ZIP trader
+}
+
+type historyState struct {
+	Prices   []int
+	Bids     []bool
+	Accepted []bool
+	Next     int
+	Bid, Ask int
+}
+
+type dealerState struct {
+	Inventory int
+	Cash      int
+	Ref       float64
+	Bought    int
+	Prices    []int
+}
+
+type arbitrageurState struct {
+	Stock  [][]int
+	Ref    []float64
+	Cash   int
+	Bought int
+	Prices []int
+}
+
+type latticeState struct {
+	Buyers, Sellers []int32
+	Source          [4]uint64
+	Moves           int64
+	Sums, Counts    [][]int64
+}
+
+type storeState struct {
+	Values        []int32
+	Prices        []int32
+	Held          []uint8
+	Unconstrained []bool
+}
+
+// Checkpoint writes the state of the run to w as a compressed checkpoint. It
+// must be called by an observer, while the trading threads are paused, and
+// only in bilateral markets; the institutions' order books are not saved.
+func (m *Model) Checkpoint(w io.Writer) error {
+	if !m.bilateral() {
+		return fmt.Errorf("checkpoints are supported only in bilateral markets")
+	}
+	if m.Transfer == CAS {
+		return fmt.Errorf("traders' prices under the cas transfer are settled only at the end of a period")
+	}
+	if m.Batched {
+		return fmt.Errorf("the batched loop draws ahead of its trades, so its runs cannot be resumed exactly")
+	}
+	if m.SharedBudget {
+		return fmt.Errorf("a run with a shared budget cannot be resumed exactly")
+	}
+	c := checkpoint{
+		Config:   m.Config,
+		Ticks:    m.ticks,
+		Period:   m.period,
+		First:    m.first,
+		Mark:     m.mark,
+		Periods:  m.periods,
+		Struck:   m.struck,
+		Shocks:   m.shocks,
+		Attempts: atomic.LoadInt64(&m.attempts),
+		Executed: atomic.LoadInt64(&m.executed),
+		Volume:   atomic.LoadInt64(&m.volume),
+		Squares:  atomic.LoadInt64(&m.squares),
+		Trades:   mergeTrades(append(append([][]Trade(nil), m.logs...), m.trades)),
+	}
+	for _, x := range m.sources {
+		c.Sources = append(c.Sources, x.s)
+	}
+	if m.columnar() {
+		for i, s := range []columnStore{m.buyerStore, m.sellerStore} {
+			c.Stores[i] = s.state()
+		}
+	}
+	for _, h := range m.histories {
+		s := historyState{Next: h.next, Bid: h.bid, Ask: h.ask}
+		for _, q := range h.quotes[:h.n] {
+			s.Prices = append(s.Prices, q.price)
+			s.Bids = append(s.Bids, q.bid)
+			s.Accepted = append(s.Accepted, q.accepted)
+		}
+		c.Histories = append(c.Histories, s)
+	}
+	for _, d := range m.dealers {
+		c.Dealers = append(c.Dealers, dealerState{Inventory: d.inventory, Cash: d.cash, Ref: d.ref, Bought: d.bought, Prices: d.prices})
+	}
+	for _, a := range m.arbitrageurs {
+		c.Arbitrageurs = append(c.Arbitrageurs, arbitrageurState{Stock: a.stock, Ref: a.ref, Cash: a.cash, Bought: a.bought, Prices: a.prices})
+	}
+	if l := m.lattice; l != nil {
+		c.Lattice = &latticeState{Buyers: l.buyers, Sellers: l.sellers, Source: l.source.s, Moves: l.moves, Sums: l.sums, Counts: l.counts}
+	}
+	c.Buyers = saveAgents(m.buyers)
+	c.Sellers = saveAgents(m.sellers)
+
+	z := gzip.NewWriter(w)
+	if err := gob.NewEncoder(z).Encode(&c); err != nil {
+		return err
+	}
+	return z.Close()
+}
+
+func saveAgents(agents []agent) []agentState {
+	states := make([]agentState, len(agents))
+	for i, a := range agents {
+		states[i] = agentState{
+			Held:     int(a.quantityHeld),
+			Value:    a.value,
+			Price:    a.price,
+			Earned:   a.earned,
+			Schedule: a.schedule,
+			Prices:   a.prices,
+		}
+		if z, ok := a.strategy.(*zipTrader); ok {
+			states[i].ZIP = []float64{z.margin, z.beta, z.gamma, z.momentum}
+		}
+	}
+	return states
+}
+
+// Resume reads a checkpoint written by Checkpoint and returns a model that
+// continues the run from where it was saved. Observers must be registered
+// again before it runs.
+func Resume(r io.Reader) (*Model, error) {
+	z, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	var c checkpoint
+	if err := gob.NewDecoder(z).Decode(&c); err != nil {
+		return nil, fmt.Errorf("reading checkpoint: %v", err)
+	}
+
+	m, err := New(c.Config)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.Sources) != m.NumThreads+1 || len(c.Buyers) != len(m.buyers) || len(c.Sellers) != len(m.sellers) {
+		return nil, fmt.Errorf("checkpoint does not match its configuration")
+	}
+	m.ticks = c.Ticks
+	m.period, m.first, m.mark, m.periods = c.Period, c.First, c.Mark, c.Periods
+	if len(c.Struck) != len(m.struck) {
+		return nil, fmt.Errorf("checkpoint does not match its configuration")
+	}
+	m.struck, m.shocks = c.Struck, c.Shocks
+	m.attempts, m.executed, m.volume, m.squares = c.Attempts, c.Executed, c.Volume, c.Squares
+	m.trades = c.Trades
+	m.sources = make([]*xoshiro, len(c.Sources))
+	for i, s := range c.Sources {
+		m.sources[i] = (&xoshiro{s: s}).mirror(m.Antithetic)
+	}
+	if m.columnar() {
+		for i, s := range []columnStore{m.buyerStore, m.sellerStore} {
+			if err := s.restore(c.Stores[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(c.Histories) != len(m.histories) {
+		return nil, fmt.Errorf("checkpoint does not match its configuration")
+	}
+	for i, s := range c.Histories {
+		h := m.histories[i]
+		if len(s.Prices) > len(h.quotes) {
+			return nil, fmt.Errorf("checkpoint does not match its configuration")
+		}
+		for k, p := range s.Prices {
+			h.quotes[k] = quote{price: p, bid: s.Bids[k], accepted: s.Accepted[k]}
+		}
+		h.next, h.n, h.bid, h.ask = s.Next, len(s.Prices), s.Bid, s.Ask
+	}
+	if len(c.Dealers) != len(m.dealers) {
+		return nil, fmt.Errorf("checkpoint does not match its configuration")
+	}
+	for i, s := range c.Dealers {
+		d := m.dealers[i]
+		d.inventory, d.cash, d.ref, d.bought, d.prices = s.Inventory, s.Cash, s.Ref, s.Bought, s.Prices
+	}
+	if len(c.Arbitrageurs) != len(m.arbitrageurs) {
+		return nil, fmt.Errorf("checkpoint does not match its configuration")
+	}
+	for i, s := range c.Arbitrageurs {
+		a := m.arbitrageurs[i]
+		a.stock, a.ref, a.cash, a.bought, a.prices = s.Stock, s.Ref, s.Cash, s.Bought, s.Prices
+	}
+	if (c.Lattice == nil) != (m.lattice == nil) {
+		return nil, fmt.Errorf("checkpoint does not match its configuration")
+	}
+	if s := c.Lattice; s != nil {
+		l := m.lattice
+		l.buyers, l.sellers, l.source.s, l.moves, l.sums, l.counts = s.Buyers, s.Sellers, s.Source, s.Moves, s.Sums, s.Counts
+		l.reindex()
+	}
+	restoreAgents(m.buyers, c.Buyers)
+	restoreAgents(m.sellers, c.Sellers)
+	return m, nil
+}
+
+// Restore agents in place, keeping their schedules and price histories in
+// the shared backing arrays. The agents' strategies were drawn again from the
+// configuration, and only those of ZIP traders have state to restore; other
+// stateful strategies start afresh.
+func restoreAgents(agents []agent, states []agentState) {
+	for i, s := range states {
+		a := &agents[i]
+		a.quantityHeld = int32(s.Held)
+		a.value = s.Value
+		a.price = s.Price
+		a.earned = s.Earned
+		copy(a.schedule, s.Schedule)
+		a.prices = append(a.prices[:0], s.Prices...)
+		if z, ok := a.strategy.(*zipTrader); ok && len(s.ZIP) == 4 {
+			z.margin, z.beta, z.gamma, z.momentum = s.ZIP[0], s.ZIP[1], s.ZIP[2], s.ZIP[3]
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package zitraders
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// A run stopped at a checkpoint and resumed gives the same results and trades
+// as an uninterrupted run.
+func TestCheckpointResume(t *testing.T) {
+	for _, mode := range []struct {
+		matching, layout string
+		gd               float64
+		periods          int
+	}{
+		{Partitioned, AoS, 0, 0},
+		{Partitioned, SoA, 0, 0},
+		{Partitioned, Compact, 0, 0},
+		{Partitioned, AoS, 0.5, 0},
+		{Partitioned, AoS, 0.5, 3},
+	} {
+		config := testConfig()
+		config.Matching, config.Layout, config.GD, config.Periods = mode.matching, mode.layout, mode.gd, mode.periods
+		config.RecordTrades = true
+		config.TickSize = 1000
+
+		full := newTestModel(t, config)
+		want := full.Run()
+
+		var saved bytes.Buffer
+		m := newTestModel(t, config)
+		m.Observe(func(m *Model, tick Tick) bool {
+			if tick.Number < 5 || tick.Period < mode.periods-1 {
+				return true
+			}
+			if err := m.Checkpoint(&saved); err != nil {
+				t.Fatal(err)
+			}
+			return false
+		})
+		m.Run()
+
+		resumed, err := Resume(&saved)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := resumed.Run()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: resumed run gave %+v, want %+v", mode, got, want)
+		}
+		if !reflect.DeepEqual(resumed.Trades(), full.Trades()) {
+			t.Errorf("%v: resumed run recorded %d trades, want %d", mode, len(resumed.Trades()), len(full.Trades()))
+		}
+	}
+}
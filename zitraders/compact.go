@@ -0,0 +1,167 @@
+package zitraders
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// compactStore is the struct-of-arrays layout at half width: 16-bit values
+// and prices and a bit of holdings, a little over 4 bytes per agent. The
+// threads' partitions need not start on a word of the bitset, so its words
+// are read and written atomically.
+type compactStore struct {
+	buyer         bool
+	offset        int // the first agent's bit
+	values        []int16
+	prices        []int16
+	held          []uint64
+	unconstrained []uint64 // nil when every trader is ZI-C
+}
+
+// Allocate a store of n agents, to be endowed.
+func newCompactStore(buyer bool, n int, unconstrained bool) *compactStore {
+	s := &compactStore{
+		buyer:  buyer,
+		values: make([]int16, n),
+		prices: make([]int16, n),
+		held:   make([]uint64, (n+63)/64),
+	}
+	if unconstrained {
+		s.unconstrained = make([]uint64, len(s.held))
+	}
+	return s
+}
+
+// The word and mask of agent i's bit.
+func (s *compactStore) bit(i int) (int, uint64) {
+	i += s.offset
+	return i / 64, 1 << uint(i%64)
+}
+
+func (s *compactStore) flip(i int) {
+	w, mask := s.bit(i)
+	for {
+		old := atomic.LoadUint64(&s.held[w])
+		if atomic.CompareAndSwapUint64(&s.held[w], old, old^mask) {
+			return
+		}
+	}
+}
+
+// Endow agents lo to hi: each seller holds its unit. Only the words at the
+// ends of the range can be shared with another partition.
+func (s *compactStore) endow(lo, hi int) {
+	if s.buyer {
+		return
+	}
+	for i := lo; i < hi; i++ {
+		if (s.offset+i)%64 == 0 && hi-i >= 64 {
+			w, _ := s.bit(i)
+			atomic.StoreUint64(&s.held[w], ^uint64(0))
+			i += 63
+		} else {
+			s.flip(i)
+		}
+	}
+}
+
+// Endow agents lo to hi, first touching their values and prices too.
+func (s *compactStore) touch(lo, hi int) {
+	zero(s.values[lo:hi])
+	zero(s.prices[lo:hi])
+	s.endow(lo, hi)
+}
+
+func (s *compactStore) setValue(i, v int) { s.values[i] = int16(v) }
+
+// Set whether agent i is unconstrained. Agents are drawn in blocks of whole
+// words, so no other goroutine writes the same word.
+func (s *compactStore) setUnconstrained(i int, unconstrained bool) {
+	w, mask := s.bit(i)
+	if unconstrained {
+		s.unconstrained[w] |= mask
+	} else {
+		s.unconstrained[w] &^= mask
+	}
+}
+
+func (s *compactStore) state() storeState {
+	state := storeState{
+		Values: make([]int32, s.Len()),
+		Prices: make([]int32, s.Len()),
+		Held:   make([]uint8, s.Len()),
+	}
+	if s.unconstrained != nil {
+		state.Unconstrained = make([]bool, s.Len())
+	}
+	for i := range s.values {
+		state.Values[i] = int32(s.values[i])
+		state.Prices[i] = int32(s.prices[i])
+		state.Held[i] = uint8(s.Held(i))
+		if state.Unconstrained != nil {
+			state.Unconstrained[i] = s.Unconstrained(i)
+		}
+	}
+	return state
+}
+
+func (s *compactStore) restore(state storeState) error {
+	if len(state.Values) != s.Len() || (state.Unconstrained != nil) != (s.unconstrained != nil) {
+		return fmt.Errorf("checkpoint does not match its configuration")
+	}
+	for i := range s.values {
+		s.values[i] = int16(state.Values[i])
+		s.prices[i] = int16(state.Prices[i])
+		if s.Held(i) != int(state.Held[i]) {
+			s.flip(i)
+		}
+		if state.Unconstrained != nil {
+			s.setUnconstrained(i, state.Unconstrained[i])
+		}
+	}
+	return nil
+}
+
+func (s *compactStore) Len() int        { return len(s.values) }
+func (s *compactStore) Value(i int) int { return int(s.values[i]) }
+func (s *compactStore) Price(i int) int { return int(s.prices[i]) }
+
+func (s *compactStore) Held(i int) int {
+	w, mask := s.bit(i)
+	if atomic.LoadUint64(&s.held[w])&mask != 0 {
+		return 1
+	}
+	return 0
+}
+
+func (s *compactStore) Unconstrained(i int) bool {
+	if s.unconstrained == nil {
+		return false
+	}
+	w, mask := s.bit(i)
+	return s.unconstrained[w]&mask != 0
+}
+
+func (s *compactStore) CanTrade(i int) bool {
+	return (s.Held(i) == 0) == s.buyer
+}
+
+func (s *compactStore) Traded(i int) bool {
+	return (s.Held(i) == 1) == s.buyer
+}
+
+func (s *compactStore) Trade(i, price int) {
+	s.prices[i] = int16(price)
+	s.flip(i)
+}
+
+func (s *compactStore) Slice(lo, hi int) agentStore {
+	return &compactStore{
+		buyer:         s.buyer,
+		offset:        s.offset + lo,
+		values:        s.values[lo:hi:hi],
+		prices:        s.prices[lo:hi:hi],
+		held:          s.held,
+		unconstrained: s.unconstrained,
+	}
+}
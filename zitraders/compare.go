@@ -0,0 +1,44 @@
+package zitraders
+
+import "math/rand"
+
+// Comparison sets the replications of a treatment against those of a base,
+// made under common random numbers by ReplicateCommon.
+type Comparison struct {
+	Reps     int                 `json:"reps"` // pairs of replications compared
+	Outcomes []OutcomeComparison `json:"outcomes"`
+}
+
+// OutcomeComparison estimates an outcome under each configuration and the
+// treatment's effect on it, the mean of the paired differences, with both a
+// t interval and a percentile bootstrap interval.
+type OutcomeComparison struct {
+	Outcome    string   `json:"outcome"`
+	Base       Estimate `json:"base"`
+	Treatment  Estimate `json:"treatment"`
+	Difference Estimate `json:"difference"` // treatment less base
+}
+
+// Compare estimates the difference the treatment makes to the quantity
+// traded, the average price, the efficiency and Smith's alpha, pairing the
+// i-th replication of each. The bootstrap draws resamples resamples of the
+// pairs from a generator seeded with seed.
+func Compare(base, treatment []Results, resamples int, seed int64) Comparison {
+	n := len(base)
+	if len(treatment) < n {
+		n = len(treatment)
+	}
+	a, b := outcomesOf(base[:n]), outcomesOf(treatment[:n])
+	r := rand.New(newXoshiro(seed))
+	c := Comparison{Reps: n}
+	for k, name := range []string{OutcomeQuantity, OutcomeMeanPrice, OutcomeEfficiency, OutcomeAlpha} {
+		d := make([]float64, n)
+		for i := range d {
+			d[i] = b[k][i] - a[k][i]
+		}
+		o := OutcomeComparison{Outcome: name, Base: estimate(a[k]), Treatment: estimate(b[k]), Difference: estimate(d)}
+		o.Difference.Bootstrap = bootstrapMean(d, resamples, r)
+		c.Outcomes = append(c.Outcomes, o)
+	}
+	return c
+}
@@ -0,0 +1,110 @@
+package zitraders
+
+import "runtime"
+
+// Matching modes.
+const (
+	Partitioned = "partitioned" // each thread matches agents within its own share of the population
+	Global      = "global"      // any buyer can meet any seller
+	Pool        = "pool"        // a generator hands random pairs to a pool of worker goroutines
+)
+
+// How units change hands under global or pool matching.
+const (
+	Claim = "claim" // both traders are claimed for the whole trade attempt
+	CAS   = "cas"   // each trader's holdings change by compare-and-swap
+	Mutex = "mutex" // both traders are locked through striped mutexes, waiting for any other thread that holds either
+)
+
+// Market institutions.
+const (
+	Bilateral = "bilateral" // random pairs of buyers and sellers meet
+	CDA       = "cda"       // continuous double auction with a limit order book
+	Call      = "call"      // periodic call market clearing at a single price
+)
+
+// Config holds the parameters of a single model run.
+type Config struct {
+	NumBuyers         int                `json:"num_buyers" yaml:"num_buyers" toml:"num_buyers"`
+	NumSellers        int                `json:"num_sellers" yaml:"num_sellers" toml:"num_sellers"`
+	MaxBuyerValue     int                `json:"max_buyer_value" yaml:"max_buyer_value" toml:"max_buyer_value"`
+	MaxSellerValue    int                `json:"max_seller_value" yaml:"max_seller_value" toml:"max_seller_value"`
+	PriceTick         float64            `json:"price_tick" yaml:"price_tick" toml:"price_tick"` // the currency value of one unit of price, zero for 1
+	BuyerValues       Distribution       `json:"buyer_values" yaml:"buyer_values" toml:"buyer_values"`
+	SellerCosts       Distribution       `json:"seller_costs" yaml:"seller_costs" toml:"seller_costs"`
+	Units             int                `json:"units" yaml:"units" toml:"units"`                      // units demanded by each buyer and supplied by each seller
+	SellerUnits       int                `json:"seller_units" yaml:"seller_units" toml:"seller_units"` // units supplied by each seller, if not Units
+	Periods           int                `json:"periods" yaml:"periods" toml:"periods"`                // trading periods, between which endowments are restored; zero for one
+	MaxNumberOfTrades int                `json:"max_number_of_trades" yaml:"max_number_of_trades" toml:"max_number_of_trades"`
+	NumThreads        int                `json:"num_threads" yaml:"num_threads" toml:"num_threads"`
+	Seed              int64              `json:"seed" yaml:"seed" toml:"seed"`                   // zero means seed from the clock
+	Antithetic        bool               `json:"antithetic" yaml:"antithetic" toml:"antithetic"` // draw from the complements of the seed's streams
+	Institution       string             `json:"institution" yaml:"institution" toml:"institution"`
+	Matching          string             `json:"matching" yaml:"matching" toml:"matching"`
+	Sampler           string             `json:"sampler" yaml:"sampler" toml:"sampler"` // how each attempt's buyer and seller are chosen, random by default
+	Layout            string             `json:"layout" yaml:"layout" toml:"layout"`
+	Batched           bool               `json:"batched" yaml:"batched" toml:"batched"`                                  // draw each thread's attempts in batches, a faster loop with its own random stream
+	FirstTouch        bool               `json:"first_touch" yaml:"first_touch" toml:"first_touch"`                      // each partition's agents are first written by a thread of their own, for NUMA placement
+	Transfer          string             `json:"transfer" yaml:"transfer" toml:"transfer"`                               // how units change hands under global or pool matching, claim by default
+	SharedBudget      bool               `json:"shared_budget" yaml:"shared_budget" toml:"shared_budget"`                // threads draw attempts from a common budget rather than making equal shares
+	CallRound         int                `json:"call_round" yaml:"call_round" toml:"call_round"`                         // quotes collected per call market round
+	BuyerArrival      float64            `json:"buyer_arrival" yaml:"buyer_arrival" toml:"buyer_arrival"`                // the chance each quote to an order book or call market is a buyer's, zero for one half
+	Latency           float64            `json:"latency" yaml:"latency" toml:"latency"`                                  // mean delay, in attempts, before a slow trader's quote reaches the order book
+	FastShare         float64            `json:"fast_share" yaml:"fast_share" toml:"fast_share"`                         // share of traders whose quotes reach the book at once
+	OrderLifetime     int                `json:"order_lifetime" yaml:"order_lifetime" toml:"order_lifetime"`             // attempts an order stands in the book before it expires, zero for good till the close
+	Requote           bool               `json:"requote" yaml:"requote" toml:"requote"`                                  // traders quote again as soon as their order expires
+	QuoteEvery        int                `json:"quote_every" yaml:"quote_every" toml:"quote_every"`                      // attempts per thread between samples of the order book, zero for none
+	Unconstrained     float64            `json:"unconstrained" yaml:"unconstrained" toml:"unconstrained"`                // share of ZI-U traders
+	ZIP               float64            `json:"zip" yaml:"zip" toml:"zip"`                                              // share of ZIP traders
+	GD                float64            `json:"gd" yaml:"gd" toml:"gd"`                                                 // share of GD traders
+	Strategy          string             `json:"strategy" yaml:"strategy" toml:"strategy"`                               // strategy of the traders not drawn as another type
+	Sniper            float64            `json:"sniper" yaml:"sniper" toml:"sniper"`                                     // share of Kaplan's snipers
+	Population        map[string]float64 `json:"population" yaml:"population" toml:"population"`                         // shares of further strategies by name
+	Dealer            bool               `json:"dealer" yaml:"dealer" toml:"dealer"`                                     // add a market maker to each thread's market
+	DealerSpread      int                `json:"dealer_spread" yaml:"dealer_spread" toml:"dealer_spread"`                // the market maker's ask less its bid, zero for the default
+	DealerInventory   int                `json:"dealer_inventory" yaml:"dealer_inventory" toml:"dealer_inventory"`       // the most units the market maker may hold, zero for the default
+	PriceFloor        int                `json:"price_floor" yaml:"price_floor" toml:"price_floor"`                      // the lowest legal price, zero for none
+	PriceCeiling      int                `json:"price_ceiling" yaml:"price_ceiling" toml:"price_ceiling"`                // the highest legal price, zero for none
+	Tax               float64            `json:"tax" yaml:"tax" toml:"tax"`                                              // a fixed tax on every trade
+	TaxRate           float64            `json:"tax_rate" yaml:"tax_rate" toml:"tax_rate"`                               // a tax on every trade as a share of its price
+	TaxPayer          string             `json:"tax_payer" yaml:"tax_payer" toml:"tax_payer"`                            // buyer, seller or split (the default)
+	Shocks            []Shock            `json:"shocks" yaml:"shocks" toml:"shocks"`                                     // changes to values or costs during the run
+	Markets           int                `json:"markets" yaml:"markets" toml:"markets"`                                  // markets the population is split into, zero for one
+	MarketShift       int                `json:"market_shift" yaml:"market_shift" toml:"market_shift"`                   // added to values and costs in each successive market
+	Arbitrage         bool               `json:"arbitrage" yaml:"arbitrage" toml:"arbitrage"`                            // add an arbitrageur across markets to each thread
+	ArbitrageMargin   int                `json:"arbitrage_margin" yaml:"arbitrage_margin" toml:"arbitrage_margin"`       // the least profit an arbitrageur seeks on a unit, zero for the default
+	ArbitrageCapacity int                `json:"arbitrage_capacity" yaml:"arbitrage_capacity" toml:"arbitrage_capacity"` // the most units an arbitrageur may hold, zero for the default
+	Network           Network            `json:"network" yaml:"network" toml:"network"`                                  // the graph traders meet on
+	Spatial           Spatial            `json:"spatial" yaml:"spatial" toml:"spatial"`                                  // the lattice traders are placed on, if any
+	Memory            int                `json:"memory" yaml:"memory" toml:"memory"`                                     // quotes remembered by GD traders, zero for the default
+	RecordTrades      bool               `json:"record_trades" yaml:"record_trades" toml:"record_trades"`
+	VolatilityWindow  int                `json:"volatility_window" yaml:"volatility_window" toml:"volatility_window"` // trades per rolling volatility window, zero to disable
+	ConvergenceBlock  int                `json:"convergence_block" yaml:"convergence_block" toml:"convergence_block"` // trades per convergence block, zero to disable
+	WarmUp            int                `json:"warm_up" yaml:"warm_up" toml:"warm_up"`                               // trades left out of the measurement window
+	Measure           int                `json:"measure" yaml:"measure" toml:"measure"`                               // trades in the measurement window, zero for the rest of the run
+	RoundSize         int                `json:"round_size" yaml:"round_size" toml:"round_size"`                      // trade attempts per thread in a reported round, zero for none
+	PriceBins         int                `json:"price_bins" yaml:"price_bins" toml:"price_bins"`                      // bins of the histogram of prices, zero for none
+	TickSize          int                `json:"tick_size" yaml:"tick_size" toml:"tick_size"`                         // trade attempts per thread in a tick, zero for a single tick
+	StopWhenCleared   bool               `json:"stop_when_cleared" yaml:"stop_when_cleared" toml:"stop_when_cleared"` // stop once no mutually beneficial trade remains
+	MinTradeRate      float64            `json:"min_trade_rate" yaml:"min_trade_rate" toml:"min_trade_rate"`          // stop once fewer than this share of a tick's attempts trade
+	Verbose           bool               `json:"verbose" yaml:"verbose" toml:"verbose"`                               // log the run's progress at info rather than debug level
+}
+
+// DefaultConfig returns the parameters used in Axtell (2009).
+func DefaultConfig() Config {
+	return Config{
+		NumBuyers:         1200000,
+		NumSellers:        1200000,
+		MaxBuyerValue:     30,
+		MaxSellerValue:    30,
+		BuyerValues:       Distribution{Kind: Uniform},
+		SellerCosts:       Distribution{Kind: Uniform},
+		Units:             1,
+		MaxNumberOfTrades: 100000000,
+		NumThreads:        runtime.NumCPU() * 2,
+		Institution:       Bilateral,
+		Matching:          Partitioned,
+		Layout:            AoS,
+		CallRound:         1000,
+	}
+}
@@ -0,0 +1,71 @@
+package zitraders
+
+import "math"
+
+// Block summarizes the transaction prices of a block of consecutive trades.
+type Block struct {
+	Trades    int     `json:"trades"`    // trades executed up to the end of the block
+	Mean      float64 `json:"mean"`      // mean price within the block
+	Variance  float64 `json:"variance"`  // price variance within the block
+	Deviation float64 `json:"deviation"` // block mean minus the equilibrium price
+	Running   float64 `json:"running"`   // mean price of all trades so far
+	Alpha     float64 `json:"alpha"`     // Smith's alpha within the block
+}
+
+// Smith's alpha: the root mean squared deviation of transaction prices from
+// the equilibrium price, as a percentage of the equilibrium price. Zero if
+// there is no positive equilibrium price.
+func alpha(meanSquaredDeviation, eqPrice float64) float64 {
+	if eqPrice <= 0 {
+		return 0
+	}
+	return 100 * math.Sqrt(meanSquaredDeviation) / eqPrice
+}
+
+// Smith's alpha over prices summarized by their moments.
+func (s *moments) alpha(eqPrice float64) float64 {
+	if s.n == 0 {
+		return 0
+	}
+	d := s.mean - eqPrice
+	return alpha(s.m2/float64(s.n)+d*d, eqPrice)
+}
+
+// Split the trade log into blocks of n trades and summarize each one, so that
+// convergence towards the equilibrium price can be followed over the run.
+func convergence(trades []Trade, n int, eqPrice float64) []Block {
+	var blocks []Block
+	total := 0.0
+	for start := 0; start < len(trades); start += n {
+		end := start + n
+		if end > len(trades) {
+			end = len(trades)
+		}
+
+		sum := 0.0
+		for _, t := range trades[start:end] {
+			sum += float64(t.Price)
+		}
+		total += sum
+		size := float64(end - start)
+		mean := sum / size
+
+		ss, sa := 0.0, 0.0
+		for _, t := range trades[start:end] {
+			d := float64(t.Price) - mean
+			ss += d * d
+			e := float64(t.Price) - eqPrice
+			sa += e * e
+		}
+
+		blocks = append(blocks, Block{
+			Trades:    end,
+			Mean:      mean,
+			Variance:  ss / size,
+			Deviation: mean - eqPrice,
+			Running:   total / float64(end),
+			Alpha:     alpha(sa/size, eqPrice),
+		})
+	}
+	return blocks
+}
@@ -0,0 +1,130 @@
+package zitraders
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Curves are the supply and demand schedules induced by the agents'
+// valuations, with the competitive equilibrium they imply.
+type Curves struct {
+	Demand              []int   `json:"demand"` // buyer values, highest first
+	Supply              []int   `json:"supply"` // seller costs, lowest first
+	EquilibriumPrice    float64 `json:"equilibrium_price"`
+	EquilibriumQuantity int     `json:"equilibrium_quantity"`
+}
+
+// Curves returns the induced supply and demand schedules of the model.
+func (m *Model) Curves() Curves {
+	values, costs := m.schedules()
+	e := findEquilibrium(values, costs)
+	return Curves{
+		Demand:              values,
+		Supply:              costs,
+		EquilibriumPrice:    e.Price(),
+		EquilibriumQuantity: e.Quantity,
+	}
+}
+
+// WriteCSV writes the curves in long format with columns series, quantity and
+// price. The demand and supply series give the price of each unit, and a
+// single equilibrium row gives the equilibrium quantity and price.
+func (c Curves) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"series", "quantity", "price"})
+	for i, v := range c.Demand {
+		cw.Write([]string{"demand", strconv.Itoa(i + 1), strconv.Itoa(v)})
+	}
+	for i, v := range c.Supply {
+		cw.Write([]string{"supply", strconv.Itoa(i + 1), strconv.Itoa(v)})
+	}
+	cw.Write([]string{"equilibrium", strconv.Itoa(c.EquilibriumQuantity), strconv.FormatFloat(c.EquilibriumPrice, 'f', -1, 64)})
+	cw.Flush()
+	return cw.Error()
+}
+
+// Every unit's value and cost, sorted into demand and supply order.
+func (m *Model) schedules() (values, costs []int) {
+	if m.columnar() {
+		// Columnar layouts hold single-unit agents.
+		values = make([]int, m.buyerStore.Len())
+		for i := range values {
+			values[i] = m.buyerStore.Value(i)
+		}
+		costs = make([]int, m.sellerStore.Len())
+		for i := range costs {
+			costs[i] = m.sellerStore.Value(i)
+		}
+		return sortedSchedules(values, costs)
+	}
+	values = make([]int, 0, len(m.buyers)*m.Units)
+	for _, x := range m.buyers {
+		values = append(values, x.schedule...)
+	}
+	costs = make([]int, 0, len(m.sellers)*m.SellerUnits)
+	for _, x := range m.sellers {
+		costs = append(costs, x.schedule...)
+	}
+	return sortedSchedules(values, costs)
+}
+
+// Sort values into demand order and costs into supply order.
+func sortedSchedules(values, costs []int) ([]int, []int) {
+	sort.Sort(sort.Reverse(sort.IntSlice(values)))
+	sort.Ints(costs)
+	return values, costs
+}
+
+// Equilibrium is the competitive outcome implied by induced supply and demand
+// schedules: the quantity traded, the range of market-clearing prices and the
+// surplus realized when exactly the intramarginal units trade.
+type Equilibrium struct {
+	Quantity  int `json:"quantity"`
+	PriceLow  int `json:"price_low"`
+	PriceHigh int `json:"price_high"`
+	Surplus   int `json:"surplus"`
+}
+
+// Price is the midpoint of the range of market-clearing prices.
+func (e Equilibrium) Price() float64 {
+	return float64(e.PriceLow+e.PriceHigh) / 2
+}
+
+// Equilibrium returns the competitive equilibrium of a single period of the
+// model's population, given the values and costs currently in force.
+func (m *Model) Equilibrium() Equilibrium {
+	return findEquilibrium(m.schedules())
+}
+
+// FindEquilibrium intersects the demand schedule of buyer values with the
+// supply schedule of seller costs, one entry per unit, in any order.
+func FindEquilibrium(values, costs []int) Equilibrium {
+	return findEquilibrium(sortedSchedules(append([]int(nil), values...), append([]int(nil), costs...)))
+}
+
+// Find the competitive equilibrium by matching the highest-value buyers with
+// the lowest-cost sellers for as long as the demand curve lies above supply.
+// The schedules must be in demand and supply order.
+func findEquilibrium(values, costs []int) Equilibrium {
+	var e Equilibrium
+	for e.Quantity < len(values) && e.Quantity < len(costs) && values[e.Quantity] >= costs[e.Quantity] {
+		e.Surplus += values[e.Quantity] - costs[e.Quantity]
+		e.Quantity++
+	}
+	if e.Quantity == 0 {
+		return e
+	}
+
+	// The clearing prices lie between the last intramarginal units and the
+	// first extramarginal ones.
+	e.PriceLow, e.PriceHigh = costs[e.Quantity-1], values[e.Quantity-1]
+	if e.Quantity < len(values) && values[e.Quantity] > e.PriceLow {
+		e.PriceLow = values[e.Quantity]
+	}
+	if e.Quantity < len(costs) && costs[e.Quantity] < e.PriceHigh {
+		e.PriceHigh = costs[e.Quantity]
+	}
+	return e
+}
@@ -0,0 +1,138 @@
+package zitraders
+
+import (
+	"math"
+	"math/rand"
+)
+
+// A market maker, or dealer, stands in each thread's bilateral market ready
+// to buy at its bid and sell at its ask, a fixed spread apart. It quotes
+// around a moving average of the prices traded in its market and holds between zero and a
+// limit of units, skewing its quotes down as its inventory builds up so that
+// it sells more and buys less. A buyer and seller who fail to trade with each
+// other may trade with the dealer instead.
+
+// Dealer is the agent index the trade log gives a dealer.
+const Dealer = -1
+
+// Defaults for a dealer whose configuration leaves them out.
+const (
+	defaultDealerSpread    = 2
+	defaultDealerInventory = 10
+)
+
+// The weight of each new price in a dealer's moving average.
+const dealerSmoothing = 0.05
+
+// DealerResults sums up what the market makers did over a run.
+type DealerResults struct {
+	Bought    int `json:"bought"`    // units bought from sellers
+	Sold      int `json:"sold"`      // units sold to buyers
+	Inventory int `json:"inventory"` // units still held at the end
+	Profit    int `json:"profit"`    // sales less purchases; units still held count for nothing
+}
+
+type dealer struct {
+	spread    int
+	limit     int // the most units the dealer may hold
+	inventory int
+	cash      int     // sales less purchases
+	ref       float64 // the moving average of prices traded in the dealer's market
+	bought    int     // units bought
+	prices    []int   // the price of every unit bought or sold
+}
+
+func (m *Model) newDealer() *dealer {
+	return &dealer{spread: m.DealerSpread, limit: m.DealerInventory, ref: float64(m.maxPrice()) / 2}
+}
+
+// The dealer of a thread's market, or nil if the market has none.
+func (m *Model) dealer(thread int) *dealer {
+	if m.dealers == nil {
+		return nil
+	}
+	return m.dealers[thread]
+}
+
+// The dealer's bid and ask: an empty dealer bids its moving average price
+// and a full one asks it.
+func (d *dealer) quotes() (bid, ask int) {
+	center := int(math.Round(d.ref)) + d.spread*(d.limit-2*d.inventory)/(2*d.limit)
+	bid = center - d.spread/2
+	return bid, bid + d.spread
+}
+
+// Note a price traded in the dealer's market.
+func (d *dealer) see(price int) {
+	if d != nil {
+		d.ref += dealerSmoothing * (float64(price) - d.ref)
+	}
+}
+
+// Let the dealer d trade with whichever of a buyer and a seller, who did not
+// trade with each other, meets its quotes, choosing at random if both do. The
+// trade returned names the dealer as its buyer or seller; if there is none it
+// carries just the quotes.
+func (m *Model) deal(buyer, seller *agent, bidPrice, askPrice int, h *history, d *dealer, generator *rand.Rand) (Trade, bool) {
+	if d == nil {
+		return Trade{Bid: bidPrice, Ask: askPrice}, false
+	}
+	dealerBid, dealerAsk := d.quotes()
+	if m.policy {
+		dealerBid, dealerAsk = m.legal(dealerBid), m.legal(dealerAsk)
+	}
+	sell := buyer.canBuy() && bidPrice != NoQuote && dealerAsk != NoQuote && bidPrice >= dealerAsk && d.inventory > 0
+	buy := seller.canSell() && askPrice != NoQuote && dealerBid != NoQuote && askPrice <= dealerBid && d.inventory < d.limit
+	if sell && buy {
+		sell = generator.Intn(2) == 0
+		buy = !sell
+	}
+
+	switch {
+	case sell:
+		m.traded(buyer, h, dealerAsk, generator)
+		buyer.buy(dealerAsk)
+		d.inventory--
+		d.cash += dealerAsk
+		d.prices = append(d.prices, dealerAsk)
+		d.see(dealerAsk)
+		return Trade{Seller: Dealer, Bid: bidPrice, Ask: dealerAsk, Price: dealerAsk}, true
+	case buy:
+		m.traded(seller, h, dealerBid, generator)
+		seller.sell(dealerBid)
+		d.inventory++
+		d.bought++
+		d.cash -= dealerBid
+		d.prices = append(d.prices, dealerBid)
+		d.see(dealerBid)
+		return Trade{Buyer: Dealer, Bid: dealerBid, Ask: askPrice, Price: dealerBid}, true
+	}
+	return Trade{Bid: bidPrice, Ask: askPrice}, false
+}
+
+// Sum up the dealers' trading.
+func (m *Model) dealerResults() *DealerResults {
+	r := &DealerResults{}
+	for _, d := range m.dealers {
+		r.Bought += d.bought
+		r.Sold += len(d.prices) - d.bought
+		r.Inventory += d.inventory
+		r.Profit += d.cash
+	}
+	return r
+}
+
+// The root mean squared change between successive prices traded in each
+// thread's market within a period, a measure of short-run price volatility.
+func volatility(trades []Trade, threads int) float64 {
+	sum, n := 0.0, 0
+	eachChange(trades, threads, func(_ int, p, t Trade) {
+		d := float64(t.Price - p.Price)
+		sum += d * d
+		n++
+	})
+	if n == 0 {
+		return 0
+	}
+	return math.Sqrt(sum / float64(n))
+}
@@ -0,0 +1,45 @@
+package zitraders
+
+import "testing"
+
+// Market makers only buy what they can hold and sell what they hold, and
+// every unit bought from or sold to one is accounted for.
+func TestDealer(t *testing.T) {
+	for _, matching := range []string{Partitioned, Global, Pool} {
+		config := testConfig()
+		config.Matching, config.Dealer, config.Units = matching, true, 2
+		config.RecordTrades = true
+		m := newTestModel(t, config)
+		r := m.Run()
+
+		d := r.Dealer
+		if d == nil || d.Bought == 0 || d.Sold == 0 {
+			t.Fatalf("%s: market makers did not trade: %+v", matching, d)
+		}
+		if d.Inventory != d.Bought-d.Sold || d.Inventory < 0 || d.Inventory > config.NumThreads*defaultDealerInventory {
+			t.Errorf("%s: %+v", matching, d)
+		}
+		if r.NumberBought-d.Sold != r.NumberSold-d.Bought || r.NumberBought+d.Bought != len(m.Trades()) {
+			t.Errorf("%s: %d bought and %d sold by traders, %d trades logged, %+v", matching, r.NumberBought, r.NumberSold, len(m.Trades()), d)
+		}
+
+		surplus := d.Profit
+		for _, b := range m.buyers {
+			surplus += b.surplus()
+		}
+		for _, s := range m.sellers {
+			surplus += s.surplus()
+		}
+		if surplus != r.RealizedSurplus {
+			t.Errorf("%s: realized surplus %d, want %d", matching, r.RealizedSurplus, surplus)
+		}
+		for _, tr := range m.Trades() {
+			if tr.Buyer == Dealer && tr.Seller == Dealer || tr.Price < tr.Ask || tr.Price > tr.Bid {
+				t.Fatalf("%s: bad trade %+v", matching, tr)
+			}
+		}
+		if r.Volatility <= 0 {
+			t.Errorf("%s: volatility %v", matching, r.Volatility)
+		}
+	}
+}
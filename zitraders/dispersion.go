@@ -0,0 +1,66 @@
+package zitraders
+
+import "math"
+
+// Call f with the index of each trade, the trade before it in the same
+// thread's market and period, and the trade itself, in the order the trades
+// were made.
+func eachChange(trades []Trade, threads int, f func(i int, prev, t Trade)) {
+	last := make([]Trade, threads)
+	seen := make([]bool, threads)
+	for i, t := range trades {
+		if p := last[t.Thread]; seen[t.Thread] && p.Period == t.Period {
+			f(i, p, t)
+		}
+		last[t.Thread], seen[t.Thread] = t, true
+	}
+}
+
+// The lag-one autocorrelation of the prices traded in each thread's market
+// within a period, measured about the mean of all of them. Under ZI trading
+// it is close to zero; markets that learn or carry inventory show more.
+func autocorrelation(trades []Trade, threads int) float64 {
+	if len(trades) < 2 {
+		return 0
+	}
+	var mean, squares float64
+	for _, t := range trades {
+		mean += float64(t.Price)
+	}
+	mean /= float64(len(trades))
+	for _, t := range trades {
+		d := float64(t.Price) - mean
+		squares += d * d
+	}
+	var cross float64
+	eachChange(trades, threads, func(_ int, p, t Trade) {
+		cross += (float64(p.Price) - mean) * (float64(t.Price) - mean)
+	})
+	if squares == 0 {
+		return 0
+	}
+	return cross / squares
+}
+
+// The volatility over each successive window of trades, measured as the
+// root mean squared change from the previous price in the same thread's
+// market. A trade's change counts towards its own window.
+func rollingVolatility(trades []Trade, threads, window int) []float64 {
+	if window <= 0 || len(trades) == 0 {
+		return nil
+	}
+	windows := (len(trades) + window - 1) / window
+	sums, counts := make([]float64, windows), make([]int, windows)
+	eachChange(trades, threads, func(i int, p, t Trade) {
+		d := float64(t.Price - p.Price)
+		sums[i/window] += d * d
+		counts[i/window]++
+	})
+	v := make([]float64, windows)
+	for k := range v {
+		if counts[k] > 0 {
+			v[k] = math.Sqrt(sums[k] / float64(counts[k]))
+		}
+	}
+	return v
+}
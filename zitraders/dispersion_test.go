@@ -0,0 +1,41 @@
+package zitraders
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAutocorrelation(t *testing.T) {
+	// Prices alternating about their mean are perfectly anticorrelated but
+	// for the first, which has no predecessor.
+	var trades []Trade
+	for i := 0; i < 10; i++ {
+		trades = append(trades, Trade{Price: 10 + 2*(i%2)})
+	}
+	if a := autocorrelation(trades, 1); math.Abs(a+0.9) > 1e-12 {
+		t.Errorf("autocorrelation %v, want -0.9", a)
+	}
+
+	// Successive prices in different threads or periods are not paired.
+	trades = []Trade{{Thread: 0, Price: 10}, {Thread: 1, Price: 12}, {Thread: 0, Period: 1, Price: 10}, {Thread: 1, Period: 1, Price: 12}}
+	if a := autocorrelation(trades, 2); a != 0 {
+		t.Errorf("autocorrelation %v across threads and periods", a)
+	}
+}
+
+func TestRollingVolatility(t *testing.T) {
+	trades := []Trade{{Price: 10}, {Price: 12}, {Price: 10}, {Price: 10}, {Price: 13}}
+	got := rollingVolatility(trades, 1, 2)
+	want := []float64{2, math.Sqrt(2), 3} // changes of 2; then of 2 and 0; then of 3
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for k := range want {
+		if math.Abs(got[k]-want[k]) > 1e-12 {
+			t.Errorf("window %d: %v, want %v", k, got[k], want[k])
+		}
+	}
+	if v := rollingVolatility(trades, 1, 0); v != nil {
+		t.Errorf("windows without a window size: %v", v)
+	}
+}
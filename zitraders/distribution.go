@@ -0,0 +1,99 @@
+package zitraders
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+)
+
+// Valuation distributions.
+const (
+	Uniform     = "uniform"     // every tick from 1 to the maximum value
+	Normal      = "normal"      // Mean and SD
+	Exponential = "exponential" // Mean
+	Empirical   = "empirical"   // resampled from the first column of a CSV File
+)
+
+// Distribution describes how buyer values or seller costs are drawn. The
+// parameters and an empirical distribution's values are in currency; draws
+// are rounded to the nearest tick and clamped to 1 through the maximum value,
+// which is counted in ticks.
+type Distribution struct {
+	Kind string  `json:"kind" yaml:"kind" toml:"kind"`
+	Mean float64 `json:"mean,omitempty" yaml:"mean" toml:"mean"`
+	SD   float64 `json:"sd,omitempty" yaml:"sd" toml:"sd"`
+	File string  `json:"file,omitempty" yaml:"file" toml:"file"`
+}
+
+// Build a function drawing values between 1 and max ticks of the given size
+// from the distribution.
+func (d Distribution) sampler(max int, tick float64) (func(*rand.Rand) int, error) {
+	clamp := func(x float64) int {
+		v := int(math.Round(x / tick))
+		if v < 1 {
+			return 1
+		}
+		if v > max {
+			return max
+		}
+		return v
+	}
+
+	switch d.Kind {
+	case "", Uniform:
+		return func(r *rand.Rand) int { return r.Intn(max) + 1 }, nil
+	case Normal:
+		if d.SD < 0 {
+			return nil, fmt.Errorf("normal distribution with negative s.d. %v", d.SD)
+		}
+		return func(r *rand.Rand) int { return clamp(d.Mean + d.SD*r.NormFloat64()) }, nil
+	case Exponential:
+		if d.Mean <= 0 {
+			return nil, fmt.Errorf("exponential distribution with nonpositive mean %v", d.Mean)
+		}
+		return func(r *rand.Rand) int { return clamp(d.Mean * r.ExpFloat64()) }, nil
+	case Empirical:
+		values, err := readValues(d.File)
+		if err != nil {
+			return nil, err
+		}
+		return func(r *rand.Rand) int { return clamp(values[r.Intn(len(values))]) }, nil
+	default:
+		return nil, fmt.Errorf("unknown distribution %q", d.Kind)
+	}
+}
+
+// Read the first column of a CSV file as numbers, skipping a header row.
+func readValues(path string) ([]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cr := csv.NewReader(f)
+	cr.FieldsPerRecord = -1
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var values []float64
+	for i, rec := range records {
+		v, err := strconv.ParseFloat(rec[0], 64)
+		if err != nil {
+			if i == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("%s line %d: %v", path, i+1, err)
+		}
+		values = append(values, v)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("%s: no values", path)
+	}
+	return values, nil
+}
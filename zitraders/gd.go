@@ -0,0 +1,182 @@
+package zitraders
+
+// GD traders, after Gjerstad and Dickhaut (1998), form beliefs about how
+// likely each price is to be accepted from the quotes recently made in their
+// market, and quote the price that maximizes their expected surplus. A seller
+// believes an ask a is accepted with probability
+//
+//	(TA(≥a) + B(≥a)) / (TA(≥a) + B(≥a) + RA(≤a))
+//
+// where TA(≥a) counts remembered asks of at least a that traded, B(≥a) bids of
+// at least a and RA(≤a) asks of at most a that did not trade; buyers' beliefs
+// are symmetric. Prices are whole numbers, so beliefs are evaluated at every
+// price rather than interpolated. A GD trader with no usable beliefs quotes
+// as a ZI-C trader.
+
+// Quotes remembered by GD traders unless the configuration says otherwise.
+const defaultMemory = 100
+
+// A quote remembered by a history.
+type quote struct {
+	price    int
+	bid      bool
+	accepted bool
+}
+
+// A history remembers the most recent quotes made in a thread's market, the
+// current quotes and the time. A nil history remembers nothing.
+type history struct {
+	quotes []quote // a ring buffer of the last len(quotes) quotes
+	next   int
+	n      int
+	bid    int      // the current bid, or NoQuote
+	ask    int      // the current ask, or NoQuote
+	time   int      // the thread's attempt number
+	end    int      // the attempt number at which the market closes
+	counts [4][]int // scratch counts by price, indexed as below
+	sums   []int    // scratch cumulative counts by price
+}
+
+const (
+	acceptedAsks = iota
+	rejectedAsks
+	acceptedBids
+	rejectedBids
+)
+
+func (m *Model) newHistory() *history {
+	maxPrice := m.maxPrice()
+	h := &history{quotes: make([]quote, m.Memory), end: m.tradesPerThread}
+	if m.Matching == Pool {
+		h.end = m.MaxNumberOfTrades
+	}
+	for i := range h.counts {
+		h.counts[i] = make([]int, maxPrice+2)
+	}
+	h.sums = make([]int, maxPrice+2)
+	return h
+}
+
+// Whether any trader consults the quote histories.
+func (m *Model) remembers() bool {
+	for _, k := range m.kinds {
+		if !isOblivious(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// The quote history of a thread, or nil if the market has no GD traders or
+// snipers.
+func (m *Model) history(thread int) *history {
+	if m.histories == nil {
+		return nil
+	}
+	return m.histories[thread]
+}
+
+// Remember a quote.
+func (h *history) add(price int, bid, accepted bool) {
+	if h == nil {
+		return
+	}
+	h.quotes[h.next] = quote{price: price, bid: bid, accepted: accepted}
+	if bid {
+		h.bid = price
+	} else {
+		h.ask = price
+	}
+	h.next = (h.next + 1) % len(h.quotes)
+	if h.n < len(h.quotes) {
+		h.n++
+	}
+}
+
+// Count the remembered quotes by kind and price.
+func (h *history) count() [4][]int {
+	for _, c := range h.counts {
+		for p := range c {
+			c[p] = 0
+		}
+	}
+	for _, q := range h.quotes[:h.n] {
+		kind := rejectedAsks
+		switch {
+		case q.bid && q.accepted:
+			kind = acceptedBids
+		case q.bid:
+			kind = rejectedBids
+		case q.accepted:
+			kind = acceptedAsks
+		}
+		h.counts[kind][q.price]++
+	}
+	return h.counts
+}
+
+// A GD trader keeps no state of its own; its beliefs come from the thread's
+// history.
+type gd struct{}
+
+func (gd) Bid(ctx MarketView) int {
+	if p, ok := ctx.history.bestBid(ctx.Value, ctx.MaxPrice()); ok {
+		return p
+	}
+	return zic{}.Bid(ctx)
+}
+
+func (gd) Ask(ctx MarketView) int {
+	if p, ok := ctx.history.bestAsk(ctx.Value, ctx.MaxPrice()); ok {
+		return p
+	}
+	return zic{}.Ask(ctx)
+}
+
+// The ask between cost and maxPrice that maximizes a seller's expected
+// surplus, if any ask is believed to have a chance of acceptance.
+func (h *history) bestAsk(cost, maxPrice int) (int, bool) {
+	c := h.count()
+	// Suffix sums of accepted asks and all bids at or above each price.
+	above := h.sums
+	above[maxPrice+1] = 0
+	for p := maxPrice; p >= 1; p-- {
+		above[p] = above[p+1] + c[acceptedAsks][p] + c[acceptedBids][p] + c[rejectedBids][p]
+	}
+	best, bestSurplus := 0, 0.0
+	rejected := 0
+	for p := 1; p <= maxPrice; p++ {
+		rejected += c[rejectedAsks][p]
+		if p < cost || above[p] == 0 {
+			continue
+		}
+		if s := float64(p-cost) * float64(above[p]) / float64(above[p]+rejected); s > bestSurplus {
+			best, bestSurplus = p, s
+		}
+	}
+	return best, best > 0
+}
+
+// The bid between 1 and value that maximizes a buyer's expected surplus, if
+// any bid is believed to have a chance of acceptance.
+func (h *history) bestBid(value, maxPrice int) (int, bool) {
+	c := h.count()
+	// Suffix sums of rejected bids at or above each price.
+	rejected := h.sums
+	rejected[maxPrice+1] = 0
+	for p := maxPrice; p >= 1; p-- {
+		rejected[p] = rejected[p+1] + c[rejectedBids][p]
+	}
+	best, bestSurplus := 0, 0.0
+	below := 0 // accepted bids and all asks at or below the price
+	for p := 1; p <= value && p <= maxPrice; p++ {
+		below += c[acceptedBids][p] + c[acceptedAsks][p] + c[rejectedAsks][p]
+		if below == 0 {
+			continue
+		}
+		if s := float64(value-p) * float64(below) / float64(below+rejected[p]); s > bestSurplus {
+			best, bestSurplus = p, s
+		}
+	}
+	return best, best > 0
+}
@@ -0,0 +1,32 @@
+package zitraders
+
+// OnTrade registers f to be called with every trade, in the order of the
+// trade log, at the end of the tick in which it was made, or of its trading
+// period if the model has no ticks. Like an observer, f is called while the
+// trading threads are paused. OnTrade makes the model record its trades, so
+// it must be called before the run.
+func (m *Model) OnTrade(f func(t Trade)) {
+	m.RecordTrades = true
+	m.Observe(func(m *Model, _ Tick) bool {
+		for _, t := range m.TickTrades() {
+			f(t)
+		}
+		return true
+	})
+}
+
+// OnRoundEnd registers f to be called at the end of every round, a tick of
+// TickSize attempts per goroutine, while the trading threads are paused.
+// Unlike an observer it is not called once more when the run ends. Returning
+// false stops the run.
+func (m *Model) OnRoundEnd(f func(m *Model, t Tick) bool) {
+	m.Observe(func(m *Model, t Tick) bool {
+		return t.Final || f(m, t)
+	})
+}
+
+// OnRunEnd registers f to be called with the results of the run once its
+// statistics are computed.
+func (m *Model) OnRunEnd(f func(m *Model, r Results)) {
+	m.finishers = append(m.finishers, f)
+}
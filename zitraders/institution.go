@@ -0,0 +1,236 @@
+package zitraders
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// An Order is a quote a trader submits to a Market: a buyer's bid or a
+// seller's ask for one unit.
+type Order struct {
+	Buyer bool
+	Agent int // the trader's index among its side of the goroutine's traders
+	Price int
+	Time  int // the attempt number
+}
+
+// A Market is a trading institution. At every attempt a random trader who
+// can still trade quotes, and its quote is submitted as an order; the market
+// returns the trades it leads to, with Buyer, Seller, Bid, Ask and Price set.
+// A trade is executed only if both traders can still trade and its price lies
+// between its bid and ask, so a market may leave stale orders of traders who
+// have since traded out. Each goroutine has a market of its own for each
+// trading period, so a market need not be safe for concurrent use.
+type Market interface {
+	Submit(o Order) []Trade
+}
+
+// A Closer is a Market that trades once more when its period ends, as a
+// call market clears its last round.
+type Closer interface {
+	Market
+	Close() []Trade
+}
+
+// A MarketFactory makes the market of a goroutine for a trading period,
+// drawing anything random from r, the goroutine's generator.
+type MarketFactory func(c Config, r *rand.Rand) Market
+
+var (
+	institutionsMu sync.RWMutex
+	institutions   = map[string]MarketFactory{
+		Bilateral: func(_ Config, r *rand.Rand) Market { return &bilateralMarket{r: r} },
+		CDA:       func(Config, *rand.Rand) Market { return bookMarket{newBook(0)} },
+		Call: func(c Config, _ *rand.Rand) Market {
+			return &callMarket{round: c.CallRound, bids: make(map[int]int), asks: make(map[int]int)}
+		},
+	}
+	// Institutions run on trade loops of their own, which support options
+	// the loop of a registered Market does not.
+	native = map[string]bool{Bilateral: true, CDA: true, Call: true}
+)
+
+// RegisterMarket makes an institution available by name, for
+// Config.Institution. It replaces any institution registered under the same
+// name, including a built-in one, which then runs without the options only
+// its own trade loop supports.
+func RegisterMarket(name string, f MarketFactory) {
+	institutionsMu.Lock()
+	defer institutionsMu.Unlock()
+	institutions[name] = f
+	delete(native, name)
+}
+
+// Institutions returns the names of the registered institutions.
+func Institutions() []string {
+	institutionsMu.RLock()
+	defer institutionsMu.RUnlock()
+	names := make([]string, 0, len(institutions))
+	for name := range institutions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewMarket makes a market of the institution registered under name, so
+// that a registered institution can build on another, such as a built-in
+// one.
+func NewMarket(name string, c Config, r *rand.Rand) (Market, error) {
+	f, _, err := institution(name)
+	if err != nil {
+		return nil, err
+	}
+	return f(c, r), nil
+}
+
+// The factory of an institution and whether it runs on a loop of its own.
+func institution(name string) (MarketFactory, bool, error) {
+	institutionsMu.RLock()
+	defer institutionsMu.RUnlock()
+	f, ok := institutions[name]
+	if !ok {
+		return nil, false, fmt.Errorf("unknown market institution %q", name)
+	}
+	return f, native[name], nil
+}
+
+// Whether the institution is the built-in random bilateral one.
+func (m *Model) bilateral() bool {
+	return m.Institution == Bilateral && m.institution == nil
+}
+
+// Run a registered market within a thread's partition. At each attempt a
+// random trader who can still trade quotes, the side drawn as in the order
+// book, and its quote is submitted to the market; the trades it returns are
+// executed if they are legal and dropped otherwise.
+func (m *Model) doMarket(ctx context.Context, p partition, generator *rand.Rand) []Trade {
+
+	buyers, sellers := p.buyers, p.sellers
+	market := m.institution(m.Config, generator)
+	var trades []Trade
+	progress := m.tally(p.thread, &trades)
+	defer progress.close()
+
+	execute := func(i int, matched []Trade) {
+		for _, t := range matched {
+			if t.Buyer < 0 || t.Buyer >= len(buyers) || t.Seller < 0 || t.Seller >= len(sellers) ||
+				!buyers[t.Buyer].canBuy() || !sellers[t.Seller].canSell() || t.Price < t.Ask || t.Price > t.Bid {
+				continue
+			}
+			m.traded(&buyers[t.Buyer], p.history, t.Price, generator)
+			m.traded(&sellers[t.Seller], p.history, t.Price, generator)
+			buyers[t.Buyer].buy(t.Price)
+			sellers[t.Seller].sell(t.Price)
+			progress.trade(t.Price)
+
+			if m.RecordTrades {
+				trades = append(trades, p.record(Trade{Buyer: t.Buyer, Seller: t.Seller, Bid: t.Bid, Ask: t.Ask, Price: t.Price}, i))
+			}
+		}
+	}
+
+	i := 1
+	for ; i < m.tradesPerThread && m.advance(ctx, i, progress); i++ {
+		progress.attempt()
+		p.history.at(i)
+		o := Order{Buyer: m.buyerArrives(generator), Time: i}
+		if o.Buyer {
+			o.Agent = generator.Intn(len(buyers))
+			if !buyers[o.Agent].canBuy() {
+				continue
+			}
+			o.Price = m.bid(&buyers[o.Agent], p.history, generator)
+		} else {
+			o.Agent = generator.Intn(len(sellers))
+			if !sellers[o.Agent].canSell() {
+				continue
+			}
+			o.Price = m.ask(&sellers[o.Agent], p.history, generator)
+		}
+		if o.Price == NoQuote {
+			continue
+		}
+		matched := market.Submit(o)
+		p.history.add(o.Price, o.Buyer, len(matched) > 0)
+		execute(i, matched)
+	}
+	if c, ok := market.(Closer); ok {
+		execute(i-1, c.Close())
+	}
+	return trades
+}
+
+// The random bilateral institution as a Market: a quote waits for the next
+// quote of the other side, and the two trade at a price drawn uniformly
+// between them if they cross; either way both leave. A later quote of the
+// same side replaces a waiting one.
+type bilateralMarket struct {
+	r       *rand.Rand
+	waiting Order
+	ok      bool
+}
+
+func (b *bilateralMarket) Submit(o Order) []Trade {
+	if !b.ok || b.waiting.Buyer == o.Buyer {
+		b.waiting, b.ok = o, true
+		return nil
+	}
+	bid, ask := b.waiting, o
+	if o.Buyer {
+		bid, ask = o, b.waiting
+	}
+	b.ok = false
+	if bid.Price < ask.Price {
+		return nil
+	}
+	return []Trade{{Buyer: bid.Agent, Seller: ask.Agent, Bid: bid.Price, Ask: ask.Price, Price: ask.Price + b.r.Intn(bid.Price-ask.Price+1)}}
+}
+
+// The continuous double auction as a Market: an order trades at the price
+// of the standing order it crosses, and otherwise joins the book.
+type bookMarket struct {
+	b *book
+}
+
+func (m bookMarket) Submit(o Order) []Trade {
+	if o.Buyer {
+		if ask, ok := m.b.bid(o.Agent, o.Price); ok {
+			return []Trade{{Buyer: o.Agent, Seller: ask.agent, Bid: o.Price, Ask: ask.price, Price: ask.price}}
+		}
+		return nil
+	}
+	if bid, ok := m.b.ask(o.Agent, o.Price); ok {
+		return []Trade{{Buyer: bid.agent, Seller: o.Agent, Bid: bid.price, Ask: o.Price, Price: bid.price}}
+	}
+	return nil
+}
+
+// The call market as a Market: it collects round orders, a trader's latest
+// replacing its earlier ones, and then clears them at a single price; with no
+// round it clears only when the period ends.
+type callMarket struct {
+	round, n   int
+	bids, asks map[int]int
+}
+
+func (c *callMarket) Submit(o Order) []Trade {
+	if o.Buyer {
+		place(c.bids, o.Agent, o.Price)
+	} else {
+		place(c.asks, o.Agent, o.Price)
+	}
+	if c.n++; c.round <= 0 || c.n%c.round != 0 {
+		return nil
+	}
+	return c.Close()
+}
+
+func (c *callMarket) Close() []Trade {
+	trades := clearCall(c.bids, c.asks)
+	c.bids, c.asks = make(map[int]int), make(map[int]int)
+	return trades
+}
@@ -0,0 +1,88 @@
+package zitraders
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// Registered markets trade legally through the generic loop, and the
+// built-in institutions run on it come close to their own loops.
+func TestRegisteredMarkets(t *testing.T) {
+	for _, name := range []string{Bilateral, CDA, Call} {
+		name := name
+		RegisterMarket("test-"+name, func(c Config, r *rand.Rand) Market {
+			market, err := NewMarket(name, c, r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return market
+		})
+		for _, modify := range []func(*Config){
+			func(c *Config) {},
+			func(c *Config) { c.Units, c.Periods = 3, 2 },
+			func(c *Config) { c.GD, c.ZIP = 0.3, 0.3 },
+		} {
+			config := testConfig()
+			config.Institution, config.RecordTrades, config.CallRound = name, true, 200
+			modify(&config)
+			native := newTestModel(t, config).Run()
+			config.Institution = "test-" + name
+			m := newTestModel(t, config)
+			r := m.Run()
+
+			if r.NumberBought != r.NumberSold || r.NumberBought != len(m.Trades()) || r.NumberBought == 0 {
+				t.Errorf("%s: %d bought, %d sold, %d trades logged", config.Institution, r.NumberBought, r.NumberSold, len(m.Trades()))
+			}
+			for _, tr := range m.Trades() {
+				if tr.Price < tr.Ask || tr.Price > tr.Bid {
+					t.Fatalf("%s: trade %+v outside the quotes", config.Institution, tr)
+				}
+			}
+			if math.Abs(r.Efficiency-native.Efficiency) > 5 {
+				t.Errorf("%s: efficiency %.2f, against %.2f on its own loop", config.Institution, r.Efficiency, native.Efficiency)
+			}
+		}
+	}
+	found := false
+	for _, name := range Institutions() {
+		found = found || name == "test-cda"
+	}
+	if !found {
+		t.Errorf("institutions %v", Institutions())
+	}
+}
+
+type badMarket struct{}
+
+func (badMarket) Submit(o Order) []Trade {
+	return []Trade{
+		{Buyer: o.Agent, Seller: o.Agent, Bid: 10, Ask: 20, Price: 15},
+		{Buyer: -1, Seller: o.Agent, Bid: 20, Ask: 10, Price: 15},
+	}
+}
+
+// Trades a market makes that break the rules are dropped, and options only
+// the built-in institutions support are refused.
+func TestMarketRules(t *testing.T) {
+	RegisterMarket("test-bad", func(Config, *rand.Rand) Market { return badMarket{} })
+	config := testConfig()
+	config.Institution = "test-bad"
+	if r := newTestModel(t, config).Run(); r.NumberBought != 0 {
+		t.Errorf("%d illegal trades executed", r.NumberBought)
+	}
+	for _, modify := range []func(*Config){
+		func(c *Config) { c.Dealer = true },
+		func(c *Config) { c.Matching = Pool },
+		func(c *Config) { c.Latency = 10 },
+		func(c *Config) { c.Layout = SoA },
+		func(c *Config) { c.Institution = "test-none" },
+	} {
+		config := testConfig()
+		config.Institution = "test-bad"
+		modify(&config)
+		if _, err := New(config); err == nil {
+			t.Errorf("%+v accepted", config)
+		}
+	}
+}
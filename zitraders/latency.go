@@ -0,0 +1,163 @@
+package zitraders
+
+import (
+	"container/heap"
+	"fmt"
+	"sync/atomic"
+)
+
+// Under a latency model, the quotes of slow traders in a continuous double
+// auction reach the book only after a random delay, drawn from an exponential
+// distribution with a mean of Latency trade attempts, while those of fast
+// traders, a FastShare of them, arrive at once. Quotes in flight are matched
+// in order of arrival. A trader's later quote replaces an earlier one still in
+// flight, and a quote is dropped if its trader has traded since sending it,
+// since the quote was made for another unit.
+
+// LatencyResults compare the fast and slow traders of a market with latency.
+type LatencyResults struct {
+	Fast       int     `json:"fast"` // traders
+	Slow       int     `json:"slow"`
+	FastTrades int     `json:"fast_trades"` // units bought or sold
+	SlowTrades int     `json:"slow_trades"`
+	FastProfit float64 `json:"fast_profit"` // realized surplus per trader
+	SlowProfit float64 `json:"slow_profit"`
+	Dropped    int64   `json:"dropped"` // quotes replaced in flight, outdated by a trade or still in flight at the close
+}
+
+func (m *Model) checkLatency() error {
+	if m.Latency < 0 || m.FastShare < 0 || m.FastShare > 1 {
+		return fmt.Errorf("latency must be positive and the share of fast traders between 0 and 1")
+	}
+	if (m.Latency > 0 || m.FastShare > 0) && m.Institution != CDA {
+		return fmt.Errorf("latency requires the cda institution")
+	}
+	return nil
+}
+
+// Whether the buyer or seller with the given population index is fast. Each
+// trader's speed is a hash of its index and the seed, so it needs no storage.
+func (m *Model) fast(buyer bool, i int) bool {
+	if m.FastShare == 0 {
+		return false
+	}
+	x := uint64(m.Seed) ^ uint64(i)<<1
+	if buyer {
+		x |= 1
+	}
+	x = splitmix(x)
+	return float64(x>>11)/(1<<53) < m.FastShare
+}
+
+// The splitmix64 finalizer.
+func splitmix(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// A quote in flight to the book.
+type arrival struct {
+	at    int // the attempt at which it arrives
+	seq   int
+	buyer bool
+	agent int
+	price int
+	held  int // the units the trader held when it sent the quote
+}
+
+// Quotes in flight, ordered by arrival and then by when they were sent.
+type arrivals struct {
+	queue   arrivalQueue
+	seq     int
+	buyers  map[int]int // the sequence number of each buyer's latest quote in flight
+	sellers map[int]int
+	dropped int64
+}
+
+type arrivalQueue []arrival
+
+func (q arrivalQueue) Len() int { return len(q) }
+func (q arrivalQueue) Less(i, j int) bool {
+	return q[i].at < q[j].at || (q[i].at == q[j].at && q[i].seq < q[j].seq)
+}
+func (q arrivalQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *arrivalQueue) Push(x interface{}) { *q = append(*q, x.(arrival)) }
+func (q *arrivalQueue) Pop() interface{} {
+	old := *q
+	a := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return a
+}
+
+func newArrivals() *arrivals {
+	return &arrivals{buyers: make(map[int]int), sellers: make(map[int]int)}
+}
+
+func (a *arrivals) latest(buyer bool) map[int]int {
+	if buyer {
+		return a.buyers
+	}
+	return a.sellers
+}
+
+// Send a quote that arrives at attempt at, replacing any of the trader's
+// still in flight.
+func (a *arrivals) send(at int, buyer bool, agent, price, held int) {
+	a.cancel(buyer, agent)
+	a.seq++
+	a.latest(buyer)[agent] = a.seq
+	heap.Push(&a.queue, arrival{at: at, seq: a.seq, buyer: buyer, agent: agent, price: price, held: held})
+}
+
+// Drop any quote of the trader's still in flight.
+func (a *arrivals) cancel(buyer bool, agent int) {
+	latest := a.latest(buyer)
+	if _, ok := latest[agent]; ok {
+		delete(latest, agent)
+		a.dropped++
+	}
+}
+
+// The next quote to arrive by attempt i, if any.
+func (a *arrivals) next(i int) (arrival, bool) {
+	for len(a.queue) > 0 && a.queue[0].at <= i {
+		x := heap.Pop(&a.queue).(arrival)
+		if latest := a.latest(x.buyer); latest[x.agent] == x.seq {
+			delete(latest, x.agent)
+			return x, true
+		}
+	}
+	return arrival{}, false
+}
+
+// Compare the fast and slow traders.
+func (m *Model) latencyResults() *LatencyResults {
+	r := &LatencyResults{Dropped: atomic.LoadInt64(&m.dropped)}
+	var fastProfit, slowProfit int
+	for _, side := range []struct {
+		buyer  bool
+		agents []agent
+	}{{true, m.buyers}, {false, m.sellers}} {
+		for i := range side.agents {
+			a := &side.agents[i]
+			if m.fast(side.buyer, i) {
+				r.Fast++
+				r.FastTrades += len(a.prices)
+				fastProfit += a.surplus()
+			} else {
+				r.Slow++
+				r.SlowTrades += len(a.prices)
+				slowProfit += a.surplus()
+			}
+		}
+	}
+	if r.Fast > 0 {
+		r.FastProfit = float64(fastProfit) / float64(r.Fast)
+	}
+	if r.Slow > 0 {
+		r.SlowProfit = float64(slowProfit) / float64(r.Slow)
+	}
+	return r
+}
@@ -0,0 +1,46 @@
+package zitraders
+
+import "testing"
+
+// Quotes arrive in order of arrival and then of sending, and a trader's later
+// quote replaces one still in flight.
+func TestArrivals(t *testing.T) {
+	a := newArrivals()
+	a.send(5, true, 1, 10, 0)
+	a.send(3, false, 2, 20, 1)
+	a.send(3, true, 3, 30, 0)
+	a.send(4, true, 1, 11, 0)
+
+	var got []int
+	for i := 1; i <= 6; i++ {
+		for x, ok := a.next(i); ok; x, ok = a.next(i) {
+			if x.at > i {
+				t.Fatalf("quote %+v arrived at %d", x, i)
+			}
+			got = append(got, x.price)
+		}
+	}
+	if want := []int{20, 30, 11}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("arrived %v, want %v", got, want)
+	}
+	if a.dropped != 1 {
+		t.Errorf("%d quotes dropped, want 1", a.dropped)
+	}
+}
+
+func TestLatencyResults(t *testing.T) {
+	config := testConfig()
+	config.Institution, config.Latency, config.FastShare = CDA, 100, 0.25
+	r := newTestModel(t, config).Run()
+
+	l := r.Latency
+	if l == nil || l.Fast+l.Slow != config.NumBuyers+config.NumSellers || l.FastTrades+l.SlowTrades != r.NumberBought+r.NumberSold {
+		t.Fatalf("got %+v", l)
+	}
+	if share := float64(l.Fast) / float64(l.Fast+l.Slow); share < 0.2 || share > 0.3 {
+		t.Errorf("%.3f of traders are fast, want 0.25", share)
+	}
+	if l.Dropped == 0 {
+		t.Errorf("no quote was dropped")
+	}
+}
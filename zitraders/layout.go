@@ -0,0 +1,342 @@
+package zitraders
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// Agent storage layouts.
+const (
+	AoS     = "aos"     // a slice of agent structs
+	SoA     = "soa"     // parallel slices of values, prices and holdings
+	Compact = "compact" // parallel slices of 16-bit values and prices, and a bitset of holdings
+)
+
+// An agentStore holds one side of the market. The struct-of-arrays layout
+// keeps only what the single-unit bilateral model needs, in 9 bytes per agent,
+// so trade attempts touch fewer cache lines and very large populations fit in
+// memory. Both layouts implement the interface so they can be compared on the
+// same trade loop.
+type agentStore interface {
+	Len() int
+	Value(i int) int
+	Unconstrained(i int) bool
+	CanTrade(i int) bool
+	Trade(i, price int)
+	Traded(i int) bool
+	Held(i int) int
+	Price(i int) int
+	Slice(lo, hi int) agentStore
+}
+
+// A columnStore is a population stored in parallel slices, at full width or
+// compact, which the model draws, runs and checkpoints the same way.
+type columnStore interface {
+	agentStore
+	endow(lo, hi int)
+	touch(lo, hi int)
+	setValue(i, v int)
+	setUnconstrained(i int, unconstrained bool)
+	state() storeState
+	restore(storeState) error
+}
+
+// Whether the population is held in column stores.
+func (m *Model) columnar() bool {
+	return m.Layout == SoA || m.Layout == Compact
+}
+
+// aosStore adapts a slice of agents to the agentStore interface.
+type aosStore []agent
+
+func (s aosStore) Len() int                    { return len(s) }
+func (s aosStore) Unconstrained(i int) bool    { _, ok := s[i].strategy.(ziu); return ok }
+func (s aosStore) Traded(i int) bool           { return len(s[i].prices) > 0 }
+func (s aosStore) Held(i int) int              { return int(s[i].quantityHeld) }
+func (s aosStore) Price(i int) int             { return s[i].price }
+func (s aosStore) Slice(lo, hi int) agentStore { return s[lo:hi:hi] }
+
+// The value of an agent's marginal unit, read from its holdings, which are
+// current even while units change hands by compare-and-swap.
+func (s aosStore) Value(i int) int {
+	if k := s[i].traded(); k < len(s[i].schedule) {
+		return s[i].schedule[k]
+	}
+	return s[i].value
+}
+
+func (s aosStore) CanTrade(i int) bool {
+	if s[i].buyerOrSeller {
+		return s[i].canBuy()
+	}
+	return s[i].canSell()
+}
+
+func (s aosStore) Trade(i, price int) {
+	if s[i].buyerOrSeller {
+		s[i].buy(price)
+	} else {
+		s[i].sell(price)
+	}
+}
+
+// soaStore is the struct-of-arrays layout for single-unit agents.
+type soaStore struct {
+	buyer         bool
+	values        []int32
+	prices        []int32
+	held          []uint8
+	unconstrained []bool // nil when every trader is ZI-C
+}
+
+// Allocate a store of n agents, to be endowed.
+func newSoAStore(buyer bool, n int, unconstrained bool) *soaStore {
+	s := &soaStore{
+		buyer:  buyer,
+		values: make([]int32, n),
+		prices: make([]int32, n),
+		held:   make([]uint8, n),
+	}
+	if unconstrained {
+		s.unconstrained = make([]bool, n)
+	}
+	return s
+}
+
+// Endow agents lo to hi: each seller holds its unit.
+func (s *soaStore) endow(lo, hi int) {
+	if !s.buyer {
+		for i := lo; i < hi; i++ {
+			s.held[i] = 1
+		}
+	}
+}
+
+// Endow agents lo to hi, first touching their values and prices too.
+func (s *soaStore) touch(lo, hi int) {
+	zero(s.values[lo:hi])
+	zero(s.prices[lo:hi])
+	zero(s.held[lo:hi])
+	s.endow(lo, hi)
+}
+
+func (s *soaStore) setValue(i, v int) { s.values[i] = int32(v) }
+
+func (s *soaStore) setUnconstrained(i int, unconstrained bool) { s.unconstrained[i] = unconstrained }
+
+func (s *soaStore) state() storeState {
+	return storeState{Values: s.values, Prices: s.prices, Held: s.held, Unconstrained: s.unconstrained}
+}
+
+func (s *soaStore) restore(state storeState) error {
+	if len(state.Values) != s.Len() {
+		return fmt.Errorf("checkpoint does not match its configuration")
+	}
+	copy(s.values, state.Values)
+	copy(s.prices, state.Prices)
+	copy(s.held, state.Held)
+	s.unconstrained = state.Unconstrained
+	return nil
+}
+
+func (s *soaStore) Len() int        { return len(s.values) }
+func (s *soaStore) Value(i int) int { return int(s.values[i]) }
+func (s *soaStore) Held(i int) int  { return int(s.held[i]) }
+func (s *soaStore) Price(i int) int { return int(s.prices[i]) }
+
+func (s *soaStore) Unconstrained(i int) bool {
+	return s.unconstrained != nil && s.unconstrained[i]
+}
+
+func (s *soaStore) CanTrade(i int) bool {
+	return (s.held[i] == 0) == s.buyer
+}
+
+func (s *soaStore) Traded(i int) bool {
+	return (s.held[i] == 1) == s.buyer
+}
+
+func (s *soaStore) Trade(i, price int) {
+	s.prices[i] = int32(price)
+	s.held[i] ^= 1
+}
+
+func (s *soaStore) Slice(lo, hi int) agentStore {
+	t := &soaStore{
+		buyer:  s.buyer,
+		values: s.values[lo:hi:hi],
+		prices: s.prices[lo:hi:hi],
+		held:   s.held[lo:hi:hi],
+	}
+	if s.unconstrained != nil {
+		t.unconstrained = s.unconstrained[lo:hi:hi]
+	}
+	return t
+}
+
+// Initialize the struct-of-arrays population, drawing from the blocks'
+// generators in the same order as initializeAgents so that every layout
+// produces the same market from the same seed.
+func (m *Model) initializeStores(buyerValue, sellerCost func(*rand.Rand) int) (columnStore, columnStore) {
+	unconstrained := m.Unconstrained > 0
+	var b, s columnStore
+	if m.Layout == Compact {
+		b = newCompactStore(true, m.NumBuyers, unconstrained)
+		s = newCompactStore(false, m.NumSellers, unconstrained)
+	} else {
+		b = newSoAStore(true, m.NumBuyers, unconstrained)
+		s = newSoAStore(false, m.NumSellers, unconstrained)
+	}
+	if m.FirstTouch {
+		m.firstTouch(func(t int) {
+			lowerBuyer, upperBuyer, lowerSeller, upperSeller := m.bounds(t)
+			b.touch(lowerBuyer, upperBuyer)
+			s.touch(lowerSeller, upperSeller)
+		})
+	} else {
+		s.endow(0, m.NumSellers)
+	}
+
+	for _, side := range []struct {
+		store columnStore
+		buyer bool
+		value func(*rand.Rand) int
+	}{{b, true, buyerValue}, {s, false, sellerCost}} {
+		m.inBlocks(side.store.Len(), func(_, block, lo, hi int) {
+			r := m.blockSource(side.buyer, block)
+			for i := lo; i < hi; i++ {
+				side.store.setValue(i, side.value(r))
+				if unconstrained {
+					side.store.setUnconstrained(i, r.Float64() < m.Unconstrained)
+				}
+			}
+		})
+	}
+	return b, s
+}
+
+// Stores hold only ZI-C and ZI-U traders.
+func (m *Model) storeStrategy(s agentStore, i int) Strategy {
+	if s.Unconstrained(i) {
+		return ziu{}
+	}
+	return zic{}
+}
+
+func (m *Model) storeView(s agentStore, i int, r *rand.Rand) MarketView {
+	return MarketView{Value: s.Value(i), MaxBuyerValue: m.MaxBuyerValue, MaxSellerValue: m.MaxSellerValue, Rand: r}
+}
+
+// The bilateral trade loop over a thread's partition of two stores.
+func (m *Model) doStoreTrades(ctx context.Context, thread int, buyers, sellers agentStore, buyerOffset, sellerOffset int, generator *rand.Rand) []Trade {
+	var trades []Trade
+	progress := m.tally(thread, &trades)
+	defer progress.close()
+	sampler := m.sampler(thread)
+
+	for i := 1 + m.skip; m.more(ctx, i, progress); i++ {
+		progress.attempt()
+
+		var buyerIndex, sellerIndex int
+		if sampler != nil {
+			buyerIndex, sellerIndex = sampler.Pair(m.period*m.tradesPerThread+i, buyers.Len(), sellers.Len())
+		} else {
+			buyerIndex = generator.Intn(buyers.Len())
+			sellerIndex = generator.Intn(sellers.Len())
+		}
+
+		bidPrice := m.storeStrategy(buyers, buyerIndex).Bid(m.storeView(buyers, buyerIndex, generator))
+		askPrice := m.storeStrategy(sellers, sellerIndex).Ask(m.storeView(sellers, sellerIndex, generator))
+
+		if buyers.CanTrade(buyerIndex) && sellers.CanTrade(sellerIndex) && bidPrice >= askPrice {
+			transactionPrice := askPrice + generator.Intn(bidPrice-askPrice+1)
+			buyers.Trade(buyerIndex, transactionPrice)
+			sellers.Trade(sellerIndex, transactionPrice)
+			progress.trade(transactionPrice)
+
+			if m.RecordTrades {
+				trades = append(trades, Trade{
+					Tick:   i,
+					Thread: thread,
+					Buyer:  buyerOffset + buyerIndex,
+					Seller: sellerOffset + sellerIndex,
+					Bid:    bidPrice,
+					Ask:    askPrice,
+					Price:  transactionPrice,
+				})
+			}
+		}
+	}
+	return trades
+}
+
+// Run the partitioned bilateral market on the struct-of-arrays stores.
+func (m *Model) openStoreMarket(ctx context.Context, generators []*rand.Rand) [][]Trade {
+	var wg sync.WaitGroup
+	logs := make([][]Trade, m.NumThreads)
+	for t := 0; t < m.NumThreads; t++ {
+		wg.Add(1)
+		go func(t int) {
+			defer wg.Done()
+			lowerBuyer, upperBuyer, lowerSeller, upperSeller := m.bounds(t)
+			logs[t] = m.doStoreTrades(ctx, t,
+				m.buyerStore.Slice(lowerBuyer, upperBuyer), m.sellerStore.Slice(lowerSeller, upperSeller),
+				lowerBuyer, lowerSeller, generators[t])
+		}(t)
+	}
+	m.schedule(ctx, &wg, m.NumThreads, logs)
+	return logs
+}
+
+// Compute the statistics of a struct-of-arrays run.
+func (m *Model) computeStoreStatistics() Results {
+	r := Results{Seed: m.Seed, PriceTick: m.PriceTick}
+	values := make([]int, m.buyerStore.Len())
+	costs := make([]int, m.sellerStore.Len())
+	t := m.tallyAgents(len(values), len(costs), func(t *agentTally, buyer bool, i int) {
+		s, schedule, side := m.buyerStore, values, 0
+		if !buyer {
+			s, schedule, side = m.sellerStore, costs, 1
+		}
+		schedule[i] = s.Value(i)
+		profit := 0
+		if s.Traded(i) {
+			profit = s.Price(i) - schedule[i]
+			if buyer {
+				profit = -profit
+			}
+			t.traded[side]++
+			t.surplus[side] += profit
+			t.prices.add(s.Price(i))
+		}
+		t.profits[side].add(profit)
+	})
+	t.results(&r)
+	prices := r.setPrices(t.prices, m.PriceBins)
+
+	sort.Sort(sort.Reverse(sort.IntSlice(values)))
+	sort.Ints(costs)
+	r.finish(findEquilibrium(values, costs), 1, m.trades, m.ConvergenceBlock)
+	r.Alpha = prices.alpha(r.EquilibriumPrice)
+
+	var l losses
+	for _, s := range []columnStore{m.buyerStore, m.sellerStore} {
+		for i := 0; i < s.Len(); i++ {
+			l.add(s == m.buyerStore, s.Value(i), s.Traded(i), r.EquilibriumPrice)
+		}
+	}
+	r.Welfare.Unrealized, r.Welfare.Extramarginal = l.unrealized, l.extramarginal
+	if m.RecordTrades {
+		r.Marginal = m.classifyTrades(r.EquilibriumPrice)
+	}
+	if m.windowed() {
+		r.Window = m.windowResults(r.EquilibriumPrice)
+	}
+	if m.RoundSize > 0 {
+		r.Rounds = m.rounds(r.EquilibriumPrice, r.MaxSurplus)
+	}
+	return r
+}
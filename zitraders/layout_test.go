@@ -0,0 +1,85 @@
+package zitraders
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// Both layouts must produce the same market from the same seed.
+func TestLayoutsAgree(t *testing.T) {
+	config := DefaultConfig()
+	config.NumBuyers, config.NumSellers = 1000, 1000
+	config.MaxNumberOfTrades = 100000
+	config.NumThreads = 4
+	config.Seed = 1
+
+	var results [3]Results
+	for i, layout := range []string{AoS, SoA, Compact} {
+		config.Layout = layout
+		m, err := New(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results[i] = m.Run()
+	}
+	for i, layout := range []string{SoA, Compact} {
+		if !reflect.DeepEqual(results[0], results[i+1]) {
+			t.Errorf("aos results %+v differ from %s results %+v", results[0], layout, results[i+1])
+		}
+	}
+}
+
+// The supply and demand schedules, and so the equilibrium, are the same
+// whichever layout holds the agents.
+func TestCurvesLayouts(t *testing.T) {
+	config := testConfig()
+	var want Curves
+	for _, layout := range []string{AoS, SoA, Compact} {
+		config.Layout = layout
+		m := newTestModel(t, config)
+		c := m.Curves()
+		if len(c.Demand) != config.NumBuyers || len(c.Supply) != config.NumSellers || c.EquilibriumQuantity == 0 {
+			t.Errorf("%s: curves of %d values and %d costs, equilibrium quantity %d", layout, len(c.Demand), len(c.Supply), c.EquilibriumQuantity)
+		}
+		if e := m.Equilibrium(); e.Quantity != c.EquilibriumQuantity || e.Price() != c.EquilibriumPrice {
+			t.Errorf("%s: equilibrium %+v, curves %+v", layout, e, c)
+		}
+		if layout == AoS {
+			want = c
+		} else if !reflect.DeepEqual(c, want) {
+			t.Errorf("%s curves differ from aos curves", layout)
+		}
+	}
+}
+
+// Benchmark the bilateral trade loop on each layout with b.N attempts over a
+// population of n buyers and n sellers.
+func benchmarkLayout(b *testing.B, layout string, n int) {
+	config := DefaultConfig()
+	config.NumBuyers, config.NumSellers = n, n
+	config.MaxNumberOfTrades = b.N + 1
+	config.NumThreads = 1
+	config.Layout = layout
+	m, err := New(config)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var buyers, sellers agentStore = aosStore(m.buyers), aosStore(m.sellers)
+	if layout != AoS {
+		buyers, sellers = m.buyerStore, m.sellerStore
+	}
+	generator := stream(rand.Int63(), 1)
+	b.ResetTimer()
+	m.doStoreTrades(context.Background(), 0, buyers, sellers, 0, 0, generator)
+}
+
+func BenchmarkLayoutAoS1M(b *testing.B)  { benchmarkLayout(b, AoS, 1000000) }
+func BenchmarkLayoutSoA1M(b *testing.B)  { benchmarkLayout(b, SoA, 1000000) }
+func BenchmarkLayoutAoS10M(b *testing.B) { benchmarkLayout(b, AoS, 10000000) }
+func BenchmarkLayoutSoA10M(b *testing.B) { benchmarkLayout(b, SoA, 10000000) }
+
+func BenchmarkLayoutCompact1M(b *testing.B)  { benchmarkLayout(b, Compact, 1000000) }
+func BenchmarkLayoutCompact10M(b *testing.B) { benchmarkLayout(b, Compact, 10000000) }
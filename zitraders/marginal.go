@@ -0,0 +1,84 @@
+package zitraders
+
+// MarginalResults divide the recorded trades into those of intramarginal
+// units, which trade in the competitive equilibrium, and those in which the
+// buyer's or the seller's unit is extramarginal, valued below or costing
+// more than the equilibrium price. Surplus is the traders' surplus on the
+// units traded, leaving out any intermediary's side of a trade.
+type MarginalResults struct {
+	Intramarginal        int     `json:"intramarginal"`
+	Extramarginal        int     `json:"extramarginal"`
+	IntramarginalSurplus int     `json:"intramarginal_surplus"`
+	ExtramarginalSurplus int     `json:"extramarginal_surplus"`
+	Displaced            float64 `json:"displaced"` // the surplus lost to the extramarginal units, at the equilibrium price
+}
+
+// Flag the extramarginal trades of the log against the values and costs in
+// force at the end and the equilibrium price p they imply, and total each
+// class. Each trader's units are taken to trade in order of its schedule,
+// starting again every period.
+func (m *Model) classifyTrades(p float64) *MarginalResults {
+	var r MarginalResults
+	m.walkTrades(func(_ int, t *Trade, value, cost int) {
+		var l losses
+		surplus := 0
+		if t.Buyer >= 0 {
+			l.add(true, value, true, p)
+			surplus += value - t.Price
+		}
+		if t.Seller >= 0 {
+			l.add(false, cost, true, p)
+			surplus += t.Price - cost
+		}
+		t.Extramarginal = l.extramarginal > 0
+		if t.Extramarginal {
+			r.Extramarginal++
+			r.ExtramarginalSurplus += surplus
+			r.Displaced += l.extramarginal
+		} else {
+			r.Intramarginal++
+			r.IntramarginalSurplus += surplus
+		}
+	})
+	return &r
+}
+
+// Visit the trades of the log in order, with the value of the buyer's unit
+// and the cost of the seller's, where the trade has a buyer or seller rather
+// than an intermediary. Each trader's units are taken to trade in order of
+// its schedule, starting again every period.
+func (m *Model) walkTrades(visit func(k int, t *Trade, value, cost int)) {
+	var bought, sold []int
+	period := -1
+	for k := range m.trades {
+		t := &m.trades[k]
+		if t.Period != period {
+			period = t.Period
+			bought, sold = make([]int, m.NumBuyers), make([]int, m.NumSellers)
+		}
+		value, cost := 0, 0
+		if t.Buyer >= 0 {
+			value = m.unitValue(true, t.Buyer, bought[t.Buyer])
+			bought[t.Buyer]++
+		}
+		if t.Seller >= 0 {
+			cost = m.unitValue(false, t.Seller, sold[t.Seller])
+			sold[t.Seller]++
+		}
+		visit(k, t, value, cost)
+	}
+}
+
+// The value or cost of a buyer's or seller's k-th unit.
+func (m *Model) unitValue(buyer bool, i, k int) int {
+	if m.columnar() {
+		if buyer {
+			return m.buyerStore.Value(i)
+		}
+		return m.sellerStore.Value(i)
+	}
+	if buyer {
+		return m.buyers[i].schedule[k]
+	}
+	return m.sellers[i].schedule[k]
+}
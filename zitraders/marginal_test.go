@@ -0,0 +1,50 @@
+package zitraders
+
+import (
+	"math"
+	"testing"
+)
+
+// The classes of trades account for every trade and, without intermediaries,
+// for the realized surplus and the welfare lost to extramarginal units.
+func TestClassifyTrades(t *testing.T) {
+	for _, modify := range []func(*Config){
+		func(c *Config) {},
+		func(c *Config) { c.Layout = SoA },
+		func(c *Config) { c.Unconstrained = 0.5 },
+		func(c *Config) { c.Units, c.Periods = 3, 2 },
+		func(c *Config) { c.Institution = CDA },
+	} {
+		config := testConfig()
+		config.RecordTrades = true
+		modify(&config)
+		m := newTestModel(t, config)
+		r := m.Run()
+
+		x := r.Marginal
+		if x == nil || x.Intramarginal+x.Extramarginal != len(m.Trades()) || x.IntramarginalSurplus+x.ExtramarginalSurplus != r.RealizedSurplus {
+			t.Fatalf("%+v: got %+v for %d trades and a surplus of %d", config, x, len(m.Trades()), r.RealizedSurplus)
+		}
+		if math.Abs(x.Displaced-r.Welfare.Extramarginal) > 1e-9 {
+			t.Errorf("%+v: trades displaced %v, the welfare decomposition %v", config, x.Displaced, r.Welfare.Extramarginal)
+		}
+		flagged := 0
+		for _, tr := range m.Trades() {
+			if tr.Extramarginal {
+				flagged++
+			}
+		}
+		if flagged != x.Extramarginal {
+			t.Errorf("%+v: %d trades flagged, %d counted", config, flagged, x.Extramarginal)
+		}
+		if config.Unconstrained > 0 && x.Extramarginal == 0 {
+			t.Errorf("%+v: no extramarginal trades among ZI-U traders", config)
+		}
+	}
+}
+
+func TestMarginalNeedsTrades(t *testing.T) {
+	if r := newTestModel(t, testConfig()).Run(); r.Marginal != nil {
+		t.Errorf("got %+v without a trade log", r.Marginal)
+	}
+}
@@ -0,0 +1,238 @@
+package zitraders
+
+import "math/rand"
+
+// The population may be split into several markets for the same good, each
+// trader belonging to the market of its population index modulo Markets. A
+// trade attempt picks a market at random and pairs a buyer and a seller from
+// it. Market k's values and costs are shifted by k times MarketShift, so that
+// markets may clear at different prices. An arbitrageur in each thread, if
+// configured, buys from sellers in one market when it expects to resell for
+// more in another, and sells the units it holds to buyers elsewhere.
+
+// Arbitrageur is the agent index the trade log gives an arbitrageur.
+const Arbitrageur = -2
+
+// Defaults for an arbitrageur whose configuration leaves them out.
+const (
+	defaultArbitrageMargin   = 1
+	defaultArbitrageCapacity = 10
+)
+
+// The weight of each new price in an arbitrageur's moving averages.
+const arbitrageSmoothing = 0.05
+
+// MarketResults holds the statistics of one market.
+type MarketResults struct {
+	NumberBought     int     `json:"number_bought"`
+	NumberSold       int     `json:"number_sold"`
+	MeanPrice        float64 `json:"mean_price"`
+	SDPrice          float64 `json:"sd_price"`
+	EquilibriumPrice float64 `json:"equilibrium_price"` // of the market on its own
+	RealizedSurplus  int     `json:"realized_surplus"`  // of the market's traders
+	MaxSurplus       int     `json:"max_surplus"`       // of the market on its own
+	Efficiency       float64 `json:"efficiency"`
+}
+
+// ArbitrageResults sums up what the arbitrageurs did over a run.
+type ArbitrageResults struct {
+	Bought    int `json:"bought"`    // units bought from sellers
+	Sold      int `json:"sold"`      // units sold to buyers in other markets
+	Inventory int `json:"inventory"` // units still held at the end
+	Profit    int `json:"profit"`    // sales less purchases; units still held count for nothing
+}
+
+type arbitrageur struct {
+	margin   int       // the least profit it seeks on a unit
+	capacity int       // the most units it may hold
+	stock    [][]int   // the prices paid for the units held, by the market they were bought in
+	ref      []float64 // the moving average of prices traded in each market
+	cash     int       // sales less purchases
+	bought   int
+	prices   []int // the price of every unit bought or sold
+}
+
+func (m *Model) newArbitrageur() *arbitrageur {
+	a := &arbitrageur{
+		margin:   m.ArbitrageMargin,
+		capacity: m.ArbitrageCapacity,
+		stock:    make([][]int, m.Markets),
+		ref:      make([]float64, m.Markets),
+	}
+	for k := range a.ref {
+		a.ref[k] = float64(m.maxPrice()) / 2
+	}
+	return a
+}
+
+// The arbitrageur of a thread, or nil if there are none.
+func (m *Model) arbitrageur(thread int) *arbitrageur {
+	if m.arbitrageurs == nil {
+		return nil
+	}
+	return m.arbitrageurs[thread]
+}
+
+// The market of the trader with the given population index.
+func (m *Model) market(i int) int {
+	return i % m.Markets
+}
+
+// Draw a random index into n agents, the first of which has population index
+// offset, of a trader in market k.
+func (m *Model) drawIn(k, n, offset int, r *rand.Rand) int {
+	first := ((k-offset)%m.Markets + m.Markets) % m.Markets
+	return first + m.Markets*r.Intn((n-first+m.Markets-1)/m.Markets)
+}
+
+// Shift a value or cost into market k, keeping it between 1 and max.
+func (m *Model) shiftInto(k, v, max int) int {
+	v += k * m.MarketShift
+	if v < 1 {
+		return 1
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// Note a price traded in market k.
+func (a *arbitrageur) see(k, price int) {
+	if a != nil {
+		a.ref[k] += arbitrageSmoothing * (float64(price) - a.ref[k])
+	}
+}
+
+func (a *arbitrageur) held() int {
+	n := 0
+	for _, s := range a.stock {
+		n += len(s)
+	}
+	return n
+}
+
+// The most the arbitrageur would pay in market k: what it expects to resell
+// for in the dearest other market, less its margin.
+func (a *arbitrageur) reservation(k int) int {
+	best := 0.0
+	for j, p := range a.ref {
+		if j != k && p > best {
+			best = p
+		}
+	}
+	return int(best) - a.margin
+}
+
+// The market, other than k, of the cheapest unit held that the arbitrageur
+// would sell at price, or -1.
+func (a *arbitrageur) source(k, price int) int {
+	j := -1
+	for i, s := range a.stock {
+		if i == k || len(s) == 0 || s[len(s)-1]+a.margin > price {
+			continue
+		}
+		if j < 0 || s[len(s)-1] < a.stock[j][len(a.stock[j])-1] {
+			j = i
+		}
+	}
+	return j
+}
+
+// Let the arbitrageur a trade with whichever of a buyer and a seller in
+// market k, who did not trade with each other, it can profit from, choosing
+// at random if both. It buys at the seller's ask and sells at the buyer's
+// bid. The trade returned names the arbitrageur as its buyer or seller.
+func (m *Model) arbitrage(a *arbitrageur, k int, buyer, seller *agent, bidPrice, askPrice int, h *history, generator *rand.Rand) (Trade, bool) {
+	if a == nil {
+		return Trade{}, false
+	}
+	j := -1
+	if buyer.canBuy() && bidPrice != NoQuote {
+		j = a.source(k, bidPrice)
+	}
+	limit := a.reservation(k)
+	sell := j >= 0
+	buy := seller.canSell() && askPrice != NoQuote && askPrice <= limit && a.held() < a.capacity
+	if sell && buy {
+		sell = generator.Intn(2) == 0
+		buy = !sell
+	}
+
+	switch {
+	case sell:
+		s := a.stock[j]
+		cost := s[len(s)-1]
+		a.stock[j] = s[:len(s)-1]
+		m.traded(buyer, h, bidPrice, generator)
+		buyer.buy(bidPrice)
+		a.cash += bidPrice
+		a.prices = append(a.prices, bidPrice)
+		a.see(k, bidPrice)
+		return Trade{Seller: Arbitrageur, Bid: bidPrice, Ask: cost + a.margin, Price: bidPrice}, true
+	case buy:
+		m.traded(seller, h, askPrice, generator)
+		seller.sell(askPrice)
+		a.stock[k] = append(a.stock[k], askPrice)
+		a.bought++
+		a.cash -= askPrice
+		a.prices = append(a.prices, askPrice)
+		a.see(k, askPrice)
+		return Trade{Buyer: Arbitrageur, Bid: limit, Ask: askPrice, Price: askPrice}, true
+	}
+	return Trade{}, false
+}
+
+// Sum up the arbitrageurs' trading.
+func (m *Model) arbitrageResults() *ArbitrageResults {
+	r := &ArbitrageResults{}
+	for _, a := range m.arbitrageurs {
+		r.Bought += a.bought
+		r.Sold += len(a.prices) - a.bought
+		r.Inventory += a.held()
+		r.Profit += a.cash
+	}
+	return r
+}
+
+// Compute the statistics of each market over n periods, and the standard
+// deviation of their mean prices.
+func (m *Model) marketResults(n int) ([]MarketResults, float64) {
+	results := make([]MarketResults, m.Markets)
+	prices := make([]moments, m.Markets)
+	values := make([][]int, m.Markets)
+	costs := make([][]int, m.Markets)
+	for i, x := range m.buyers {
+		k := m.market(i)
+		results[k].NumberBought += len(x.prices)
+		results[k].RealizedSurplus += x.surplus()
+		values[k] = append(values[k], x.schedule...)
+		for _, p := range x.prices {
+			prices[k].add(float64(p))
+		}
+	}
+	for i, x := range m.sellers {
+		k := m.market(i)
+		results[k].NumberSold += len(x.prices)
+		results[k].RealizedSurplus += x.surplus()
+		costs[k] = append(costs[k], x.schedule...)
+		for _, p := range x.prices {
+			prices[k].add(float64(p))
+		}
+	}
+
+	var means moments
+	for k := range results {
+		r := &results[k]
+		r.MeanPrice, r.SDPrice = prices[k].mean, prices[k].sd()
+		eq := findEquilibrium(sortedSchedules(values[k], costs[k]))
+		r.EquilibriumPrice, r.MaxSurplus = eq.Price(), eq.Surplus*n
+		if r.MaxSurplus > 0 {
+			r.Efficiency = 100 * float64(r.RealizedSurplus) / float64(r.MaxSurplus)
+		}
+		if prices[k].n > 0 {
+			means.add(r.MeanPrice)
+		}
+	}
+	return results, means.sd()
+}
@@ -0,0 +1,79 @@
+package zitraders
+
+import "testing"
+
+// Every trade takes place between traders of one market, the markets' counts
+// add up to the totals, and shifted markets trade at rising prices.
+func TestMarkets(t *testing.T) {
+	for _, matching := range []string{Partitioned, Global, Pool} {
+		config := testConfig()
+		config.Matching, config.Markets, config.MarketShift = matching, 3, 5
+		config.RecordTrades = true
+		m := newTestModel(t, config)
+		r := m.Run()
+
+		if len(r.Markets) != 3 {
+			t.Fatalf("%s: %d markets", matching, len(r.Markets))
+		}
+		bought, sold, surplus := 0, 0, 0
+		for k, x := range r.Markets {
+			bought += x.NumberBought
+			sold += x.NumberSold
+			surplus += x.RealizedSurplus
+			if x.NumberBought == 0 || x.Efficiency <= 0 || x.Efficiency > 100 {
+				t.Errorf("%s: market %d: %+v", matching, k, x)
+			}
+			if k > 0 && x.MeanPrice <= r.Markets[k-1].MeanPrice {
+				t.Errorf("%s: market %d trades at %v, no dearer than market %d at %v", matching, k, x.MeanPrice, k-1, r.Markets[k-1].MeanPrice)
+			}
+		}
+		if bought != r.NumberBought || sold != r.NumberSold || surplus != r.RealizedSurplus {
+			t.Errorf("%s: markets sum to %d bought, %d sold and %d surplus, want %d, %d and %d",
+				matching, bought, sold, surplus, r.NumberBought, r.NumberSold, r.RealizedSurplus)
+		}
+		if r.Dispersion <= 0 {
+			t.Errorf("%s: dispersion %v", matching, r.Dispersion)
+		}
+		for _, tr := range m.Trades() {
+			if m.market(tr.Buyer) != tr.Market || m.market(tr.Seller) != tr.Market {
+				t.Fatalf("%s: trade across markets %+v", matching, tr)
+			}
+		}
+	}
+}
+
+// Arbitrageurs only sell units they hold, within their capacity, and every
+// unit bought from or sold to one is accounted for.
+func TestArbitrage(t *testing.T) {
+	for _, matching := range []string{Partitioned, Global, Pool} {
+		config := testConfig()
+		config.Matching, config.Markets, config.MarketShift = matching, 3, 5
+		config.Arbitrage, config.RecordTrades = true, true
+		m := newTestModel(t, config)
+		r := m.Run()
+
+		a := r.Arbitrage
+		if a == nil || a.Bought == 0 || a.Sold == 0 {
+			t.Fatalf("%s: arbitrageurs did not trade: %+v", matching, a)
+		}
+		if a.Inventory != a.Bought-a.Sold || a.Inventory < 0 || a.Inventory > config.NumThreads*defaultArbitrageCapacity {
+			t.Errorf("%s: %+v", matching, a)
+		}
+		if r.NumberBought-a.Sold != r.NumberSold-a.Bought || r.NumberBought+a.Bought != len(m.Trades()) {
+			t.Errorf("%s: %d bought and %d sold by traders, %d trades logged, %+v", matching, r.NumberBought, r.NumberSold, len(m.Trades()), a)
+		}
+
+		surplus := a.Profit
+		for _, x := range r.Markets {
+			surplus += x.RealizedSurplus
+		}
+		if surplus != r.RealizedSurplus {
+			t.Errorf("%s: realized surplus %d, want %d", matching, r.RealizedSurplus, surplus)
+		}
+		for _, tr := range m.Trades() {
+			if tr.Buyer == Arbitrageur && tr.Seller == Arbitrageur || tr.Price < tr.Ask || tr.Price > tr.Bid {
+				t.Fatalf("%s: bad trade %+v", matching, tr)
+			}
+		}
+	}
+}
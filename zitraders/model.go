@@ -0,0 +1,569 @@
+// Package zitraders implements Rob Axtell's port of the zero-intelligence
+// traders model of Gode and Sunder (QJE, 1993).
+package zitraders
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Model is a single market of ZI buyers and sellers.
+type Model struct {
+	attempts int64 // accessed atomically; kept first for 64-bit alignment
+	executed int64
+	volume   int64 // sum of transaction prices
+	squares  int64 // sum of squared transaction prices
+	running  int64 // trading threads
+
+	Config
+	Scheduler
+
+	rng              *rand.Rand
+	buyers           []agent
+	sellers          []agent
+	buyerStore       columnStore // the population under the struct-of-arrays layouts
+	sellerStore      columnStore
+	sources          []*xoshiro    // the threads' random sources
+	histories        []*history    // the threads' quote histories, if any trader consults them
+	dealers          []*dealer     // the threads' market makers, if any
+	books            []*bookTally  // the threads' order book statistics, under a cda
+	institution      MarketFactory // the registered market, unless the institution runs on a loop of its own
+	arbitrageurs     []*arbitrageur
+	graph            *graph
+	lattice          *lattice
+	neighbors        neighborhood // the graph or lattice, unless any buyer may meet any seller
+	samplers         []Sampler    // the threads' samplers, unless they draw pairs pseudo-randomly
+	dropped          int64        // quotes that never reached the book under latency; accessed atomically
+	expired          int64        // orders that expired in the book; accessed atomically
+	buyersPerThread  int          // in the smallest partition
+	sellersPerThread int
+	tradesPerThread  int
+	trades           []Trade
+	kinds            []string // the strategies traders may draw
+	learning         bool     // whether any trader's strategy is a Learner
+	policy           bool     // whether a price band or tax is set
+	stopped          string   // why the run stopped early, if it did
+	period           int      // the trading period under way
+	mark             Progress // the counts at the start of the period
+	periods          []Period // the completed periods
+	perf             []ThreadPerformance
+	elapsed          time.Duration // spent with the market open
+
+	redraws [][2]func(*rand.Rand) int // each shock's samplers of new buyer values and seller costs, if it redraws them
+	struck  []bool                    // which shocks have struck
+	shocks  []ShockResults
+	budget  budget  // the attempts left under a shared budget
+	stripes stripes // the mutexes of the mutex transfer
+	dry     bool    // the configuration is only being checked, so nothing is drawn
+
+	finishers []func(*Model, Results) // called when the run ends
+}
+
+// New creates a model from the given configuration and initializes its agents.
+func New(config Config) (*Model, error) {
+	return newModel(config, false)
+}
+
+// Plan is the configuration a model would run with, with every default
+// filled in, and the shares of the population and attempts each thread
+// would take.
+type Plan struct {
+	Config           Config `json:"config"`
+	BuyersPerThread  int    `json:"buyers_per_thread"` // in the smallest partition
+	SellersPerThread int    `json:"sellers_per_thread"`
+	TradesPerThread  int    `json:"trades_per_thread"`
+}
+
+// Check makes every check New makes of a configuration and fills in the same
+// defaults, but draws no population, and returns the resulting plan. A seed
+// of zero is left for the run to draw from the clock.
+func Check(config Config) (Plan, error) {
+	m, err := newModel(config, true)
+	if err != nil {
+		return Plan{}, err
+	}
+	m.Config.Seed = config.Seed
+	return Plan{Config: m.Config, BuyersPerThread: m.buyersPerThread, SellersPerThread: m.sellersPerThread, TradesPerThread: m.tradesPerThread}, nil
+}
+
+func newModel(config Config, dry bool) (*Model, error) {
+	m := &Model{Config: config, dry: dry}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	if m.Periods < 0 {
+		return nil, fmt.Errorf("a session must have at least one trading period")
+	}
+	if m.Periods == 0 {
+		m.Periods = 1
+	}
+	// Values, costs, quotes and prices are whole numbers of ticks, which
+	// keeps them on the price grid and lets the books index them.
+	if m.PriceTick < 0 {
+		return nil, fmt.Errorf("the price tick must be positive")
+	}
+	if m.PriceTick == 0 {
+		m.PriceTick = 1
+	}
+	if m.Institution == "" {
+		m.Institution = Bilateral
+	}
+	f, native, err := institution(m.Institution)
+	switch {
+	case err != nil:
+		return nil, err
+	case !native:
+		m.institution = f
+		if m.Latency > 0 || m.FastShare > 0 || m.QuoteEvery > 0 || m.OrderLifetime > 0 || m.Requote {
+			return nil, fmt.Errorf("the %s institution does not support latency, order book samples or order lifetimes", m.Institution)
+		}
+	case m.Institution == Call && m.CallRound <= 0:
+		return nil, fmt.Errorf("call market rounds must collect at least one quote")
+	}
+	switch m.Matching {
+	case "":
+		m.Matching = Partitioned
+	case Partitioned:
+	case Global, Pool:
+		if !m.bilateral() {
+			return nil, fmt.Errorf("%s matching requires the bilateral institution", m.Matching)
+		}
+	default:
+		return nil, fmt.Errorf("unknown matching mode %q", m.Matching)
+	}
+
+	if m.BuyerArrival < 0 || m.BuyerArrival > 1 || (m.BuyerArrival > 0 && m.bilateral()) {
+		return nil, fmt.Errorf("the buyers' share of arrivals must lie between 0 and 1, and requires an institution other than bilateral")
+	}
+	if m.QuoteEvery < 0 || (m.QuoteEvery > 0 && m.Institution != CDA) {
+		return nil, fmt.Errorf("quote sampling requires the cda institution")
+	}
+	if m.OrderLifetime < 0 || ((m.OrderLifetime > 0 || m.Requote) && m.Institution != CDA) {
+		return nil, fmt.Errorf("order lifetimes must be positive and require the cda institution")
+	}
+
+	if m.Strategy == "" {
+		m.Strategy = "zi-c"
+	}
+	if _, err := strategy(m.Strategy); err != nil {
+		return nil, err
+	}
+	if err := m.checkMix(); err != nil {
+		return nil, err
+	}
+	if err := m.checkLatency(); err != nil {
+		return nil, err
+	}
+	if err := m.checkBudget(); err != nil {
+		return nil, err
+	}
+	if m.Memory < 0 {
+		return nil, fmt.Errorf("traders must remember a positive number of quotes")
+	}
+	if m.remembers() && m.Memory == 0 {
+		m.Memory = defaultMemory
+	}
+
+	if err := m.checkPolicy(); err != nil {
+		return nil, err
+	}
+	if m.Markets == 0 {
+		m.Markets = 1
+	}
+	if m.Markets < 0 || (m.Markets > 1 && !m.bilateral()) {
+		return nil, fmt.Errorf("several markets require the bilateral institution")
+	}
+	if m.Arbitrage {
+		if m.Markets < 2 {
+			return nil, fmt.Errorf("arbitrage requires several markets")
+		}
+		if m.Tax > 0 || m.TaxRate > 0 {
+			return nil, fmt.Errorf("arbitrageurs cannot be combined with a transaction tax")
+		}
+		if m.ArbitrageMargin < 0 || m.ArbitrageCapacity < 0 {
+			return nil, fmt.Errorf("the arbitrageur's margin and capacity must not be negative")
+		}
+		if m.ArbitrageMargin == 0 {
+			m.ArbitrageMargin = defaultArbitrageMargin
+		}
+		if m.ArbitrageCapacity == 0 {
+			m.ArbitrageCapacity = defaultArbitrageCapacity
+		}
+	}
+	if m.Dealer {
+		if m.Tax > 0 || m.TaxRate > 0 {
+			return nil, fmt.Errorf("market makers cannot be combined with a transaction tax")
+		}
+		if !m.bilateral() {
+			return nil, fmt.Errorf("market makers require the bilateral institution")
+		}
+		if m.DealerSpread < 0 || m.DealerInventory < 0 {
+			return nil, fmt.Errorf("the market maker's spread and inventory must not be negative")
+		}
+		if m.DealerSpread == 0 {
+			m.DealerSpread = defaultDealerSpread
+		}
+		if m.DealerInventory == 0 {
+			m.DealerInventory = defaultDealerInventory
+		}
+	}
+
+	if m.FirstTouch && m.Matching != Partitioned {
+		return nil, fmt.Errorf("first touch requires partitioned matching")
+	}
+
+	switch m.Layout {
+	case "":
+		m.Layout = AoS
+	case AoS:
+	case SoA, Compact:
+		if m.Layout == Compact && m.maxPrice() > math.MaxInt16 {
+			return nil, fmt.Errorf("the compact layout supports values and costs of at most %d", math.MaxInt16)
+		}
+		if m.Units != 1 || m.SellerUnits != 1 || !m.bilateral() || m.Matching != Partitioned {
+			return nil, fmt.Errorf("the %s layout supports only single-unit agents in a partitioned bilateral market", m.Layout)
+		}
+		if m.ZIP > 0 || m.GD > 0 || m.Sniper > 0 || len(m.Population) > 0 || m.Strategy != "zi-c" {
+			return nil, fmt.Errorf("the %s layout supports only ZI traders", m.Layout)
+		}
+		if m.Periods > 1 {
+			return nil, fmt.Errorf("the %s layout supports only a single trading period", m.Layout)
+		}
+		if m.Dealer {
+			return nil, fmt.Errorf("the %s layout does not support market makers", m.Layout)
+		}
+		if m.policy {
+			return nil, fmt.Errorf("the %s layout does not support price bands or taxes", m.Layout)
+		}
+		if len(m.Shocks) > 0 {
+			return nil, fmt.Errorf("the %s layout does not support shocks", m.Layout)
+		}
+		if m.Markets > 1 {
+			return nil, fmt.Errorf("the %s layout supports only a single market", m.Layout)
+		}
+	default:
+		return nil, fmt.Errorf("unknown agent layout %q", m.Layout)
+	}
+
+	if m.Seed == 0 {
+		m.Seed = time.Now().UnixNano()
+	}
+	m.rng = rand.New(m.stream(0))
+	m.RecordTrades = m.RecordTrades || m.ConvergenceBlock > 0 || m.VolatilityWindow > 0 || m.windowed() || m.RoundSize > 0
+	if m.StopWhenCleared || m.MinTradeRate > 0 {
+		if m.TickSize == 0 {
+			m.TickSize = defaultTickSize
+		}
+		m.Observe(m.stoppingRules())
+	}
+	if err := m.checkShocks(); err != nil {
+		return nil, err
+	}
+	m.buyersPerThread = m.NumBuyers / m.NumThreads
+	m.sellersPerThread = m.NumSellers / m.NumThreads
+	m.tradesPerThread = m.MaxNumberOfTrades / m.NumThreads
+	if m.Markets > 1 && (m.buyersPerThread <= m.Markets || m.sellersPerThread <= m.Markets) {
+		return nil, fmt.Errorf("each thread needs more buyers and sellers than there are markets")
+	}
+
+	buyerValue, err := m.BuyerValues.sampler(m.MaxBuyerValue, m.PriceTick)
+	if err != nil {
+		return nil, fmt.Errorf("buyer values: %v", err)
+	}
+	sellerCost, err := m.SellerCosts.sampler(m.MaxSellerValue, m.PriceTick)
+	if err != nil {
+		return nil, fmt.Errorf("seller costs: %v", err)
+	}
+	switch {
+	case m.dry:
+	case m.columnar():
+		m.buyerStore, m.sellerStore = m.initializeStores(buyerValue, sellerCost)
+	default:
+		if m.buyers, m.sellers, err = m.initializeAgents(buyerValue, sellerCost); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.buildNetwork(); err != nil {
+		return nil, err
+	}
+	if err := m.buildLattice(); err != nil {
+		return nil, err
+	}
+	if err := m.checkSampler(); err != nil {
+		return nil, err
+	}
+	if err := m.checkTransfer(); err != nil {
+		return nil, err
+	}
+	if err := m.checkBatched(); err != nil {
+		return nil, err
+	}
+	if m.remembers() {
+		m.histories = make([]*history, m.NumThreads)
+		for i := range m.histories {
+			m.histories[i] = m.newHistory()
+		}
+	}
+	if m.Institution == CDA && m.institution == nil {
+		m.books = make([]*bookTally, m.NumThreads)
+		for i := range m.books {
+			m.books[i] = newBookTally()
+		}
+	}
+	if m.Dealer {
+		m.dealers = make([]*dealer, m.NumThreads)
+		for i := range m.dealers {
+			m.dealers[i] = m.newDealer()
+		}
+	}
+	if m.Arbitrage {
+		m.arbitrageurs = make([]*arbitrageur, m.NumThreads)
+		for i := range m.arbitrageurs {
+			m.arbitrageurs[i] = m.newArbitrageur()
+		}
+	}
+	// Allocated here rather than when the market opens, so that loops driven
+	// directly, as by the benchmarks, can tally their work too.
+	m.perf = make([]ThreadPerformance, m.NumThreads)
+	return m, nil
+}
+
+// Run opens the market for each trading period in turn and returns the
+// statistics of the whole run. An observer that stops the run ends the
+// session; the other stopping rules end only the period under way.
+func (m *Model) Run() Results {
+	return m.RunContext(context.Background())
+}
+
+// RunContext is like Run but also ends the session once ctx is done, with
+// statistics on the trades made until then. Threads notice within a tick, or
+// within a few thousand attempts if the model has no ticks.
+func (m *Model) RunContext(ctx context.Context) Results {
+	for m.period < m.Periods && !m.ended() {
+		m.openMarket(ctx)
+		if m.Transfer == CAS {
+			m.settle()
+		}
+		if ctx.Err() != nil && m.stopped == "" {
+			m.stopped = StopCanceled
+		}
+		m.endPeriod()
+	}
+	r := m.computeStatistics()
+	r.Attempts = m.Progress().Attempts
+	r.Stopped = StopAttempts
+	if m.stopped != "" {
+		r.Stopped = m.stopped
+	}
+	if m.Periods > 1 {
+		r.Periods = m.periods
+		for i := range r.Periods {
+			r.Periods[i].finish(r.EquilibriumPrice, r.MaxSurplus/len(r.Periods))
+		}
+	}
+	for _, f := range m.finishers {
+		f(m, r)
+	}
+	return r
+}
+
+// Divide the agent population into chunks and have these chunks perform trades.
+func (m *Model) openMarket(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	// Each thread needs its own random source to prevent excessive blocking on rand.
+	// Adding these lines sped the model up approx. 9 times. The sources are
+	// non-overlapping streams of the master seed, so a run is reproducible
+	// regardless of goroutine scheduling.
+	// A model resumed from a checkpoint already has its sources.
+	if m.sources == nil {
+		m.sources = make([]*xoshiro, m.NumThreads+1) // the last feeds the pool's generator
+		for i := range m.sources {
+			m.sources[i] = m.stream(i + 1)
+		}
+	}
+	generators := make([]*rand.Rand, m.NumThreads)
+	for i := range generators {
+		generators[i] = rand.New(m.sources[i])
+	}
+
+	m.log("market opened", "period", m.period+1, "threads", m.NumThreads, "matching", m.Matching, "institution", m.Institution)
+	opened := time.Now()
+	defer func() {
+		m.elapsed += time.Since(opened)
+		p := m.Progress()
+		m.log("market closed", "period", m.period+1, "attempts", p.Attempts, "trades", p.Trades)
+	}()
+
+	if m.Matching == Pool {
+		m.open(m.TickSize*m.NumThreads, m.NumThreads)
+	} else {
+		m.open(m.TickSize, m.NumThreads)
+	}
+	if m.SharedBudget {
+		m.budget.unissued = m.MaxNumberOfTrades
+		m.issue()
+	}
+
+	// Trades recorded before a checkpoint the model resumed from are kept.
+	if m.columnar() {
+		m.trades = mergeTrades(append(m.openStoreMarket(ctx, generators), m.trades))
+		return
+	}
+
+	if m.Matching == Pool {
+		m.trades = mergeTrades(append(m.runPool(ctx, generators, rand.New(m.sources[m.NumThreads])), m.trades))
+		return
+	}
+
+	logs := make([][]Trade, m.NumThreads)
+	parts := m.partitions()
+	for i := 0; i < m.NumThreads; i++ {
+		wg.Add(1)
+		go func(threadNum int) {
+			defer wg.Done()
+			defer m.log("thread finished", "thread", threadNum)
+			switch {
+			case m.institution != nil:
+				logs[threadNum] = m.doMarket(ctx, parts[threadNum], generators[threadNum])
+			case m.Institution == CDA:
+				logs[threadNum] = m.doAuction(ctx, parts[threadNum], generators[threadNum])
+			case m.Institution == Call:
+				logs[threadNum] = m.doCallMarket(ctx, parts[threadNum], generators[threadNum])
+			default:
+				if m.Batched {
+					logs[threadNum] = m.doBatchedTrades(ctx, parts[threadNum], m.sources[threadNum])
+				} else {
+					logs[threadNum] = m.doTrades(ctx, parts[threadNum], generators[threadNum])
+				}
+			}
+		}(i)
+	}
+	m.schedule(ctx, &wg, m.NumThreads, logs) //block until all threads are done for safety
+	m.trades = mergeTrades(append(logs, m.trades))
+}
+
+// Log the progress of a run at debug level, or at info level if the
+// configuration is verbose.
+func (m *Model) log(msg string, args ...any) {
+	level := slog.LevelDebug
+	if m.Verbose {
+		level = slog.LevelInfo
+	}
+	slog.Log(context.Background(), level, msg, args...)
+}
+
+// Pair up buyers and sellers within a partition and execute trades if the bid
+// and ask prices are compatible. The executed trades are returned if the model
+// records them. Under global matching partitions overlap, so both agents are
+// claimed or locked before they are read, unless units change hands by
+// compare-and-swap.
+func (m *Model) doTrades(ctx context.Context, p partition, generator *rand.Rand) []Trade {
+	buyers, sellers := p.buyers, p.sellers
+	claims, cas, locks := m.Matching == Global && m.Transfer == Claim, m.Transfer == CAS, m.stripes != nil
+	markets, neighbors, sampler := m.Markets, m.neighbors, m.sampler(p.thread)
+	var trades []Trade
+	progress := m.tally(p.thread, &trades)
+	defer progress.close()
+
+	for i := 1 + m.skip; m.more(ctx, i, progress); i++ { //why i=1?
+		progress.attempt()
+		p.history.at(i)
+
+		//select buyer and seller
+		var buyerIndex, sellerIndex, market int
+		if markets > 1 {
+			market = generator.Intn(markets)
+			buyerIndex = m.drawIn(market, len(buyers), p.buyerOffset, generator)
+			sellerIndex = m.drawIn(market, len(sellers), p.sellerOffset, generator)
+		} else if neighbors != nil {
+			buyerIndex = generator.Intn(len(buyers))
+			if sellerIndex = neighbors.partner(buyerIndex, generator); sellerIndex < 0 {
+				continue
+			}
+		} else if sampler != nil {
+			buyerIndex, sellerIndex = sampler.Pair(p.period*m.tradesPerThread+i, len(buyers), len(sellers))
+		} else {
+			buyerIndex = generator.Intn(len(buyers))
+			sellerIndex = generator.Intn(len(sellers))
+		}
+		if claims && !claim(&buyers[buyerIndex], &sellers[sellerIndex]) {
+			continue
+		}
+		if locks {
+			m.stripes.lock(buyerIndex, sellerIndex)
+		}
+
+		var t Trade
+		var ok bool
+		if cas {
+			t, ok = m.transfer(&buyers[buyerIndex], &sellers[sellerIndex], generator)
+		} else {
+			t, ok = m.match(&buyers[buyerIndex], &sellers[sellerIndex], p.history, p.dealer, generator)
+		}
+		if a := p.arbitrageur; a != nil {
+			if ok {
+				a.see(market, t.Price)
+			} else {
+				t, ok = m.arbitrage(a, market, &buyers[buyerIndex], &sellers[sellerIndex], t.Bid, t.Ask, p.history, generator)
+			}
+		}
+		if ok {
+			progress.trade(t.Price)
+			if m.lattice != nil {
+				m.lattice.record(p.thread, sellerIndex, t.Price)
+			}
+			if m.RecordTrades {
+				t.Market = market
+				trades = append(trades, p.record(t.between(buyerIndex, sellerIndex), i))
+			}
+		}
+
+		if claims {
+			release(&buyers[buyerIndex], &sellers[sellerIndex])
+		}
+		if locks {
+			m.stripes.unlock(buyerIndex, sellerIndex)
+		}
+	}
+	return trades
+}
+
+// Have a buyer and a seller quote prices and trade if a deal is possible,
+// with each other or else with the thread's dealer d. The returned trade
+// carries the quotes and price but no agent indices other than Dealer; if
+// there is no trade it carries just the quotes. Both quotes are remembered in
+// the thread's history h.
+func (m *Model) match(buyer, seller *agent, h *history, d *dealer, generator *rand.Rand) (Trade, bool) {
+	//set bid and ask prices
+	bidPrice := m.bid(buyer, h, generator)
+	askPrice := m.ask(seller, h, generator)
+
+	//is a deal possible?
+	if !buyer.canBuy() || !seller.canSell() || bidPrice == NoQuote || askPrice == NoQuote {
+		return m.deal(buyer, seller, bidPrice, askPrice, h, d, generator)
+	}
+	h.add(bidPrice, true, bidPrice >= askPrice)
+	h.add(askPrice, false, bidPrice >= askPrice)
+	if bidPrice < askPrice {
+		m.missed(buyer, h, askPrice, generator)
+		m.missed(seller, h, bidPrice, generator)
+		return m.deal(buyer, seller, bidPrice, askPrice, h, d, generator)
+	}
+
+	// set transaction price
+	transactionPrice := askPrice + generator.Intn(bidPrice-askPrice+1)
+	m.traded(buyer, h, transactionPrice, generator)
+	m.traded(seller, h, transactionPrice, generator)
+
+	// execute trade
+	buyer.buy(transactionPrice)
+	seller.sell(transactionPrice)
+	d.see(transactionPrice)
+	return Trade{Bid: bidPrice, Ask: askPrice, Price: transactionPrice}, true
+}
@@ -0,0 +1,304 @@
+package zitraders
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// A small market that runs quickly.
+func testConfig() Config {
+	config := DefaultConfig()
+	config.NumBuyers, config.NumSellers = 1000, 1000
+	config.MaxNumberOfTrades = 100000
+	config.NumThreads = 4
+	config.Seed = 1
+	return config
+}
+
+func newTestModel(t testing.TB, config Config) *Model {
+	m, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestInitializeAgents(t *testing.T) {
+	config := testConfig()
+	config.Units = 3
+	m := newTestModel(t, config)
+
+	if len(m.buyers) != config.NumBuyers || len(m.sellers) != config.NumSellers {
+		t.Fatalf("got %d buyers and %d sellers", len(m.buyers), len(m.sellers))
+	}
+	for _, b := range m.buyers {
+		if !b.buyerOrSeller || b.quantityHeld != 0 || len(b.schedule) != 3 {
+			t.Fatalf("bad buyer %v", b)
+		}
+		for k, v := range b.schedule {
+			if v < 1 || v > config.MaxBuyerValue || (k > 0 && v > b.schedule[k-1]) {
+				t.Fatalf("bad buyer schedule %v", b.schedule)
+			}
+		}
+	}
+	for _, s := range m.sellers {
+		if s.buyerOrSeller || s.quantityHeld != 3 || len(s.schedule) != 3 {
+			t.Fatalf("bad seller %v", s)
+		}
+		for k, v := range s.schedule {
+			if v < 1 || v > config.MaxSellerValue || (k > 0 && v < s.schedule[k-1]) {
+				t.Fatalf("bad seller schedule %v", s.schedule)
+			}
+		}
+	}
+}
+
+// The agents drawn depend on the seed alone, not on how many goroutines draw
+// them.
+func TestInitializeAgentsInParallel(t *testing.T) {
+	config := testConfig()
+	config.NumBuyers, config.NumSellers = 3*initBlock+1, 2*initBlock
+	config.Population = map[string]float64{"zi-c": 0.5, "zip": 0.5}
+	m := newTestModel(t, config)
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+	single := newTestModel(t, config)
+	for _, agents := range [][2][]agent{{m.buyers, single.buyers}, {m.sellers, single.sellers}} {
+		for i := range agents[0] {
+			a, b := agents[0][i], agents[1][i]
+			if !reflect.DeepEqual(a.schedule, b.schedule) || a.kind != b.kind {
+				t.Fatalf("agent %d differs: %v and %v", i, a, b)
+			}
+		}
+	}
+}
+
+// A partitioned population too small for every thread runs on fewer.
+func TestFewerThreads(t *testing.T) {
+	config := testConfig()
+	config.NumSellers = 3
+	m := newTestModel(t, config)
+	if m.NumThreads != 3 {
+		t.Fatalf("%d threads for 3 sellers", m.NumThreads)
+	}
+	for _, p := range m.partitions() {
+		if len(p.buyers) == 0 || len(p.sellers) == 0 {
+			t.Errorf("thread %d has %d buyers and %d sellers", p.thread, len(p.buyers), len(p.sellers))
+		}
+	}
+	if r := m.Run(); r.NumberBought == 0 {
+		t.Errorf("no trades")
+	}
+}
+
+// Buyers and sellers may differ in number and in the units they trade.
+func TestAsymmetricPopulation(t *testing.T) {
+	config := testConfig()
+	config.NumSellers, config.Units, config.SellerUnits = 100, 2, 15
+	m := newTestModel(t, config)
+	for _, b := range m.buyers {
+		if len(b.schedule) != 2 {
+			t.Fatalf("bad buyer %v", b)
+		}
+	}
+	for _, s := range m.sellers {
+		if s.quantityHeld != 15 || len(s.schedule) != 15 || cap(s.prices) != 15 {
+			t.Fatalf("bad seller %v", s)
+		}
+	}
+	if c := m.Curves(); len(c.Demand) != 2000 || len(c.Supply) != 1500 {
+		t.Errorf("curves of %d values and %d costs", len(c.Demand), len(c.Supply))
+	}
+	r := m.Run()
+	if r.NumberSold == 0 || r.NumberSold > 1500 {
+		t.Errorf("%d units sold", r.NumberSold)
+	}
+}
+
+// Values drawn from a distribution given in currency land on the nearest tick.
+func TestDrawsInTicks(t *testing.T) {
+	config := testConfig()
+	config.PriceTick, config.MaxBuyerValue = 0.25, 120
+	config.BuyerValues = Distribution{Kind: Normal, Mean: 20.1}
+	m := newTestModel(t, config)
+
+	for _, b := range m.buyers {
+		if b.value != 80 {
+			t.Fatalf("a value of 20.1 drew %d ticks of 0.25", b.value)
+		}
+	}
+}
+
+func TestNewRejectsBadConfig(t *testing.T) {
+	for name, modify := range map[string]func(*Config){
+		"institution":   func(c *Config) { c.Institution = "barter" },
+		"matching":      func(c *Config) { c.Matching = "speed dating" },
+		"units":         func(c *Config) { c.Units = 0 },
+		"global cda":    func(c *Config) { c.Matching, c.Institution = Global, CDA },
+		"soa units":     func(c *Config) { c.Layout, c.Units = SoA, 2 },
+		"compact value": func(c *Config) { c.Layout, c.MaxBuyerValue = Compact, 40000 },
+		"strategy":      func(c *Config) { c.Strategy = "telepathy" },
+		"dealer cda":    func(c *Config) { c.Dealer, c.Institution = true, CDA },
+		"dealer tax":    func(c *Config) { c.Dealer, c.Tax = true, 1 },
+		"band":          func(c *Config) { c.PriceFloor, c.PriceCeiling = 20, 10 },
+		"tax payer":     func(c *Config) { c.Tax, c.TaxPayer = 1, "nobody" },
+		"markets cda":   func(c *Config) { c.Markets, c.Institution = 2, CDA },
+		"arbitrage":     func(c *Config) { c.Arbitrage = true },
+		"markets":       func(c *Config) { c.Markets = 1000 },
+		"network":       func(c *Config) { c.Network.Topology = Ring },
+		"topology":      func(c *Config) { c.Network.Topology, c.Matching = "hypercube", Global },
+		"lattice":       func(c *Config) { c.Spatial.Width, c.Spatial.Height = 10, 10 },
+		"grid":          func(c *Config) { c.Spatial.Width, c.Matching = 10, Global },
+		"price tick":    func(c *Config) { c.PriceTick = -0.25 },
+		"sampler":       func(c *Config) { c.Sampler = "dice" },
+		"sobol cda":     func(c *Config) { c.Sampler, c.Institution = Sobol, CDA },
+		"latency":       func(c *Config) { c.Latency = 10 },
+		"fast share":    func(c *Config) { c.Latency, c.FastShare, c.Institution = 10, 1.5, CDA },
+		"lifetime":      func(c *Config) { c.OrderLifetime = 100 },
+		"quotes":        func(c *Config) { c.QuoteEvery = 10 },
+		"no sellers":    func(c *Config) { c.NumSellers, c.Matching = 0, Global },
+		"no buyers":     func(c *Config) { c.NumBuyers = 0 },
+		"max value":     func(c *Config) { c.MaxSellerValue = 0 },
+		"threads":       func(c *Config) { c.NumThreads = 0 },
+		"attempts":      func(c *Config) { c.MaxNumberOfTrades = 0 },
+		"few attempts":  func(c *Config) { c.MaxNumberOfTrades = 5 },
+		"tick size":     func(c *Config) { c.TickSize = -1 },
+		"warm-up":       func(c *Config) { c.WarmUp = -1 },
+		"seller units":  func(c *Config) { c.SellerUnits = -1 },
+		"arrival":       func(c *Config) { c.BuyerArrival = 0.8 },
+		"arrival cda":   func(c *Config) { c.BuyerArrival, c.Institution = 1.5, CDA },
+		"budget cda":    func(c *Config) { c.SharedBudget, c.Institution = true, CDA },
+		"budget pool":   func(c *Config) { c.SharedBudget, c.Matching = true, Pool },
+		"budget parted": func(c *Config) { c.SharedBudget = true },
+		"transfer":      func(c *Config) { c.Transfer = "barter" },
+		"cas":           func(c *Config) { c.Transfer = CAS },
+		"cas zip":       func(c *Config) { c.Transfer, c.Matching, c.ZIP = CAS, Global, 0.5 },
+		"mutex":         func(c *Config) { c.Transfer = Mutex },
+		"first touch":   func(c *Config) { c.FirstTouch, c.Matching = true, Global },
+		"batched":       func(c *Config) { c.Batched, c.ZIP = true, 0.5 },
+		"batched sobol": func(c *Config) { c.Batched, c.Sampler = true, Sobol },
+	} {
+		config := testConfig()
+		modify(&config)
+		if _, err := New(config); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		if _, err := Check(config); err == nil {
+			t.Errorf("%s: expected Check to fail", name)
+		}
+	}
+}
+
+// Check fills in the defaults New would, without drawing a population.
+func TestCheck(t *testing.T) {
+	config := testConfig()
+	config.Seed = 0
+	config.Matching = Global
+	config.Spatial = Spatial{Width: 10, Height: 10, Move: 0.1}
+	plan, err := Check(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Config.Seed != 0 || plan.Config.Spatial.Radius != defaultRadius || plan.Config.TickSize != defaultTickSize {
+		t.Errorf("plan %+v", plan.Config)
+	}
+	if plan.BuyersPerThread != 250 || plan.SellersPerThread != 250 || plan.TradesPerThread != 25000 {
+		t.Errorf("plan of %d buyers, %d sellers and %d trades per thread", plan.BuyersPerThread, plan.SellersPerThread, plan.TradesPerThread)
+	}
+
+	config.Spatial = Spatial{}
+	config.Network = Network{Topology: EdgeList, File: "no such file"}
+	if _, err := Check(config); err == nil {
+		t.Errorf("missing edge list accepted")
+	}
+}
+
+// ZI-C traders never trade at a loss, in any institution or matching mode.
+func TestTradesAreLegal(t *testing.T) {
+	for _, modify := range []func(*Config){
+		func(c *Config) {},
+		func(c *Config) { c.Units = 3 },
+		func(c *Config) { c.Institution = CDA },
+		func(c *Config) { c.Institution = Call },
+		func(c *Config) { c.Matching = Global },
+		func(c *Config) { c.Matching = Pool },
+		func(c *Config) { c.ZIP = 1 },
+		func(c *Config) { c.ZIP, c.Units, c.Institution = 0.5, 3, CDA },
+		func(c *Config) { c.ZIP, c.Institution = 1, Call },
+		func(c *Config) { c.GD = 1 },
+		func(c *Config) { c.GD, c.ZIP, c.Matching = 0.5, 0.25, Pool },
+		func(c *Config) { c.GD, c.Units, c.Institution = 1, 3, CDA },
+		func(c *Config) { c.GD, c.Memory, c.Institution = 0.5, 10, Call },
+		func(c *Config) { c.Sniper = 0.5 },
+		func(c *Config) { c.Sniper, c.GD, c.Units, c.Institution = 0.3, 0.3, 2, CDA },
+		func(c *Config) { c.Sniper, c.Institution = 0.5, Call },
+		func(c *Config) { c.Sniper, c.Matching = 0.5, Pool },
+		func(c *Config) { c.Sampler, c.Units = Sobol, 3 },
+		func(c *Config) { c.Sampler, c.Matching = Sobol, Global },
+		func(c *Config) { c.Latency, c.FastShare, c.Units, c.Institution = 50, 0.2, 2, CDA },
+		func(c *Config) { c.Latency, c.ZIP, c.Institution = 20, 0.5, CDA },
+		func(c *Config) { c.OrderLifetime, c.Requote, c.Units, c.Institution = 100, true, 2, CDA },
+		func(c *Config) { c.OrderLifetime, c.Latency, c.GD, c.Institution = 50, 20, 0.5, CDA },
+		func(c *Config) { c.NumSellers, c.SellerUnits, c.Units, c.Institution = 40, 10, 2, CDA },
+		func(c *Config) { c.NumSellers, c.SellerUnits, c.BuyerArrival, c.Institution = 40, 5, 0.9, Call },
+		func(c *Config) { c.NumBuyers, c.Matching, c.ZIP = 3, Global, 0.5 },
+		func(c *Config) { c.Matching, c.Transfer, c.Units = Global, CAS, 3 },
+		func(c *Config) { c.Matching, c.Transfer, c.NumSellers, c.SellerUnits = Pool, CAS, 40, 10 },
+		func(c *Config) { c.Matching, c.Transfer, c.ZIP, c.Units = Global, Mutex, 1, 3 },
+		func(c *Config) { c.Batched, c.Units, c.SellerUnits, c.NumSellers = true, 2, 5, 500 },
+		func(c *Config) { c.Matching, c.Transfer, c.GD, c.Units = Pool, Mutex, 0.5, 2 },
+	} {
+		config := testConfig()
+		config.RecordTrades = true
+		modify(&config)
+		m := newTestModel(t, config)
+		r := m.Run()
+
+		if r.NumberBought != r.NumberSold || r.NumberBought != len(m.Trades()) {
+			t.Errorf("%s/%s: %d bought, %d sold, %d trades logged", config.Institution, config.Matching, r.NumberBought, r.NumberSold, len(m.Trades()))
+		}
+		for _, tr := range m.Trades() {
+			if tr.Bid < tr.Ask || tr.Price < tr.Ask || tr.Price > tr.Bid {
+				t.Fatalf("%s/%s: trade %+v outside the quotes", config.Institution, config.Matching, tr)
+			}
+		}
+		for _, b := range m.buyers {
+			for k, p := range b.prices {
+				if p > b.schedule[k] {
+					t.Fatalf("buyer %v paid more than its value", b)
+				}
+			}
+		}
+		for _, s := range m.sellers {
+			for k, p := range s.prices {
+				if p < s.schedule[k] {
+					t.Fatalf("seller %v sold below its cost", s)
+				}
+			}
+		}
+	}
+}
+
+func TestSeedReproducible(t *testing.T) {
+	a := newTestModel(t, testConfig()).Run()
+	b := newTestModel(t, testConfig()).Run()
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("runs with the same seed differ: %+v and %+v", a, b)
+	}
+}
+
+func benchmarkDoTrades(b *testing.B, n int) {
+	config := testConfig()
+	config.NumBuyers, config.NumSellers = n, n
+	config.MaxNumberOfTrades = b.N + 1
+	config.NumThreads = 1
+	m := newTestModel(b, config)
+	p := m.partitions()[0]
+	b.ResetTimer()
+	m.doTrades(context.Background(), p, stream(1, 1))
+}
+
+func BenchmarkDoTrades1K(b *testing.B)   { benchmarkDoTrades(b, 1000) }
+func BenchmarkDoTrades100K(b *testing.B) { benchmarkDoTrades(b, 100000) }
+func BenchmarkDoTrades1M(b *testing.B)   { benchmarkDoTrades(b, 1000000) }
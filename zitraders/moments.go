@@ -0,0 +1,271 @@
+package zitraders
+
+import "math"
+
+// moments accumulates the count, mean, variance and range of a stream of
+// observations in a single pass using Welford's algorithm.
+type moments struct {
+	n    int
+	mean float64
+	m2   float64
+	min  float64
+	max  float64
+}
+
+func (s *moments) add(x float64) {
+	s.n++
+	if s.n == 1 {
+		s.min, s.max = x, x
+	} else if x < s.min {
+		s.min = x
+	} else if x > s.max {
+		s.max = x
+	}
+	d := x - s.mean
+	s.mean += d / float64(s.n)
+	s.m2 += d * (x - s.mean)
+}
+
+// Combine the observations of t with those of s, using Chan et al.'s pairwise
+// update.
+func (s *moments) merge(t moments) {
+	if t.n == 0 {
+		return
+	}
+	if s.n == 0 {
+		*s = t
+		return
+	}
+	if t.min < s.min {
+		s.min = t.min
+	}
+	if t.max > s.max {
+		s.max = t.max
+	}
+	n := s.n + t.n
+	d := t.mean - s.mean
+	s.m2 += t.m2 + d*d*float64(s.n)*float64(t.n)/float64(n)
+	s.mean += d * float64(t.n) / float64(n)
+	s.n = n
+}
+
+// The sample variance, or zero for fewer than two observations.
+func (s *moments) variance() float64 {
+	if s.n < 2 {
+		return 0
+	}
+	return s.m2 / float64(s.n-1)
+}
+
+func (s *moments) sd() float64 {
+	return math.Sqrt(s.variance())
+}
+
+// median returns the median of xs, reordering xs in the process. It is zero
+// for an empty slice.
+func median(xs []int) float64 {
+	n := len(xs)
+	if n == 0 {
+		return 0
+	}
+	hi := selectKth(xs, n/2)
+	if n%2 == 1 {
+		return float64(hi)
+	}
+	// After selection the lower half sits below n/2, so its maximum is the
+	// other middle element.
+	lo := xs[0]
+	for _, x := range xs[1 : n/2] {
+		if x > lo {
+			lo = x
+		}
+	}
+	return float64(lo+hi) / 2
+}
+
+// quantile returns the q-th quantile of xs, interpolating linearly between
+// the order statistics around it, and reorders xs in the process. It is zero
+// for an empty slice.
+func quantile(xs []int, q float64) float64 {
+	n := len(xs)
+	if n == 0 {
+		return 0
+	}
+	h := q * float64(n-1)
+	k := int(h)
+	lo := selectKth(xs, k)
+	if k+1 >= n || h == float64(k) {
+		return float64(lo)
+	}
+	// The next order statistic is the least of the elements above k.
+	hi := xs[k+1]
+	for _, x := range xs[k+2:] {
+		if x < hi {
+			hi = x
+		}
+	}
+	return float64(lo) + (h-float64(k))*float64(hi-lo)
+}
+
+// selectKth partially sorts xs so that xs[k] holds the k-th smallest element,
+// with smaller elements before it and larger ones after, and returns it. This
+// is Hoare's quickselect with a median-of-three pivot, linear on average.
+func selectKth(xs []int, k int) int {
+	lo, hi := 0, len(xs)-1
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		if xs[mid] < xs[lo] {
+			xs[mid], xs[lo] = xs[lo], xs[mid]
+		}
+		if xs[hi] < xs[lo] {
+			xs[hi], xs[lo] = xs[lo], xs[hi]
+		}
+		if xs[hi] < xs[mid] {
+			xs[hi], xs[mid] = xs[mid], xs[hi]
+		}
+		pivot := xs[mid]
+
+		i, j := lo, hi
+		for i <= j {
+			for xs[i] < pivot {
+				i++
+			}
+			for xs[j] > pivot {
+				j--
+			}
+			if i <= j {
+				xs[i], xs[j] = xs[j], xs[i]
+				i++
+				j--
+			}
+		}
+		switch {
+		case k <= j:
+			hi = j
+		case k >= i:
+			lo = i
+		default:
+			return xs[k]
+		}
+	}
+	return xs[k]
+}
+
+// histogram counts integer observations from min up, so that their moments
+// and order statistics need no copy of them, and histograms counted in
+// parallel can be summed in any order with the same result.
+type histogram struct {
+	min    int
+	counts []int
+	n      int
+}
+
+// A histogram sized for observations between min and max, though it grows to
+// take any larger.
+func newHistogram(min, max int) *histogram {
+	return &histogram{min: min, counts: make([]int, max-min+1)}
+}
+
+func (h *histogram) add(x int) {
+	i := x - h.min
+	if i >= len(h.counts) {
+		h.counts = append(h.counts, make([]int, i+1-len(h.counts))...)
+	}
+	h.counts[i]++
+	h.n++
+}
+
+func (h *histogram) merge(t *histogram) {
+	if len(t.counts) > len(h.counts) {
+		h.counts = append(h.counts, make([]int, len(t.counts)-len(h.counts))...)
+	}
+	for i, c := range t.counts {
+		h.counts[i] += c
+	}
+	h.n += t.n
+}
+
+// The moments of the observations, computed in two passes over the counts.
+func (h *histogram) moments() moments {
+	s := moments{n: h.n}
+	if h.n == 0 {
+		return s
+	}
+	first, last, sum := -1, 0, 0.0
+	for i, c := range h.counts {
+		if c > 0 {
+			if first < 0 {
+				first = i
+			}
+			last = i
+			sum += float64(c) * float64(i)
+		}
+	}
+	s.mean = float64(h.min) + sum/float64(h.n)
+	s.min, s.max = float64(h.min+first), float64(h.min+last)
+	for i := first; i <= last; i++ {
+		d := float64(h.min+i) - s.mean
+		s.m2 += float64(h.counts[i]) * d * d
+	}
+	return s
+}
+
+// The k-th smallest observation, counting from zero.
+func (h *histogram) kth(k int) int {
+	i := 0
+	for ; k >= h.counts[i]; i++ {
+		k -= h.counts[i]
+	}
+	return h.min + i
+}
+
+// The median, as median would compute it from the observations.
+func (h *histogram) median() float64 {
+	if h.n == 0 {
+		return 0
+	}
+	hi := h.kth(h.n / 2)
+	if h.n%2 == 1 {
+		return float64(hi)
+	}
+	return float64(h.kth(h.n/2-1)+hi) / 2
+}
+
+// The q-th quantile, as quantile would compute it from the observations.
+func (h *histogram) quantile(q float64) float64 {
+	if h.n == 0 {
+		return 0
+	}
+	x := q * float64(h.n-1)
+	k := int(x)
+	lo := h.kth(k)
+	if k+1 >= h.n || x == float64(k) {
+		return float64(lo)
+	}
+	return float64(lo) + (x-float64(k))*float64(h.kth(k+1)-lo)
+}
+
+// Bin counts the observations from Low to High inclusive.
+type Bin struct {
+	Low   int `json:"low"`
+	High  int `json:"high"`
+	Count int `json:"count"`
+}
+
+// Split the observations from lo to hi into at most n bins of equal width,
+// the last of which may be narrower.
+func (h *histogram) bins(lo, hi, n int) []Bin {
+	width := (hi - lo + n) / n
+	var bins []Bin
+	for low := lo; low <= hi; low += width {
+		b := Bin{Low: low, High: low + width - 1}
+		if b.High > hi {
+			b.High = hi
+		}
+		for x := b.Low; x <= b.High; x++ {
+			b.Count += h.counts[x-h.min]
+		}
+		bins = append(bins, b)
+	}
+	return bins
+}
@@ -0,0 +1,137 @@
+package zitraders
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMoments(t *testing.T) {
+	var s moments
+	for _, x := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		s.add(x)
+	}
+	if s.n != 8 || s.mean != 5 || s.min != 2 || s.max != 9 {
+		t.Errorf("got %+v", s)
+	}
+	if math.Abs(s.variance()-32.0/7) > 1e-12 {
+		t.Errorf("variance %v", s.variance())
+	}
+}
+
+func TestMomentsMerge(t *testing.T) {
+	xs := []float64{2, 4, 4, 4, 5, 5, 7, 9}
+	var all moments
+	for _, x := range xs {
+		all.add(x)
+	}
+	for k := 0; k <= len(xs); k++ {
+		var a, b moments
+		for _, x := range xs[:k] {
+			a.add(x)
+		}
+		for _, x := range xs[k:] {
+			b.add(x)
+		}
+		a.merge(b)
+		if a.n != all.n || a.min != all.min || a.max != all.max || math.Abs(a.mean-all.mean) > 1e-12 || math.Abs(a.m2-all.m2) > 1e-12 {
+			t.Errorf("split at %d: got %+v, want %+v", k, a, all)
+		}
+	}
+}
+
+func TestMedian(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 50; n++ {
+		xs := make([]int, n)
+		for i := range xs {
+			xs[i] = r.Intn(10)
+		}
+		sorted := append([]int(nil), xs...)
+		sort.Ints(sorted)
+
+		want := 0.0
+		if n%2 == 1 {
+			want = float64(sorted[n/2])
+		} else if n > 0 {
+			want = float64(sorted[n/2-1]+sorted[n/2]) / 2
+		}
+		if got := median(xs); got != want {
+			t.Errorf("median of %v = %v, want %v", sorted, got, want)
+		}
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for n := 1; n < 50; n++ {
+		xs := make([]int, n)
+		for i := range xs {
+			xs[i] = r.Intn(10)
+		}
+		sorted := append([]int(nil), xs...)
+		sort.Ints(sorted)
+
+		for _, q := range []float64{0, 0.25, 0.5, 0.75, 1} {
+			h := q * float64(n-1)
+			k := int(h)
+			want := float64(sorted[k])
+			if k+1 < n {
+				want += (h - float64(k)) * float64(sorted[k+1]-sorted[k])
+			}
+			if got := quantile(xs, q); math.Abs(got-want) > 1e-12 {
+				t.Errorf("quantile %v of %v = %v, want %v", q, sorted, got, want)
+			}
+		}
+	}
+}
+
+func TestHistogramBins(t *testing.T) {
+	h := newHistogram(0, 30)
+	for _, x := range []int{3, 4, 4, 9, 10, 11, 12} {
+		h.add(x)
+	}
+	want := []Bin{{3, 5, 3}, {6, 8, 0}, {9, 11, 3}, {12, 12, 1}}
+	if got := h.bins(3, 12, 4); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := h.bins(3, 12, 20); len(got) != 10 || got[0] != (Bin{3, 3, 1}) {
+		t.Errorf("bins of one price: got %v", got)
+	}
+}
+
+// A histogram filled in two halves and merged agrees with the observations
+// themselves, including ones beyond the range it was sized for.
+func TestHistogram(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 50; n++ {
+		xs := make([]int, n)
+		a, b := newHistogram(-3, 5), newHistogram(-3, 5)
+		var want moments
+		for i := range xs {
+			xs[i] = r.Intn(12) - 3
+			want.add(float64(xs[i]))
+			if i%2 == 0 {
+				a.add(xs[i])
+			} else {
+				b.add(xs[i])
+			}
+		}
+		a.merge(b)
+
+		got := a.moments()
+		if got.n != want.n || got.min != want.min || got.max != want.max || math.Abs(got.mean-want.mean) > 1e-12 || math.Abs(got.m2-want.m2) > 1e-9 {
+			t.Errorf("moments of %v: got %+v, want %+v", xs, got, want)
+		}
+		if got, want := a.median(), median(append([]int(nil), xs...)); got != want {
+			t.Errorf("median of %v = %v, want %v", xs, got, want)
+		}
+		for _, q := range []float64{0, 0.25, 0.5, 0.75, 1} {
+			if got, want := a.quantile(q), quantile(append([]int(nil), xs...), q); math.Abs(got-want) > 1e-12 {
+				t.Errorf("quantile %v of %v = %v, want %v", q, xs, got, want)
+			}
+		}
+	}
+}
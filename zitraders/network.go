@@ -0,0 +1,335 @@
+package zitraders
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// Interaction topologies.
+const (
+	Complete   = "complete"    // any buyer can meet any seller
+	Ring       = "ring"        // buyers and sellers interleaved on a ring, linked to their nearest neighbors
+	SmallWorld = "small-world" // a ring with a share of its links rewired at random (Watts and Strogatz)
+	ScaleFree  = "scale-free"  // grown by preferential attachment (Barabási and Albert)
+	EdgeList   = "edges"       // buyer and seller pairs read from the CSV File
+)
+
+// Defaults for a network whose configuration leaves them out.
+const (
+	defaultDegree = 4
+	defaultRewire = 0.1
+)
+
+// Network describes the graph on which buyers and sellers meet. Outside a
+// complete graph a trade attempt draws a buyer and then one of the sellers it
+// is linked to, so trade only takes place along links.
+type Network struct {
+	Topology string  `json:"topology" yaml:"topology" toml:"topology"`
+	Degree   int     `json:"degree,omitempty" yaml:"degree" toml:"degree"` // sellers each buyer is linked to on a ring, or links each trader adds when scale-free; zero for the default
+	Rewire   float64 `json:"rewire,omitempty" yaml:"rewire" toml:"rewire"` // the share of a small world's links rewired, zero for the default
+	File     string  `json:"file,omitempty" yaml:"file" toml:"file"`       // the edge list, one buyer and seller index per row
+}
+
+// NetworkResults reports the shape of the trading network and how the
+// traders fared on it.
+type NetworkResults struct {
+	Topology   string          `json:"topology"`
+	Links      int             `json:"links"`
+	MeanDegree float64         `json:"mean_degree"` // over all traders
+	MaxDegree  int             `json:"max_degree"`
+	Isolated   int             `json:"isolated"`   // traders with no links
+	Dispersion float64         `json:"dispersion"` // the s.d. across buyers of the mean price in their neighborhood
+	Degrees    []DegreeResults `json:"degrees"`
+}
+
+// DegreeResults holds the statistics of the traders whose degree lies in
+// [Degree, 2*Degree), or of the isolated traders if Degree is zero.
+type DegreeResults struct {
+	Degree     int     `json:"degree"`
+	Traders    int     `json:"traders"`
+	Trades     int     `json:"trades"` // units bought or sold
+	MeanProfit float64 `json:"mean_profit"`
+	MeanPrice  float64 `json:"mean_price"`
+}
+
+// A neighborhood restricts the sellers a buyer may meet.
+type neighborhood interface {
+	// Draw a seller buyer i may meet, or -1 if there is none.
+	partner(i int, r *rand.Rand) int
+}
+
+// A graph holds the sellers linked to each buyer in compressed rows: those of
+// buyer i are sellers[offsets[i]:offsets[i+1]].
+type graph struct {
+	offsets []int32
+	sellers []int32
+}
+
+// Check the network and build its graph, unless it is complete.
+func (m *Model) buildNetwork() error {
+	n := &m.Network
+	switch n.Topology {
+	case "":
+		n.Topology = Complete
+		return nil
+	case Complete:
+		return nil
+	case Ring, SmallWorld, ScaleFree, EdgeList:
+	default:
+		return fmt.Errorf("unknown network topology %q", n.Topology)
+	}
+	if !m.bilateral() || m.Matching == Partitioned {
+		return fmt.Errorf("a trading network requires the bilateral institution and global or pool matching")
+	}
+	if m.Markets > 1 {
+		return fmt.Errorf("a trading network cannot be split into markets")
+	}
+	if n.Degree < 0 || n.Rewire < 0 || n.Rewire > 1 {
+		return fmt.Errorf("the network's degree must not be negative and its rewiring share must lie in [0, 1]")
+	}
+	if n.Degree == 0 {
+		n.Degree = defaultDegree
+	}
+	if n.Rewire == 0 && n.Topology == SmallWorld {
+		n.Rewire = defaultRewire
+	}
+	if n.Degree > m.NumSellers {
+		return fmt.Errorf("a degree of %d needs at least as many sellers", n.Degree)
+	}
+	if m.dry {
+		// Of the graphs, only an edge list can fail to build.
+		if n.Topology == EdgeList {
+			_, err := readLinks(n.File, m.NumBuyers, m.NumSellers)
+			return err
+		}
+		return nil
+	}
+
+	var links [][2]int32
+	switch n.Topology {
+	case Ring:
+		links = m.ring(0)
+	case SmallWorld:
+		links = m.ring(n.Rewire)
+	case ScaleFree:
+		links = m.scaleFree()
+	case EdgeList:
+		var err error
+		if links, err = readLinks(n.File, m.NumBuyers, m.NumSellers); err != nil {
+			return err
+		}
+	}
+	m.graph = newGraph(m.NumBuyers, links)
+	m.neighbors = m.graph
+	return nil
+}
+
+// Link each buyer to the Degree sellers nearest it when both are spread
+// evenly around a ring, rewiring each link with probability p to a seller
+// drawn at random.
+func (m *Model) ring(p float64) [][2]int32 {
+	k, s := m.Network.Degree, int64(m.NumSellers)
+	links := make([][2]int32, 0, m.NumBuyers*k)
+	for i := 0; i < m.NumBuyers; i++ {
+		center := int64(i) * s / int64(m.NumBuyers)
+		for d := 0; d < k; d++ {
+			j := (center + int64(d-k/2) + s) % s
+			if p > 0 && m.rng.Float64() < p {
+				j = int64(m.rng.Intn(m.NumSellers))
+			}
+			links = append(links, [2]int32{int32(i), int32(j)})
+		}
+	}
+	return links
+}
+
+// Grow a bipartite network by preferential attachment: buyers and sellers
+// join in turn, each linking to half Degree traders on the other side who
+// have already joined, drawn with probability proportional to their degree
+// plus one.
+func (m *Model) scaleFree() [][2]int32 {
+	k := m.Network.Degree / 2
+	if k < 1 {
+		k = 1
+	}
+	links := make([][2]int32, 0, (m.NumBuyers+m.NumSellers)*k)
+	// Every link lists its buyer and its seller, so drawing a link at random
+	// draws a trader in proportion to its degree. The first n traders of a
+	// side have joined.
+	attach := func(n, side int) int32 {
+		if len(links) == 0 || m.rng.Intn(n+len(links)) < n {
+			return int32(m.rng.Intn(n))
+		}
+		return links[m.rng.Intn(len(links))][side]
+	}
+	joined := func(i, n int) int {
+		if i < n {
+			return i + 1
+		}
+		return n
+	}
+	for i := 0; i < m.NumBuyers || i < m.NumSellers; i++ {
+		if i < m.NumBuyers {
+			for d := 0; d < k; d++ {
+				links = append(links, [2]int32{int32(i), attach(joined(i, m.NumSellers), 1)})
+			}
+		}
+		if i < m.NumSellers {
+			for d := 0; d < k; d++ {
+				links = append(links, [2]int32{attach(joined(i, m.NumBuyers), 0), int32(i)})
+			}
+		}
+	}
+	return links
+}
+
+// Read an edge list of buyer and seller indices, skipping a header row.
+func readLinks(path string, buyers, sellers int) ([][2]int32, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cr := csv.NewReader(f)
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var links [][2]int32
+	for i, rec := range records {
+		if len(rec) != 2 {
+			return nil, fmt.Errorf("%s line %d: want a buyer and a seller", path, i+1)
+		}
+		b, err := strconv.Atoi(rec[0])
+		if err != nil && i == 0 {
+			continue
+		}
+		s, err2 := strconv.Atoi(rec[1])
+		if err != nil || err2 != nil {
+			return nil, fmt.Errorf("%s line %d: bad index", path, i+1)
+		}
+		if b < 0 || b >= buyers || s < 0 || s >= sellers {
+			return nil, fmt.Errorf("%s line %d: no buyer %d or seller %d", path, i+1, b, s)
+		}
+		links = append(links, [2]int32{int32(b), int32(s)})
+	}
+	return links, nil
+}
+
+// Build the graph of the links, dropping any duplicates.
+func newGraph(buyers int, links [][2]int32) *graph {
+	sort.Slice(links, func(i, j int) bool {
+		if links[i][0] != links[j][0] {
+			return links[i][0] < links[j][0]
+		}
+		return links[i][1] < links[j][1]
+	})
+	g := &graph{offsets: make([]int32, buyers+1), sellers: make([]int32, 0, len(links))}
+	for i, l := range links {
+		if i > 0 && l == links[i-1] {
+			continue
+		}
+		g.sellers = append(g.sellers, l[1])
+		g.offsets[l[0]+1]++
+	}
+	for i := 0; i < buyers; i++ {
+		g.offsets[i+1] += g.offsets[i]
+	}
+	return g
+}
+
+// The sellers linked to buyer i.
+func (g *graph) neighbors(i int) []int32 {
+	return g.sellers[g.offsets[i]:g.offsets[i+1]]
+}
+
+// Draw a seller linked to buyer i, or -1 if it has none.
+func (g *graph) partner(i int, r *rand.Rand) int {
+	n := g.neighbors(i)
+	if len(n) == 0 {
+		return -1
+	}
+	return int(n[r.Intn(len(n))])
+}
+
+// Sum up the network and the trading of buyers and sellers by degree.
+func (m *Model) networkResults() *NetworkResults {
+	g := m.graph
+	r := &NetworkResults{Topology: m.Network.Topology, Links: len(g.sellers)}
+	degrees := make([]int, m.NumSellers)
+	for _, s := range g.sellers {
+		degrees[s]++
+	}
+	type tally struct {
+		DegreeResults
+		profit, volume int
+	}
+	buckets := map[int]*tally{}
+	add := func(a *agent, degree int) {
+		bucket := 0
+		for b := 1; b <= degree; b *= 2 {
+			bucket = b
+		}
+		d := buckets[bucket]
+		if d == nil {
+			d = &tally{DegreeResults: DegreeResults{Degree: bucket}}
+			buckets[bucket] = d
+		}
+		d.Traders++
+		d.Trades += len(a.prices)
+		d.profit += a.surplus()
+		for _, p := range a.prices {
+			d.volume += p
+		}
+		if degree > r.MaxDegree {
+			r.MaxDegree = degree
+		}
+		if degree == 0 {
+			r.Isolated++
+		}
+	}
+	for i := range m.buyers {
+		add(&m.buyers[i], len(g.neighbors(i)))
+	}
+	for i := range m.sellers {
+		add(&m.sellers[i], degrees[i])
+	}
+	r.MeanDegree = 2 * float64(r.Links) / float64(m.NumBuyers+m.NumSellers)
+	for _, d := range buckets {
+		d.MeanProfit = float64(d.profit) / float64(d.Traders)
+		if d.Trades > 0 {
+			d.MeanPrice = float64(d.volume) / float64(d.Trades)
+		}
+		r.Degrees = append(r.Degrees, d.DegreeResults)
+	}
+	sort.Slice(r.Degrees, func(i, j int) bool { return r.Degrees[i].Degree < r.Degrees[j].Degree })
+
+	// The price in a buyer's neighborhood pools every sale of the sellers it
+	// is linked to.
+	sums, counts := make([]int, m.NumSellers), make([]int, m.NumSellers)
+	for i, s := range m.sellers {
+		for _, p := range s.prices {
+			sums[i] += p
+			counts[i]++
+		}
+	}
+	var local moments
+	for i := range m.buyers {
+		sum, count := 0, 0
+		for _, s := range g.neighbors(i) {
+			sum += sums[s]
+			count += counts[s]
+		}
+		if count > 0 {
+			local.add(float64(sum) / float64(count))
+		}
+	}
+	r.Dispersion = local.sd()
+	return r
+}
@@ -0,0 +1,72 @@
+package zitraders
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Trade only takes place along links, whatever the topology.
+func TestNetwork(t *testing.T) {
+	dir := t.TempDir()
+	edges := filepath.Join(dir, "edges.csv")
+	if err := os.WriteFile(edges, []byte("buyer,seller\n0,0\n0,1\n1,1\n2,3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, network := range []Network{
+		{Topology: Ring},
+		{Topology: SmallWorld, Rewire: 0.5},
+		{Topology: ScaleFree},
+		{Topology: EdgeList, File: edges},
+	} {
+		for _, matching := range []string{Global, Pool} {
+			config := testConfig()
+			config.Matching, config.Network, config.RecordTrades = matching, network, true
+			m := newTestModel(t, config)
+			r := m.Run()
+
+			n := r.Network
+			if n == nil || n.Links == 0 || r.NumberBought == 0 {
+				t.Fatalf("%s %s: %+v", network.Topology, matching, n)
+			}
+			for _, tr := range m.Trades() {
+				linked := false
+				for _, s := range m.graph.neighbors(tr.Buyer) {
+					linked = linked || int(s) == tr.Seller
+				}
+				if !linked {
+					t.Fatalf("%s %s: trade off the network %+v", network.Topology, matching, tr)
+				}
+			}
+			traders := 0
+			for _, d := range n.Degrees {
+				traders += d.Traders
+			}
+			if traders != config.NumBuyers+config.NumSellers || n.Isolated > 0 && n.Degrees[0].Degree != 0 {
+				t.Errorf("%s %s: %+v", network.Topology, matching, n)
+			}
+		}
+	}
+}
+
+// Each buyer on a ring is linked to its nearest sellers.
+func TestRing(t *testing.T) {
+	config := testConfig()
+	config.NumBuyers, config.NumSellers = 10, 20
+	config.Matching, config.Network = Global, Network{Topology: Ring, Degree: 3}
+	m := newTestModel(t, config)
+	for i := 0; i < config.NumBuyers; i++ {
+		got := m.graph.neighbors(i)
+		if len(got) != 3 {
+			t.Fatalf("buyer %d linked to %v", i, got)
+		}
+		for _, s := range got {
+			if d := (int(s) - 2*i + 21) % 20; d > 2 {
+				t.Errorf("buyer %d linked to seller %d", i, s)
+			}
+		}
+	}
+	if r := m.Run().Network; r.MaxDegree != 3 || r.Isolated != 0 || r.Links != 30 {
+		t.Errorf("%+v", r)
+	}
+}
@@ -0,0 +1,37 @@
+package zitraders
+
+import "sync"
+
+// On a machine with several NUMA nodes the kernel places each page of memory
+// on the node of the processor that first writes it. The agents are drawn in
+// order on a single thread, so by default every page lands on that thread's
+// node and the threads on other nodes trade across the interconnect. Under
+// first touch each thread's partition of the agents is zeroed by a goroutine
+// of its own, in parallel, before the agents are drawn, so its pages are
+// spread over the nodes the goroutines ran on. Go does not pin goroutines to
+// processors, so the goroutine that later trades a partition may run on
+// another node than the one that touched it; the placement is only a better
+// starting point than putting everything on one node, and its benefit should
+// be measured on the machine in question.
+
+// Have a goroutine for each thread call touch with the thread's number, and
+// wait for them all.
+func (m *Model) firstTouch(touch func(t int)) {
+	var wg sync.WaitGroup
+	for t := 0; t < m.NumThreads; t++ {
+		wg.Add(1)
+		go func(t int) {
+			defer wg.Done()
+			touch(t)
+		}(t)
+	}
+	wg.Wait()
+}
+
+// Write the zero value to every element of s.
+func zero[T any](s []T) {
+	var z T
+	for i := range s {
+		s[i] = z
+	}
+}
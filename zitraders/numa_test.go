@@ -0,0 +1,40 @@
+package zitraders
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// First touch changes where the agents live, not what they are.
+func TestFirstTouch(t *testing.T) {
+	for _, modify := range []func(*Config){
+		func(c *Config) { c.Units, c.SellerUnits, c.Periods = 2, 3, 2 },
+		func(c *Config) { c.NumBuyers, c.NumThreads = 1003, 7 },
+		func(c *Config) { c.Layout = SoA },
+		func(c *Config) { c.NumBuyers, c.NumThreads, c.Layout = 1003, 7, Compact },
+	} {
+		config := testConfig()
+		modify(&config)
+		want := newTestModel(t, config).Run()
+		config.FirstTouch = true
+		if got := newTestModel(t, config).Run(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%+v: first touch changed the results", config)
+		}
+	}
+}
+
+func benchmarkFirstTouch(b *testing.B, touch bool) {
+	config := testConfig()
+	config.NumBuyers, config.NumSellers = 2000000, 2000000
+	config.NumThreads = runtime.GOMAXPROCS(0)
+	config.MaxNumberOfTrades = config.NumThreads * (b.N/config.NumThreads + 2)
+	config.FirstTouch = touch
+	m := newTestModel(b, config)
+	b.ResetTimer()
+	m.openMarket(context.Background())
+}
+
+func BenchmarkFirstTouch(b *testing.B)  { benchmarkFirstTouch(b, true) }
+func BenchmarkSingleTouch(b *testing.B) { benchmarkFirstTouch(b, false) }
@@ -0,0 +1,80 @@
+package zitraders
+
+// A partition is one thread's share of the population. Partitions hold
+// disjoint sub-slices of the buyer and seller slices, so a thread can only
+// ever reach its own agents and trading needs no synchronization.
+type partition struct {
+	thread       int
+	period       int
+	buyers       []agent
+	sellers      []agent
+	buyerOffset  int      // population index of buyers[0]
+	sellerOffset int      // population index of sellers[0]
+	history      *history // quotes made in the partition, for GD traders
+	dealer       *dealer  // the partition's market maker, if any
+	arbitrageur  *arbitrageur
+}
+
+// Split the population into one partition per thread. Under global matching
+// every thread's partition is the whole population.
+func (m *Model) partitions() []partition {
+	parts := make([]partition, m.NumThreads)
+	for t := range parts {
+		if m.Matching == Global {
+			parts[t] = partition{thread: t, period: m.period, buyers: m.buyers, sellers: m.sellers, history: m.history(t), dealer: m.dealer(t), arbitrageur: m.arbitrageur(t)}
+			continue
+		}
+
+		lowerBuyer, upperBuyer, lowerSeller, upperSeller := m.bounds(t)
+
+		parts[t] = partition{
+			thread:       t,
+			period:       m.period,
+			buyers:       m.buyers[lowerBuyer:upperBuyer:upperBuyer],
+			sellers:      m.sellers[lowerSeller:upperSeller:upperSeller],
+			buyerOffset:  lowerBuyer,
+			sellerOffset: lowerSeller,
+			history:      m.history(t),
+			dealer:       m.dealer(t),
+			arbitrageur:  m.arbitrageur(t),
+		}
+	}
+	return parts
+}
+
+// The population index bounds of a thread's partition. Each side is split
+// as evenly as it divides, the first threads taking one agent more than the
+// rest, so that every agent belongs to exactly one partition.
+func (m *Model) bounds(t int) (lowerBuyer, upperBuyer, lowerSeller, upperSeller int) {
+	lowerBuyer, upperBuyer = split(t, m.NumBuyers, m.NumThreads)
+	lowerSeller, upperSeller = split(t, m.NumSellers, m.NumThreads)
+	return
+}
+
+// The bounds of part t of n items split into parts as evenly as possible.
+func split(t, n, parts int) (lower, upper int) {
+	per, extra := n/parts, n%parts
+	lower = t*per + t
+	if t > extra {
+		lower = t*per + extra
+	}
+	upper = lower + per
+	if t < extra {
+		upper++
+	}
+	return
+}
+
+// Stamp a trade between partition-local agents with its period, tick and
+// thread and translate its agent indices to population indices for the trade
+// log.
+func (p partition) record(t Trade, tick int) Trade {
+	t.Period, t.Tick, t.Thread = p.period, tick, p.thread
+	if t.Buyer >= 0 {
+		t.Buyer += p.buyerOffset
+	}
+	if t.Seller >= 0 {
+		t.Seller += p.sellerOffset
+	}
+	return t
+}
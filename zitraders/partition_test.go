@@ -0,0 +1,59 @@
+package zitraders
+
+import "testing"
+
+func TestSplit(t *testing.T) {
+	for _, c := range []struct{ n, parts int }{{10, 3}, {12, 4}, {5, 5}, {7, 1}, {1000003, 8}} {
+		next := 0
+		for k := 0; k < c.parts; k++ {
+			lower, upper := split(k, c.n, c.parts)
+			if lower != next || upper-lower < c.n/c.parts || upper-lower > c.n/c.parts+1 {
+				t.Fatalf("%d in %d parts: part %d is [%d, %d)", c.n, c.parts, k, lower, upper)
+			}
+			next = upper
+		}
+		if next != c.n {
+			t.Errorf("%d in %d parts: the parts end at %d", c.n, c.parts, next)
+		}
+	}
+}
+
+// Every agent belongs to exactly one partition, however the population
+// divides among the threads, and so every agent can trade.
+func TestEveryAgentReachable(t *testing.T) {
+	config := testConfig()
+	config.NumBuyers, config.NumSellers, config.NumThreads = 103, 101, 6
+	config.MaxNumberOfTrades = 600000
+	m := newTestModel(t, config)
+
+	buyers, sellers := make([]int, config.NumBuyers), make([]int, config.NumSellers)
+	for _, p := range m.partitions() {
+		for i := range p.buyers {
+			buyers[p.buyerOffset+i]++
+		}
+		for i := range p.sellers {
+			sellers[p.sellerOffset+i]++
+		}
+	}
+	for _, counts := range [][]int{buyers, sellers} {
+		for i, n := range counts {
+			if n != 1 {
+				t.Fatalf("agent %d is in %d partitions", i, n)
+			}
+		}
+	}
+
+	// Buyers who value a unit at the top and sellers who cost it at the bottom
+	// trade with anyone, so all of them trade in a long enough run.
+	m.Run()
+	for i, b := range m.buyers {
+		if b.value == config.MaxBuyerValue && len(b.prices) == 0 {
+			t.Errorf("buyer %d of value %d never traded", i, b.value)
+		}
+	}
+	for i, s := range m.sellers {
+		if s.value == 1 && len(s.prices) == 0 {
+			t.Errorf("seller %d of cost 1 never traded", i)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package zitraders
+
+import "time"
+
+// Performance describes how the work of a run was shared among its threads,
+// so that an imbalance between partitions shows. Unlike Results it depends on
+// the machine, not just the seed.
+type Performance struct {
+	Threads   []ThreadPerformance `json:"threads"`
+	Attempts  int64               `json:"attempts"`
+	Trades    int64               `json:"trades"`
+	Elapsed   time.Duration       `json:"elapsed"`   // wall-clock time with the market open
+	Rate      float64             `json:"rate"`      // attempts per second of Elapsed
+	Imbalance float64             `json:"imbalance"` // the busiest thread's time over the mean
+}
+
+// ThreadPerformance counts the work of one thread, or of one worker of the
+// pool.
+type ThreadPerformance struct {
+	Thread   int           `json:"thread"`
+	Attempts int64         `json:"attempts"`
+	Trades   int64         `json:"trades"`
+	Elapsed  time.Duration `json:"elapsed"` // spent trading, not paused at the end of ticks
+	Rate     float64       `json:"rate"`    // attempts per second of Elapsed
+}
+
+// Performance returns the work done by each thread in every trading period
+// since the model was created or resumed. It is meant to be called once the
+// model has run.
+func (m *Model) Performance() Performance {
+	p := Performance{Threads: make([]ThreadPerformance, len(m.perf)), Elapsed: m.elapsed}
+	var busy, most time.Duration
+	for i, t := range m.perf {
+		t.Thread = i
+		t.Rate = rate(t.Attempts, t.Elapsed)
+		p.Threads[i] = t
+		p.Attempts += t.Attempts
+		p.Trades += t.Trades
+		busy += t.Elapsed
+		if t.Elapsed > most {
+			most = t.Elapsed
+		}
+	}
+	p.Rate = rate(p.Attempts, p.Elapsed)
+	if busy > 0 {
+		p.Imbalance = float64(most) * float64(len(m.perf)) / float64(busy)
+	}
+	return p
+}
+
+func rate(attempts int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(attempts) / elapsed.Seconds()
+}
@@ -0,0 +1,33 @@
+package zitraders
+
+import "testing"
+
+// The threads' counts add up to the run's, in every mode.
+func TestPerformance(t *testing.T) {
+	for _, mode := range []struct{ matching, layout, institution string }{
+		{Partitioned, AoS, Bilateral},
+		{Partitioned, AoS, CDA},
+		{Partitioned, SoA, Bilateral},
+		{Pool, AoS, Bilateral},
+	} {
+		config := testConfig()
+		config.Matching, config.Layout, config.Institution = mode.matching, mode.layout, mode.institution
+		config.TickSize = 1000
+		m := newTestModel(t, config)
+		m.Observe(func(*Model, Tick) bool { return true })
+		m.Run()
+
+		p, progress := m.Performance(), m.Progress()
+		if len(p.Threads) != m.NumThreads || p.Attempts != progress.Attempts || p.Trades != progress.Trades {
+			t.Errorf("%v: %d threads made %d attempts and %d trades, want %d and %d", mode, len(p.Threads), p.Attempts, p.Trades, progress.Attempts, progress.Trades)
+		}
+		for _, thread := range p.Threads {
+			if thread.Elapsed <= 0 || thread.Elapsed > p.Elapsed {
+				t.Errorf("%v: thread %d traded for %v of %v", mode, thread.Thread, thread.Elapsed, p.Elapsed)
+			}
+		}
+		if p.Rate <= 0 || p.Imbalance < 1 {
+			t.Errorf("%v: a rate of %f and an imbalance of %f", mode, p.Rate, p.Imbalance)
+		}
+	}
+}
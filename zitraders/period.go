@@ -0,0 +1,63 @@
+package zitraders
+
+// Period summarizes one trading period of a session. As in the sessions of
+// experimental markets, every trader's endowment and demand is restored at
+// the start of each period, so successive periods show whether trading
+// converges as the traders gain experience.
+type Period struct {
+	Trades          int     `json:"trades"`
+	MeanPrice       float64 `json:"mean_price"`
+	SDPrice         float64 `json:"sd_price"`
+	RealizedSurplus int     `json:"realized_surplus"`
+	Efficiency      float64 `json:"efficiency"`    // realized surplus as a percentage of the period's maximum
+	Alpha           float64 `json:"alpha"`         // Smith's alpha over the period's trades
+	Unrealized      float64 `json:"unrealized"`    // the surplus of intramarginal units left untraded
+	Extramarginal   float64 `json:"extramarginal"` // the surplus lost to extramarginal units traded
+	Stopped         string  `json:"stopped"`       // the reason the period ended
+}
+
+// Summarize the trading period that just ended and, unless the session ends
+// with it, restore the traders' endowments for the next.
+func (m *Model) endPeriod() {
+	p := m.Progress()
+	d := Progress{Trades: p.Trades - m.mark.Trades, Volume: p.Volume - m.mark.Volume, Squares: p.Squares - m.mark.Squares}
+	period := Period{Trades: int(d.Trades), MeanPrice: d.MeanPrice(), SDPrice: d.SDPrice(), Stopped: StopAttempts}
+	if m.stopped != "" {
+		period.Stopped = m.stopped
+	}
+	for _, agents := range [][]agent{m.buyers, m.sellers} {
+		for i := range agents {
+			period.RealizedSurplus += agents[i].surplus() - agents[i].earned
+		}
+	}
+	l := m.periodLosses()
+	period.Unrealized, period.Extramarginal = l.unrealized, l.extramarginal
+	m.periods = append(m.periods, period)
+	m.period++
+	m.mark = p
+	m.first = m.ticks
+
+	if m.period == m.Periods || m.ended() {
+		return
+	}
+	m.stopped = ""
+	for _, agents := range [][]agent{m.buyers, m.sellers} {
+		for i := range agents {
+			agents[i].reendow()
+		}
+	}
+	m.periodShocks()
+}
+
+// Fill in the statistics that compare the period with the competitive
+// equilibrium of a single period.
+func (p *Period) finish(eqPrice float64, maxSurplus int) {
+	if maxSurplus > 0 {
+		p.Efficiency = 100 * float64(p.RealizedSurplus) / float64(maxSurplus)
+	}
+	if p.Trades > 0 {
+		n := float64(p.Trades)
+		d := p.MeanPrice - eqPrice
+		p.Alpha = alpha(p.SDPrice*p.SDPrice*(n-1)/n+d*d, eqPrice)
+	}
+}
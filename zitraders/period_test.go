@@ -0,0 +1,39 @@
+package zitraders
+
+import "testing"
+
+// The periods of a session add up to the whole, and each realizes no more
+// than the surplus available in a period.
+func TestPeriods(t *testing.T) {
+	config := testConfig()
+	config.Periods, config.Units, config.ZIP = 3, 2, 0.5
+	config.RecordTrades = true
+	m := newTestModel(t, config)
+	r := m.Run()
+
+	if len(r.Periods) != 3 {
+		t.Fatalf("got %d periods", len(r.Periods))
+	}
+	trades, surplus := 0, 0
+	for k, p := range r.Periods {
+		if p.Trades == 0 || p.RealizedSurplus > r.MaxSurplus/3 {
+			t.Errorf("period %d: %+v of a maximum %d", k, p, r.MaxSurplus/3)
+		}
+		trades += p.Trades
+		surplus += p.RealizedSurplus
+	}
+	if trades != r.NumberBought || surplus != r.RealizedSurplus {
+		t.Errorf("periods traded %d for %d, session %d for %d", trades, surplus, r.NumberBought, r.RealizedSurplus)
+	}
+
+	last := Trade{}
+	for _, tr := range m.Trades() {
+		if tr.Period < last.Period || (tr.Period == last.Period && tr.Tick < last.Tick) {
+			t.Fatalf("trade %+v logged after %+v", tr, last)
+		}
+		last = tr
+	}
+	if last.Period != 2 {
+		t.Errorf("last trade in period %d", last.Period)
+	}
+}
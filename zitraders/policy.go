@@ -0,0 +1,150 @@
+package zitraders
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Policy levers. A price band forbids trade outside [PriceFloor,
+// PriceCeiling]: quotes outside it are not made. A transaction tax of Tax
+// plus TaxRate times the price is levied on every trade and paid by
+// TaxPayer. Traders quote on their values net of the tax they pay and within
+// the band, so ZI-C traders still never trade at a loss.
+
+// Who pays a transaction tax.
+const (
+	TaxBuyer  = "buyer"
+	TaxSeller = "seller"
+	TaxSplit  = "split" // each side pays half
+)
+
+// PolicyResults reports the effects of a price band or transaction tax.
+type PolicyResults struct {
+	TaxRevenue     float64 `json:"tax_revenue"`
+	MaxSurplus     int     `json:"max_surplus"`     // the most surplus trade can realize under the policy
+	DeadweightLoss int     `json:"deadweight_loss"` // the equilibrium surplus the policy forgoes
+}
+
+// Check the policy levers and note whether any is set.
+func (m *Model) checkPolicy() error {
+	if m.PriceFloor < 0 || m.PriceCeiling < 0 || (m.PriceCeiling > 0 && m.PriceCeiling < m.PriceFloor) {
+		return fmt.Errorf("the price floor must not be negative or above the ceiling")
+	}
+	if m.Tax < 0 || m.TaxRate < 0 || m.TaxRate >= 1 {
+		return fmt.Errorf("taxes must not be negative, and tax rates must be below one")
+	}
+	switch m.TaxPayer {
+	case "":
+		m.TaxPayer = TaxSplit
+	case TaxBuyer, TaxSeller, TaxSplit:
+	default:
+		return fmt.Errorf("unknown tax payer %q", m.TaxPayer)
+	}
+	m.policy = m.PriceFloor > 0 || m.PriceCeiling > 0 || m.Tax > 0 || m.TaxRate > 0
+	return nil
+}
+
+// The share of the tax that buyers pay; sellers pay the rest.
+func (m *Model) buyerShare() float64 {
+	switch m.TaxPayer {
+	case TaxBuyer:
+		return 1
+	case TaxSeller:
+		return 0
+	}
+	return 0.5
+}
+
+// The highest legal price a buyer of value v can pay without a loss after
+// tax.
+func (m *Model) bidLimit(v int) int {
+	s := m.buyerShare()
+	l := int(math.Floor((float64(v) - s*m.Tax) / (1 + s*m.TaxRate)))
+	if m.PriceCeiling > 0 && l > m.PriceCeiling {
+		l = m.PriceCeiling
+	}
+	return l
+}
+
+// The lowest legal price a seller of cost c can accept without a loss after
+// tax.
+func (m *Model) askLimit(c int) int {
+	s := 1 - m.buyerShare()
+	l := int(math.Ceil((float64(c) + s*m.Tax) / (1 - s*m.TaxRate)))
+	if l < m.PriceFloor {
+		l = m.PriceFloor
+	}
+	return l
+}
+
+// The value or cost on which a trader quotes: its own, adjusted for the
+// policy.
+func (m *Model) limit(a *agent) int {
+	if !m.policy {
+		return a.value
+	}
+	if a.buyerOrSeller {
+		return m.bidLimit(a.value)
+	}
+	return m.askLimit(a.value)
+}
+
+// Whether a trader with the given limit can make any legal quote at all.
+func (m *Model) canQuote(a *agent, limit int) bool {
+	if a.buyerOrSeller {
+		return limit >= 1 && limit >= m.PriceFloor
+	}
+	return limit <= m.maxPrice() && (m.PriceCeiling == 0 || limit <= m.PriceCeiling)
+}
+
+// A trader's quote under the policy, made on its limit; NoQuote if it can
+// make no legal quote without a loss, or if the band forbids the quote its
+// strategy chose.
+func (m *Model) policed(a *agent, h *history, r *rand.Rand) int {
+	v := m.view(a, h, r)
+	if !m.canQuote(a, v.Value) {
+		return NoQuote
+	}
+	if a.buyerOrSeller {
+		return m.legal(a.strategy.Bid(v))
+	}
+	return m.legal(a.strategy.Ask(v))
+}
+
+// A quote, or NoQuote if the band forbids it.
+func (m *Model) legal(q int) int {
+	if q < m.PriceFloor || (m.PriceCeiling > 0 && q > m.PriceCeiling) {
+		return NoQuote
+	}
+	return q
+}
+
+// The effects of the policy on a run of n periods with the given trade
+// counts, against the competitive equilibrium surplus without it.
+func (m *Model) policyResults(p Progress, maxSurplus, n int) *PolicyResults {
+	r := &PolicyResults{TaxRevenue: m.Tax*float64(p.Trades) + m.TaxRate*float64(p.Volume)}
+	values, costs := m.schedules()
+	most := len(values)
+	if len(costs) < most {
+		most = len(costs)
+	}
+	// The most surplus comes from the highest j values and lowest j costs for
+	// some j. They can all trade if paired highest with highest, which a
+	// proportional tax may allow when pairing them in order does not.
+	j := sort.Search(most+1, func(j int) bool {
+		for i := 0; i < j; i++ {
+			if m.bidLimit(values[i]) < m.askLimit(costs[j-1-i]) {
+				return true
+			}
+		}
+		return false
+	}) - 1
+	for k := 0; k < j && values[k] >= costs[k]; k++ {
+		r.MaxSurplus += values[k] - costs[k]
+	}
+	r.MaxSurplus *= n
+	r.DeadweightLoss = maxSurplus - r.MaxSurplus
+	return r
+}
@@ -0,0 +1,57 @@
+package zitraders
+
+import (
+	"math"
+	"testing"
+)
+
+// Trades respect the price band, and ZI-C traders never lose after tax.
+func TestPolicy(t *testing.T) {
+	for _, modify := range []func(*Config){
+		func(c *Config) { c.PriceCeiling = 10 },
+		func(c *Config) { c.PriceFloor, c.Institution = 20, CDA },
+		func(c *Config) { c.PriceFloor, c.PriceCeiling, c.Institution = 12, 18, Call },
+		func(c *Config) { c.Tax, c.TaxPayer = 2, TaxBuyer },
+		func(c *Config) { c.TaxRate, c.TaxPayer, c.Institution = 0.2, TaxSeller, CDA },
+		func(c *Config) { c.Tax, c.TaxRate, c.ZIP, c.PriceCeiling = 1, 0.1, 0.5, 25 },
+		func(c *Config) { c.PriceCeiling, c.Dealer = 15, true },
+	} {
+		config := testConfig()
+		config.RecordTrades = true
+		modify(&config)
+		m := newTestModel(t, config)
+		r := m.Run()
+
+		p := r.Policy
+		if p == nil || p.MaxSurplus > r.MaxSurplus || p.DeadweightLoss != r.MaxSurplus-p.MaxSurplus || r.RealizedSurplus > p.MaxSurplus {
+			t.Fatalf("%+v: got %+v with realized surplus %d of %d", config, p, r.RealizedSurplus, r.MaxSurplus)
+		}
+		n := float64(len(m.Trades()))
+		if want := config.Tax*n + config.TaxRate*n*r.MeanPrice; math.Abs(p.TaxRevenue-want) > 1e-6*want {
+			t.Errorf("%+v: tax revenue %v, want %v", config, p.TaxRevenue, want)
+		}
+		for _, tr := range m.Trades() {
+			if tr.Price < config.PriceFloor || (config.PriceCeiling > 0 && tr.Price > config.PriceCeiling) {
+				t.Fatalf("%+v: trade %+v outside the band", config, tr)
+			}
+		}
+		if config.ZIP > 0 {
+			continue
+		}
+		share := m.buyerShare()
+		for _, b := range m.buyers {
+			for k, price := range b.prices {
+				if tax := config.Tax + config.TaxRate*float64(price); float64(b.schedule[k]-price) < share*tax-1e-9 {
+					t.Fatalf("%+v: buyer %v lost after tax", config, b)
+				}
+			}
+		}
+		for _, s := range m.sellers {
+			for k, price := range s.prices {
+				if tax := config.Tax + config.TaxRate*float64(price); float64(price-s.schedule[k]) < (1-share)*tax-1e-9 {
+					t.Fatalf("%+v: seller %v lost after tax", config, s)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package zitraders
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+)
+
+// Candidate pairs are handed to workers in batches to amortize channel costs.
+const poolBatch = 1024
+
+// A candidate is a buyer and seller drawn from the whole population at a tick.
+type candidate struct {
+	tick   int
+	market int
+	buyer  int
+	seller int
+}
+
+// Run the market as a producer and a pool of workers: a generator draws
+// MaxNumberOfTrades candidate pairs from the whole population and sends them
+// in batches over a channel, and each worker executes whatever batch it
+// receives next. Busy workers simply take fewer batches, so the load balances
+// itself however unevenly trades deplete the population. Agents are claimed
+// or locked, or units change hands, as under global matching. The workers' trade logs are returned.
+func (m *Model) runPool(ctx context.Context, generators []*rand.Rand, generator *rand.Rand) [][]Trade {
+	var wg sync.WaitGroup
+	batches := make(chan []candidate, 2*m.NumThreads)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(batches)
+		batch := make([]candidate, 0, poolBatch)
+		for i := 1 + m.skip; i < m.MaxNumberOfTrades; i++ {
+			if m.ends(i) {
+				// Send what has been drawn and a tick marker to each
+				// worker, so that every thread pauses at the same point.
+				if len(batch) > 0 {
+					batches <- batch
+					batch = make([]candidate, 0, poolBatch)
+				}
+				for w := 0; w < m.NumThreads; w++ {
+					batches <- nil
+				}
+				if !m.pause(nil) {
+					return
+				}
+			} else if !m.advance(ctx, i, nil) {
+				return
+			}
+			c := candidate{tick: i}
+			if m.Markets > 1 {
+				c.market = generator.Intn(m.Markets)
+				c.buyer = m.drawIn(c.market, len(m.buyers), 0, generator)
+				c.seller = m.drawIn(c.market, len(m.sellers), 0, generator)
+			} else if m.neighbors != nil {
+				c.buyer = generator.Intn(len(m.buyers))
+				c.seller = m.neighbors.partner(c.buyer, generator)
+			} else {
+				c.buyer = generator.Intn(len(m.buyers))
+				c.seller = generator.Intn(len(m.sellers))
+			}
+			batch = append(batch, c)
+			if len(batch) == poolBatch {
+				batches <- batch
+				batch = make([]candidate, 0, poolBatch)
+			}
+		}
+		if len(batch) > 0 {
+			batches <- batch
+		}
+	}()
+
+	logs := make([][]Trade, m.NumThreads)
+	for w := 0; w < m.NumThreads; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			logs[worker] = m.work(worker, batches, generators[worker])
+		}(w)
+	}
+	m.schedule(ctx, &wg, m.NumThreads+1, logs)
+	return logs
+}
+
+// Execute candidate pairs from the channel until it is closed.
+func (m *Model) work(worker int, batches <-chan []candidate, generator *rand.Rand) []Trade {
+	var trades []Trade
+	progress := m.tally(worker, &trades)
+	defer progress.close()
+	h, d, a := m.history(worker), m.dealer(worker), m.arbitrageur(worker)
+	claims, cas, locks := m.Transfer == Claim, m.Transfer == CAS, m.stripes != nil
+
+	for batch := range batches {
+		if batch == nil {
+			m.pause(progress)
+			continue
+		}
+		for _, c := range batch {
+			progress.attempt()
+			h.at(c.tick)
+			if c.seller < 0 {
+				continue
+			}
+			buyer, seller := &m.buyers[c.buyer], &m.sellers[c.seller]
+			if claims && !claim(buyer, seller) {
+				continue
+			}
+			if locks {
+				m.stripes.lock(c.buyer, c.seller)
+			}
+			var t Trade
+			var ok bool
+			if cas {
+				t, ok = m.transfer(buyer, seller, generator)
+			} else {
+				t, ok = m.match(buyer, seller, h, d, generator)
+			}
+			if a != nil {
+				if ok {
+					a.see(c.market, t.Price)
+				} else {
+					t, ok = m.arbitrage(a, c.market, buyer, seller, t.Bid, t.Ask, h, generator)
+				}
+			}
+			if ok {
+				progress.trade(t.Price)
+				if m.lattice != nil {
+					m.lattice.record(worker, c.seller, t.Price)
+				}
+				if m.RecordTrades {
+					t = t.between(c.buyer, c.seller)
+					t.Period, t.Tick, t.Thread, t.Market = m.period, c.tick, worker, c.market
+					trades = append(trades, t)
+				}
+			}
+			if claims {
+				release(buyer, seller)
+			}
+			if locks {
+				m.stripes.unlock(c.buyer, c.seller)
+			}
+		}
+	}
+	return trades
+}
@@ -0,0 +1,122 @@
+package zitraders
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A share of the population that follows a strategy.
+type share struct {
+	strategy string
+	share    float64
+}
+
+// The population's mix of strategies in the order agents draw them: the
+// shares of ZI-U, ZIP, GD and sniper traders, then the shares of Population
+// by strategy name. The rest of the population follows Strategy.
+func (m *Model) mix() []share {
+	mix := []share{
+		{"zi-u", m.Unconstrained},
+		{"zip", m.ZIP},
+		{"gd", m.GD},
+		{"sniper", m.Sniper},
+	}
+	names := make([]string, 0, len(m.Population))
+	for name := range m.Population {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		mix = append(mix, share{name, m.Population[name]})
+	}
+	return mix
+}
+
+// Check that the shares are valid and their strategies registered, and
+// name the strategies that traders may draw.
+func (m *Model) checkMix() error {
+	total := 0.0
+	for _, s := range m.mix() {
+		if s.share < 0 || s.share > 1 {
+			return fmt.Errorf("trader type shares must be between 0 and 1 and sum to at most 1")
+		}
+		if _, err := strategy(s.strategy); err != nil {
+			return err
+		}
+		total += s.share
+		if s.share > 0 {
+			m.addKind(s.strategy)
+		}
+	}
+	if total > 1+1e-9 {
+		return fmt.Errorf("trader type shares must be between 0 and 1 and sum to at most 1")
+	}
+	m.addKind(m.Strategy)
+	if len(m.kinds) > 256 {
+		return fmt.Errorf("a population can mix at most 256 strategies")
+	}
+	return nil
+}
+
+// Add a strategy to those traders may draw, if it is not already.
+func (m *Model) addKind(name string) {
+	for _, k := range m.kinds {
+		if k == name {
+			return
+		}
+	}
+	m.kinds = append(m.kinds, name)
+}
+
+// The index of a strategy among those traders may draw.
+func (m *Model) kind(name string) uint8 {
+	for i, k := range m.kinds {
+		if k == name {
+			return uint8(i)
+		}
+	}
+	panic("strategy " + name + " not in the population")
+}
+
+// Whether the population mixes more than plain ZI-C traders.
+func (m *Model) mixed() bool {
+	return len(m.kinds) != 1 || m.kinds[0] != "zi-c"
+}
+
+// TypeResults are the results of the traders following one strategy.
+type TypeResults struct {
+	Strategy   string  `json:"strategy"`
+	Buyers     int     `json:"buyers"`
+	Sellers    int     `json:"sellers"`
+	Trades     int     `json:"trades"`      // units bought or sold
+	Profit     int     `json:"profit"`      // realized surplus
+	MeanProfit float64 `json:"mean_profit"` // realized surplus per trader
+}
+
+// The results of each strategy followed by any trader.
+func (m *Model) typeResults() []TypeResults {
+	types := make([]TypeResults, len(m.kinds))
+	for i, name := range m.kinds {
+		types[i].Strategy = name
+	}
+	for _, x := range m.buyers {
+		t := &types[x.kind]
+		t.Buyers++
+		t.Trades += len(x.prices)
+		t.Profit += x.surplus()
+	}
+	for _, x := range m.sellers {
+		t := &types[x.kind]
+		t.Sellers++
+		t.Trades += len(x.prices)
+		t.Profit += x.surplus()
+	}
+	present := types[:0]
+	for _, t := range types {
+		if n := t.Buyers + t.Sellers; n > 0 {
+			t.MeanProfit = float64(t.Profit) / float64(n)
+			present = append(present, t)
+		}
+	}
+	return present
+}
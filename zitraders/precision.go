@@ -0,0 +1,108 @@
+package zitraders
+
+import (
+	"context"
+	"fmt"
+)
+
+// The outcomes of a set of replications. The precision of the mean price or
+// the efficiency can end a sequence of them.
+const (
+	OutcomeQuantity   = "quantity"
+	OutcomeMeanPrice  = "mean_price"
+	OutcomeEfficiency = "efficiency"
+	OutcomeAlpha      = "alpha"
+)
+
+const (
+	defaultMinReps = 5
+	defaultMaxReps = 1000
+)
+
+// Precision is a sequential stopping rule: replications continue until the
+// half-width of the 95% confidence interval of the outcome's mean is at most
+// Tolerance, after at least MinReps of them and at most MaxReps.
+type Precision struct {
+	Outcome   string  `json:"outcome"` // OutcomeMeanPrice, the default, or OutcomeEfficiency
+	Tolerance float64 `json:"tolerance"`
+	MinReps   int     `json:"min_reps"` // zero for the default of 5
+	MaxReps   int     `json:"max_reps"` // zero for the default of 1000
+}
+
+// HalfWidth is half the width of the confidence interval.
+func (e Estimate) HalfWidth() float64 {
+	return (e.High - e.Low) / 2
+}
+
+// Estimate returns the estimate of the rule's outcome from the replications.
+func (p Precision) Estimate(results []Results) Estimate {
+	s := Summarize(results)
+	if p.Outcome == OutcomeEfficiency {
+		return s.Efficiency
+	}
+	return s.MeanPrice
+}
+
+// Met reports whether the replications satisfy the rule.
+func (p Precision) Met(results []Results) bool {
+	least := p.MinReps
+	if least == 0 {
+		least = defaultMinReps
+	}
+	return len(results) >= least && len(results) >= 2 && p.Estimate(results).HalfWidth() <= p.Tolerance
+}
+
+func (p *Precision) check() error {
+	switch p.Outcome {
+	case "":
+		p.Outcome = OutcomeMeanPrice
+	case OutcomeMeanPrice, OutcomeEfficiency:
+	default:
+		return fmt.Errorf("unknown outcome %q: choose %s or %s", p.Outcome, OutcomeMeanPrice, OutcomeEfficiency)
+	}
+	if p.MinReps == 0 {
+		p.MinReps = defaultMinReps
+	}
+	if p.MaxReps == 0 {
+		p.MaxReps = defaultMaxReps
+	}
+	if p.Tolerance <= 0 || p.MinReps < 2 || p.MaxReps < p.MinReps {
+		return fmt.Errorf("a stopping rule needs a positive tolerance, at least 2 replications and a maximum no smaller than the minimum")
+	}
+	return nil
+}
+
+// ReplicateUntil runs replications, up to workers at once, until they meet
+// the stopping rule or MaxReps of them have run. The replications are those
+// of Replicate with the same seed, and the rule is checked after each in
+// turn, so the replications returned, and their number, do not depend on
+// workers; any run beyond the first to meet the rule is discarded. Once
+// ctx is done no further replication starts, and the results so far are
+// returned. Whether the rule was met can be checked with Met.
+func ReplicateUntil(ctx context.Context, config Config, p Precision, workers int, prepare ...func(*Model)) ([]Results, error) {
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	seeds := ReplicationSeeds(config.Seed, p.MaxReps)
+	var results []Results
+	for len(results) < len(seeds) && ctx.Err() == nil {
+		end := len(results) + workers
+		if end > len(seeds) {
+			end = len(seeds)
+		}
+		batch, err := replicateSeeds(ctx, config, seeds[len(results):end], workers, prepare...)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range batch {
+			results = append(results, r)
+			if p.Met(results) {
+				return results, nil
+			}
+		}
+	}
+	return results, nil
+}
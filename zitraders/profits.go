@@ -0,0 +1,108 @@
+package zitraders
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// Profits summarizes how the realized surplus is distributed over traders,
+// counting every buyer and seller, including those who never traded.
+type Profits struct {
+	Mean      float64       `json:"mean"`
+	SD        float64       `json:"sd"`
+	Gini      float64       `json:"gini"`             // 0 if all traders profit equally, near 1 if one takes everything
+	Min       int           `json:"min"`              // the profit counted by Histogram[0]
+	Histogram []int         `json:"histogram"`        // traders by profit, from Min up in steps of one
+	Lorenz    []LorenzPoint `json:"lorenz,omitempty"` // from (0, 0) to (1, 1), if profits are positive on average
+}
+
+// LorenzPoint is a point of the Lorenz curve: the share of the surplus
+// realized by the share of traders who profited least.
+type LorenzPoint struct {
+	Traders float64 `json:"traders"`
+	Surplus float64 `json:"surplus"`
+}
+
+// A profitTally counts traders by profit, which lies within ±bound.
+type profitTally struct {
+	histogram
+}
+
+func newProfitTally(bound int) *profitTally {
+	return &profitTally{*newHistogram(-bound, bound)}
+}
+
+// Summarize the profits counted. The Gini coefficient and the Lorenz curve
+// are computed from the traders ranked by profit, and are left out unless
+// profits are positive on average. The curve has a point for each profit
+// any trader made.
+func (t *profitTally) summary() Profits {
+	m := t.moments()
+	p := Profits{Mean: m.mean, SD: m.sd()}
+	if m.n == 0 {
+		return p
+	}
+	p.Min = int(m.min)
+	p.Histogram = t.counts[p.Min-t.min : int(m.max)-t.min+1]
+
+	if m.mean > 0 {
+		// Gini = Σ (2i - n - 1) x_i / (n Σ x) over traders ranked i = 1..n;
+		// the c traders with profit x at ranks r+1..r+c contribute
+		// x (c (2r + c + 1) - c (n + 1)).
+		n := float64(m.n)
+		var sum, rank float64
+		for k, c := range p.Histogram {
+			x, c := float64(p.Min+k), float64(c)
+			sum += x * (c*(2*rank+c+1) - c*(n+1))
+			rank += c
+		}
+		p.Gini = sum / (n * n * m.mean)
+
+		p.Lorenz = []LorenzPoint{{0, 0}}
+		var traders, surplus float64
+		for k, c := range p.Histogram {
+			if c == 0 {
+				continue
+			}
+			traders += float64(c)
+			surplus += float64(c * (p.Min + k))
+			p.Lorenz = append(p.Lorenz, LorenzPoint{traders / n, surplus / (n * m.mean)})
+		}
+	}
+	return p
+}
+
+// The profits counted of either side pooled.
+func (t *profitTally) pool(u *profitTally) *profitTally {
+	all := &profitTally{histogram{min: t.min, counts: append([]int(nil), t.counts...), n: t.n}}
+	all.merge(&u.histogram)
+	return all
+}
+
+// WriteLorenzCSV writes the Lorenz curves of the profits of all traders, of
+// buyers and of sellers as CSV with columns group (all, buyers or sellers),
+// traders_share and surplus_share.
+func WriteLorenzCSV(w io.Writer, r Results) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"group", "traders_share", "surplus_share"})
+	for _, c := range []struct {
+		name    string
+		profits Profits
+	}{{"all", r.Profits}, {"buyers", r.BuyerProfits}, {"sellers", r.SellerProfits}} {
+		for _, p := range c.profits.Lorenz {
+			cw.Write([]string{c.name, strconv.FormatFloat(p.Traders, 'g', -1, 64), strconv.FormatFloat(p.Surplus, 'g', -1, 64)})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// The bound on any trader's profit.
+func (m *Model) profitBound() int {
+	units := m.Units
+	if m.SellerUnits > units {
+		units = m.SellerUnits
+	}
+	return units * m.Periods * m.maxPrice()
+}
@@ -0,0 +1,79 @@
+package zitraders
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestProfitSummary(t *testing.T) {
+	for _, c := range []struct {
+		profits   []int
+		gini      float64
+		min       int
+		histogram []int
+		lorenz    []LorenzPoint
+	}{
+		{[]int{3, 3, 3}, 0, 3, []int{3}, []LorenzPoint{{0, 0}, {1, 1}}},
+		{[]int{0, 0, 0, 4}, 0.75, 0, []int{3, 0, 0, 0, 1}, []LorenzPoint{{0, 0}, {0.75, 0}, {1, 1}}},
+		{[]int{4, 2, 1, 3}, 0.25, 1, []int{1, 1, 1, 1}, []LorenzPoint{{0, 0}, {0.25, 0.1}, {0.5, 0.3}, {0.75, 0.6}, {1, 1}}},
+		{[]int{-1, 1}, 0, -1, []int{1, 0, 1}, nil},
+	} {
+		tally := newProfitTally(5)
+		for _, p := range c.profits {
+			tally.add(p)
+		}
+		p := tally.summary()
+		if math.Abs(p.Gini-c.gini) > 1e-12 || p.Min != c.min || !reflect.DeepEqual(p.Histogram, c.histogram) || len(p.Lorenz) != len(c.lorenz) {
+			t.Errorf("%v: got %+v", c.profits, p)
+			continue
+		}
+		for k, l := range c.lorenz {
+			if math.Abs(p.Lorenz[k].Traders-l.Traders) > 1e-12 || math.Abs(p.Lorenz[k].Surplus-l.Surplus) > 1e-12 {
+				t.Errorf("%v: Lorenz curve %v, want %v", c.profits, p.Lorenz, c.lorenz)
+			}
+		}
+	}
+}
+
+// The profits of the two sides pool into those of all traders.
+func TestProfitsPool(t *testing.T) {
+	buyers, sellers := newProfitTally(5), newProfitTally(5)
+	for _, p := range []int{1, 2, 2} {
+		buyers.add(p)
+	}
+	sellers.add(-3)
+	sellers.add(4)
+	all := buyers.pool(sellers)
+	if p := all.summary(); p.Min != -3 || !reflect.DeepEqual(p.Histogram, []int{1, 0, 0, 0, 1, 2, 0, 1}) {
+		t.Errorf("pooled %+v", p)
+	}
+	if p := buyers.summary(); !reflect.DeepEqual(p.Histogram, []int{1, 2}) {
+		t.Errorf("pooling changed the buyers' profits to %+v", p)
+	}
+}
+
+// The profit distribution accounts for every trader and all the surplus.
+func TestProfitsAddUp(t *testing.T) {
+	for _, layout := range []string{AoS, SoA} {
+		config := testConfig()
+		config.Layout = layout
+		r := newTestModel(t, config).Run()
+		traders, surplus := 0, 0
+		for k, c := range r.Profits.Histogram {
+			traders += c
+			surplus += c * (r.Profits.Min + k)
+		}
+		if traders != config.NumBuyers+config.NumSellers || surplus != r.RealizedSurplus {
+			t.Errorf("%s: histogram has %d traders and %d surplus, want %d and %d", layout, traders, surplus, config.NumBuyers+config.NumSellers, r.RealizedSurplus)
+		}
+		for _, p := range []Profits{r.Profits, r.BuyerProfits, r.SellerProfits} {
+			if p.Gini <= 0 || p.Gini >= 1 || len(p.Lorenz) < 2 || p.Lorenz[len(p.Lorenz)-1].Surplus < 1-1e-9 {
+				t.Errorf("%s: Gini coefficient %v and Lorenz curve %v", layout, p.Gini, p.Lorenz)
+			}
+		}
+		if n := r.BuyerProfits.Mean*float64(config.NumBuyers) + r.SellerProfits.Mean*float64(config.NumSellers); math.Abs(n-float64(r.RealizedSurplus)) > 1e-6 {
+			t.Errorf("%s: the sides realized %v of %d", layout, n, r.RealizedSurplus)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package zitraders
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// Threads publish their counts once per progressBatch attempts to keep
+// contention on the shared counters negligible.
+const progressBatch = 1 << 12
+
+// Progress counts the trade attempts made and trades executed so far.
+type Progress struct {
+	Attempts int64
+	Trades   int64
+	Volume   int64 // sum of transaction prices
+	Squares  int64 // sum of squared transaction prices
+	Running  int   // threads still trading
+}
+
+// MeanPrice is the average transaction price so far.
+func (p Progress) MeanPrice() float64 {
+	if p.Trades == 0 {
+		return 0
+	}
+	return float64(p.Volume) / float64(p.Trades)
+}
+
+// SDPrice is the standard deviation of transaction prices so far.
+func (p Progress) SDPrice() float64 {
+	if p.Trades < 2 {
+		return 0
+	}
+	n := float64(p.Trades)
+	mean := float64(p.Volume) / n
+	return math.Sqrt(math.Max(0, (float64(p.Squares)-n*mean*mean)/(n-1)))
+}
+
+// Progress returns the counts published so far. It is safe to call while the
+// model is running.
+func (m *Model) Progress() Progress {
+	return Progress{
+		Attempts: atomic.LoadInt64(&m.attempts),
+		Trades:   atomic.LoadInt64(&m.executed),
+		Volume:   atomic.LoadInt64(&m.volume),
+		Squares:  atomic.LoadInt64(&m.squares),
+		Running:  int(atomic.LoadInt64(&m.running)),
+	}
+}
+
+// A tally batches a thread's progress counts before publishing them.
+type tally struct {
+	m        *Model
+	thread   int
+	log      *[]Trade // the thread's trade log, published at the end of each tick
+	attempts int64
+	trades   int64
+	volume   int64
+	squares  int64
+	quota    int // attempts drawn from a shared budget and not yet made
+	start    time.Time
+	paused   time.Duration // spent waiting at the end of ticks
+}
+
+// Start counting a trading thread's progress. The thread must close the tally
+// when it finishes.
+func (m *Model) tally(thread int, log *[]Trade) *tally {
+	atomic.AddInt64(&m.running, 1)
+	return &tally{m: m, thread: thread, log: log, start: time.Now()}
+}
+
+func (t *tally) attempt() {
+	t.attempts++
+	if t.attempts == progressBatch {
+		t.flush()
+	}
+}
+
+func (t *tally) trade(price int) {
+	t.trades++
+	t.volume += int64(price)
+	t.squares += int64(price) * int64(price)
+}
+
+func (t *tally) flush() {
+	perf := &t.m.perf[t.thread]
+	perf.Attempts += t.attempts
+	perf.Trades += t.trades
+	atomic.AddInt64(&t.m.attempts, t.attempts)
+	atomic.AddInt64(&t.m.executed, t.trades)
+	atomic.AddInt64(&t.m.volume, t.volume)
+	atomic.AddInt64(&t.m.squares, t.squares)
+	t.attempts, t.trades, t.volume, t.squares = 0, 0, 0, 0
+}
+
+func (t *tally) close() {
+	t.flush()
+	t.m.perf[t.thread].Elapsed += time.Since(t.start) - t.paused
+	atomic.AddInt64(&t.m.running, -1)
+}
@@ -0,0 +1,56 @@
+package zitraders
+
+import "fmt"
+
+// Replay re-executes a recorded trade log against the model's population, as
+// initialized, in place of a run, and returns the statistics of the replayed
+// trades. It fails at the first trade that names an agent who does not exist
+// or cannot trade, which shows where a log and a population part ways. Only
+// the traders' side of a market maker's or an arbitrageur's trade is replayed,
+// and the number of attempts is not known.
+func (m *Model) Replay(trades []Trade) (Results, error) {
+	for _, s := range m.Shocks {
+		if s.At > 0 {
+			return Results{}, fmt.Errorf("shocks at a number of attempts cannot be replayed")
+		}
+	}
+	buyers, sellers := m.stores()
+	for k, t := range trades {
+		if t.Period < m.period || t.Period >= m.Periods {
+			return Results{}, fmt.Errorf("trade %d: period %d out of order or beyond the session", k+1, t.Period)
+		}
+		for m.period < t.Period {
+			m.endPeriod()
+		}
+		if t.Buyer >= buyers.Len() || (t.Buyer >= 0 && !buyers.CanTrade(t.Buyer)) {
+			return Results{}, fmt.Errorf("trade %d: buyer %d cannot buy", k+1, t.Buyer)
+		}
+		if t.Seller >= sellers.Len() || (t.Seller >= 0 && !sellers.CanTrade(t.Seller)) {
+			return Results{}, fmt.Errorf("trade %d: seller %d cannot sell", k+1, t.Seller)
+		}
+		if t.Buyer >= 0 {
+			buyers.Trade(t.Buyer, t.Price)
+		}
+		if t.Seller >= 0 {
+			sellers.Trade(t.Seller, t.Price)
+		}
+		m.executed++
+		m.volume += int64(t.Price)
+		m.squares += int64(t.Price) * int64(t.Price)
+	}
+	for m.period < m.Periods {
+		m.endPeriod()
+	}
+
+	m.RecordTrades, m.trades = true, trades
+	r := m.computeStatistics()
+	r.Stopped = StopReplayed
+	r.Book = nil // the order books are not replayed
+	if m.Periods > 1 {
+		r.Periods = m.periods
+		for i := range r.Periods {
+			r.Periods[i].finish(r.EquilibriumPrice, r.MaxSurplus/len(r.Periods))
+		}
+	}
+	return r, nil
+}
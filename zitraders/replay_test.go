@@ -0,0 +1,53 @@
+package zitraders
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// Replaying a run's trade log through CSV against a fresh population
+// reproduces its statistics.
+func TestReplay(t *testing.T) {
+	for _, modify := range []func(*Config){
+		func(c *Config) {},
+		func(c *Config) { c.Layout = SoA },
+		func(c *Config) { c.Units, c.Periods, c.ZIP = 3, 2, 0.5 },
+		func(c *Config) { c.Institution = CDA },
+	} {
+		config := testConfig()
+		config.RecordTrades = true
+		modify(&config)
+		m := newTestModel(t, config)
+		want := m.Run()
+
+		var b bytes.Buffer
+		if err := WriteTradesCSV(&b, m.Trades()); err != nil {
+			t.Fatal(err)
+		}
+		trades, err := ReadTradesCSV(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(trades, m.Trades()) {
+			t.Fatalf("the trade log changed through CSV")
+		}
+
+		got, err := newTestModel(t, config).Replay(trades)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want.Attempts, want.Stopped, want.Book = 0, StopReplayed, nil
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%+v: replay gave %+v, want %+v", config, got, want)
+		}
+	}
+}
+
+func TestReplayRejectsImpossibleTrades(t *testing.T) {
+	m := newTestModel(t, testConfig())
+	trade := Trade{Buyer: 3, Seller: 5, Bid: 20, Ask: 10, Price: 15}
+	if _, err := m.Replay([]Trade{trade, trade}); err == nil {
+		t.Error("a buyer of one unit bought two")
+	}
+}
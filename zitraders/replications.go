@@ -0,0 +1,326 @@
+package zitraders
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Replicate runs the model reps times. The seed of each replication is drawn
+// from a generator seeded with config.Seed, so a set of replications is
+// reproducible from a single seed. Each model is passed to the prepare
+// functions, if any, before it runs.
+func Replicate(config Config, reps int, prepare ...func(*Model)) ([]Results, error) {
+	return ReplicateContext(context.Background(), config, reps, prepare...)
+}
+
+// ReplicateContext is like Replicate but stops once ctx is done, returning
+// the results of the replications made until then, the last of them cut
+// short.
+func ReplicateContext(ctx context.Context, config Config, reps int, prepare ...func(*Model)) ([]Results, error) {
+	results := make([]Results, reps)
+	for i, seed := range ReplicationSeeds(config.Seed, reps) {
+		c := config
+		c.Seed = seed
+		m, err := New(c)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range prepare {
+			f(m)
+		}
+		results[i] = m.RunContext(ctx)
+		if ctx.Err() != nil {
+			return results[:i+1], nil
+		}
+	}
+	return results, nil
+}
+
+// ReplicateParallel is like ReplicateContext but runs up to workers
+// replications at once, each on its own goroutines. The replications and
+// their results are those of Replicate with the same seed, in the same order.
+// The prepare functions may be called concurrently. Once ctx is done no
+// further replication starts; those under way are cut short, and the results
+// of every replication started are returned.
+func ReplicateParallel(ctx context.Context, config Config, reps, workers int, prepare ...func(*Model)) ([]Results, error) {
+	return replicateSeeds(ctx, config, ReplicationSeeds(config.Seed, reps), workers, prepare...)
+}
+
+// Run a replication with each of the seeds, up to workers at once, as
+// ReplicateParallel does.
+func replicateSeeds(ctx context.Context, config Config, seeds []int64, workers int, prepare ...func(*Model)) ([]Results, error) {
+	configs := make([]Config, len(seeds))
+	for i, seed := range seeds {
+		configs[i] = config
+		configs[i].Seed = seed
+	}
+	return replicateConfigs(ctx, configs, workers, prepare...)
+}
+
+// Run a replication of each of the configurations, up to workers at once.
+func replicateConfigs(ctx context.Context, configs []Config, workers int, prepare ...func(*Model)) ([]Results, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	reps := len(configs)
+	results := make([]Results, reps)
+	errs := make([]error, workers)
+	var next int64 = -1
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= reps {
+					return
+				}
+				m, err := New(configs[i])
+				if err != nil {
+					errs[w] = err
+					return
+				}
+				for _, f := range prepare {
+					f(m)
+				}
+				results[i] = m.RunContext(ctx)
+			}
+		}(w)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	if started := int(next) + 1; started < reps {
+		return results[:started], nil
+	}
+	return results, nil
+}
+
+// ReplicateAntithetic runs pairs of replications, up to workers at once: each
+// pair has the seed of a replication of Replicate and runs once on its
+// streams and once on their antithetic complements, whatever the Antithetic
+// setting of config. The results come pair by pair, the antithetic run
+// second, and are summarized with SummarizePairs.
+func ReplicateAntithetic(ctx context.Context, config Config, pairs, workers int, prepare ...func(*Model)) ([]Results, error) {
+	seeds := ReplicationSeeds(config.Seed, pairs)
+	configs := make([]Config, 2*pairs)
+	for i, seed := range seeds {
+		for k := range configs[2*i : 2*i+2] {
+			c := config
+			c.Seed, c.Antithetic = seed, k == 1
+			configs[2*i+k] = c
+		}
+	}
+	return replicateConfigs(ctx, configs, workers, prepare...)
+}
+
+// ReplicateCommon runs reps replications of each configuration under common
+// random numbers: the i-th replication of every configuration has the i-th
+// seed Replicate draws for the first, so that the configurations differ only
+// in their treatment, not in their luck. The streams match as long as the
+// configurations divide the market among the same number of threads. Up to
+// workers replications run at once. The results are returned by
+// configuration, and paired differences between two of them are summarized
+// with Difference.
+func ReplicateCommon(ctx context.Context, configs []Config, reps, workers int, prepare ...func(*Model)) ([][]Results, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	seeds := ReplicationSeeds(configs[0].Seed, reps)
+	all := make([]Config, 0, len(configs)*reps)
+	for _, config := range configs {
+		for _, seed := range seeds {
+			c := config
+			c.Seed = seed
+			all = append(all, c)
+		}
+	}
+	results, err := replicateConfigs(ctx, all, workers, prepare...)
+	if err != nil {
+		return nil, err
+	}
+	byConfig := make([][]Results, len(configs))
+	for k := range configs {
+		lo, hi := k*reps, (k+1)*reps
+		if lo > len(results) {
+			lo = len(results)
+		}
+		if hi > len(results) {
+			hi = len(results)
+		}
+		byConfig[k] = results[lo:hi]
+	}
+	return byConfig, nil
+}
+
+// ReplicationSeeds returns the seeds of the reps replications Replicate runs
+// for a configuration with the given seed, drawn from a generator seeded with
+// it, or with the clock if it is zero. No replication has a zero seed.
+func ReplicationSeeds(seed int64, reps int) []int64 {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	r := rand.New(newXoshiro(seed))
+	seeds := make([]int64, reps)
+	for i := range seeds {
+		for seeds[i] == 0 {
+			seeds[i] = r.Int63()
+		}
+	}
+	return seeds
+}
+
+// Estimate is the mean of an outcome across replications with its standard
+// deviation and 95% confidence interval, and the percentile bootstrap
+// interval if one was drawn.
+type Estimate struct {
+	Mean      float64   `json:"mean"`
+	SD        float64   `json:"sd"`
+	Low       float64   `json:"low"`
+	High      float64   `json:"high"`
+	Bootstrap *Interval `json:"bootstrap,omitempty"`
+}
+
+// Interval is a 95% confidence interval.
+type Interval struct {
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
+}
+
+// Summary aggregates the key outcomes of a set of replications.
+type Summary struct {
+	Reps       int      `json:"reps"`
+	Quantity   Estimate `json:"quantity"`
+	MeanPrice  Estimate `json:"mean_price"`
+	Efficiency Estimate `json:"efficiency"`
+	Alpha      Estimate `json:"alpha"`
+}
+
+// Summarize computes the mean, standard deviation and confidence interval of
+// the quantity traded, average price, efficiency and Smith's alpha across
+// replications.
+func Summarize(results []Results) Summary {
+	return summaryOf(outcomesOf(results), len(results))
+}
+
+// SummarizeBootstrap is like Summarize but also draws a percentile bootstrap
+// interval for each outcome from resamples resamples of the replications,
+// drawn from a generator seeded with seed. Unlike the t interval, it does
+// not lean on the outcomes being normal.
+func SummarizeBootstrap(results []Results, resamples int, seed int64) Summary {
+	o := outcomesOf(results)
+	s := summaryOf(o, len(results))
+	r := rand.New(newXoshiro(seed))
+	for k, e := range s.estimates() {
+		e.Bootstrap = bootstrapMean(o[k], resamples, r)
+	}
+	return s
+}
+
+// SummarizePairs summarizes the pairs of replications of
+// ReplicateAntithetic. The mean of each pair is one observation, since the
+// runs of a pair are not independent; Reps counts the pairs.
+func SummarizePairs(results []Results) Summary {
+	o := outcomesOf(results)
+	for k, xs := range o {
+		means := make([]float64, len(xs)/2)
+		for i := range means {
+			means[i] = (xs[2*i] + xs[2*i+1]) / 2
+		}
+		o[k] = means
+	}
+	return summaryOf(o, len(results)/2)
+}
+
+// Difference summarizes the differences of a treatment's outcomes from a
+// base's, replication by replication, as ReplicateCommon pairs them.
+func Difference(base, treatment []Results) Summary {
+	n := len(base)
+	if len(treatment) < n {
+		n = len(treatment)
+	}
+	a, b := outcomesOf(base[:n]), outcomesOf(treatment[:n])
+	for k := range a {
+		for i := range a[k] {
+			a[k][i] = b[k][i] - a[k][i]
+		}
+	}
+	return summaryOf(a, n)
+}
+
+// The quantity traded, average price, efficiency and Smith's alpha of each
+// replication, in the order of the estimates of a Summary.
+func outcomesOf(results []Results) [4][]float64 {
+	var o [4][]float64
+	for k := range o {
+		o[k] = make([]float64, len(results))
+	}
+	for i, r := range results {
+		o[0][i] = float64(r.NumberBought)
+		o[1][i] = r.MeanPrice
+		o[2][i] = r.Efficiency
+		o[3][i] = r.Alpha
+	}
+	return o
+}
+
+func summaryOf(o [4][]float64, reps int) Summary {
+	return Summary{
+		Reps:       reps,
+		Quantity:   estimate(o[0]),
+		MeanPrice:  estimate(o[1]),
+		Efficiency: estimate(o[2]),
+		Alpha:      estimate(o[3]),
+	}
+}
+
+// The estimates of the summary, in the order of outcomesOf.
+func (s *Summary) estimates() [4]*Estimate {
+	return [4]*Estimate{&s.Quantity, &s.MeanPrice, &s.Efficiency, &s.Alpha}
+}
+
+func estimate(xs []float64) Estimate {
+	n := float64(len(xs))
+	var e Estimate
+	for _, x := range xs {
+		e.Mean += x
+	}
+	e.Mean /= n
+	if len(xs) < 2 {
+		e.Low, e.High = e.Mean, e.Mean
+		return e
+	}
+
+	for _, x := range xs {
+		e.SD += (x - e.Mean) * (x - e.Mean)
+	}
+	e.SD = math.Sqrt(e.SD / (n - 1))
+
+	half := tCritical(len(xs)-1) * e.SD / math.Sqrt(n)
+	e.Low, e.High = e.Mean-half, e.Mean+half
+	return e
+}
+
+// Two-sided 95% critical values of Student's t for 1 to 30 degrees of freedom.
+var tTable = [...]float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// tCritical returns the 95% critical value of Student's t, falling back to
+// the normal approximation for large samples.
+func tCritical(df int) float64 {
+	if df <= len(tTable) {
+		return tTable[df-1]
+	}
+	return 1.96
+}
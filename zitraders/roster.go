@@ -0,0 +1,73 @@
+package zitraders
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Member describes an agent as it was initialized, to join the trade log
+// back to the characteristics of the agents who traded.
+type Member struct {
+	ID       int // stable over the run: buyers count from zero, then sellers follow
+	Side     string
+	Agent    int // the index among its side, as in the trade log and snapshots
+	Strategy string
+	Market   int
+	Values   []int // the marginal value or cost of each unit
+}
+
+// EachMember calls f with every buyer and then every seller. The values are
+// those in force when it is called, so it should be called before the run,
+// ahead of any shock; f must not keep them.
+func (m *Model) EachMember(f func(Member)) {
+	buyers, sellers := m.stores()
+	id := 0
+	for _, side := range []struct {
+		name   string
+		store  agentStore
+		agents []agent
+	}{{"buyer", buyers, m.buyers}, {"seller", sellers, m.sellers}} {
+		value := make([]int, 1)
+		for i := 0; i < side.store.Len(); i++ {
+			x := Member{ID: id, Side: side.name, Agent: i, Market: m.market(i)}
+			if side.agents != nil {
+				x.Strategy, x.Values = m.kinds[side.agents[i].kind], side.agents[i].schedule
+			} else {
+				x.Strategy, value[0] = "zi-c", side.store.Value(i)
+				if side.store.Unconstrained(i) {
+					x.Strategy = "zi-u"
+				}
+				x.Values = value
+			}
+			f(x)
+			id++
+		}
+	}
+}
+
+// WriteRoster writes every agent as CSV with columns id, side, agent,
+// strategy, market and values, the values of its units separated by spaces.
+// Like EachMember, it should be called before the run.
+func (m *Model) WriteRoster(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "side", "agent", "strategy", "market", "values"})
+	var values []string
+	m.EachMember(func(x Member) {
+		values = values[:0]
+		for _, v := range x.Values {
+			values = append(values, strconv.Itoa(v))
+		}
+		cw.Write([]string{
+			strconv.Itoa(x.ID),
+			x.Side,
+			strconv.Itoa(x.Agent),
+			x.Strategy,
+			strconv.Itoa(x.Market),
+			strings.Join(values, " "),
+		})
+	})
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,77 @@
+package zitraders
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// Round summarizes the trades of a round of RoundSize attempts by each
+// goroutine, so that the market's dynamics can be followed over the run
+// rather than only its end. Rounds count from zero in each period.
+type Round struct {
+	Period     int     `json:"period"`
+	Round      int     `json:"round"`
+	Trades     int     `json:"trades"`
+	MeanPrice  float64 `json:"mean_price"` // zero if none traded
+	Alpha      float64 `json:"alpha"`      // Smith's alpha within the round
+	Efficiency float64 `json:"efficiency"` // the traders' surplus so far, as a percentage of the run's maximum
+}
+
+// Summarize the trade log round by round against the equilibrium price p,
+// up to the last round of each period with a trade. A round's attempts are
+// those whose tick falls in it, whichever goroutine made them.
+func (m *Model) rounds(p float64, maxSurplus int) []Round {
+	var rounds []Round
+	var prices moments
+	surplus := 0
+	finish := func() {
+		if len(rounds) == 0 {
+			return
+		}
+		r := &rounds[len(rounds)-1]
+		r.Trades, r.MeanPrice, r.Alpha = prices.n, prices.mean, prices.alpha(p)
+		if maxSurplus > 0 {
+			r.Efficiency = 100 * float64(surplus) / float64(maxSurplus)
+		}
+		prices = moments{}
+	}
+	m.walkTrades(func(_ int, t *Trade, value, cost int) {
+		k := t.Tick / m.RoundSize
+		for n := len(rounds); n == 0 || rounds[n-1].Period != t.Period || rounds[n-1].Round < k; n = len(rounds) {
+			finish()
+			next := Round{Period: t.Period}
+			if n > 0 && rounds[n-1].Period == t.Period {
+				next.Round = rounds[n-1].Round + 1
+			}
+			rounds = append(rounds, next)
+		}
+		prices.add(float64(t.Price))
+		if t.Buyer >= 0 {
+			surplus += value - t.Price
+		}
+		if t.Seller >= 0 {
+			surplus += t.Price - cost
+		}
+	})
+	finish()
+	return rounds
+}
+
+// WriteRoundsCSV writes the rounds of a run as CSV with a header row.
+func WriteRoundsCSV(w io.Writer, rounds []Round) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"period", "round", "trades", "mean_price", "alpha", "efficiency"})
+	for _, r := range rounds {
+		cw.Write([]string{
+			strconv.Itoa(r.Period),
+			strconv.Itoa(r.Round),
+			strconv.Itoa(r.Trades),
+			strconv.FormatFloat(r.MeanPrice, 'f', -1, 64),
+			strconv.FormatFloat(r.Alpha, 'f', -1, 64),
+			strconv.FormatFloat(r.Efficiency, 'f', -1, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
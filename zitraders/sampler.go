@@ -0,0 +1,124 @@
+package zitraders
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// Samplers.
+const (
+	Random = "random" // independent pseudo-random draws from the thread's generator
+	Sobol  = "sobol"  // the two-dimensional Sobol sequence, digitally shifted for each thread
+)
+
+// A Sampler chooses the buyer and seller who meet in each of a thread's trade
+// attempts, in place of independent pseudo-random draws. Pair is given the
+// number of the attempt, counting over the whole session, and returns a buyer
+// index below buyers and a seller index below sellers.
+type Sampler interface {
+	Pair(attempt, buyers, sellers int) (buyer, seller int)
+}
+
+// A SamplerFactory makes the sampler of one thread, drawing any random
+// parameters from r.
+type SamplerFactory func(thread int, r *rand.Rand) Sampler
+
+var (
+	samplersMu sync.RWMutex
+	samplers   = map[string]SamplerFactory{
+		Random: nil, // the threads draw from their generators directly
+		Sobol:  func(_ int, r *rand.Rand) Sampler { return newSobol(r) },
+	}
+)
+
+// RegisterSampler makes a sampler available by name, for Config.Sampler. It
+// replaces any sampler registered under the same name.
+func RegisterSampler(name string, f SamplerFactory) {
+	samplersMu.Lock()
+	defer samplersMu.Unlock()
+	samplers[name] = f
+}
+
+// Samplers returns the names of the registered samplers.
+func Samplers() []string {
+	samplersMu.RLock()
+	defer samplersMu.RUnlock()
+	names := make([]string, 0, len(samplers))
+	for name := range samplers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Check the sampler and make one for each thread. The default random
+// sampler needs none, leaving the threads to draw pairs themselves.
+func (m *Model) checkSampler() error {
+	if m.Sampler == "" {
+		m.Sampler = Random
+	}
+	samplersMu.RLock()
+	f, ok := samplers[m.Sampler]
+	samplersMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown sampler %q", m.Sampler)
+	}
+	if f == nil {
+		return nil
+	}
+	if !m.bilateral() || m.Matching == Pool || m.Markets > 1 || m.Network.Topology != Complete || m.Spatial != (Spatial{}) {
+		return fmt.Errorf("the %s sampler requires a single bilateral market where any buyer may meet any seller, without pool matching", m.Sampler)
+	}
+	m.samplers = make([]Sampler, m.NumThreads)
+	for t := range m.samplers {
+		m.samplers[t] = f(t, m.rng)
+	}
+	return nil
+}
+
+// The sampler of thread t, or nil for pseudo-random draws.
+func (m *Model) sampler(t int) Sampler {
+	if m.samplers == nil {
+		return nil
+	}
+	return m.samplers[t]
+}
+
+// Direction numbers of the first two dimensions of the Sobol sequence: the
+// van der Corput sequence in base 2 and the sequence of the primitive
+// polynomial x + 1.
+var sobolDirections = func() (v [2][32]uint32) {
+	m := uint32(1)
+	for k := 0; k < 32; k++ {
+		v[0][k] = 1 << (31 - k)
+		v[1][k] = m << (31 - k)
+		m ^= m << 1
+	}
+	return
+}()
+
+// A sobol sampler pairs agents at the points of the two-dimensional Sobol
+// sequence, XORed with a random shift so that each thread follows its own
+// sequence. The shift keeps every aligned block of 2^k points a (0, k,
+// 2)-net: they cover a 2^i by 2^(k-i) grid of buyers and sellers exactly
+// once.
+type sobol struct {
+	shift [2]uint32
+}
+
+func newSobol(r *rand.Rand) *sobol {
+	return &sobol{shift: [2]uint32{r.Uint32(), r.Uint32()}}
+}
+
+func (s *sobol) Pair(attempt, buyers, sellers int) (int, int) {
+	x, y := s.shift[0], s.shift[1]
+	for g, k := uint32(attempt^attempt>>1), 0; g != 0; g, k = g>>1, k+1 {
+		if g&1 != 0 {
+			x ^= sobolDirections[0][k]
+			y ^= sobolDirections[1][k]
+		}
+	}
+	return int(uint64(x) * uint64(buyers) >> 32), int(uint64(y) * uint64(sellers) >> 32)
+}
@@ -0,0 +1,37 @@
+package zitraders
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// Every aligned block of 16 Sobol points meets each pair of a 4 by 4 grid of
+// buyers and sellers once, and so does every block of 32 a 2 by 16 grid.
+func TestSobolNets(t *testing.T) {
+	s := newSobol(rand.New(rand.NewSource(1)))
+	for _, grid := range [][2]int{{4, 4}, {2, 16}, {16, 2}} {
+		n := grid[0] * grid[1]
+		for block := 0; block < 4; block++ {
+			seen := make(map[[2]int]bool)
+			for i := block * n; i < (block+1)*n; i++ {
+				b, s := s.Pair(i, grid[0], grid[1])
+				seen[[2]int{b, s}] = true
+			}
+			if len(seen) != n {
+				t.Errorf("%v grid: block %d met %d of the %d pairs", grid, block, len(seen), n)
+			}
+		}
+	}
+}
+
+// The Sobol sampler reaches the same efficiency as random matching.
+func TestSobolEfficiency(t *testing.T) {
+	config := testConfig()
+	random := newTestModel(t, config).Run()
+	config.Sampler = Sobol
+	sobol := newTestModel(t, config).Run()
+
+	if d := sobol.Efficiency - random.Efficiency; d < -5 || d > 5 {
+		t.Errorf("efficiency %.2f%% with Sobol matching, %.2f%% with random", sobol.Efficiency, random.Efficiency)
+	}
+}
@@ -0,0 +1,170 @@
+package zitraders
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A Tick describes the market at the end of a tick.
+type Tick struct {
+	Number   int           `json:"number"`   // ticks completed, counting this one
+	Period   int           `json:"period"`   // the trading period under way, counting from zero
+	Attempts int64         `json:"attempts"` // trade attempts made so far
+	Trades   int64         `json:"trades"`   // trades executed so far
+	Elapsed  time.Duration `json:"elapsed"`
+	Final    bool          `json:"final"` // the run has ended
+}
+
+// An Observer is called at the end of every tick while the trading threads are
+// paused, so it may safely inspect the model. Returning false stops the run;
+// the return value of the final call is ignored.
+type Observer func(m *Model, t Tick) bool
+
+// A Scheduler advances the model in ticks of TickSize trade attempts per
+// thread. At the end of each tick every thread publishes its progress and
+// waits while the observers run. Without observers the threads never pause.
+type Scheduler struct {
+	observers []Observer
+	size      int // attempts per participant per tick, zero for a single tick
+	arrive    chan struct{}
+	resume    chan bool
+	ticks     int
+	start     time.Time
+	first     int       // ticks completed in earlier trading periods
+	skip      int       // attempts per participant made before a checkpoint the model resumed from
+	logs      [][]Trade // each thread's trade log as of the end of the tick
+	marks     []int     // the length of each log at the end of the previous tick
+}
+
+// Observe registers an observer to be called at the end of every tick and
+// once more when the run ends.
+func (s *Scheduler) Observe(o Observer) {
+	s.observers = append(s.observers, o)
+}
+
+// Prepare the scheduler for a run whose participating threads make size
+// attempts each per tick, with the given number of trade logs.
+func (s *Scheduler) open(size, threads int) {
+	s.skip = (s.ticks - s.first) * size
+	s.start = time.Now()
+	s.logs = make([][]Trade, threads)
+	s.marks = make([]int, threads)
+	if len(s.observers) == 0 || size <= 0 {
+		s.size = 0
+		return
+	}
+	s.size = size
+	s.arrive = make(chan struct{})
+	s.resume = make(chan bool)
+}
+
+// Called by a participating thread before attempt i. At the end of a tick the
+// thread publishes its progress, if any, and waits for the observers. The
+// result is false if they stopped the run or, without ticks, once ctx is done.
+func (s *Scheduler) advance(ctx context.Context, i int, progress *tally) bool {
+	if s.size == 0 {
+		// Threads that never pause stop on their own; those that do stop
+		// together when the observers learn that ctx is done.
+		return i%progressBatch != 0 || ctx.Err() == nil
+	}
+	if !s.ends(i) {
+		return true
+	}
+	return s.pause(progress)
+}
+
+// Whether a tick ends before attempt i.
+func (s *Scheduler) ends(i int) bool {
+	return s.size > 0 && i > 1+s.skip && (i-1)%s.size == 0
+}
+
+// Publish a thread's progress, if any, and wait for the observers.
+func (s *Scheduler) pause(progress *tally) bool {
+	if progress == nil {
+		s.arrive <- struct{}{}
+		return <-s.resume
+	}
+	progress.flush()
+	s.logs[progress.thread] = *progress.log
+	waited := time.Now()
+	s.arrive <- struct{}{}
+	more := <-s.resume
+	progress.paused += time.Since(waited)
+	return more
+}
+
+// Wait for the participating threads in wg to finish, running the observers
+// whenever all of them reach the end of a tick, and stopping the run then if
+// ctx is done. The threads' final trade logs are in logs once they finish.
+func (m *Model) schedule(ctx context.Context, wg *sync.WaitGroup, participants int, logs [][]Trade) {
+	if len(m.observers) == 0 {
+		wg.Wait()
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	for {
+		for k := 0; k < participants; k++ {
+			select {
+			case <-m.arrive:
+			case <-done:
+				copy(m.logs, logs)
+				m.observe(ctx, true)
+				return
+			}
+		}
+		more := m.observe(ctx, false)
+		if m.SharedBudget {
+			m.issue()
+		}
+		for k := 0; k < participants; k++ {
+			m.resume <- more
+		}
+	}
+}
+
+// Call every observer at the end of a tick. The run continues only if all of
+// them agree and ctx is not done. The end of a trading period is final only if the session ends
+// with it.
+func (m *Model) observe(ctx context.Context, done bool) bool {
+	m.ticks++
+	p := m.Progress()
+	canceled := ctx.Err() != nil
+	final := done && (m.period == m.Periods-1 || m.ended() || canceled)
+	t := Tick{Number: m.ticks, Period: m.period, Attempts: p.Attempts, Trades: p.Trades, Elapsed: time.Since(m.start), Final: final}
+	more := true
+	for _, o := range m.observers {
+		if !o(m, t) && more {
+			more = false
+			if m.stopped == "" {
+				m.stopped = StopObserver
+			}
+		}
+	}
+	if canceled {
+		m.stopped, more = StopCanceled, false
+	}
+	for i, log := range m.logs {
+		m.marks[i] = len(log)
+	}
+	for _, t := range m.books {
+		t.mark = len(t.quotes)
+	}
+	return more
+}
+
+// TickTrades returns the trades recorded during the tick that just ended,
+// ordered by tick and thread. It is meant to be called by observers of a
+// model that records trades.
+func (s *Scheduler) TickTrades() []Trade {
+	logs := make([][]Trade, len(s.logs))
+	for i, log := range s.logs {
+		logs[i] = log[s.marks[i]:]
+	}
+	return mergeTrades(logs)
+}
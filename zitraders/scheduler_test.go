@@ -0,0 +1,140 @@
+package zitraders
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSchedulerTicks(t *testing.T) {
+	for _, mode := range []struct{ matching, layout, institution string }{
+		{Partitioned, AoS, Bilateral},
+		{Partitioned, AoS, CDA},
+		{Partitioned, SoA, Bilateral},
+		{Global, AoS, Bilateral},
+		{Pool, AoS, Bilateral},
+	} {
+		config := testConfig()
+		config.Matching, config.Layout, config.Institution = mode.matching, mode.layout, mode.institution
+		config.TickSize = 1000
+		m := newTestModel(t, config)
+
+		var ticks []Tick
+		m.Observe(func(m *Model, tick Tick) bool {
+			ticks = append(ticks, tick)
+			return true
+		})
+		m.Run()
+
+		// 25000 attempts per thread make 24 full ticks and a final one.
+		if len(ticks) != 25 || !ticks[24].Final {
+			t.Fatalf("%v: got %d ticks", mode, len(ticks))
+		}
+		if mode.matching != Pool && ticks[0].Attempts != 4000 {
+			t.Errorf("%v: %d attempts after the first tick, want 4000", mode, ticks[0].Attempts)
+		}
+	}
+}
+
+func TestObserverStopsRun(t *testing.T) {
+	config := testConfig()
+	config.TickSize = 1000
+	m := newTestModel(t, config)
+	m.Observe(func(m *Model, tick Tick) bool {
+		return tick.Number < 3
+	})
+	m.Run()
+	if p := m.Progress(); p.Attempts != 3*4000 {
+		t.Errorf("%d attempts after stopping at the third tick, want 12000", p.Attempts)
+	}
+}
+
+func TestTickTrades(t *testing.T) {
+	for _, matching := range []string{Partitioned, Pool} {
+		config := testConfig()
+		config.Matching = matching
+		config.RecordTrades = true
+		config.TickSize = 1000
+		m := newTestModel(t, config)
+
+		var trades []Trade
+		m.Observe(func(m *Model, tick Tick) bool {
+			trades = append(trades, m.TickTrades()...)
+			return true
+		})
+		m.Run()
+		if len(trades) != len(m.Trades()) {
+			t.Errorf("%s: observed %d trades of %d", matching, len(trades), len(m.Trades()))
+		}
+	}
+}
+
+func TestTickQuotes(t *testing.T) {
+	config := testConfig()
+	config.Institution = CDA
+	config.QuoteEvery = 100
+	config.TickSize = 1000
+	m := newTestModel(t, config)
+
+	var quotes []Quote
+	m.Observe(func(m *Model, tick Tick) bool {
+		quotes = append(quotes, m.TickQuotes()...)
+		return true
+	})
+	m.Run()
+	if all := m.Quotes(); len(all) == 0 || !reflect.DeepEqual(quotes, all) {
+		t.Errorf("observed %d quotes of %d", len(quotes), len(all))
+	}
+}
+
+// The hooks see every trade, every round and the results.
+func TestHooks(t *testing.T) {
+	for _, tick := range []int{0, 1000} {
+		config := testConfig()
+		config.TickSize, config.Periods = tick, 2
+		m := newTestModel(t, config)
+
+		var trades []Trade
+		var rounds int
+		var results *Results
+		m.OnTrade(func(tr Trade) { trades = append(trades, tr) })
+		m.OnRoundEnd(func(m *Model, tick Tick) bool {
+			if tick.Final {
+				t.Errorf("round end called at the end of the run")
+			}
+			rounds++
+			return true
+		})
+		m.OnRunEnd(func(m *Model, r Results) { results = &r })
+		r := m.Run()
+
+		// The trades are classified as extramarginal only once the run ends.
+		logged := append([]Trade(nil), m.Trades()...)
+		for i := range logged {
+			logged[i].Extramarginal = false
+		}
+		if !reflect.DeepEqual(trades, logged) || len(trades) != r.NumberBought {
+			t.Errorf("tick %d: saw %d trades of %d", tick, len(trades), r.NumberBought)
+		}
+		// Each period of 25000 attempts per goroutine has 25 rounds, the last
+		// of them cut short, or one without ticks, but the end of the run is
+		// no round's end.
+		want := 1
+		if tick > 0 {
+			want = 2*25 - 1
+		}
+		if rounds != want {
+			t.Errorf("tick %d: %d rounds ended", tick, rounds)
+
+		}
+		if results == nil || !reflect.DeepEqual(*results, r) {
+			t.Errorf("tick %d: run ended with %+v", tick, results)
+		}
+	}
+
+	m := newTestModel(t, testConfig())
+	m.TickSize = 1000
+	m.OnRoundEnd(func(m *Model, tick Tick) bool { return tick.Number < 2 })
+	if r := m.Run(); r.Stopped != StopObserver {
+		t.Errorf("stopped: %s", r.Stopped)
+	}
+}
@@ -0,0 +1,168 @@
+package zitraders
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// Sides of the market a shock strikes.
+const (
+	Buyers  = "buyers"
+	Sellers = "sellers"
+	Both    = "both"
+)
+
+// A Shock changes buyer values or seller costs during a run, either once
+// the session has made At trade attempts or at the start of a trading
+// Period. It shifts every value or cost by Shift, clamped to 1 through the
+// maximum value, or redraws them from Values.
+type Shock struct {
+	At     int64         `json:"at,omitempty" yaml:"at" toml:"at"`
+	Period int           `json:"period,omitempty" yaml:"period" toml:"period"`
+	Side   string        `json:"side" yaml:"side" toml:"side"` // buyers, sellers or both (the default)
+	Shift  int           `json:"shift,omitempty" yaml:"shift" toml:"shift"`
+	Values *Distribution `json:"values,omitempty" yaml:"values" toml:"values"`
+}
+
+// ShockResults records when a shock struck and how it moved the equilibrium.
+type ShockResults struct {
+	Attempts int64   `json:"attempts"` // trade attempts made before the shock
+	Trades   int64   `json:"trades"`   // trades executed before the shock
+	Period   int     `json:"period"`
+	Before   float64 `json:"before"` // the equilibrium price before the shock
+	After    float64 `json:"after"`  // and after it
+}
+
+// Check the shocks and prepare their samplers. Shocks at a number of attempts
+// are applied by an observer at the end of a tick.
+func (m *Model) checkShocks() error {
+	m.redraws = make([][2]func(*rand.Rand) int, len(m.Shocks))
+	timed := false
+	for i := range m.Shocks {
+		s := &m.Shocks[i]
+		switch s.Side {
+		case "":
+			s.Side = Both
+		case Buyers, Sellers, Both:
+		default:
+			return fmt.Errorf("shock %d: unknown side %q", i+1, s.Side)
+		}
+		if (s.At > 0) == (s.Period > 0) {
+			return fmt.Errorf("shock %d: give either the attempts or the period at which it strikes", i+1)
+		}
+		if s.Period >= m.Periods {
+			return fmt.Errorf("shock %d: there is no trading period %d", i+1, s.Period)
+		}
+		timed = timed || s.At > 0
+		if s.Values == nil {
+			continue
+		}
+		var err error
+		if m.redraws[i][0], err = s.Values.sampler(m.MaxBuyerValue, m.PriceTick); err != nil {
+			return fmt.Errorf("shock %d: %v", i+1, err)
+		}
+		if m.redraws[i][1], err = s.Values.sampler(m.MaxSellerValue, m.PriceTick); err != nil {
+			return fmt.Errorf("shock %d: %v", i+1, err)
+		}
+	}
+	m.struck = make([]bool, len(m.Shocks))
+	if timed {
+		if m.TickSize == 0 {
+			m.TickSize = defaultTickSize
+		}
+		m.Observe(func(m *Model, t Tick) bool {
+			for i, s := range m.Shocks {
+				if !m.struck[i] && s.At > 0 && t.Attempts >= s.At && !t.Final {
+					m.strike(i)
+				}
+			}
+			return true
+		})
+	}
+	return nil
+}
+
+// Apply the shocks due at the start of the trading period under way.
+func (m *Model) periodShocks() {
+	for i, s := range m.Shocks {
+		if !m.struck[i] && s.Period > 0 && s.Period <= m.period {
+			m.strike(i)
+		}
+	}
+}
+
+// Apply shock i to every trader on its side of the market. Each trader's
+// surplus from units already traded this period is kept at the values it
+// traded them at.
+func (m *Model) strike(i int) {
+	s := m.Shocks[i]
+	p := m.Progress()
+	r := ShockResults{Attempts: p.Attempts, Trades: p.Trades, Period: m.period, Before: m.Equilibrium().Price()}
+	// Each shock draws from its own stream, beyond those of the threads.
+	rng := rand.New(m.stream(m.NumThreads + 2 + i))
+	if s.Side != Sellers {
+		shockAgents(m.buyers, s.Shift, m.MaxBuyerValue, m.redraws[i][0], rng)
+	}
+	if s.Side != Buyers {
+		shockAgents(m.sellers, s.Shift, m.MaxSellerValue, m.redraws[i][1], rng)
+	}
+	r.After = m.Equilibrium().Price()
+	m.struck[i] = true
+	m.shocks = append(m.shocks, r)
+}
+
+func shockAgents(agents []agent, shift, max int, redraw func(*rand.Rand) int, rng *rand.Rand) {
+	for i := range agents {
+		a := &agents[i]
+		before := a.surplus()
+		for k := range a.schedule {
+			if redraw != nil {
+				a.schedule[k] = redraw(rng)
+				continue
+			}
+			v := a.schedule[k] + shift
+			if v < 1 {
+				v = 1
+			}
+			if v > max {
+				v = max
+			}
+			a.schedule[k] = v
+		}
+		if a.buyerOrSeller {
+			sort.Sort(sort.Reverse(sort.IntSlice(a.schedule)))
+		} else {
+			sort.Ints(a.schedule)
+		}
+		a.earned += before - a.surplus()
+		if k := a.traded(); k < len(a.schedule) {
+			a.value = a.schedule[k]
+		}
+	}
+}
+
+// Split the trade log at every shock and measure the convergence of each
+// part towards the equilibrium in force during it. Block trade counts stay
+// cumulative, but running means restart at each shock.
+func shockedConvergence(trades []Trade, n int, eqPrice float64, shocks []ShockResults) []Block {
+	if len(shocks) == 0 {
+		return convergence(trades, n, eqPrice)
+	}
+	var blocks []Block
+	start, price := 0, shocks[0].Before
+	for k := 0; k <= len(shocks); k++ {
+		end := len(trades)
+		if k < len(shocks) && int(shocks[k].Trades) < end {
+			end = int(shocks[k].Trades)
+		}
+		for _, b := range convergence(trades[start:end], n, price) {
+			b.Trades += start
+			blocks = append(blocks, b)
+		}
+		if k < len(shocks) {
+			start, price = end, shocks[k].After
+		}
+	}
+	return blocks
+}
@@ -0,0 +1,61 @@
+package zitraders
+
+import (
+	"reflect"
+	"testing"
+)
+
+// A shock that changes nothing leaves the run as it was.
+func TestNullShock(t *testing.T) {
+	config := testConfig()
+	config.Units, config.TickSize = 2, 1000
+	want := newTestModel(t, config).Run()
+
+	config.Shocks = []Shock{{At: 50000, Side: Buyers}}
+	got := newTestModel(t, config).Run()
+	if len(got.Shocks) != 1 || got.Shocks[0].Before != got.Shocks[0].After {
+		t.Fatalf("got shocks %+v", got.Shocks)
+	}
+	got.Shocks = nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("null shock gave %+v, want %+v", got, want)
+	}
+}
+
+// Raising buyer values raises the equilibrium and the prices that follow.
+func TestShock(t *testing.T) {
+	config := testConfig()
+	config.ConvergenceBlock = 1000
+	config.Shocks = []Shock{{At: 50000, Side: Buyers, Shift: 10}}
+	r := newTestModel(t, config).Run()
+
+	if len(r.Shocks) != 1 {
+		t.Fatalf("got shocks %+v", r.Shocks)
+	}
+	s := r.Shocks[0]
+	if s.Attempts < 50000 || s.After <= s.Before || r.EquilibriumPrice != s.After {
+		t.Errorf("got shock %+v, equilibrium price %v", s, r.EquilibriumPrice)
+	}
+	before, after := 0.0, 0.0
+	for _, b := range r.Convergence {
+		if b.Trades <= int(s.Trades) {
+			before = b.Running
+		} else {
+			after = b.Running
+		}
+	}
+	if after <= before {
+		t.Errorf("mean price %v after the shock, %v before", after, before)
+	}
+
+	config = testConfig()
+	config.Periods = 3
+	config.Shocks = []Shock{{Period: 1, Side: Sellers, Values: &Distribution{Kind: Normal, Mean: 25, SD: 2}}}
+	r = newTestModel(t, config).Run()
+	if len(r.Shocks) != 1 || r.Shocks[0].Period != 1 || r.Shocks[0].Trades != int64(r.Periods[0].Trades) {
+		t.Fatalf("got shocks %+v", r.Shocks)
+	}
+	if r.Periods[1].MeanPrice <= r.Periods[0].MeanPrice {
+		t.Errorf("periods %+v", r.Periods)
+	}
+}
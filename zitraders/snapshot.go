@@ -0,0 +1,128 @@
+package zitraders
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// AgentState is the state of one agent in a snapshot.
+type AgentState struct {
+	Side  string // buyer or seller
+	Agent int
+	Value int // of the marginal unit
+	Held  int
+	Price int // of the most recent trade, zero if none
+}
+
+// EachAgent calls f with the state of every buyer and then every seller. It
+// must be called by an observer, while the trading threads are paused, or
+// outside a run.
+func (m *Model) EachAgent(f func(AgentState)) {
+	buyers, sellers := m.stores()
+	for _, side := range []struct {
+		name  string
+		store agentStore
+	}{{"buyer", buyers}, {"seller", sellers}} {
+		for i := 0; i < side.store.Len(); i++ {
+			f(AgentState{side.name, i, side.store.Value(i), side.store.Held(i), side.store.Price(i)})
+		}
+	}
+}
+
+// WriteSnapshot writes the state of every agent as CSV with columns side,
+// agent, value, held and price. Like EachAgent, it must be called while the
+// trading threads are paused or outside a run.
+func (m *Model) WriteSnapshot(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"side", "agent", "value", "held", "price"})
+	m.EachAgent(func(a AgentState) {
+		cw.Write([]string{
+			a.Side,
+			strconv.Itoa(a.Agent),
+			strconv.Itoa(a.Value),
+			strconv.Itoa(a.Held),
+			strconv.Itoa(a.Price),
+		})
+	})
+	cw.Flush()
+	return cw.Error()
+}
+
+// AgentResult is an agent's state at the end of a run with what it made of
+// the run.
+type AgentResult struct {
+	AgentState
+	ID       int // as in the roster
+	Strategy string
+	Traded   int // units bought or sold over the run
+	Surplus  int // realized over the run
+}
+
+// EachAgentResult calls f with the result of every buyer and then every
+// seller. Like EachAgent, it must be called while the trading threads are
+// paused or outside a run.
+func (m *Model) EachAgentResult(f func(AgentResult)) {
+	buyers, sellers := m.stores()
+	id := 0
+	for _, side := range []struct {
+		name   string
+		buyer  bool
+		store  agentStore
+		agents []agent
+	}{{"buyer", true, buyers, m.buyers}, {"seller", false, sellers, m.sellers}} {
+		s := side.store
+		for i := 0; i < s.Len(); i++ {
+			x := AgentResult{AgentState: AgentState{side.name, i, s.Value(i), s.Held(i), s.Price(i)}, ID: id}
+			if side.agents != nil {
+				a := &side.agents[i]
+				x.Strategy, x.Traded, x.Surplus = m.kinds[a.kind], len(a.prices), a.surplus()
+			} else {
+				x.Strategy = "zi-c"
+				if s.Unconstrained(i) {
+					x.Strategy = "zi-u"
+				}
+				if s.Traded(i) {
+					x.Traded, x.Surplus = 1, s.Price(i)-s.Value(i)
+					if side.buyer {
+						x.Surplus = -x.Surplus
+					}
+				}
+			}
+			f(x)
+			id++
+		}
+	}
+}
+
+// WriteAgentResults writes the result of every agent as CSV with columns id,
+// side, agent, strategy, value, held, price, traded and surplus. Like
+// EachAgent, it must be called while the trading threads are paused or
+// outside a run.
+func (m *Model) WriteAgentResults(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "side", "agent", "strategy", "value", "held", "price", "traded", "surplus"})
+	m.EachAgentResult(func(a AgentResult) {
+		cw.Write([]string{
+			strconv.Itoa(a.ID),
+			a.Side,
+			strconv.Itoa(a.Agent),
+			a.Strategy,
+			strconv.Itoa(a.Value),
+			strconv.Itoa(a.Held),
+			strconv.Itoa(a.Price),
+			strconv.Itoa(a.Traded),
+			strconv.Itoa(a.Surplus),
+		})
+	})
+	cw.Flush()
+	return cw.Error()
+}
+
+// The whole population as agent stores, in either layout.
+func (m *Model) stores() (buyers, sellers agentStore) {
+	if m.columnar() {
+		return m.buyerStore, m.sellerStore
+	}
+	return aosStore(m.buyers), aosStore(m.sellers)
+}
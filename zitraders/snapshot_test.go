@@ -0,0 +1,107 @@
+package zitraders
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteSnapshot(t *testing.T) {
+	for _, layout := range []string{AoS, SoA} {
+		config := testConfig()
+		config.Layout = layout
+		m := newTestModel(t, config)
+		r := m.Run()
+
+		var b bytes.Buffer
+		if err := m.WriteSnapshot(&b); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+		if len(lines) != 1+config.NumBuyers+config.NumSellers {
+			t.Fatalf("%s: got %d lines", layout, len(lines))
+		}
+		bought := 0
+		for _, l := range lines[1 : 1+config.NumBuyers] {
+			if strings.Split(l, ",")[3] == "1" {
+				bought++
+			}
+		}
+		if bought != r.NumberBought {
+			t.Errorf("%s: %d buyers hold a unit, want %d", layout, bought, r.NumberBought)
+		}
+	}
+}
+
+// The roster joins each trade to the values of the agents who made it.
+func TestWriteRoster(t *testing.T) {
+	for _, layout := range []string{AoS, SoA} {
+		config := testConfig()
+		config.Layout, config.RecordTrades = layout, true
+		m := newTestModel(t, config)
+
+		var b bytes.Buffer
+		if err := m.WriteRoster(&b); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+		if len(lines) != 1+config.NumBuyers+config.NumSellers {
+			t.Fatalf("%s: got %d lines", layout, len(lines))
+		}
+		values := make([]int, len(lines)-1)
+		for i, l := range lines[1:] {
+			f := strings.Split(l, ",")
+			if id, _ := strconv.Atoi(f[0]); id != i || f[3] != "zi-c" {
+				t.Fatalf("%s: bad roster line %q", layout, l)
+			}
+			values[i], _ = strconv.Atoi(f[5])
+		}
+
+		m.Run()
+		for _, tr := range m.Trades() {
+			if v, c := values[tr.Buyer], values[config.NumBuyers+tr.Seller]; tr.Price > v || tr.Price < c {
+				t.Fatalf("%s: trade %+v between a value of %d and a cost of %d", layout, tr, v, c)
+			}
+		}
+	}
+}
+
+// The agents' results add up to the run's.
+func TestEachAgentResult(t *testing.T) {
+	for _, modify := range []func(*Config){
+		func(c *Config) {},
+		func(c *Config) { c.Layout = SoA },
+		func(c *Config) { c.Units, c.Periods, c.Unconstrained = 3, 2, 0.5 },
+	} {
+		config := testConfig()
+		modify(&config)
+		m := newTestModel(t, config)
+		r := m.Run()
+
+		var agents, bought, sold, surplus int
+		m.EachAgentResult(func(a AgentResult) {
+			if a.ID != agents {
+				t.Fatalf("%+v: agent %d has ID %d", config, agents, a.ID)
+			}
+			agents++
+			if a.Side == "buyer" {
+				bought += a.Traded
+			} else {
+				sold += a.Traded
+			}
+			surplus += a.Surplus
+		})
+		if agents != config.NumBuyers+config.NumSellers || bought != r.NumberBought || sold != r.NumberSold || surplus != r.RealizedSurplus {
+			t.Errorf("%+v: %d agents bought %d, sold %d for a surplus of %d; want %+v", config, agents, bought, sold, surplus, r)
+		}
+
+		var b bytes.Buffer
+		if err := m.WriteAgentResults(&b); err != nil {
+			t.Fatal(err)
+		}
+		if lines := strings.Count(b.String(), "\n"); lines != 1+agents {
+			t.Errorf("%+v: got %d lines", config, lines)
+		}
+	}
+}
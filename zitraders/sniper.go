@@ -0,0 +1,95 @@
+package zitraders
+
+// Snipers, after Kaplan's winning entry in the Santa Fe double auction
+// tournament (Rust, Miller and Palmer, 1993), wait in the background while
+// other traders narrow the market and then jump in to take the current quote
+// on the other side: when it is a bargain, when the bid-ask spread is narrow
+// or when the market is about to close. A sniper never quotes a price it
+// would lose money at, and otherwise stays out of the market.
+
+const (
+	snipeSpread = 10 // percent of the ask within which a spread is narrow
+	snipeLate   = 10 // percent of a thread's attempts left when the deadline is near
+)
+
+// Note the standing quotes of an order book as the current quotes.
+func (h *history) see(b *book) {
+	if h == nil {
+		return
+	}
+	h.bid, h.ask = NoQuote, NoQuote
+	if bid, ok := b.bestBid(); ok {
+		h.bid = bid.price
+	}
+	if ask, ok := b.bestAsk(); ok {
+		h.ask = ask.price
+	}
+}
+
+// Note the thread's attempt number, the market's clock.
+func (h *history) at(i int) {
+	if h != nil {
+		h.time = i
+	}
+}
+
+// A sniper keeps no state of its own.
+type sniper struct{}
+
+// Whether the market is about to close.
+func late(ctx MarketView) bool {
+	return ctx.Time >= ctx.End-ctx.End*snipeLate/100
+}
+
+// Whether the spread between the current quotes is narrow.
+func narrow(ctx MarketView) bool {
+	return ctx.Bid != NoQuote && ctx.Ask != NoQuote && (ctx.Ask-ctx.Bid)*100 <= ctx.Ask*snipeSpread
+}
+
+// The lowest remembered ask and the highest remembered bid that traded, or
+// NoQuote if there are none.
+func (h *history) extremes() (lowAsk, highBid int) {
+	for _, q := range h.quotes[:h.n] {
+		switch {
+		case !q.accepted:
+		case q.bid && q.price > highBid:
+			highBid = q.price
+		case !q.bid && (lowAsk == NoQuote || q.price < lowAsk):
+			lowAsk = q.price
+		}
+	}
+	return lowAsk, highBid
+}
+
+// A sniper buyer takes the current ask if it leaves a profit and is a
+// bargain, the spread is narrow or the deadline is near, and otherwise stays
+// out. Near the deadline a sniper who has seen no ask to take quotes as a
+// ZI-C trader.
+func (sniper) Bid(ctx MarketView) int {
+	switch {
+	case ctx.Ask == NoQuote && late(ctx):
+		return zic{}.Bid(ctx)
+	case ctx.Ask == NoQuote || ctx.Ask >= ctx.Value:
+		return NoQuote
+	}
+	lowAsk, _ := ctx.history.extremes()
+	if late(ctx) || narrow(ctx) || ctx.Ask <= lowAsk {
+		return ctx.Ask
+	}
+	return NoQuote
+}
+
+// A sniper seller is symmetric to a sniper buyer.
+func (sniper) Ask(ctx MarketView) int {
+	switch {
+	case ctx.Bid == NoQuote && late(ctx):
+		return zic{}.Ask(ctx)
+	case ctx.Bid == NoQuote || ctx.Bid <= ctx.Value:
+		return NoQuote
+	}
+	_, highBid := ctx.history.extremes()
+	if late(ctx) || narrow(ctx) || (highBid != NoQuote && ctx.Bid >= highBid) {
+		return ctx.Bid
+	}
+	return NoQuote
+}
@@ -0,0 +1,252 @@
+package zitraders
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+)
+
+// Defaults for a lattice whose configuration leaves them out.
+const defaultRadius = 1
+
+// Spatial places the traders on a Width by Height lattice, wrapped into a
+// torus. A trade attempt draws a buyer, then a cell within Radius of its own
+// in either direction, then a seller in that cell. At the end of every tick
+// each trader moves to one of the eight cells around it with probability
+// Move.
+type Spatial struct {
+	Width  int     `json:"width,omitempty" yaml:"width" toml:"width"` // zero for no lattice
+	Height int     `json:"height,omitempty" yaml:"height" toml:"height"`
+	Radius int     `json:"radius,omitempty" yaml:"radius" toml:"radius"` // zero for the default
+	Move   float64 `json:"move,omitempty" yaml:"move" toml:"move"`
+}
+
+// SpatialResults reports how prices vary across the lattice.
+type SpatialResults struct {
+	Moves      int64   `json:"moves"`      // steps taken by traders between ticks
+	Cells      int     `json:"cells"`      // cells in which trade took place
+	Dispersion float64 `json:"dispersion"` // the s.d. across those cells of their mean price
+	Moran      float64 `json:"moran"`      // Moran's I of the cells' mean prices over adjacent cells
+}
+
+// PriceMap holds the trades made in each cell of the lattice, the seller's,
+// and their mean price, row by row.
+type PriceMap struct {
+	Width     int       `json:"width"`
+	Height    int       `json:"height"`
+	Trades    []int64   `json:"trades"`
+	MeanPrice []float64 `json:"mean_price"` // zero where there was no trade
+}
+
+type lattice struct {
+	width, height, radius int
+	buyers, sellers       []int32 // the cell of each trader
+	start, index          []int32 // the sellers in cell c are index[start[c]:start[c+1]]
+	source                *xoshiro
+	moves                 int64
+	sums, counts          [][]int64 // each thread's price sums and trade counts by cell
+}
+
+// Check the lattice and place the traders on it at random.
+func (m *Model) buildLattice() error {
+	s := &m.Spatial
+	if s.Width == 0 && s.Height == 0 {
+		return nil
+	}
+	if s.Width < 1 || s.Height < 1 || s.Radius < 0 || s.Move < 0 || s.Move > 1 {
+		return fmt.Errorf("the lattice needs a positive size, a radius that is not negative and a move probability in [0, 1]")
+	}
+	if !m.bilateral() || m.Matching == Partitioned {
+		return fmt.Errorf("a lattice requires the bilateral institution and global or pool matching")
+	}
+	if m.Network.Topology != Complete || m.Markets > 1 {
+		return fmt.Errorf("a lattice cannot be combined with a trading network or several markets")
+	}
+	if s.Radius == 0 {
+		s.Radius = defaultRadius
+	}
+	if s.Move > 0 && m.TickSize == 0 {
+		m.TickSize = defaultTickSize
+	}
+	if m.dry {
+		return nil
+	}
+
+	cells := s.Width * s.Height
+	l := &lattice{
+		width:   s.Width,
+		height:  s.Height,
+		radius:  s.Radius,
+		buyers:  make([]int32, m.NumBuyers),
+		sellers: make([]int32, m.NumSellers),
+		start:   make([]int32, cells+1),
+		index:   make([]int32, m.NumSellers),
+		// Moves draw from their own stream, beyond those of the threads and
+		// shocks.
+		source: m.stream(m.NumThreads + 2 + len(m.Shocks)),
+		sums:   make([][]int64, m.NumThreads),
+		counts: make([][]int64, m.NumThreads),
+	}
+	for t := range l.sums {
+		l.sums[t], l.counts[t] = make([]int64, cells), make([]int64, cells)
+	}
+	for i := range l.buyers {
+		l.buyers[i] = int32(m.rng.Intn(cells))
+	}
+	for i := range l.sellers {
+		l.sellers[i] = int32(m.rng.Intn(cells))
+	}
+	l.reindex()
+	m.lattice, m.neighbors = l, l
+
+	if s.Move > 0 {
+		m.Observe(func(m *Model, t Tick) bool {
+			if !t.Final {
+				m.lattice.move(m.Spatial.Move)
+			}
+			return true
+		})
+	}
+	return nil
+}
+
+// Index the sellers by cell.
+func (l *lattice) reindex() {
+	for c := range l.start {
+		l.start[c] = 0
+	}
+	for _, c := range l.sellers {
+		l.start[c+1]++
+	}
+	for c := 1; c < len(l.start); c++ {
+		l.start[c] += l.start[c-1]
+	}
+	next := make([]int32, len(l.start)-1)
+	copy(next, l.start)
+	for i, c := range l.sellers {
+		l.index[next[c]] = int32(i)
+		next[c]++
+	}
+}
+
+// The cell dx across and dy down from cell c, wrapping around the edges.
+func (l *lattice) offset(c, dx, dy int) int {
+	x := (c%l.width + dx%l.width + l.width) % l.width
+	y := (c/l.width + dy%l.height + l.height) % l.height
+	return y*l.width + x
+}
+
+// Draw a seller within the radius of buyer i, or -1 if the cell drawn is
+// empty.
+func (l *lattice) partner(i int, r *rand.Rand) int {
+	d := 2*l.radius + 1
+	c := l.offset(int(l.buyers[i]), r.Intn(d)-l.radius, r.Intn(d)-l.radius)
+	n := l.start[c+1] - l.start[c]
+	if n == 0 {
+		return -1
+	}
+	return int(l.index[l.start[c]+int32(r.Int31n(n))])
+}
+
+// Note a trade by a thread at a price in the seller's cell.
+func (l *lattice) record(thread, seller, price int) {
+	c := l.sellers[seller]
+	l.sums[thread][c] += int64(price)
+	l.counts[thread][c]++
+}
+
+// Move each trader to an adjacent cell with probability p.
+func (l *lattice) move(p float64) {
+	r := rand.New(l.source)
+	for _, cells := range [][]int32{l.buyers, l.sellers} {
+		for i, c := range cells {
+			if r.Float64() >= p {
+				continue
+			}
+			dx, dy := 0, 0
+			for dx == 0 && dy == 0 {
+				dx, dy = r.Intn(3)-1, r.Intn(3)-1
+			}
+			cells[i] = int32(l.offset(int(c), dx, dy))
+			l.moves++
+		}
+	}
+	l.reindex()
+}
+
+// PriceMap returns the trades made in each cell of the lattice and their
+// mean price, or nil if the traders are not on a lattice.
+func (m *Model) PriceMap() *PriceMap {
+	l := m.lattice
+	if l == nil {
+		return nil
+	}
+	cells := l.width * l.height
+	p := &PriceMap{Width: l.width, Height: l.height, Trades: make([]int64, cells), MeanPrice: make([]float64, cells)}
+	sums := make([]int64, cells)
+	for t := range l.sums {
+		for c := 0; c < cells; c++ {
+			sums[c] += l.sums[t][c]
+			p.Trades[c] += l.counts[t][c]
+		}
+	}
+	for c, n := range p.Trades {
+		if n > 0 {
+			p.MeanPrice[c] = float64(sums[c]) / float64(n)
+		}
+	}
+	return p
+}
+
+// Measure how prices vary across the cells of the lattice.
+func (m *Model) spatialResults() *SpatialResults {
+	l, p := m.lattice, m.PriceMap()
+	r := &SpatialResults{Moves: l.moves}
+	var prices moments
+	for c, n := range p.Trades {
+		if n > 0 {
+			prices.add(p.MeanPrice[c])
+		}
+	}
+	r.Cells, r.Dispersion = prices.n, prices.sd()
+
+	// Moran's I weighs each pair of adjacent cells with trades equally.
+	var cross, weights, squares float64
+	for c, n := range p.Trades {
+		if n == 0 {
+			continue
+		}
+		d := p.MeanPrice[c] - prices.mean
+		squares += d * d
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				if o := l.offset(c, dx, dy); o != c && p.Trades[o] > 0 {
+					cross += d * (p.MeanPrice[o] - prices.mean)
+					weights++
+				}
+			}
+		}
+	}
+	if weights > 0 && squares > 0 {
+		r.Moran = float64(prices.n) / weights * cross / squares
+	}
+	return r
+}
+
+// WriteCSV writes the price map as CSV with a header row, one row per cell.
+func (p *PriceMap) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"x", "y", "trades", "mean_price"})
+	for c, n := range p.Trades {
+		cw.Write([]string{
+			strconv.Itoa(c % p.Width),
+			strconv.Itoa(c / p.Width),
+			strconv.FormatInt(n, 10),
+			strconv.FormatFloat(p.MeanPrice[c], 'f', -1, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,66 @@
+package zitraders
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Trade on a lattice only takes place within the radius, and the price map
+// accounts for every trade.
+func TestLattice(t *testing.T) {
+	for _, matching := range []string{Global, Pool} {
+		config := testConfig()
+		config.Matching, config.RecordTrades = matching, true
+		config.Spatial = Spatial{Width: 8, Height: 6, Radius: 1}
+		m := newTestModel(t, config)
+		l := m.lattice
+		r := m.Run()
+
+		for _, tr := range m.Trades() {
+			b, s := int(l.buyers[tr.Buyer]), int(l.sellers[tr.Seller])
+			dx := (s%8 - b%8 + 8) % 8
+			dy := (s/8 - b/8 + 6) % 6
+			if (dx > 1 && dx < 7) || (dy > 1 && dy < 5) {
+				t.Fatalf("%s: buyer in cell %d traded with seller in cell %d", matching, b, s)
+			}
+		}
+		p := m.PriceMap()
+		var trades int64
+		for _, n := range p.Trades {
+			trades += n
+		}
+		if trades != int64(r.NumberBought) || r.Spatial.Cells != 48 || r.Spatial.Dispersion <= 0 {
+			t.Errorf("%s: %d trades mapped, want %d; %+v", matching, trades, r.NumberBought, r.Spatial)
+		}
+
+		var buf bytes.Buffer
+		if err := p.WriteCSV(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if lines := strings.Count(buf.String(), "\n"); lines != 49 {
+			t.Errorf("%s: price map has %d lines", matching, lines)
+		}
+	}
+}
+
+// Traders who move keep to adjacent cells and the seller index follows them.
+func TestLatticeMove(t *testing.T) {
+	config := testConfig()
+	config.Matching, config.TickSize = Global, 1000
+	config.Spatial = Spatial{Width: 10, Height: 10, Move: 0.5}
+	m := newTestModel(t, config)
+	r := m.Run()
+
+	if r.Spatial.Moves == 0 {
+		t.Fatal("no trader moved")
+	}
+	l := m.lattice
+	for c := 0; c < 100; c++ {
+		for _, s := range l.index[l.start[c]:l.start[c+1]] {
+			if int(l.sellers[s]) != c {
+				t.Fatalf("seller %d indexed in cell %d, is in %d", s, c, l.sellers[s])
+			}
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package zitraders
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Quote is a sample of a thread's order book at the start of an attempt: its
+// best bid and ask, NoQuote if there is none, and its depth, the standing
+// orders on each side.
+type Quote struct {
+	Period   int `json:"period"`
+	Tick     int `json:"tick"`
+	Thread   int `json:"thread"`
+	Bid      int `json:"bid"`
+	Ask      int `json:"ask"`
+	BidDepth int `json:"bid_depth"`
+	AskDepth int `json:"ask_depth"`
+}
+
+// BookResults describe the order books of a continuous double auction, as
+// they stood at the start of every attempt.
+type BookResults struct {
+	Quoted           float64 `json:"quoted"` // the share of attempts with both a bid and an ask standing
+	MeanSpread       float64 `json:"mean_spread"`
+	SDSpread         float64 `json:"sd_spread"`
+	SpreadVolatility float64 `json:"spread_volatility"` // root mean squared change in the spread between successive attempts
+	MeanBidDepth     float64 `json:"mean_bid_depth"`
+	MeanAskDepth     float64 `json:"mean_ask_depth"`
+	MaxBidDepth      int     `json:"max_bid_depth"`
+	MaxAskDepth      int     `json:"max_ask_depth"`
+}
+
+// A thread's running statistics of its order book, and its samples.
+type bookTally struct {
+	attempts  int
+	spread    moments
+	bidDepth  moments
+	askDepth  moments
+	last      int // the last spread, or -1 if the book was one-sided
+	changes   float64
+	n         int // spread changes summed into changes
+	quotes    []Quote
+	mark      int // the samples taken by the end of the previous tick
+	sinceLast int
+}
+
+func newBookTally() *bookTally {
+	return &bookTally{last: -1}
+}
+
+// Observe the partition's book at attempt i, sampling it every QuoteEvery
+// attempts.
+func (m *Model) observeBook(t *bookTally, b *book, p partition, i int) {
+	bid, hasBid := b.bestBid()
+	ask, hasAsk := b.bestAsk()
+	t.attempts++
+	t.bidDepth.add(float64(len(b.buyers)))
+	t.askDepth.add(float64(len(b.sellers)))
+	if hasBid && hasAsk {
+		s := ask.price - bid.price
+		t.spread.add(float64(s))
+		if t.last >= 0 {
+			d := float64(s - t.last)
+			t.changes += d * d
+			t.n++
+		}
+		t.last = s
+	} else {
+		t.last = -1
+	}
+
+	if m.QuoteEvery > 0 {
+		if t.sinceLast++; t.sinceLast == m.QuoteEvery {
+			t.sinceLast = 0
+			q := Quote{Period: p.period, Tick: i, Thread: p.thread, BidDepth: len(b.buyers), AskDepth: len(b.sellers)}
+			if hasBid {
+				q.Bid = bid.price
+			}
+			if hasAsk {
+				q.Ask = ask.price
+			}
+			t.quotes = append(t.quotes, q)
+		}
+	}
+}
+
+// Combine the threads' statistics of their books.
+func (m *Model) bookResults() *BookResults {
+	var attempts, n int
+	var spread, bidDepth, askDepth moments
+	changes := 0.0
+	for _, t := range m.books {
+		attempts += t.attempts
+		spread.merge(t.spread)
+		bidDepth.merge(t.bidDepth)
+		askDepth.merge(t.askDepth)
+		changes += t.changes
+		n += t.n
+	}
+	r := &BookResults{
+		MeanSpread:   spread.mean,
+		SDSpread:     spread.sd(),
+		MeanBidDepth: bidDepth.mean,
+		MeanAskDepth: askDepth.mean,
+		MaxBidDepth:  int(bidDepth.max),
+		MaxAskDepth:  int(askDepth.max),
+	}
+	if attempts > 0 {
+		r.Quoted = float64(spread.n) / float64(attempts)
+	}
+	if n > 0 {
+		r.SpreadVolatility = math.Sqrt(changes / float64(n))
+	}
+	return r
+}
+
+// Quotes returns the samples of the order books in period, tick and thread
+// order. It is empty unless the model was configured with QuoteEvery.
+func (m *Model) Quotes() []Quote {
+	var quotes []Quote
+	for _, t := range m.books {
+		quotes = append(quotes, t.quotes...)
+	}
+	sortQuotes(quotes)
+	return quotes
+}
+
+// TickQuotes returns the samples of the order books taken during the tick
+// that just ended, in tick and thread order. It is meant to be called by
+// observers of a model configured with QuoteEvery.
+func (m *Model) TickQuotes() []Quote {
+	var quotes []Quote
+	for _, t := range m.books {
+		quotes = append(quotes, t.quotes[t.mark:]...)
+	}
+	sortQuotes(quotes)
+	return quotes
+}
+
+func sortQuotes(quotes []Quote) {
+	sort.Slice(quotes, func(i, j int) bool {
+		a, b := quotes[i], quotes[j]
+		if a.Period != b.Period {
+			return a.Period < b.Period
+		}
+		if a.Tick != b.Tick {
+			return a.Tick < b.Tick
+		}
+		return a.Thread < b.Thread
+	})
+}
+
+// WriteQuotesCSV writes samples of the order books as CSV with a header row.
+func WriteQuotesCSV(w io.Writer, quotes []Quote) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"period", "tick", "thread", "bid", "ask", "bid_depth", "ask_depth"})
+	for _, q := range quotes {
+		cw.Write([]string{
+			strconv.Itoa(q.Period),
+			strconv.Itoa(q.Tick),
+			strconv.Itoa(q.Thread),
+			strconv.Itoa(q.Bid),
+			strconv.Itoa(q.Ask),
+			strconv.Itoa(q.BidDepth),
+			strconv.Itoa(q.AskDepth),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,64 @@
+package zitraders
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBookResults(t *testing.T) {
+	config := testConfig()
+	config.Institution, config.QuoteEvery = CDA, 100
+	m := newTestModel(t, config)
+	r := m.Run()
+
+	b := r.Book
+	if b == nil || b.Quoted <= 0 || b.Quoted > 1 {
+		t.Fatalf("got %+v", b)
+	}
+	if b.MeanSpread <= 0 || b.SpreadVolatility <= 0 || b.MeanBidDepth <= 0 || b.MeanAskDepth <= 0 {
+		t.Errorf("got %+v", b)
+	}
+	if float64(b.MaxBidDepth) < b.MeanBidDepth || b.MaxBidDepth > config.NumBuyers {
+		t.Errorf("bid depth at most %d, mean %f", b.MaxBidDepth, b.MeanBidDepth)
+	}
+
+	quotes := m.Quotes()
+	if want := config.NumThreads * (config.MaxNumberOfTrades / config.NumThreads) / config.QuoteEvery; len(quotes) < want/2 || len(quotes) > want {
+		t.Fatalf("%d quotes sampled, want about %d", len(quotes), want)
+	}
+	for k, q := range quotes {
+		if q.Bid != NoQuote && q.Ask != NoQuote && q.Bid >= q.Ask {
+			t.Errorf("quote %+v is crossed", q)
+		}
+		if k > 0 && q.Tick < quotes[k-1].Tick {
+			t.Errorf("quote %+v sampled out of order", q)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteQuotesCSV(&buf, quotes); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != len(quotes)+1 {
+		t.Errorf("%d lines written for %d quotes", lines, len(quotes))
+	}
+}
+
+func TestBookResultsBilateral(t *testing.T) {
+	if r := newTestModel(t, testConfig()).Run(); r.Book != nil {
+		t.Errorf("a bilateral market reported an order book: %+v", r.Book)
+	}
+}
+
+// The side that quotes more often keeps more orders standing in the book.
+func TestBuyerArrival(t *testing.T) {
+	for _, arrival := range []float64{0.1, 0.9} {
+		config := testConfig()
+		config.Institution, config.BuyerArrival = CDA, arrival
+		b := newTestModel(t, config).Run().Book
+		if (b.MeanBidDepth > b.MeanAskDepth) != (arrival > 0.5) {
+			t.Errorf("buyers quoting %v of the time: %.1f bids and %.1f asks standing on average", arrival, b.MeanBidDepth, b.MeanAskDepth)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package zitraders
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"sync/atomic"
+)
+
+// Results holds the market statistics computed at the end of a run.
+type Results struct {
+	Seed            int64   `json:"seed"`
+	Attempts        int64   `json:"attempts"`
+	Stopped         string  `json:"stopped"`    // the reason the run ended
+	PriceTick       float64 `json:"price_tick"` // the currency value of a tick, in which prices, values and profits are counted
+	NumberBought    int     `json:"number_bought"`
+	NumberSold      int     `json:"number_sold"`
+	MeanPrice       float64 `json:"mean_price"`
+	SDPrice         float64 `json:"sd_price"`
+	MinPrice        float64 `json:"min_price"`
+	MaxPrice        float64 `json:"max_price"`
+	MedianPrice     float64 `json:"median_price"`
+	P5Price         float64 `json:"p5_price"`                  // the 5th percentile of prices
+	P95Price        float64 `json:"p95_price"`                 // the 95th percentile of prices
+	IQRPrice        float64 `json:"iqr_price"`                 // the interquartile range of prices
+	CVPrice         float64 `json:"cv_price"`                  // the coefficient of variation of prices, their s.d. over their mean
+	PriceHistogram  []Bin   `json:"price_histogram,omitempty"` // prices in PriceBins bins of equal width
+	RealizedSurplus int     `json:"realized_surplus"`
+	MaxSurplus      int     `json:"max_surplus"`
+	Efficiency      float64 `json:"efficiency"`        // realized surplus as a percentage of the maximum
+	Profits         Profits `json:"profits"`           // the distribution of realized surplus over traders
+	BuyerProfits    Profits `json:"buyer_profits"`     // and over buyers
+	SellerProfits   Profits `json:"seller_profits"`    // and sellers
+	Welfare         Welfare `json:"welfare"`           // where the surplus went, and where it was lost
+	Volatility      float64 `json:"volatility"`        // root mean squared change between successive prices, if trades are recorded
+	Expired         int64   `json:"expired,omitempty"` // orders that expired in the book
+
+	Autocorrelation   float64   `json:"autocorrelation"`              // lag-one autocorrelation of successive prices, if trades are recorded
+	RollingVolatility []float64 `json:"rolling_volatility,omitempty"` // the volatility over each window of VolatilityWindow trades
+
+	Equilibrium      Equilibrium    `json:"equilibrium"` // of a single period, with the values and costs in force at the end
+	EquilibriumPrice float64        `json:"equilibrium_price"`
+	Alpha            float64        `json:"alpha"` // Smith's alpha over all trades
+	Convergence      []Block        `json:"convergence,omitempty"`
+	Window           *WindowResults `json:"window,omitempty"` // the trades after the warm-up, if there is a measurement window
+	Rounds           []Round        `json:"rounds,omitempty"` // the trades of each round, if rounds are reported
+
+	Types    []TypeResults    `json:"types,omitempty"`    // by strategy, if the population is mixed
+	Periods  []Period         `json:"periods,omitempty"`  // by trading period, if there are several
+	Dealer   *DealerResults   `json:"dealer,omitempty"`   // the market makers, if any
+	Latency  *LatencyResults  `json:"latency,omitempty"`  // fast and slow traders, under latency
+	Book     *BookResults     `json:"book,omitempty"`     // the spread and depth of the order books, under a cda
+	Policy   *PolicyResults   `json:"policy,omitempty"`   // the effects of a price band or tax, if any
+	Marginal *MarginalResults `json:"marginal,omitempty"` // intramarginal and extramarginal trades, if trades are recorded
+	Shocks   []ShockResults   `json:"shocks,omitempty"`
+
+	Markets    []MarketResults   `json:"markets,omitempty"`    // by market, if there are several
+	Dispersion float64           `json:"dispersion,omitempty"` // the standard deviation of the markets' mean prices
+	Arbitrage  *ArbitrageResults `json:"arbitrage,omitempty"`  // the arbitrageurs, if any
+	Network    *NetworkResults   `json:"network,omitempty"`    // the trading network, unless it is complete
+	Spatial    *SpatialResults   `json:"spatial,omitempty"`    // prices across the lattice, if there is one
+}
+
+// Currency converts a figure counted in ticks, such as a price or a profit,
+// to currency.
+func (r Results) Currency(x float64) float64 {
+	return x * r.PriceTick
+}
+
+// Compute some statistics for the run.
+func (m *Model) computeStatistics() Results {
+	if m.columnar() {
+		return m.computeStoreStatistics()
+	}
+	r := Results{Seed: m.Seed, PriceTick: m.PriceTick}
+	t := m.tallyAgents(len(m.buyers), len(m.sellers), func(t *agentTally, buyer bool, i int) {
+		var x *agent
+		side := 0
+		if buyer {
+			x = &m.buyers[i]
+		} else {
+			x, side = &m.sellers[i], 1
+		}
+		surplus := x.surplus()
+		t.traded[side] += len(x.prices)
+		t.surplus[side] += surplus
+		t.profits[side].add(surplus)
+		for _, p := range x.prices {
+			t.prices.add(p)
+		}
+	})
+	t.results(&r)
+
+	// The dealers' side of their trades counts towards the prices, and their
+	// profits towards the realized surplus.
+	for _, d := range m.dealers {
+		r.RealizedSurplus += d.cash
+		r.Welfare.IntermediarySurplus += d.cash
+		for _, p := range d.prices {
+			t.prices.add(p)
+		}
+	}
+	if m.Dealer {
+		r.Dealer = m.dealerResults()
+	}
+	// And so do the arbitrageurs'.
+	for _, a := range m.arbitrageurs {
+		r.RealizedSurplus += a.cash
+		r.Welfare.IntermediarySurplus += a.cash
+		for _, p := range a.prices {
+			t.prices.add(p)
+		}
+	}
+	if m.Arbitrage {
+		r.Arbitrage = m.arbitrageResults()
+	}
+	prices := r.setPrices(t.prices, m.PriceBins)
+	for _, p := range m.periods {
+		r.Welfare.Unrealized += p.Unrealized
+		r.Welfare.Extramarginal += p.Extramarginal
+	}
+	if m.RecordTrades {
+		r.Volatility = volatility(m.trades, m.NumThreads)
+		r.Autocorrelation = autocorrelation(m.trades, m.NumThreads)
+		r.RollingVolatility = rollingVolatility(m.trades, m.NumThreads, m.VolatilityWindow)
+	}
+	if m.mixed() {
+		r.Types = m.typeResults()
+	}
+	if m.Latency > 0 {
+		r.Latency = m.latencyResults()
+	}
+	r.Expired = atomic.LoadInt64(&m.expired)
+	if m.books != nil {
+		r.Book = m.bookResults()
+	}
+
+	// The maximum surplus is that of every period traded.
+	eq, n := m.Equilibrium(), 1
+	if len(m.periods) > 1 {
+		n = len(m.periods)
+	}
+	r.Shocks = m.shocks
+	r.finish(eq, n, m.trades, m.ConvergenceBlock)
+	if m.Markets > 1 {
+		r.Markets, r.Dispersion = m.marketResults(n)
+	}
+	if m.graph != nil {
+		r.Network = m.networkResults()
+	}
+	if m.lattice != nil {
+		r.Spatial = m.spatialResults()
+	}
+	if m.policy {
+		r.Policy = m.policyResults(m.Progress(), r.MaxSurplus, n)
+	}
+	r.Alpha = prices.alpha(r.EquilibriumPrice)
+	if m.RecordTrades {
+		r.Marginal = m.classifyTrades(r.EquilibriumPrice)
+	}
+	if m.windowed() {
+		r.Window = m.windowResults(r.EquilibriumPrice)
+	}
+	if m.RoundSize > 0 {
+		r.Rounds = m.rounds(r.EquilibriumPrice, r.MaxSurplus)
+	}
+	return r
+}
+
+// A goroutine's share of the pass over the agents: the prices they traded at,
+// counted once per side of each trade, and the profits, units and surplus of
+// each side, buyers first.
+type agentTally struct {
+	prices  *histogram
+	profits [2]*profitTally
+	traded  [2]int
+	surplus [2]int
+}
+
+func (t *agentTally) merge(u *agentTally) {
+	t.prices.merge(u.prices)
+	for side := range t.traded {
+		t.profits[side].merge(&u.profits[side].histogram)
+		t.traded[side] += u.traded[side]
+		t.surplus[side] += u.surplus[side]
+	}
+}
+
+// Fill in the trade counts, surplus and profits of the tally.
+func (t *agentTally) results(r *Results) {
+	r.NumberBought, r.NumberSold = t.traded[0], t.traded[1]
+	r.Welfare.BuyerSurplus, r.Welfare.SellerSurplus = t.surplus[0], t.surplus[1]
+	r.RealizedSurplus += t.surplus[0] + t.surplus[1]
+	r.Profits = t.profits[0].pool(t.profits[1]).summary()
+	r.BuyerProfits, r.SellerProfits = t.profits[0].summary(), t.profits[1].summary()
+}
+
+// Tally the buyers and sellers in one pass, in parallel over blocks of them.
+// visit adds agent i of a side to the tally of the goroutine calling it. Every
+// tally is made of counts and sums of integers, so the result does not depend
+// on how the blocks fall to the goroutines, and it needs no more memory as
+// trades grow.
+func (m *Model) tallyAgents(buyers, sellers int, visit func(t *agentTally, buyer bool, i int)) *agentTally {
+	n := buyers
+	if sellers > n {
+		n = sellers
+	}
+	tallies := make([]*agentTally, blockWorkers(n))
+	for w := range tallies {
+		bound := m.profitBound()
+		tallies[w] = &agentTally{prices: newHistogram(0, m.maxPrice()), profits: [2]*profitTally{newProfitTally(bound), newProfitTally(bound)}}
+	}
+	for _, side := range []struct {
+		buyer bool
+		n     int
+	}{{true, buyers}, {false, sellers}} {
+		m.inBlocks(side.n, func(w, _, lo, hi int) {
+			for i := lo; i < hi; i++ {
+				visit(tallies[w], side.buyer, i)
+			}
+		})
+	}
+	for _, t := range tallies[1:] {
+		tallies[0].merge(t)
+	}
+	return tallies[0]
+}
+
+// Fill in the price statistics from a histogram of the prices, binned into
+// bins, and return their moments.
+func (r *Results) setPrices(prices *histogram, bins int) moments {
+	s := prices.moments()
+	r.MeanPrice = s.mean
+	r.SDPrice = s.sd()
+	r.MinPrice = s.min
+	r.MaxPrice = s.max
+	r.MedianPrice = prices.median()
+	r.P5Price = prices.quantile(0.05)
+	r.P95Price = prices.quantile(0.95)
+	r.IQRPrice = prices.quantile(0.75) - prices.quantile(0.25)
+	if bins > 0 && s.n > 0 {
+		r.PriceHistogram = prices.bins(int(s.min), int(s.max), bins)
+	}
+	if r.MeanPrice != 0 {
+		r.CVPrice = r.SDPrice / r.MeanPrice
+	}
+	return s
+}
+
+// Fill in the statistics that compare the run with the competitive
+// equilibrium of each of its periods.
+func (r *Results) finish(eq Equilibrium, periods int, trades []Trade, block int) {
+	r.Equilibrium = eq
+	r.EquilibriumPrice = eq.Price()
+	r.MaxSurplus = eq.Surplus * periods
+	if block > 0 {
+		r.Convergence = shockedConvergence(trades, block, r.EquilibriumPrice, r.Shocks)
+	}
+	if r.MaxSurplus > 0 {
+		r.Efficiency = 100 * float64(r.RealizedSurplus) / float64(r.MaxSurplus)
+	}
+}
+
+// WritePriceHistogramCSV writes the bins of a histogram of prices as CSV with
+// a header row.
+func WritePriceHistogramCSV(w io.Writer, bins []Bin) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"low", "high", "count"})
+	for _, b := range bins {
+		cw.Write([]string{strconv.Itoa(b.Low), strconv.Itoa(b.High), strconv.Itoa(b.Count)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,330 @@
+package zitraders
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestFindEquilibrium(t *testing.T) {
+	// Demand 30, 20, 10 against supply 5, 15, 25: two units trade, for a
+	// surplus of 25 + 5, at prices between 15 and 20.
+	e := findEquilibrium([]int{30, 20, 10}, []int{5, 15, 25})
+	if e.Quantity != 2 || e.Surplus != 30 || e.PriceLow != 15 || e.PriceHigh != 20 || e.Price() != 17.5 {
+		t.Errorf("got %+v", e)
+	}
+
+	if e := findEquilibrium([]int{5}, []int{10}); e.Quantity != 0 || e.Surplus != 0 {
+		t.Errorf("no trade expected, got %+v", e)
+	}
+
+	// The exported form sorts copies of schedules given in any order.
+	values, costs := []int{10, 30, 20}, []int{25, 5, 15}
+	if e := FindEquilibrium(values, costs); e != (Equilibrium{Quantity: 2, PriceLow: 15, PriceHigh: 20, Surplus: 30}) {
+		t.Errorf("got %+v", e)
+	}
+	if values[0] != 10 || costs[0] != 25 {
+		t.Errorf("schedules reordered to %v and %v", values, costs)
+	}
+}
+
+func TestComputeStatistics(t *testing.T) {
+	config := testConfig()
+	config.NumBuyers, config.NumSellers, config.NumThreads = 2, 2, 1
+	m := newTestModel(t, config)
+
+	m.buyers[0].schedule[0], m.buyers[0].value = 30, 30
+	m.buyers[1].schedule[0], m.buyers[1].value = 20, 20
+	m.sellers[0].schedule[0], m.sellers[0].value = 5, 5
+	m.sellers[1].schedule[0], m.sellers[1].value = 25, 25
+	m.buyers[0].buy(10)
+	m.sellers[0].sell(10)
+
+	r := m.computeStatistics()
+	if r.NumberBought != 1 || r.NumberSold != 1 {
+		t.Errorf("got %d bought and %d sold", r.NumberBought, r.NumberSold)
+	}
+	if r.MeanPrice != 10 || r.RealizedSurplus != 25 || r.MaxSurplus != 25 || r.Efficiency != 100 {
+		t.Errorf("got %+v", r)
+	}
+}
+
+// A price tick changes the currency prices are reported in, not the trades.
+func TestPriceTick(t *testing.T) {
+	config := testConfig()
+	config.RecordTrades = true
+	want := newTestModel(t, config).Run()
+	config.PriceTick = 0.25
+	got := newTestModel(t, config).Run()
+
+	if got.PriceTick != 0.25 || want.PriceTick != 1 {
+		t.Errorf("got price ticks %v and %v", got.PriceTick, want.PriceTick)
+	}
+	got.PriceTick = 1
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("a price tick changed the results")
+	}
+	if p := got.MeanPrice; (Results{PriceTick: 0.25}).Currency(p) != p/4 {
+		t.Errorf("a mean price of %v ticks is not %v in currency", p, p/4)
+	}
+}
+
+func TestConvergence(t *testing.T) {
+	trades := []Trade{{Price: 10}, {Price: 20}, {Price: 30}}
+	blocks := convergence(trades, 2, 15)
+	if len(blocks) != 2 {
+		t.Fatalf("got %d blocks", len(blocks))
+	}
+	if b := blocks[0]; b.Trades != 2 || b.Mean != 15 || b.Variance != 25 || b.Deviation != 0 || b.Running != 15 {
+		t.Errorf("first block %+v", b)
+	}
+	if b := blocks[1]; b.Trades != 3 || b.Mean != 30 || b.Deviation != 15 || b.Running != 20 || b.Alpha != 100 {
+		t.Errorf("second block %+v", b)
+	}
+	if a := blocks[0].Alpha; math.Abs(a-100*5.0/15) > 1e-9 {
+		t.Errorf("first block alpha %v", a)
+	}
+
+	var prices moments
+	for _, tr := range trades {
+		prices.add(float64(tr.Price))
+	}
+	if a, want := prices.alpha(15), 100*math.Sqrt(275.0/3)/15; math.Abs(a-want) > 1e-9 {
+		t.Errorf("overall alpha %v, want %v", a, want)
+	}
+}
+
+// The measurement window covers the trades of the log after the warm-up, and
+// a window that covers them all agrees with the run's statistics.
+func TestWindow(t *testing.T) {
+	for _, layout := range []string{AoS, SoA} {
+		config := testConfig()
+		config.Layout, config.WarmUp = layout, 0
+		config.Measure = 1 << 30
+		m := newTestModel(t, config)
+		r := m.Run()
+		w := r.Window
+		if w == nil || w.Trades != len(m.Trades()) || w.Surplus != r.RealizedSurplus || math.Abs(w.MeanPrice-r.MeanPrice) > 1e-9 || w.MedianPrice != r.MedianPrice {
+			t.Fatalf("%s: window %+v of a run with %d trades, %+v", layout, w, len(m.Trades()), r)
+		}
+
+		config.WarmUp, config.Measure = 100, 200
+		m = newTestModel(t, config)
+		r = m.Run()
+		var prices moments
+		for _, tr := range m.Trades()[100:300] {
+			prices.add(float64(tr.Price))
+		}
+		if w := r.Window; w.WarmUp != 100 || w.Trades != 200 || math.Abs(w.MeanPrice-prices.mean) > 1e-9 || w.MinPrice != prices.min {
+			t.Errorf("%s: window %+v, want a mean price of %v", layout, w, prices.mean)
+		}
+	}
+}
+
+// Rounds follow the trades of each round of attempts to the run's efficiency.
+func TestRounds(t *testing.T) {
+	for _, modify := range []func(*Config){
+		func(c *Config) {},
+		func(c *Config) { c.Layout = SoA },
+		func(c *Config) { c.Units, c.Periods = 3, 2 },
+	} {
+		config := testConfig()
+		config.RoundSize = 500
+		modify(&config)
+		m := newTestModel(t, config)
+		r := m.Run()
+
+		counts := map[[2]int]int{}
+		for _, tr := range m.Trades() {
+			counts[[2]int{tr.Period, tr.Tick / 500}]++
+		}
+		total, efficiency := 0, 0.0
+		for k, x := range r.Rounds {
+			if x.Trades != counts[[2]int{x.Period, x.Round}] || x.Efficiency < efficiency {
+				t.Fatalf("%+v: round %d %+v after an efficiency of %v, want %d trades", config, k, x, efficiency, counts[[2]int{x.Period, x.Round}])
+			}
+			if k > 0 && r.Rounds[k-1].Period == x.Period && r.Rounds[k-1].Round != x.Round-1 {
+				t.Fatalf("%+v: round %+v follows %+v", config, x, r.Rounds[k-1])
+			}
+			total, efficiency = total+x.Trades, x.Efficiency
+		}
+		if total != len(m.Trades()) || math.Abs(efficiency-r.Efficiency) > 1e-9 {
+			t.Errorf("%+v: rounds hold %d trades and reach an efficiency of %v, want %d and %v", config, total, efficiency, len(m.Trades()), r.Efficiency)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	s := Summarize([]Results{{NumberBought: 10, MeanPrice: 14}, {NumberBought: 20, MeanPrice: 16}})
+	if s.Reps != 2 || s.Quantity.Mean != 15 || s.MeanPrice.Mean != 15 {
+		t.Errorf("got %+v", s)
+	}
+	if math.Abs(s.Quantity.SD-math.Sqrt(50)) > 1e-9 {
+		t.Errorf("quantity s.d. %v", s.Quantity.SD)
+	}
+	if s.Quantity.Low >= 15 || s.Quantity.High <= 15 {
+		t.Errorf("confidence interval %+v does not cover the mean", s.Quantity)
+	}
+}
+
+func TestSummarizeBootstrap(t *testing.T) {
+	var results []Results
+	for i := 0; i < 20; i++ {
+		results = append(results, Results{NumberBought: 10 + i%5, MeanPrice: 100, Efficiency: float64(90 + i%3)})
+	}
+	s := SummarizeBootstrap(results, 500, 1)
+	for _, e := range s.estimates() {
+		if b := e.Bootstrap; b == nil || b.Low > e.Mean || b.High < e.Mean {
+			t.Errorf("bootstrap interval %v of a mean of %v", b, e.Mean)
+		}
+	}
+	if b := s.MeanPrice.Bootstrap; b.Low != 100 || b.High != 100 {
+		t.Errorf("bootstrap interval %+v of a constant", b)
+	}
+	if !reflect.DeepEqual(s, SummarizeBootstrap(results, 500, 1)) {
+		t.Errorf("bootstrap not reproducible")
+	}
+	if Summarize(results).Quantity.Bootstrap != nil {
+		t.Errorf("Summarize drew a bootstrap")
+	}
+}
+
+// The block bootstrap of a run brackets its statistics, and needs its trades.
+func TestBootstrap(t *testing.T) {
+	config := testConfig()
+	config.RecordTrades = true
+	m := newTestModel(t, config)
+	r := m.Run()
+	b, err := m.Bootstrap(r, 500, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := len(m.Trades())
+	if b.Block*b.Block*b.Block < n || (b.Block-1)*(b.Block-1)*(b.Block-1) >= n {
+		t.Errorf("block of %d trades for %d trades", b.Block, n)
+	}
+	for _, c := range []struct {
+		name     string
+		i        Interval
+		estimate float64
+	}{{"mean price", b.MeanPrice, r.MeanPrice}, {"efficiency", b.Efficiency, r.Efficiency}, {"alpha", b.Alpha, r.Alpha}} {
+		if c.i.Low > c.estimate || c.i.High < c.estimate || c.i.Low == c.i.High {
+			t.Errorf("%s: interval %+v of %v", c.name, c.i, c.estimate)
+		}
+	}
+	if again, _ := m.Bootstrap(r, 500, 0); again != b {
+		t.Errorf("bootstrap not reproducible: %+v, then %+v", b, again)
+	}
+	if _, err := m.Bootstrap(r, 0, 0); err == nil {
+		t.Errorf("no resamples accepted")
+	}
+
+	m = newTestModel(t, testConfig())
+	if _, err := m.Bootstrap(m.Run(), 500, 0); err == nil {
+		t.Errorf("bootstrap without recorded trades")
+	}
+}
+
+// Replications run in parallel match those run one after another.
+func TestReplicateParallel(t *testing.T) {
+	config := testConfig()
+	want, err := Replicate(config, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReplicateParallel(context.Background(), config, 6, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parallel replications differ:\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+// Replications stop once the interval is narrow enough, whatever the number
+// of workers.
+func TestReplicateUntil(t *testing.T) {
+	config := testConfig()
+	config.MaxNumberOfTrades = 20000
+	p := Precision{Outcome: OutcomeEfficiency, Tolerance: 2, MaxReps: 50}
+	results, err := ReplicateUntil(context.Background(), config, p, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) < defaultMinReps || len(results) == 50 || !p.Met(results) || p.Met(results[:len(results)-1]) {
+		t.Fatalf("stopped after %d replications with a half-width of %v", len(results), p.Estimate(results).HalfWidth())
+	}
+	parallel, err := ReplicateUntil(context.Background(), config, p, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parallel, results) {
+		t.Errorf("%d replications on 3 workers, %d on one", len(parallel), len(results))
+	}
+
+	p.Tolerance = 1e-9
+	if results, _ := ReplicateUntil(context.Background(), config, p, 2); len(results) != 50 || p.Met(results) {
+		t.Errorf("an unreachable tolerance stopped after %d replications", len(results))
+	}
+	if _, err := ReplicateUntil(context.Background(), config, Precision{Outcome: "quantity", Tolerance: 1}, 1); err == nil {
+		t.Errorf("unknown outcome accepted")
+	}
+}
+
+// Antithetic pairs share a seed, their second runs mirrored, and common
+// random numbers give identical configurations identical replications.
+func TestVarianceReduction(t *testing.T) {
+	config := testConfig()
+	config.MaxNumberOfTrades = 20000
+	results, err := ReplicateAntithetic(context.Background(), config, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := Replicate(config, 3)
+	for i := range plain {
+		a, b := results[2*i], results[2*i+1]
+		if !reflect.DeepEqual(a, plain[i]) || b.Seed != a.Seed || reflect.DeepEqual(a, b) {
+			t.Errorf("pair %d: %+v and %+v", i, a, b)
+		}
+	}
+	if s := SummarizePairs(results); s.Reps != 3 || math.Abs(s.MeanPrice.Mean-Summarize(results).MeanPrice.Mean) > 1e-9 {
+		t.Errorf("summary of pairs %+v", s)
+	}
+
+	taxed := config
+	taxed.Tax = 2
+	common, err := ReplicateCommon(context.Background(), []Config{config, config, taxed}, 4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(common[0], common[1]) || common[2][0].Seed != common[0][0].Seed {
+		t.Fatalf("replications of the same configuration differ")
+	}
+	if d := Difference(common[0], common[1]); d.Reps != 4 || d.MeanPrice.Mean != 0 || d.Efficiency.SD != 0 {
+		t.Errorf("difference of identical runs %+v", d)
+	}
+	if d := Difference(common[0], common[2]); d.Quantity.Mean >= 0 {
+		t.Errorf("a tax raised the quantity traded by %v", d.Quantity.Mean)
+	}
+}
+
+// The bootstrap interval of a difference brackets its mean, and vanishes
+// when the configurations do not differ.
+func TestCompare(t *testing.T) {
+	base := []Results{{NumberBought: 10, MeanPrice: 14}, {NumberBought: 20, MeanPrice: 16}, {NumberBought: 30, MeanPrice: 15}}
+	treatment := []Results{{NumberBought: 8, MeanPrice: 14}, {NumberBought: 19, MeanPrice: 17}, {NumberBought: 25, MeanPrice: 15}}
+	c := Compare(base, treatment, 1000, 1)
+	q := c.Outcomes[0]
+	if c.Reps != 3 || q.Outcome != OutcomeQuantity || math.Abs(q.Difference.Mean+8.0/3) > 1e-9 {
+		t.Fatalf("comparison %+v", c)
+	}
+	if b := q.Difference.Bootstrap; b.Low > q.Difference.Mean || b.High < q.Difference.Mean || b.Low < -5 || b.High > -1 {
+		t.Errorf("bootstrap interval %+v of a mean difference of %v", b, q.Difference.Mean)
+	}
+	for _, o := range Compare(base, base, 1000, 1).Outcomes {
+		if b := o.Difference.Bootstrap; o.Difference.Mean != 0 || b.Low != 0 || b.High != 0 {
+			t.Errorf("%s differs from itself: %+v", o.Outcome, o)
+		}
+	}
+}
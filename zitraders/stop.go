@@ -0,0 +1,108 @@
+package zitraders
+
+// Reasons a run stopped.
+const (
+	StopAttempts = "attempts" // every trade attempt was made
+	StopCleared  = "cleared"  // no mutually beneficial trade remains
+	StopRate     = "rate"     // the trade rate fell below MinTradeRate
+	StopObserver = "observer" // an observer stopped the run
+	StopCanceled = "canceled" // the run's context was canceled or its deadline passed
+	StopReplayed = "replayed" // the results are of a replayed trade log
+)
+
+// Whether the whole session has ended early, rather than just the period
+// under way.
+func (m *Model) ended() bool {
+	return m.stopped == StopObserver || m.stopped == StopCanceled
+}
+
+// The tick size used by the stopping rules when none is configured.
+const defaultTickSize = 10000
+
+// Check the configured stopping rules at the end of every tick. The rate rule
+// compares the trades executed in the tick with the attempts made in it.
+func (m *Model) stoppingRules() Observer {
+	var last Tick
+	return func(m *Model, t Tick) bool {
+		defer func() { last = t }()
+		if m.StopWhenCleared && m.cleared() {
+			m.stopped = StopCleared
+			return false
+		}
+		if attempts := t.Attempts - last.Attempts; m.MinTradeRate > 0 && attempts > 0 &&
+			float64(t.Trades-last.Trades)/float64(attempts) < m.MinTradeRate {
+			m.stopped = StopRate
+			return false
+		}
+		return true
+	}
+}
+
+// Whether no thread can find another trade. A trade is possible within a
+// partition while the highest bid any buyer there could make reaches the
+// lowest ask any seller there could make.
+func (m *Model) cleared() bool {
+	for t := 0; t < m.NumThreads; t++ {
+		var buyers, sellers agentStore
+		switch {
+		case m.Matching != Partitioned:
+			if t > 0 {
+				return true
+			}
+			buyers, sellers = aosStore(m.buyers), aosStore(m.sellers)
+		case m.columnar():
+			lowerBuyer, upperBuyer, lowerSeller, upperSeller := m.bounds(t)
+			buyers, sellers = m.buyerStore.Slice(lowerBuyer, upperBuyer), m.sellerStore.Slice(lowerSeller, upperSeller)
+		default:
+			lowerBuyer, upperBuyer, lowerSeller, upperSeller := m.bounds(t)
+			buyers, sellers = aosStore(m.buyers[lowerBuyer:upperBuyer]), aosStore(m.sellers[lowerSeller:upperSeller])
+		}
+		if m.highestBid(buyers) >= m.lowestAsk(sellers) {
+			return false
+		}
+	}
+	return true
+}
+
+// The highest bid any buyer who can still trade could make, or zero.
+func (m *Model) highestBid(buyers agentStore) int {
+	high := 0
+	for i := 0; i < buyers.Len(); i++ {
+		if !buyers.CanTrade(i) {
+			continue
+		}
+		bid := buyers.Value(i)
+		if m.policy {
+			bid = m.bidLimit(bid)
+		}
+		if buyers.Unconstrained(i) {
+			bid = m.maxPrice()
+		}
+		if bid > high {
+			high = bid
+		}
+	}
+	return high
+}
+
+// The lowest ask any seller who can still trade could make, or one more than
+// the highest possible price.
+func (m *Model) lowestAsk(sellers agentStore) int {
+	low := m.maxPrice() + 1
+	for i := 0; i < sellers.Len(); i++ {
+		if !sellers.CanTrade(i) {
+			continue
+		}
+		ask := sellers.Value(i)
+		if m.policy {
+			ask = m.askLimit(ask)
+		}
+		if sellers.Unconstrained(i) {
+			ask = 1
+		}
+		if ask < low {
+			low = ask
+		}
+	}
+	return low
+}
@@ -0,0 +1,66 @@
+package zitraders
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStopWhenCleared(t *testing.T) {
+	for _, layout := range []string{AoS, SoA} {
+		config := testConfig()
+		config.NumBuyers, config.NumSellers = 100, 100
+		config.MaxNumberOfTrades = 10000000
+		config.Layout = layout
+		config.StopWhenCleared = true
+		m := newTestModel(t, config)
+
+		r := m.Run()
+		if r.Stopped != StopCleared || r.Attempts >= int64(config.MaxNumberOfTrades) {
+			t.Errorf("%s: stopped for %q after %d attempts", layout, r.Stopped, r.Attempts)
+		}
+		if !m.cleared() {
+			t.Errorf("%s: trades remain possible", layout)
+		}
+	}
+}
+
+func TestMinTradeRate(t *testing.T) {
+	config := testConfig()
+	config.MinTradeRate = 0.5
+	r := newTestModel(t, config).Run()
+	if r.Stopped != StopRate || r.Attempts != 4*defaultTickSize {
+		t.Errorf("stopped for %q after %d attempts", r.Stopped, r.Attempts)
+	}
+
+	r = newTestModel(t, testConfig()).Run()
+	if r.Stopped != StopAttempts {
+		t.Errorf("stopped for %q without stopping rules", r.Stopped)
+	}
+}
+
+// A canceled context ends the session early, with or without ticks, and the
+// statistics cover the trades made until then.
+func TestRunContext(t *testing.T) {
+	for _, tick := range []int{0, 1000} {
+		for _, matching := range []string{Partitioned, Pool} {
+			config := testConfig()
+			config.Matching, config.TickSize, config.Periods = matching, tick, 2
+			config.MaxNumberOfTrades = 1 << 30
+			m := newTestModel(t, config)
+			if tick > 0 {
+				m.Observe(func(*Model, Tick) bool { return true })
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			r := m.RunContext(ctx)
+			cancel()
+			if r.Stopped != StopCanceled || r.Attempts >= int64(config.MaxNumberOfTrades) || r.NumberBought == 0 {
+				t.Errorf("tick %d, %s: stopped for %q after %d attempts and %d trades", tick, matching, r.Stopped, r.Attempts, r.NumberBought)
+			}
+			if m.period != 1 {
+				t.Errorf("tick %d, %s: %d periods opened", tick, matching, m.period)
+			}
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package zitraders
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// A Strategy decides a trader's quotes. Strategies that keep no state may be
+// shared by many traders.
+type Strategy interface {
+	Bid(ctx MarketView) int // a buyer's bid, or NoQuote to stay out
+	Ask(ctx MarketView) int // a seller's ask, or NoQuote to stay out
+}
+
+// A Learner is a strategy that learns from what happens to its trader's
+// quotes.
+type Learner interface {
+	Strategy
+	Traded(ctx MarketView, price int) // the trader is about to trade at price
+	Missed(ctx MarketView, quote int) // the other side's quote did not meet the trader's
+}
+
+// NoQuote is the quote of a trader who stays out of the market.
+const NoQuote = 0
+
+// A MarketView is what a trader knows of itself and its market when it
+// quotes. The current quotes and the clock are kept only when some trader in
+// the market may consult them; otherwise they are NoQuote and zero.
+type MarketView struct {
+	Value          int // the marginal value of a buyer or cost of a seller, net of any tax it pays
+	MaxBuyerValue  int
+	MaxSellerValue int
+	Bid            int // the current best bid, or NoQuote
+	Ask            int // the current best ask, or NoQuote
+	Time           int // the thread's attempt number
+	End            int // the attempt number at which the market closes
+	Rand           *rand.Rand
+
+	history *history
+}
+
+// MaxPrice is the highest price any trader can quote.
+func (v MarketView) MaxPrice() int {
+	if v.MaxBuyerValue > v.MaxSellerValue {
+		return v.MaxBuyerValue
+	}
+	return v.MaxSellerValue
+}
+
+// A StrategyFactory makes the strategy of a new buyer or seller, drawing any
+// random parameters from r.
+type StrategyFactory func(buyer bool, r *rand.Rand) Strategy
+
+var (
+	strategiesMu sync.RWMutex
+	strategies   = map[string]StrategyFactory{
+		"zi-c":   func(bool, *rand.Rand) Strategy { return zic{} },
+		"zi-u":   func(bool, *rand.Rand) Strategy { return ziu{} },
+		"zip":    func(buyer bool, r *rand.Rand) Strategy { return newZIP(buyer, r) },
+		"gd":     func(bool, *rand.Rand) Strategy { return gd{} },
+		"sniper": func(bool, *rand.Rand) Strategy { return sniper{} },
+	}
+	// Strategies that consult neither the current quotes nor the clock, so
+	// markets of only these need not keep them.
+	oblivious = map[string]bool{"zi-c": true, "zi-u": true, "zip": true}
+)
+
+// RegisterStrategy makes a strategy available by name, for Config.Strategy.
+// It replaces any strategy registered under the same name.
+func RegisterStrategy(name string, f StrategyFactory) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+	strategies[name] = f
+	delete(oblivious, name)
+}
+
+// Strategies returns the names of the registered strategies.
+func Strategies() []string {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func strategy(name string) (StrategyFactory, error) {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	f, ok := strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy %q", name)
+	}
+	return f, nil
+}
+
+func isOblivious(name string) bool {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	return oblivious[name]
+}
+
+// What a trader sees of the thread's market h.
+func (m *Model) view(a *agent, h *history, r *rand.Rand) MarketView {
+	v := MarketView{
+		Value:          m.limit(a),
+		MaxBuyerValue:  m.MaxBuyerValue,
+		MaxSellerValue: m.MaxSellerValue,
+		Rand:           r,
+		history:        h,
+	}
+	if h != nil {
+		v.Bid, v.Ask, v.Time, v.End = h.bid, h.ask, h.time, h.end
+	}
+	return v
+}
+
+// Let a learning trader know it is about to trade at price.
+func (m *Model) traded(a *agent, h *history, price int, r *rand.Rand) {
+	if !m.learning {
+		return
+	}
+	if l, ok := a.strategy.(Learner); ok {
+		l.Traded(m.view(a, h, r), price)
+	}
+}
+
+// Let a learning trader know the other side's quote q did not meet its own.
+func (m *Model) missed(a *agent, h *history, q int, r *rand.Rand) {
+	if !m.learning {
+		return
+	}
+	if l, ok := a.strategy.(Learner); ok {
+		l.Missed(m.view(a, h, r), q)
+	}
+}
+
+// A ZI-C trader bids at most its value and asks at least its cost, up to the
+// highest seller cost.
+type zic struct{}
+
+func (zic) Bid(ctx MarketView) int { return zicBid(ctx.Value, ctx.Rand) }
+func (zic) Ask(ctx MarketView) int {
+	if ctx.Value > ctx.MaxSellerValue { // a seller's cost and its tax may exceed the highest cost
+		return ctx.Value
+	}
+	return zicAsk(ctx.Value, ctx.MaxSellerValue, ctx.Rand)
+}
+
+func zicBid(value int, r *rand.Rand) int { return r.Intn(value) + 1 }
+func zicAsk(cost, maxCost int, r *rand.Rand) int {
+	return cost + r.Intn(maxCost-cost+1)
+}
+
+// A ZI-U trader quotes anywhere in the price range.
+type ziu struct{}
+
+func (ziu) Bid(ctx MarketView) int { return ctx.Rand.Intn(ctx.MaxPrice()) + 1 }
+func (ziu) Ask(ctx MarketView) int { return ctx.Rand.Intn(ctx.MaxPrice()) + 1 }
@@ -0,0 +1,65 @@
+package zitraders
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// A truthful trader quotes its value or cost.
+type truthful struct{}
+
+func (truthful) Bid(ctx MarketView) int { return ctx.Value }
+func (truthful) Ask(ctx MarketView) int { return ctx.Value }
+
+// A registered strategy is used for the traders not drawn as another type,
+// and sees the current quotes and the clock.
+func TestRegisterStrategy(t *testing.T) {
+	RegisterStrategy("truthful", func(bool, *rand.Rand) Strategy { return truthful{} })
+	config := testConfig()
+	config.Strategy = "truthful"
+	config.RecordTrades = true
+	m := newTestModel(t, config)
+	if m.histories == nil {
+		t.Fatal("a market with a registered strategy keeps no histories")
+	}
+	m.Run()
+	if len(m.Trades()) == 0 {
+		t.Fatal("no trades")
+	}
+	for _, tr := range m.Trades() {
+		if tr.Bid < tr.Ask || tr.Price < tr.Ask || tr.Price > tr.Bid {
+			t.Fatalf("illegal trade %+v", tr)
+		}
+	}
+}
+
+// A population mix gives each strategy about its share of the traders, and
+// the strategies' results add up to the market's.
+func TestPopulationMix(t *testing.T) {
+	config := testConfig()
+	config.Population = map[string]float64{"zi-c": 0.5, "zip": 0.3, "sniper": 0.2}
+	config.Institution = CDA
+	m := newTestModel(t, config)
+	r := m.Run()
+	if len(r.Types) != 3 {
+		t.Fatalf("got results for %d strategies, want 3", len(r.Types))
+	}
+	traders, profit := 0, 0
+	for _, tr := range r.Types {
+		n := tr.Buyers + tr.Sellers
+		share := float64(n) / float64(config.NumBuyers+config.NumSellers)
+		if want := config.Population[tr.Strategy]; share < want-0.05 || share > want+0.05 {
+			t.Errorf("%s: share %.3f, want about %.1f", tr.Strategy, share, want)
+		}
+		traders += n
+		profit += tr.Profit
+	}
+	if traders != config.NumBuyers+config.NumSellers || profit != r.RealizedSurplus {
+		t.Errorf("strategies have %d traders and %d profit, want %d and %d", traders, profit, config.NumBuyers+config.NumSellers, r.RealizedSurplus)
+	}
+
+	config.Population["gd"] = 0.1
+	if _, err := New(config); err == nil {
+		t.Error("shares summing to more than 1 were accepted")
+	}
+}
@@ -0,0 +1,91 @@
+package zitraders
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Range declares the values a parameter takes in a sweep, either as an
+// explicit list or as an arithmetic sequence from From to To inclusive.
+type Range struct {
+	From   float64   `json:"from" yaml:"from" toml:"from"`
+	To     float64   `json:"to" yaml:"to" toml:"to"`
+	Step   float64   `json:"step" yaml:"step" toml:"step"`
+	Values []float64 `json:"values" yaml:"values" toml:"values"`
+}
+
+// Levels expands the range into the list of values it declares.
+func (r Range) Levels() []float64 {
+	if len(r.Values) > 0 {
+		return r.Values
+	}
+	if r.Step <= 0 {
+		return []float64{r.From}
+	}
+	var levels []float64
+	for i := 0; ; i++ {
+		v := r.From + float64(i)*r.Step
+		if v > r.To+r.Step*1e-9 {
+			break
+		}
+		levels = append(levels, v)
+	}
+	return levels
+}
+
+// Factor is a parameter varied in a sweep, named by its config key.
+type Factor struct {
+	Param  string
+	Levels []float64
+}
+
+// Cell is one point of a full factorial design.
+type Cell struct {
+	Levels []float64 // the level of each factor, in factor order
+	Config Config
+}
+
+// Factorial expands the factors into every combination of their levels
+// applied to the base configuration.
+func Factorial(base Config, factors []Factor) ([]Cell, error) {
+	cells := []Cell{{Config: base}}
+	for _, f := range factors {
+		var next []Cell
+		for _, c := range cells {
+			for _, v := range f.Levels {
+				n := Cell{Levels: append(append([]float64(nil), c.Levels...), v), Config: c.Config}
+				if err := n.Config.Set(f.Param, v); err != nil {
+					return nil, err
+				}
+				next = append(next, n)
+			}
+		}
+		cells = next
+	}
+	return cells, nil
+}
+
+// Set assigns a numeric value to the parameter with the given config key.
+func (c *Config) Set(param string, value float64) error {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if strings.Split(t.Field(i).Tag.Get("json"), ",")[0] != param {
+			continue
+		}
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.Int, reflect.Int64:
+			f.SetInt(int64(value))
+		case reflect.Float64:
+			f.SetFloat(value)
+		case reflect.Bool:
+			f.SetBool(value != 0)
+		default:
+			return fmt.Errorf("parameter %q cannot be swept", param)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown parameter %q", param)
+}
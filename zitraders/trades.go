@@ -0,0 +1,124 @@
+package zitraders
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Trade is a single executed transaction. Tick is the attempt number within
+// the goroutine and trading period that executed it; buyer and seller indices
+// are population-wide, or Dealer for a market maker and Arbitrageur for an
+// arbitrageur. Market is the market of the trading population it was in.
+// Extramarginal flags a trade of a unit that does not trade in the
+// competitive equilibrium, once the run's statistics are computed.
+type Trade struct {
+	Period int `json:"period"`
+	Tick   int `json:"tick"`
+	Thread int `json:"thread"`
+	Buyer  int `json:"buyer"`
+	Seller int `json:"seller"`
+	Bid    int `json:"bid"`
+	Ask    int `json:"ask"`
+	Price  int `json:"price"`
+	Market int `json:"market"`
+
+	Extramarginal bool `json:"extramarginal"`
+}
+
+// Fill in the agent indices of a trade, other than a dealer's or an
+// arbitrageur's.
+func (t Trade) between(buyer, seller int) Trade {
+	if t.Buyer >= 0 {
+		t.Buyer = buyer
+	}
+	if t.Seller >= 0 {
+		t.Seller = seller
+	}
+	return t
+}
+
+// Trades returns the executed trades in period and tick order. It is empty
+// unless the model was configured with RecordTrades.
+func (m *Model) Trades() []Trade {
+	return m.trades
+}
+
+// Merge the per-thread trade logs into a single log ordered by period and tick.
+func mergeTrades(logs [][]Trade) []Trade {
+	n := 0
+	for _, l := range logs {
+		n += len(l)
+	}
+	trades := make([]Trade, 0, n)
+	for _, l := range logs {
+		trades = append(trades, l...)
+	}
+	sort.SliceStable(trades, func(i, j int) bool {
+		if trades[i].Period != trades[j].Period {
+			return trades[i].Period < trades[j].Period
+		}
+		return trades[i].Tick < trades[j].Tick
+	})
+	return trades
+}
+
+// WriteTradesCSV writes a trade log as CSV with a header row.
+func WriteTradesCSV(w io.Writer, trades []Trade) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"period", "tick", "thread", "buyer", "seller", "bid", "ask", "price", "market", "extramarginal"})
+	for _, t := range trades {
+		extramarginal := "0"
+		if t.Extramarginal {
+			extramarginal = "1"
+		}
+		cw.Write([]string{
+			strconv.Itoa(t.Period),
+			strconv.Itoa(t.Tick),
+			strconv.Itoa(t.Thread),
+			strconv.Itoa(t.Buyer),
+			strconv.Itoa(t.Seller),
+			strconv.Itoa(t.Bid),
+			strconv.Itoa(t.Ask),
+			strconv.Itoa(t.Price),
+			strconv.Itoa(t.Market),
+			extramarginal,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ReadTradesCSV reads a trade log written by WriteTradesCSV, with or without
+// the extramarginal column.
+func ReadTradesCSV(r io.Reader) ([]Trade, error) {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(header) != 9 && len(header) != 10 {
+		return nil, fmt.Errorf("a trade log has 9 or 10 columns, not %d", len(header))
+	}
+	cr.FieldsPerRecord = len(header)
+	var trades []Trade
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return trades, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var f [10]int
+		for i, s := range rec {
+			if f[i], err = strconv.Atoi(s); err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+		}
+		trades = append(trades, Trade{Period: f[0], Tick: f[1], Thread: f[2], Buyer: f[3], Seller: f[4], Bid: f[5], Ask: f[6], Price: f[7], Market: f[8], Extramarginal: f[9] != 0})
+	}
+}
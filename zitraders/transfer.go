@@ -0,0 +1,145 @@
+package zitraders
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+// Under global or pool matching any two threads may draw the same trader, so
+// by default an attempt claims its buyer and seller before reading them and
+// is abandoned if another thread holds either. Under the cas transfer traders
+// are never claimed. A thread reads the marginal units of its buyer and
+// seller from their holdings and quotes on them, then commits the trade by
+// compare-and-swap: the buyer's holdings are marked pending, the seller's
+// decremented if they are unchanged, and the buyer's then incremented, or
+// restored if the seller had moved on. A thread that finds a buyer pending or
+// either trader's holdings changed since it quoted abandons the attempt, so no
+// thread ever waits for another. The price of each unit is written to the
+// unit's own slot in the trader's price history, and the traders' other
+// fields are brought up to date at the end of the period. Only ZI-C traders
+// trading bilaterally without price controls, market makers or arbitrageurs
+// can trade this way.
+
+// The bit that marks a buyer's holdings as pending.
+const pending = 1 << 30
+
+func (m *Model) checkTransfer() error {
+	switch m.Transfer {
+	case "":
+		m.Transfer = Claim
+	case Claim:
+	case Mutex:
+		if m.Matching != Global && m.Matching != Pool {
+			return fmt.Errorf("the mutex transfer requires global or pool matching")
+		}
+		m.stripes = make(stripes, stripeCount)
+	case CAS:
+		if m.Matching != Global && m.Matching != Pool {
+			return fmt.Errorf("the cas transfer requires global or pool matching")
+		}
+		if m.mixed() || m.policy || m.Dealer || m.Arbitrage {
+			return fmt.Errorf("the cas transfer supports only ZI-C traders without price controls, market makers or arbitrage")
+		}
+	default:
+		return fmt.Errorf("unknown transfer %q", m.Transfer)
+	}
+	return nil
+}
+
+// Have a ZI-C buyer and seller quote on their marginal units and, if a deal
+// is possible, trade one unit without claiming either. The returned trade is
+// as for match.
+func (m *Model) transfer(buyer, seller *agent, r *rand.Rand) (Trade, bool) {
+	held := atomic.LoadInt32(&buyer.quantityHeld)
+	stock := atomic.LoadInt32(&seller.quantityHeld)
+	if held&pending != 0 || int(held) >= len(buyer.schedule) || stock <= 0 {
+		return Trade{}, false
+	}
+	sold := len(seller.schedule) - int(stock)
+	bid := zicBid(buyer.schedule[held], r)
+	ask := zicAsk(seller.schedule[sold], m.MaxSellerValue, r)
+	if bid < ask {
+		return Trade{Bid: bid, Ask: ask}, false
+	}
+	price := ask + r.Intn(bid-ask+1)
+
+	if !atomic.CompareAndSwapInt32(&buyer.quantityHeld, held, held|pending) {
+		return Trade{Bid: bid, Ask: ask}, false
+	}
+	if !atomic.CompareAndSwapInt32(&seller.quantityHeld, stock, stock-1) {
+		atomic.StoreInt32(&buyer.quantityHeld, held)
+		return Trade{Bid: bid, Ask: ask}, false
+	}
+	// The price histories' lengths change only between periods, so each
+	// unit's slot lies beyond them.
+	buyer.prices[:cap(buyer.prices)][len(buyer.prices)+int(held)] = price
+	seller.prices[:cap(seller.prices)][len(seller.prices)+sold] = price
+	atomic.StoreInt32(&buyer.quantityHeld, held+1)
+	return Trade{Bid: bid, Ask: ask, Price: price}, true
+}
+
+// Bring the traders' values, price histories and latest prices up to date
+// with their holdings at the end of a period under the cas transfer.
+func (m *Model) settle() {
+	for _, agents := range [][]agent{m.buyers, m.sellers} {
+		for i := range agents {
+			a := &agents[i]
+			k := a.traded()
+			if k < len(a.schedule) {
+				a.value = a.schedule[k]
+			}
+			if k > 0 {
+				a.prices = a.prices[:len(a.prices)+k]
+				a.price = a.prices[len(a.prices)-1]
+			}
+		}
+	}
+}
+
+// Under the mutex transfer each trader is guarded by one of a fixed set of
+// mutexes, chosen by its index, and an attempt holds its buyer's and seller's
+// for the whole of the attempt. Unlike claims, which abandon an attempt on a
+// busy trader, the thread waits, so every attempt is made and strategies may
+// read and update any of a trader's fields. A mutex may guard several traders,
+// so threads sometimes wait on traders they do not share; a few thousand
+// keep that rare at any useful number of threads.
+
+// Mutexes striped over the traders.
+const stripeCount = 4096
+
+// Striped mutexes, each padded to its own cache line.
+type stripes []struct {
+	sync.Mutex
+	_ [56]byte
+}
+
+// The stripes of a buyer and a seller, in the order they are locked. Sellers
+// are offset by half the stripes so that a buyer and seller with the same index
+// do not share a mutex.
+func (s stripes) of(buyer, seller int) (int, int) {
+	i, j := buyer%len(s), (seller+len(s)/2)%len(s)
+	if i > j {
+		return j, i
+	}
+	return i, j
+}
+
+// Lock a buyer and a seller, by their population indices.
+func (s stripes) lock(buyer, seller int) {
+	i, j := s.of(buyer, seller)
+	s[i].Lock()
+	if j != i {
+		s[j].Lock()
+	}
+}
+
+// Unlock a buyer and a seller locked with lock.
+func (s stripes) unlock(buyer, seller int) {
+	i, j := s.of(buyer, seller)
+	if j != i {
+		s[j].Unlock()
+	}
+	s[i].Unlock()
+}
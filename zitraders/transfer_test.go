@@ -0,0 +1,69 @@
+package zitraders
+
+import (
+	"context"
+	"testing"
+)
+
+// Units traded by compare-and-swap are each priced once, in every period, and
+// the traders' values follow their holdings.
+func TestTransferCAS(t *testing.T) {
+	for _, matching := range []string{Global, Pool} {
+		config := testConfig()
+		config.Matching, config.Transfer = matching, CAS
+		config.Units, config.Periods, config.TickSize = 3, 2, 1000
+		config.RecordTrades = true
+		m := newTestModel(t, config)
+		m.Observe(func(m *Model, tick Tick) bool { return true })
+		r := m.Run()
+
+		if r.NumberBought == 0 || r.NumberBought != r.NumberSold || r.NumberBought != len(m.Trades()) {
+			t.Errorf("%s: %d bought, %d sold, %d trades logged", matching, r.NumberBought, r.NumberSold, len(m.Trades()))
+		}
+		for _, agents := range [][]agent{m.buyers, m.sellers} {
+			for _, a := range agents {
+				if k := a.traded(); k < len(a.schedule) && a.value != a.schedule[k] {
+					t.Fatalf("%s: %v has the value of another unit", matching, a)
+				}
+				for _, p := range a.prices {
+					if p < 1 || p > config.MaxBuyerValue {
+						t.Fatalf("%s: %v has an unpriced unit", matching, a)
+					}
+				}
+			}
+		}
+	}
+}
+
+// A buyer and seller guarded by the same mutex lock it once.
+func TestStripes(t *testing.T) {
+	s := make(stripes, stripeCount)
+	if i, j := s.of(7, 7); i == j {
+		t.Errorf("buyer and seller 7 share stripe %d", i)
+	}
+	if i, j := s.of(5, stripeCount/2+5); i != j {
+		t.Fatalf("stripes %d and %d, want one", i, j)
+	}
+	s.lock(5, stripeCount/2+5)
+	s.unlock(5, stripeCount/2+5)
+	s.lock(9, 3)
+	s.unlock(9, 3)
+}
+
+func benchmarkTransfer(b *testing.B, matching, transfer string) {
+	config := testConfig()
+	config.NumBuyers, config.NumSellers = 100000, 100000
+	config.Matching, config.Transfer = matching, transfer
+	config.MaxNumberOfTrades = config.NumThreads * (b.N/config.NumThreads + 2)
+	m := newTestModel(b, config)
+	b.ResetTimer()
+	m.openMarket(context.Background())
+}
+
+func BenchmarkPartitioned(b *testing.B) { benchmarkTransfer(b, Partitioned, Claim) }
+func BenchmarkGlobalClaim(b *testing.B) { benchmarkTransfer(b, Global, Claim) }
+func BenchmarkGlobalCAS(b *testing.B)   { benchmarkTransfer(b, Global, CAS) }
+func BenchmarkPoolClaim(b *testing.B)   { benchmarkTransfer(b, Pool, Claim) }
+func BenchmarkPoolCAS(b *testing.B)     { benchmarkTransfer(b, Pool, CAS) }
+func BenchmarkGlobalMutex(b *testing.B) { benchmarkTransfer(b, Global, Mutex) }
+func BenchmarkPoolMutex(b *testing.B)   { benchmarkTransfer(b, Pool, Mutex) }
@@ -0,0 +1,58 @@
+package zitraders
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// Check the sizes of the market before anything is derived from them, so
+// that a bad configuration is reported rather than panicking deep inside a
+// random draw. Where a configuration can be made to work, as with more
+// threads than a partitioned population can feed, it is adjusted with a
+// warning instead.
+func (m *Model) validate() error {
+	if m.SellerUnits == 0 {
+		m.SellerUnits = m.Units
+	}
+	if m.Units < 1 || m.SellerUnits < 1 {
+		return fmt.Errorf("agents must trade at least one unit")
+	}
+	if m.NumBuyers < 1 || m.NumSellers < 1 {
+		return fmt.Errorf("a market needs at least one buyer and one seller, not %d and %d", m.NumBuyers, m.NumSellers)
+	}
+	if m.MaxBuyerValue < 1 || m.MaxSellerValue < 1 {
+		return fmt.Errorf("the maximum buyer value and seller cost must be at least 1, not %d and %d", m.MaxBuyerValue, m.MaxSellerValue)
+	}
+	if m.NumThreads < 1 {
+		return fmt.Errorf("a run needs at least one thread, not %d", m.NumThreads)
+	}
+	if m.MaxNumberOfTrades < 1 {
+		return fmt.Errorf("a run needs at least one trade attempt, not %d", m.MaxNumberOfTrades)
+	}
+	if m.TickSize < 0 || m.ConvergenceBlock < 0 || m.VolatilityWindow < 0 || m.PriceBins < 0 || m.RoundSize < 0 {
+		return fmt.Errorf("ticks, convergence blocks, volatility windows, price bins and rounds must not be negative")
+	}
+	if m.WarmUp < 0 || m.Measure < 0 {
+		return fmt.Errorf("the warm-up and measurement window must not be negative")
+	}
+
+	// Under partitioned matching each thread needs a buyer and a seller of
+	// its own.
+	if m.Matching == "" || m.Matching == Partitioned {
+		thin := m.NumBuyers
+		if m.NumSellers < thin {
+			thin = m.NumSellers
+		}
+		if m.NumThreads > thin {
+			slog.Warn("too few traders for every thread under partitioned matching; using fewer threads", "threads", m.NumThreads, "now", thin)
+			m.NumThreads = thin
+		}
+	}
+	if m.MaxNumberOfTrades < 2*m.NumThreads {
+		return fmt.Errorf("%d trade attempts cannot be shared among %d threads", m.MaxNumberOfTrades, m.NumThreads)
+	}
+	if m.MaxNumberOfTrades%m.NumThreads != 0 && !m.SharedBudget {
+		slog.Warn("trade attempts are not divisible by the threads; the remainder is not made", "attempts", m.MaxNumberOfTrades, "threads", m.NumThreads)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package zitraders
+
+// Welfare decomposes a run's surplus as Gode and Sunder do. The realized
+// surplus is split between buyers, sellers and intermediaries. The shortfall
+// from the maximum is split between the intramarginal units that went
+// untraded and the extramarginal units that traded in their place, each unit
+// valued at its distance from the equilibrium price of its period. Without
+// intermediaries, taxes or shocks within a period, the two losses sum to the
+// maximum surplus less the realized.
+type Welfare struct {
+	BuyerSurplus        int     `json:"buyer_surplus"`
+	SellerSurplus       int     `json:"seller_surplus"`
+	IntermediarySurplus int     `json:"intermediary_surplus"` // of market makers and arbitrageurs
+	Unrealized          float64 `json:"unrealized"`           // the surplus of intramarginal units left untraded
+	Extramarginal       float64 `json:"extramarginal"`        // the surplus lost to extramarginal units traded
+}
+
+// The two sources of lost surplus, over some units.
+type losses struct {
+	unrealized, extramarginal float64
+}
+
+// Count a buyer's unit of value v, or a seller's unit of cost v, against the
+// equilibrium price p.
+func (l *losses) add(buyer bool, v int, traded bool, p float64) {
+	d := float64(v) - p // the unit's surplus at the equilibrium price
+	if !buyer {
+		d = -d
+	}
+	switch {
+	case d > 0 && !traded:
+		l.unrealized += d
+	case d < 0 && traded:
+		l.extramarginal -= d
+	}
+}
+
+// The losses of the period under way, against its equilibrium.
+func (m *Model) periodLosses() losses {
+	var l losses
+	if len(m.buyers) == 0 {
+		return l
+	}
+	p := m.Equilibrium().Price()
+	for _, agents := range [][]agent{m.buyers, m.sellers} {
+		for i := range agents {
+			a := &agents[i]
+			traded := a.traded()
+			for k, v := range a.schedule {
+				l.add(a.buyerOrSeller, v, k < traded, p)
+			}
+		}
+	}
+	return l
+}
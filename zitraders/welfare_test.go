@@ -0,0 +1,51 @@
+package zitraders
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLosses(t *testing.T) {
+	var l losses
+	l.add(true, 20, false, 15)  // an intramarginal buyer left out
+	l.add(true, 12, true, 15)   // an extramarginal buyer who traded
+	l.add(false, 10, true, 15)  // an intramarginal seller who traded
+	l.add(false, 18, true, 15)  // an extramarginal seller who traded
+	l.add(false, 15, false, 15) // a marginal seller
+	if l.unrealized != 5 || l.extramarginal != 6 {
+		t.Errorf("got %+v", l)
+	}
+}
+
+// Without intermediaries, the realized surplus goes to buyers and sellers and
+// the losses account for the rest of the maximum.
+func TestWelfareDecomposition(t *testing.T) {
+	for _, modify := range []func(*Config){
+		func(c *Config) {},
+		func(c *Config) { c.Layout = SoA },
+		func(c *Config) { c.Unconstrained = 1 },
+		func(c *Config) { c.Units, c.Periods, c.ZIP = 3, 2, 0.5 },
+		func(c *Config) { c.Institution = CDA },
+	} {
+		config := testConfig()
+		modify(&config)
+		r := newTestModel(t, config).Run()
+
+		w := r.Welfare
+		if w.BuyerSurplus+w.SellerSurplus != r.RealizedSurplus || w.IntermediarySurplus != 0 {
+			t.Errorf("%+v: %+v splits a realized surplus of %d", config, w, r.RealizedSurplus)
+		}
+		if gap := float64(r.MaxSurplus - r.RealizedSurplus); math.Abs(w.Unrealized+w.Extramarginal-gap) > 1e-9 {
+			t.Errorf("%+v: losses %+v account for %v of %v", config, w, w.Unrealized+w.Extramarginal, gap)
+		}
+	}
+}
+
+func TestWelfareIntermediaries(t *testing.T) {
+	config := testConfig()
+	config.Dealer = true
+	r := newTestModel(t, config).Run()
+	if w := r.Welfare; w.BuyerSurplus+w.SellerSurplus+w.IntermediarySurplus != r.RealizedSurplus || w.IntermediarySurplus != r.Dealer.Profit {
+		t.Errorf("%+v splits a realized surplus of %d, with %d to the dealers", w, r.RealizedSurplus, r.Dealer.Profit)
+	}
+}
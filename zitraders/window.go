@@ -0,0 +1,50 @@
+package zitraders
+
+// WindowResults describe the trades of the measurement window, those that
+// follow the first WarmUp trades of the log, up to Measure of them, so that
+// the market can be studied once the transient of its opening has passed.
+type WindowResults struct {
+	WarmUp      int     `json:"warm_up"` // trades discarded before the window
+	Trades      int     `json:"trades"`  // trades measured
+	MeanPrice   float64 `json:"mean_price"`
+	SDPrice     float64 `json:"sd_price"`
+	MedianPrice float64 `json:"median_price"`
+	MinPrice    float64 `json:"min_price"`
+	MaxPrice    float64 `json:"max_price"`
+	Alpha       float64 `json:"alpha"`   // Smith's alpha within the window
+	Surplus     int     `json:"surplus"` // the traders' surplus on the units traded in the window
+}
+
+// Whether the statistics are also computed over a measurement window.
+func (m *Model) windowed() bool {
+	return m.WarmUp > 0 || m.Measure > 0
+}
+
+// Summarize the trades of the measurement window against the equilibrium
+// price p.
+func (m *Model) windowResults(p float64) *WindowResults {
+	end := len(m.trades)
+	if m.Measure > 0 && m.WarmUp+m.Measure < end {
+		end = m.WarmUp + m.Measure
+	}
+	r := &WindowResults{WarmUp: m.WarmUp}
+	prices := newHistogram(0, m.maxPrice())
+	m.walkTrades(func(k int, t *Trade, value, cost int) {
+		if k < m.WarmUp || k >= end {
+			return
+		}
+		prices.add(t.Price)
+		if t.Buyer >= 0 {
+			r.Surplus += value - t.Price
+		}
+		if t.Seller >= 0 {
+			r.Surplus += t.Price - cost
+		}
+	})
+	s := prices.moments()
+	r.Trades = s.n
+	r.MeanPrice, r.SDPrice, r.MedianPrice = s.mean, s.sd(), prices.median()
+	r.MinPrice, r.MaxPrice = s.min, s.max
+	r.Alpha = s.alpha(p)
+	return r
+}
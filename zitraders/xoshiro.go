@@ -0,0 +1,99 @@
+package zitraders
+
+import (
+	"math/bits"
+	"math/rand"
+)
+
+// xoshiro is the xoshiro256** generator of Blackman and Vigna, a rand.Source64
+// with a 2^256 - 1 period whose jump function advances it by 2^128 steps.
+// Streams derived from one seed by successive jumps never overlap in any
+// feasible run, unlike streams seeded independently (for example from the
+// clock), which may collide.
+type xoshiro struct {
+	s    [4]uint64
+	flip uint64 // all ones for the antithetic stream, whose draws are the complements of the generator's
+}
+
+func newXoshiro(seed int64) *xoshiro {
+	x := &xoshiro{}
+	x.Seed(seed)
+	return x
+}
+
+// Seed expands the seed into the generator state with splitmix64, as the
+// authors recommend.
+func (x *xoshiro) Seed(seed int64) {
+	sm := uint64(seed)
+	for i := range x.s {
+		sm += 0x9e3779b97f4a7c15
+		z := sm
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		x.s[i] = z ^ (z >> 31)
+	}
+}
+
+func (x *xoshiro) Uint64() uint64 {
+	s := &x.s
+	result := bits.RotateLeft64(s[1]*5, 7) * 9
+	t := s[1] << 17
+	s[2] ^= s[0]
+	s[3] ^= s[1]
+	s[1] ^= s[2]
+	s[0] ^= s[3]
+	s[2] ^= t
+	s[3] = bits.RotateLeft64(s[3], 45)
+	return result ^ x.flip
+}
+
+// Make the generator antithetic, or not: each output x becomes ^x, so that a
+// uniform draw u becomes, to within a rounding, 1 - u.
+func (x *xoshiro) mirror(antithetic bool) *xoshiro {
+	x.flip = 0
+	if antithetic {
+		x.flip = ^uint64(0)
+	}
+	return x
+}
+
+func (x *xoshiro) Int63() int64 {
+	return int64(x.Uint64() >> 1)
+}
+
+var xoshiroJump = [4]uint64{0x180ec6d33cfd0aba, 0xd5a61266f0c9392c, 0xa9582618e03fc9aa, 0x39abdc4529b1661c}
+
+// jump advances the generator by 2^128 steps.
+func (x *xoshiro) jump() {
+	var t [4]uint64
+	for _, j := range xoshiroJump {
+		for b := uint(0); b < 64; b++ {
+			if j&(1<<b) != 0 {
+				for i := range t {
+					t[i] ^= x.s[i]
+				}
+			}
+			x.Uint64()
+		}
+	}
+	x.s = t
+}
+
+// stream returns the k-th stream derived from a seed: the seeded generator
+// jumped k times.
+func stream(seed int64, k int) *rand.Rand {
+	return rand.New(newStream(seed, k))
+}
+
+// The k-th stream of the model's seed, antithetic if the model is.
+func (m *Model) stream(k int) *xoshiro {
+	return newStream(m.Seed, k).mirror(m.Antithetic)
+}
+
+func newStream(seed int64, k int) *xoshiro {
+	x := newXoshiro(seed)
+	for i := 0; i < k; i++ {
+		x.jump()
+	}
+	return x
+}
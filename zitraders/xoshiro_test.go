@@ -0,0 +1,43 @@
+package zitraders
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// The reference implementation's output from the state {1, 2, 3, 4}.
+func TestXoshiroReference(t *testing.T) {
+	x := &xoshiro{s: [4]uint64{1, 2, 3, 4}}
+	for i, want := range []uint64{11520, 0, 1509978240, 1215971899390074240} {
+		if got := x.Uint64(); got != want {
+			t.Errorf("output %d = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestStreamsDiffer(t *testing.T) {
+	a, b := stream(1, 1), stream(1, 2)
+	if a.Int63() == b.Int63() && a.Int63() == b.Int63() {
+		t.Error("streams 1 and 2 start with the same values")
+	}
+	if stream(1, 3).Int63() != stream(1, 3).Int63() {
+		t.Error("stream is not deterministic")
+	}
+}
+
+// An antithetic stream's draws mirror those of the stream.
+func TestAntitheticStream(t *testing.T) {
+	a, b := newStream(1, 1), newStream(1, 1).mirror(true)
+	for i := 0; i < 100; i++ {
+		if x, y := a.Uint64(), b.Uint64(); x != ^y {
+			t.Fatalf("draw %d: %x and %x", i, x, y)
+		}
+	}
+	u, v := rand.New(newStream(2, 1)), rand.New(newStream(2, 1).mirror(true))
+	for i := 0; i < 100; i++ {
+		if x, y := u.Float64(), v.Float64(); math.Abs(x+y-1) > 1e-9 {
+			t.Fatalf("draw %d: %v and %v", i, x, y)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package zitraders
+
+import (
+	"math"
+	"math/rand"
+)
+
+// A zipTrader holds the state of one of Cliff's (1997) zero-intelligence-plus
+// traders. A ZIP trader quotes its marginal value or cost marked up by a
+// profit margin, which it adapts to the quotes and trades it observes with
+// the Widrow-Hoff rule plus momentum. Sellers' margins are non-negative and
+// buyers' are between -1 and 0, so ZIP traders never trade at a loss.
+//
+// Here traders learn from their own meetings rather than from every shout in
+// the market: after a trade both parties raise their margins toward the
+// price, and a trader whose quote was not met lowers its margin toward the
+// other side's quote.
+type zipTrader struct {
+	buyer    bool
+	margin   float64
+	beta     float64 // learning rate
+	gamma    float64 // momentum
+	momentum float64 // smoothed price change
+}
+
+// Draw a ZIP trader with Cliff's initial parameters.
+func newZIP(buyer bool, r *rand.Rand) *zipTrader {
+	z := &zipTrader{
+		buyer:  buyer,
+		beta:   0.1 + 0.4*r.Float64(),
+		gamma:  0.1 * r.Float64(),
+		margin: 0.05 + 0.3*r.Float64(),
+	}
+	if buyer {
+		z.margin = -z.margin
+	}
+	return z
+}
+
+// The price a ZIP trader with the given marginal value or cost quotes.
+func (z *zipTrader) quote(value, maxPrice int) int {
+	p := int(math.Round(float64(value) * (1 + z.margin)))
+	if z.buyer {
+		return clamp(p, 1, value)
+	}
+	return clamp(p, value, maxPrice)
+}
+
+// Move the trader's price toward a target just above q if up is true, or just
+// below it otherwise.
+func (z *zipTrader) adjust(value, q int, up bool, r *rand.Rand) {
+	R, A := 1+0.05*r.Float64(), 0.05*r.Float64()
+	if !up {
+		R, A = 2-R, -A
+	}
+	price := float64(value) * (1 + z.margin)
+	delta := z.beta * (R*float64(q) + A - price)
+	z.momentum = z.gamma*z.momentum + (1-z.gamma)*delta
+	z.margin = (price+z.momentum)/float64(value) - 1
+	if z.buyer {
+		z.margin = math.Max(-1, math.Min(0, z.margin))
+	} else {
+		z.margin = math.Max(0, z.margin)
+	}
+}
+
+func (z *zipTrader) Bid(ctx MarketView) int { return z.quote(ctx.Value, ctx.MaxPrice()) }
+func (z *zipTrader) Ask(ctx MarketView) int { return z.quote(ctx.Value, ctx.MaxPrice()) }
+
+// A ZIP trader who traded at price raises its margin: a seller aims above
+// the price and a buyer below it.
+func (z *zipTrader) Traded(ctx MarketView, price int) {
+	z.adjust(ctx.Value, price, !z.buyer, ctx.Rand)
+}
+
+// A ZIP trader whose quote was not met by the other side's quote q lowers its
+// margin: a seller aims below q and a buyer above it.
+func (z *zipTrader) Missed(ctx MarketView, q int) {
+	z.adjust(ctx.Value, q, z.buyer, ctx.Rand)
+}
+
+func clamp(x, low, high int) int {
+	if x < low {
+		return low
+	}
+	if x > high {
+		return high
+	}
+	return x
+}